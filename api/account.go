@@ -0,0 +1,356 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"log/slog"
+
+	"github.com/garnizeh/rag/internal/models"
+	"github.com/garnizeh/rag/internal/notify"
+	"github.com/garnizeh/rag/pkg/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AccountHandler serves the authenticated engineer's own account (/v1/me) and
+// the password reset flow.
+type AccountHandler struct {
+	engineerRepo repository.EngineerRepo
+	profileRepo  repository.ProfileRepo
+	resetRepo    repository.PasswordResetRepo
+	notifier     notify.Notifier
+	resetTTL     time.Duration
+}
+
+// NewAccountHandler creates a new AccountHandler. A nil notifier falls back to
+// logging reset tokens through the package logger.
+func NewAccountHandler(er repository.EngineerRepo, pr repository.ProfileRepo, rr repository.PasswordResetRepo, n notify.Notifier, resetTTL time.Duration) *AccountHandler {
+	if n == nil {
+		n = notify.NewLogNotifier(logger)
+	}
+	if resetTTL <= 0 {
+		resetTTL = time.Hour
+	}
+	return &AccountHandler{engineerRepo: er, profileRepo: pr, resetRepo: rr, notifier: n, resetTTL: resetTTL}
+}
+
+type updateMeRequest struct {
+	Name  *string `json:"name,omitempty"`
+	Email *string `json:"email,omitempty"`
+}
+
+type deleteMeRequest struct {
+	Password string `json:"password"`
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type forgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type resetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// currentEngineer loads the engineer identified by the JWT. It writes the error
+// response itself and returns nil when the request cannot continue.
+func (h *AccountHandler) currentEngineer(w http.ResponseWriter, r *http.Request) *models.Engineer {
+	id, ok := engineerIDFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil
+	}
+
+	e, err := h.engineerRepo.GetByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Error loading user", http.StatusInternalServerError)
+		return nil
+	}
+	if e == nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return nil
+	}
+
+	return e
+}
+
+// GetMe returns the authenticated engineer without the password hash.
+func (h *AccountHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	e := h.currentEngineer(w, r)
+	if e == nil {
+		return
+	}
+
+	out := *e
+	out.PasswordHash = ""
+	writeJSON(w, out, http.StatusOK)
+}
+
+// UpdateMe changes name and/or email of the authenticated engineer.
+func (h *AccountHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	var req updateMeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.Name == nil && req.Email == nil {
+		http.Error(w, "Nothing to update", http.StatusBadRequest)
+		return
+	}
+
+	e := h.currentEngineer(w, r)
+	if e == nil {
+		return
+	}
+
+	ctx := r.Context()
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			http.Error(w, "Name cannot be empty", http.StatusBadRequest)
+			return
+		}
+		e.Name = name
+	}
+	if req.Email != nil {
+		email := strings.TrimSpace(*req.Email)
+		if email == "" || !strings.Contains(email, "@") {
+			http.Error(w, "Invalid email", http.StatusBadRequest)
+			return
+		}
+		if email != e.Email {
+			other, err := h.engineerRepo.GetByEmail(ctx, email)
+			if err != nil {
+				http.Error(w, "Error updating user", http.StatusInternalServerError)
+				return
+			}
+			if other != nil && other.ID != e.ID {
+				http.Error(w, "Email already in use", http.StatusConflict)
+				return
+			}
+		}
+		e.Email = email
+	}
+
+	if err := h.engineerRepo.UpdateEngineer(ctx, e); err != nil {
+		http.Error(w, "Error updating user", http.StatusInternalServerError)
+		return
+	}
+
+	out := *e
+	out.PasswordHash = ""
+	writeJSON(w, out, http.StatusOK)
+}
+
+// DeleteMe removes the authenticated engineer after confirming the password.
+func (h *AccountHandler) DeleteMe(w http.ResponseWriter, r *http.Request) {
+	var req deleteMeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Password == "" {
+		http.Error(w, "Password confirmation required", http.StatusBadRequest)
+		return
+	}
+
+	e := h.currentEngineer(w, r)
+	if e == nil {
+		return
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(e.PasswordHash), []byte(req.Password)) != nil {
+		http.Error(w, "Invalid password", http.StatusUnauthorized)
+		return
+	}
+
+	// DeleteEngineer removes the profile, activities, jobs and everything else
+	// that belongs to the engineer along with it
+	if err := h.engineerRepo.DeleteEngineer(r.Context(), e.ID); err != nil {
+		http.Error(w, "Error deleting user", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ChangePassword replaces the password after verifying the current one.
+func (h *AccountHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var req changePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		http.Error(w, "Missing fields", http.StatusBadRequest)
+		return
+	}
+	if err := validatePassword(req.NewPassword); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	e := h.currentEngineer(w, r)
+	if e == nil {
+		return
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(e.PasswordHash), []byte(req.CurrentPassword)) != nil {
+		http.Error(w, "Invalid current password", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.setPassword(r, e, req.NewPassword); err != nil {
+		http.Error(w, "Error updating password", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ForgotPassword issues a single-use reset token and hands it to the notifier.
+// The response is identical whether or not the email exists so the endpoint
+// cannot be used to enumerate accounts.
+func (h *AccountHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req forgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Email) == "" {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	resp := map[string]string{"message": "if the account exists, a reset token has been sent"}
+
+	ctx := r.Context()
+	e, err := h.engineerRepo.GetByEmail(ctx, strings.TrimSpace(req.Email))
+	if err != nil {
+		logger.Error("forgot password lookup failed", slog.Any("err", err))
+		writeJSON(w, resp, http.StatusAccepted)
+		return
+	}
+	if e == nil {
+		writeJSON(w, resp, http.StatusAccepted)
+		return
+	}
+
+	token, err := newResetToken()
+	if err != nil {
+		http.Error(w, "Error creating reset token", http.StatusInternalServerError)
+		return
+	}
+
+	pr := &models.PasswordReset{
+		EngineerID: e.ID,
+		TokenHash:  hashResetToken(token),
+		ExpiresAt:  time.Now().Add(h.resetTTL).UTC().UnixMilli(),
+	}
+	if _, err := h.resetRepo.CreateReset(ctx, pr); err != nil {
+		http.Error(w, "Error creating reset token", http.StatusInternalServerError)
+		return
+	}
+
+	msg := notify.Message{
+		To:      e.Email,
+		Subject: "Password reset",
+		Body:    fmt.Sprintf("Use this token to reset your password within %s: %s", h.resetTTL, token),
+		Meta:    map[string]string{"kind": "password_reset"},
+	}
+	if err := h.notifier.Notify(ctx, msg); err != nil {
+		logger.Error("deliver reset token failed", slog.Int64("engineer_id", e.ID), slog.Any("err", err))
+	}
+
+	writeJSON(w, resp, http.StatusAccepted)
+}
+
+// ResetPassword sets a new password using a token issued by ForgotPassword.
+func (h *AccountHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req resetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.Token == "" || req.NewPassword == "" {
+		http.Error(w, "Missing fields", http.StatusBadRequest)
+		return
+	}
+	if err := validatePassword(req.NewPassword); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	pr, err := h.resetRepo.GetResetByTokenHash(ctx, hashResetToken(req.Token))
+	if err != nil {
+		http.Error(w, "Error verifying reset token", http.StatusInternalServerError)
+		return
+	}
+	if pr == nil || pr.UsedAt != nil || time.Now().UTC().UnixMilli() > pr.ExpiresAt {
+		http.Error(w, "Invalid or expired reset token", http.StatusBadRequest)
+		return
+	}
+
+	e, err := h.engineerRepo.GetByID(ctx, pr.EngineerID)
+	if err != nil || e == nil {
+		http.Error(w, "Invalid or expired reset token", http.StatusBadRequest)
+		return
+	}
+
+	// the token is consumed before the password changes; losing a race with
+	// another reset using the same token rejects this one
+	consumed, err := h.resetRepo.MarkResetUsed(ctx, pr.ID)
+	if err != nil {
+		http.Error(w, "Error consuming reset token", http.StatusInternalServerError)
+		return
+	}
+	if !consumed {
+		http.Error(w, "Invalid or expired reset token", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.setPassword(r, e, req.NewPassword); err != nil {
+		http.Error(w, "Error updating password", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setPassword hashes and stores a new password and revokes outstanding reset tokens.
+func (h *AccountHandler) setPassword(r *http.Request, e *models.Engineer, pw string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(pw), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	e.PasswordHash = string(hash)
+	if err := h.engineerRepo.UpdateEngineer(r.Context(), e); err != nil {
+		return err
+	}
+
+	if h.resetRepo != nil {
+		if err := h.resetRepo.DeleteResetsByEngineer(r.Context(), e.ID); err != nil {
+			logger.Warn("revoke password resets failed", slog.Int64("engineer_id", e.ID), slog.Any("err", err))
+		}
+	}
+
+	return nil
+}
+
+func newResetToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
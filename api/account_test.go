@@ -0,0 +1,220 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/garnizeh/rag/api"
+	"github.com/garnizeh/rag/internal/models"
+	"github.com/garnizeh/rag/internal/notify"
+	"github.com/garnizeh/rag/pkg/repository"
+	"github.com/garnizeh/rag/pkg/repository/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// captureNotifier records delivered messages instead of sending them.
+type captureNotifier struct {
+	msgs []notify.Message
+}
+
+func (c *captureNotifier) Notify(ctx context.Context, m notify.Message) error {
+	c.msgs = append(c.msgs, m)
+	return nil
+}
+
+func newAccountFixture(t *testing.T, password string) (*api.AccountHandler, *mock.Mocks, *captureNotifier) {
+	t.Helper()
+	m := mock.NewMocks()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	m.EngRepo.Stored = &models.Engineer{ID: 1, Name: "Alice", Email: "alice@example.com", PasswordHash: string(hash)}
+	n := &captureNotifier{}
+	return api.NewAccountHandler(m.EngRepo, m.ProfRepo, m.ResetRepo, n, time.Hour), m, n
+}
+
+func authedRequest(method, path string, body any) *http.Request {
+	var b []byte
+	if body != nil {
+		b, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(b))
+	return req.WithContext(context.WithValue(req.Context(), api.CtxEngineerID, int64(1)))
+}
+
+func TestAccount_GetMe_HidesPasswordHash(t *testing.T) {
+	h, _, _ := newAccountFixture(t, "hunter2pass")
+
+	w := httptest.NewRecorder()
+	h.GetMe(w, authedRequest(http.MethodGet, "/v1/me", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d body=%s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "password_hash") {
+		t.Fatalf("password hash leaked: %s", w.Body.String())
+	}
+
+	// no engineer id in context
+	w2 := httptest.NewRecorder()
+	h.GetMe(w2, httptest.NewRequest(http.MethodGet, "/v1/me", nil))
+	if w2.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without engineer id got %d", w2.Code)
+	}
+}
+
+func TestAccount_UpdateMe(t *testing.T) {
+	h, m, _ := newAccountFixture(t, "hunter2pass")
+
+	w := httptest.NewRecorder()
+	h.UpdateMe(w, authedRequest(http.MethodPatch, "/v1/me", map[string]string{"name": "Alicia", "email": "alicia@example.com"}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d body=%s", w.Code, w.Body.String())
+	}
+	if m.EngRepo.Stored.Name != "Alicia" || m.EngRepo.Stored.Email != "alicia@example.com" {
+		t.Fatalf("engineer not updated: %#v", m.EngRepo.Stored)
+	}
+
+	w2 := httptest.NewRecorder()
+	h.UpdateMe(w2, authedRequest(http.MethodPatch, "/v1/me", map[string]string{"name": "  "}))
+	if w2.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for empty name got %d", w2.Code)
+	}
+}
+
+func TestAccount_ChangePassword(t *testing.T) {
+	h, m, _ := newAccountFixture(t, "hunter2pass")
+
+	cases := []struct {
+		name       string
+		body       map[string]string
+		wantStatus int
+	}{
+		{name: "WrongCurrent", body: map[string]string{"current_password": "nope", "new_password": "newpass123"}, wantStatus: http.StatusUnauthorized},
+		{name: "WeakNew", body: map[string]string{"current_password": "hunter2pass", "new_password": "short"}, wantStatus: http.StatusBadRequest},
+		{name: "Success", body: map[string]string{"current_password": "hunter2pass", "new_password": "newpass123"}, wantStatus: http.StatusNoContent},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ChangePassword(w, authedRequest(http.MethodPost, "/v1/me/password", c.body))
+			if w.Code != c.wantStatus {
+				t.Fatalf("want %d got %d body=%s", c.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(m.EngRepo.Stored.PasswordHash), []byte("newpass123")) != nil {
+		t.Fatalf("password was not changed")
+	}
+}
+
+func TestAccount_DeleteMe(t *testing.T) {
+	h, m, _ := newAccountFixture(t, "hunter2pass")
+
+	w := httptest.NewRecorder()
+	h.DeleteMe(w, authedRequest(http.MethodDelete, "/v1/me", map[string]string{"password": "wrong"}))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for wrong password got %d", w.Code)
+	}
+
+	w2 := httptest.NewRecorder()
+	h.DeleteMe(w2, authedRequest(http.MethodDelete, "/v1/me", map[string]string{"password": "hunter2pass"}))
+	if w2.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 got %d body=%s", w2.Code, w2.Body.String())
+	}
+	if m.EngRepo.Stored != nil {
+		t.Fatalf("engineer was not deleted")
+	}
+}
+
+func TestAccount_ForgotAndResetPassword(t *testing.T) {
+	h, m, n := newAccountFixture(t, "hunter2pass")
+
+	// unknown email: same response, nothing delivered
+	w := httptest.NewRecorder()
+	h.ForgotPassword(w, authedRequest(http.MethodPost, "/v1/auth/password/forgot", map[string]string{"email": "nobody@example.com"}))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 got %d", w.Code)
+	}
+	if len(n.msgs) != 0 {
+		t.Fatalf("expected no notification for unknown email")
+	}
+
+	w = httptest.NewRecorder()
+	h.ForgotPassword(w, authedRequest(http.MethodPost, "/v1/auth/password/forgot", map[string]string{"email": "alice@example.com"}))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 got %d", w.Code)
+	}
+	if len(n.msgs) != 1 || n.msgs[0].To != "alice@example.com" {
+		t.Fatalf("expected one notification to alice, got %#v", n.msgs)
+	}
+	body := n.msgs[0].Body
+	token := body[strings.LastIndex(body, " ")+1:]
+
+	w = httptest.NewRecorder()
+	h.ResetPassword(w, authedRequest(http.MethodPost, "/v1/auth/password/reset", map[string]string{"token": "bogus", "new_password": "resetpass1"}))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for bogus token got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ResetPassword(w, authedRequest(http.MethodPost, "/v1/auth/password/reset", map[string]string{"token": token, "new_password": "resetpass1"}))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 got %d body=%s", w.Code, w.Body.String())
+	}
+	if bcrypt.CompareHashAndPassword([]byte(m.EngRepo.Stored.PasswordHash), []byte("resetpass1")) != nil {
+		t.Fatalf("password was not reset")
+	}
+
+	// token is single use
+	w = httptest.NewRecorder()
+	h.ResetPassword(w, authedRequest(http.MethodPost, "/v1/auth/password/reset", map[string]string{"token": token, "new_password": "another12"}))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 when replaying token got %d", w.Code)
+	}
+}
+
+// racedResetRepo lets another reset consume the token right after it is
+// read, as a concurrent request with the same token would.
+type racedResetRepo struct {
+	repository.PasswordResetRepo
+}
+
+func (r racedResetRepo) GetResetByTokenHash(ctx context.Context, tokenHash string) (*models.PasswordReset, error) {
+	pr, err := r.PasswordResetRepo.GetResetByTokenHash(ctx, tokenHash)
+	if err == nil && pr != nil {
+		_, err = r.MarkResetUsed(ctx, pr.ID)
+	}
+	return pr, err
+}
+
+func TestAccount_ResetPasswordLosesRace(t *testing.T) {
+	m := mock.NewMocks()
+	m.EngRepo.Stored = &models.Engineer{ID: 1, Email: "alice@example.com", PasswordHash: "unchanged"}
+	n := &captureNotifier{}
+	h := api.NewAccountHandler(m.EngRepo, m.ProfRepo, racedResetRepo{m.ResetRepo}, n, time.Hour)
+
+	w := httptest.NewRecorder()
+	h.ForgotPassword(w, authedRequest(http.MethodPost, "/v1/auth/password/forgot", map[string]string{"email": "alice@example.com"}))
+	if len(n.msgs) != 1 {
+		t.Fatalf("expected a reset notification, got %#v", n.msgs)
+	}
+	body := n.msgs[0].Body
+	token := body[strings.LastIndex(body, " ")+1:]
+
+	w = httptest.NewRecorder()
+	h.ResetPassword(w, authedRequest(http.MethodPost, "/v1/auth/password/reset", map[string]string{"token": token, "new_password": "resetpass1"}))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 when the token was consumed concurrently got %d", w.Code)
+	}
+	if m.EngRepo.Stored.PasswordHash != "unchanged" {
+		t.Fatalf("password changed with a consumed token")
+	}
+}
@@ -2,54 +2,89 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/garnizeh/rag/internal/ai"
 	"github.com/garnizeh/rag/internal/models"
+	"github.com/garnizeh/rag/internal/privacy"
 	"github.com/garnizeh/rag/pkg/repository"
 )
 
 type ActivitiesHandler struct {
 	activityRepo repository.ActivityRepo
 	jobRepo      repository.JobRepo
+	redactor     *privacy.Redactor
+	quota        *ai.Quota
 }
 
-func NewActivitiesHandler(ar repository.ActivityRepo, jr repository.JobRepo) *ActivitiesHandler {
-	return &ActivitiesHandler{activityRepo: ar, jobRepo: jr}
+// NewActivitiesHandler creates the activities handler. redactor masks PII in the
+// text queued for analysis and in activities shown to anyone but their owner;
+// nil disables redaction. quota limits the analyses an engineer can queue per
+// day; nil disables quotas.
+func NewActivitiesHandler(ar repository.ActivityRepo, jr repository.JobRepo, redactor *privacy.Redactor, quota *ai.Quota) *ActivitiesHandler {
+	return &ActivitiesHandler{activityRepo: ar, jobRepo: jr, redactor: redactor, quota: quota}
 }
 
 type postActivityRequest struct {
-	EngineerID int64  `json:"engineer_id"` // TODO: get engineer ID from JWT
+	// EngineerID is optional and must be the authenticated engineer
+	EngineerID int64  `json:"engineer_id,omitempty"`
 	Activity   string `json:"activity"`
+	Visibility string `json:"visibility,omitempty"`
 	Timestamp  *int64 `json:"timestamp,omitempty"`
 }
 
 type postActivityResponse struct {
 	ID int64 `json:"id"`
+	// JobID is the analysis job, pollable at GET /v1/jobs/{id}; omitted for private activities
+	JobID int64 `json:"job_id,omitempty"`
+	// AnalysisError is set when the activity was stored but its analysis could not be queued
+	AnalysisError string `json:"analysis_error,omitempty"`
 }
 
+// maxIdempotencyKeyLen bounds the Idempotency-Key header.
+const maxIdempotencyKeyLen = 255
+
+// CreateActivity stores an activity of the authenticated engineer and queues it
+// for analysis; an engineer_id in the body naming anyone else is refused with
+// 403. Clients may send an Idempotency-Key header to retry safely: a repeated
+// key from the same engineer returns the original activity with 200 and Idempotent-Replayed: true
+// instead of creating a duplicate. A shared activity from an engineer over the
+// daily LLM quota is refused with 429 before anything is stored.
 func (h *ActivitiesHandler) CreateActivity(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	if len(key) > maxIdempotencyKeyLen {
+		http.Error(w, "Idempotency-Key too long", http.StatusBadRequest)
+		return
+	}
+
 	var req postActivityRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid request", http.StatusBadRequest)
 		return
 	}
 
-	// If engineer id not provided in body, try to read from context (set by JWT middleware)
-	if req.EngineerID <= 0 {
-		if v := r.Context().Value(CtxEngineerID); v == nil {
-			if id, ok := v.(int64); ok {
-				req.EngineerID = id
-			}
-		}
+	// the activity, its idempotency key and its analysis belong to the caller
+	caller, ok := engineerIDFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if req.EngineerID != 0 && req.EngineerID != caller {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
 	}
+	req.EngineerID = caller
 
 	// Basic validation
 	req.Activity = strings.TrimSpace(req.Activity)
-	if req.EngineerID <= 0 || req.Activity == "" {
+	if req.Activity == "" {
 		http.Error(w, "missing fields", http.StatusBadRequest)
 		return
 	}
@@ -57,36 +92,113 @@ func (h *ActivitiesHandler) CreateActivity(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "activity too long", http.StatusBadRequest)
 		return
 	}
+	switch req.Visibility {
+	case "":
+		req.Visibility = models.VisibilityShared
+	case models.VisibilityShared, models.VisibilityPrivate:
+	default:
+		http.Error(w, "visibility must be shared or private", http.StatusBadRequest)
+		return
+	}
 
 	if req.Timestamp == nil || *req.Timestamp <= 0 {
 		now := time.Now().UTC().UnixMicro()
 		req.Timestamp = &now
 	}
-	// TODO: deduplication: simple in-memory check could be added; for now rely on consumer
 
-	a := &models.Activity{EngineerID: req.EngineerID, Activity: req.Activity, Created: *req.Timestamp}
+	if key != "" {
+		existing, err := h.activityRepo.GetActivityByIdempotencyKey(r.Context(), req.EngineerID, key)
+		if err != nil {
+			http.Error(w, "failed to check idempotency key", http.StatusInternalServerError)
+			return
+		}
+		if existing != nil {
+			h.replay(w, r, existing, req.Activity)
+			return
+		}
+	}
+
+	// the quota is always the caller's own
+	if req.Visibility != models.VisibilityPrivate {
+		if err := h.quota.Check(r.Context(), caller, time.Now()); err != nil {
+			var qe *ai.QuotaError
+			if !errors.As(err, &qe) {
+				http.Error(w, "failed to check llm quota", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(max(int(math.Ceil(time.Until(qe.Reset).Seconds())), 1)))
+			http.Error(w, qe.Error(), http.StatusTooManyRequests)
+			return
+		}
+	}
+
+	redacted, spans := h.redactor.Redact(req.Activity)
+	if spans == nil {
+		// record that the activity was scanned, even if nothing was found
+		spans = []models.Redaction{}
+	}
+
+	a := &models.Activity{EngineerID: req.EngineerID, Activity: req.Activity, Visibility: req.Visibility, Redactions: spans, IdempotencyKey: key, Created: *req.Timestamp}
 	id, err := h.activityRepo.CreateActivity(r.Context(), a)
+	if errors.Is(err, repository.ErrDuplicateKey) {
+		// a concurrent request with the same key won the race
+		existing, gerr := h.activityRepo.GetActivityByIdempotencyKey(r.Context(), req.EngineerID, key)
+		if gerr != nil || existing == nil {
+			http.Error(w, "failed to store activity", http.StatusInternalServerError)
+			return
+		}
+		h.replay(w, r, existing, req.Activity)
+		return
+	}
 	if err != nil {
 		http.Error(w, "failed to store activity", http.StatusInternalServerError)
 		return
 	}
 
-	// enqueue legacy processing job (short-term compatibility)
-	job := &models.Job{Status: "pending"}
-	if _, err := h.jobRepo.CreateJob(r.Context(), job); err != nil {
-		// don't fail the request if job enqueue fails; log and continue
-		fmt.Println("warning: failed to create job:", err)
+	// enqueue AI analysis job into the worker queue: ai.analyze_activity.
+	// Private activities never leave the server; shared ones are queued redacted.
+	resp := postActivityResponse{ID: id}
+	if req.Visibility != models.VisibilityPrivate {
+		payloadObj := map[string]any{"engineer_id": req.EngineerID, "activity_id": id, "activity": redacted, "timestamp": *req.Timestamp}
+		b, _ := json.Marshal(payloadObj)
+		// one pending analysis per activity, however often it is enqueued
+		j := &models.BackgroundJob{Type: ai.AnalyzeActivityJob, Payload: b, Priority: 100, MaxAttempts: 3, DedupeKey: analysisDedupeKey(id), EngineerID: req.EngineerID}
+		if resp.JobID, err = h.jobRepo.Enqueue(r.Context(), j); err != nil {
+			logger.Warn("failed to enqueue activity analysis", slog.Int64("activity_id", id), slog.Any("err", err))
+			resp.AnalysisError = "analysis could not be queued"
+		}
 	}
 
-	// enqueue AI analysis job into the worker queue: ai.analyze_activity
-	payloadObj := map[string]any{"engineer_id": req.EngineerID, "activity": req.Activity, "timestamp": *req.Timestamp}
-	b, _ := json.Marshal(payloadObj)
-	j := &models.BackgroundJob{Type: "ai.analyze_activity", Payload: b, Priority: 100, MaxAttempts: 3}
-	if _, err := h.jobRepo.Enqueue(r.Context(), j); err != nil {
-		fmt.Println("warning: failed to enqueue ai.analyze_activity job:", err)
+	writeJSON(w, resp, http.StatusCreated)
+}
+
+// analysisDedupeKey identifies the analysis job of an activity.
+func analysisDedupeKey(activityID int64) string {
+	return fmt.Sprintf("%s:%d", ai.AnalyzeActivityJob, activityID)
+}
+
+// replay answers a retried request with the activity and analysis job created
+// by the original one. Reusing a key for a different activity is a client error.
+func (h *ActivitiesHandler) replay(w http.ResponseWriter, r *http.Request, existing *models.Activity, activity string) {
+	if existing.Activity != activity {
+		http.Error(w, "Idempotency-Key was already used for a different activity", http.StatusUnprocessableEntity)
+		return
+	}
+
+	resp := postActivityResponse{ID: existing.ID}
+	if existing.Visibility != models.VisibilityPrivate {
+		j, err := h.jobRepo.GetJobByDedupeKey(r.Context(), analysisDedupeKey(existing.ID))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to get analysis job: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if j != nil {
+			resp.JobID = j.ID
+		}
 	}
 
-	writeJSON(w, postActivityResponse{ID: id}, http.StatusCreated)
+	w.Header().Set("Idempotent-Replayed", "true")
+	writeJSON(w, resp, http.StatusOK)
 }
 
 func (h *ActivitiesHandler) ListActivities(w http.ResponseWriter, r *http.Request) {
@@ -116,13 +228,23 @@ func (h *ActivitiesHandler) ListActivities(w http.ResponseWriter, r *http.Reques
 		}
 	}
 
-	acts, err := h.activityRepo.ListByEngineer(r.Context(), engID, limit, offset)
+	// Only the owner sees private activities and original text; everyone else
+	// gets shared activities with PII masked.
+	callerID, _ := engineerIDFromContext(r)
+	owner := callerID == engID
+
+	list, count := h.activityRepo.ListSharedByEngineer, h.activityRepo.CountSharedActivitiesByEngineer
+	if owner {
+		list, count = h.activityRepo.ListByEngineer, h.activityRepo.CountActivitiesByEngineer
+	}
+
+	acts, err := list(r.Context(), engID, limit, offset)
 	if err != nil {
 		http.Error(w, "failed to list activities", http.StatusInternalServerError)
 		return
 	}
 
-	total, err := h.activityRepo.CountActivitiesByEngineer(r.Context(), engID)
+	total, err := count(r.Context(), engID)
 	if err != nil {
 		http.Error(w, "failed to count activities", http.StatusInternalServerError)
 		return
@@ -131,6 +253,12 @@ func (h *ActivitiesHandler) ListActivities(w http.ResponseWriter, r *http.Reques
 	if acts == nil {
 		acts = []models.Activity{}
 	}
+	if !owner {
+		for i := range acts {
+			acts[i].Activity = h.redactedText(acts[i])
+			acts[i].Redactions = nil
+		}
+	}
 
 	resp := map[string]any{
 		"total":  total,
@@ -141,3 +269,14 @@ func (h *ActivitiesHandler) ListActivities(w http.ResponseWriter, r *http.Reques
 
 	writeJSON(w, resp, http.StatusOK)
 }
+
+// redactedText returns the masked view of an activity. Activities stored before
+// redaction existed have no recorded spans and are scanned on the fly.
+func (h *ActivitiesHandler) redactedText(a models.Activity) string {
+	if a.Redactions == nil {
+		text, _ := h.redactor.Redact(a.Activity)
+		return text
+	}
+
+	return privacy.Apply(a.Activity, a.Redactions)
+}
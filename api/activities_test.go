@@ -7,9 +7,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/garnizeh/rag/api"
 	"github.com/garnizeh/rag/internal/db"
+	"github.com/garnizeh/rag/internal/models"
+	"github.com/garnizeh/rag/internal/privacy"
 	sqlite "github.com/garnizeh/rag/internal/repository/sqlite"
 )
 
@@ -24,8 +27,7 @@ func setupServer(t *testing.T) (*httptest.Server, func()) {
 	// create minimal schema
 	stmts := []string{
 		`CREATE TABLE IF NOT EXISTS engineers (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, email TEXT, updated INTEGER, password_hash TEXT);`,
-		`CREATE TABLE IF NOT EXISTS raw_activities (id INTEGER PRIMARY KEY AUTOINCREMENT, engineer_id INTEGER, activity TEXT, created INTEGER);`,
-		`CREATE TABLE IF NOT EXISTS processing_jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, status TEXT, created INTEGER);`,
+		`CREATE TABLE IF NOT EXISTS raw_activities (id INTEGER PRIMARY KEY AUTOINCREMENT, engineer_id INTEGER, activity TEXT, visibility TEXT NOT NULL DEFAULT 'shared', redactions TEXT, idempotency_key TEXT, created INTEGER);`,
 	}
 	for _, s := range stmts {
 		if _, err := d.Exec(ctx, s); err != nil {
@@ -35,10 +37,12 @@ func setupServer(t *testing.T) (*httptest.Server, func()) {
 	}
 
 	repo := sqlite.New(d, nil)
-	ah := api.NewActivitiesHandler(repo, repo)
+	ah := api.NewActivitiesHandler(repo, repo, privacy.DefaultRedactor(), nil)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/v1/activities", func(w http.ResponseWriter, r *http.Request) {
+		// stands in for the JWT middleware
+		r = r.WithContext(context.WithValue(r.Context(), api.CtxEngineerID, int64(1)))
 		switch r.Method {
 		case http.MethodPost:
 			ah.CreateActivity(w, r)
@@ -68,6 +72,19 @@ func TestCreateAndListActivities(t *testing.T) {
 		if res.StatusCode != http.StatusCreated {
 			t.Fatalf("expected 201 created got %d", res.StatusCode)
 		}
+		// this schema has no jobs table, so the analysis cannot be queued
+		var created struct {
+			ID            int64  `json:"id"`
+			JobID         int64  `json:"job_id"`
+			AnalysisError string `json:"analysis_error"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&created); err != nil {
+			t.Fatalf("decode create response: %v", err)
+		}
+		res.Body.Close()
+		if created.ID == 0 || created.JobID != 0 || created.AnalysisError == "" {
+			t.Fatalf("expected the stored activity with an analysis error, got %+v", created)
+		}
 	}
 
 	// page1
@@ -124,3 +141,169 @@ func TestCreateAndListActivities(t *testing.T) {
 		}
 	}
 }
+
+func TestActivities_PrivacyForNonOwners(t *testing.T) {
+	ctx := context.Background()
+	d, err := db.New(ctx, "file:activities_privacy?mode=memory&cache=shared", nil)
+	if err != nil {
+		t.Fatalf("db.New: %v", err)
+	}
+	defer d.Close()
+	if _, err := d.Exec(ctx, `CREATE TABLE raw_activities (id INTEGER PRIMARY KEY AUTOINCREMENT, engineer_id INTEGER, activity TEXT, visibility TEXT NOT NULL DEFAULT 'shared', redactions TEXT, idempotency_key TEXT, created INTEGER);`); err != nil {
+		t.Fatalf("setup schema: %v", err)
+	}
+	repo := sqlite.New(d, nil)
+	ah := api.NewActivitiesHandler(repo, repo, privacy.DefaultRedactor(), nil)
+
+	for _, body := range []map[string]any{
+		{"activity": "paired with bob@example.com on the deploy"},
+		{"activity": "1:1 notes", "visibility": "private"},
+	} {
+		w := httptest.NewRecorder()
+		ah.CreateActivity(w, authedRequest(http.MethodPost, "/v1/activities", body))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("create: expected 201 got %d body=%s", w.Code, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	ah.CreateActivity(w, authedRequest(http.MethodPost, "/v1/activities", map[string]any{"activity": "x", "visibility": "secret"}))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid visibility: expected 400 got %d", w.Code)
+	}
+
+	list := func(r *http.Request) (total int, items []map[string]any) {
+		w := httptest.NewRecorder()
+		ah.ListActivities(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("list: expected 200 got %d", w.Code)
+		}
+		var body struct {
+			Total int              `json:"total"`
+			Items []map[string]any `json:"items"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return body.Total, body.Items
+	}
+
+	// owner sees both activities with the original text and the recorded spans
+	total, items := list(authedRequest(http.MethodGet, "/v1/activities?engineer_id=1", nil))
+	if total != 2 || len(items) != 2 {
+		t.Fatalf("owner: expected 2 activities, got total=%d items=%d", total, len(items))
+	}
+
+	// anyone else only sees the shared one, masked
+	req := httptest.NewRequest(http.MethodGet, "/v1/activities?engineer_id=1", nil)
+	req = req.WithContext(context.WithValue(req.Context(), api.CtxEngineerID, int64(2)))
+	total, items = list(req)
+	if total != 1 || len(items) != 1 {
+		t.Fatalf("non-owner: expected 1 activity, got total=%d items=%d", total, len(items))
+	}
+	if got := items[0]["activity"]; got != "paired with [REDACTED:email] on the deploy" {
+		t.Fatalf("non-owner: expected redacted text, got %q", got)
+	}
+	if _, ok := items[0]["redactions"]; ok {
+		t.Fatalf("non-owner: redaction spans must not be exposed")
+	}
+}
+
+func TestActivities_IdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	d, err := db.New(ctx, "file:activities_idempotency?mode=memory&cache=shared", nil)
+	if err != nil {
+		t.Fatalf("db.New: %v", err)
+	}
+	defer d.Close()
+	for _, s := range []string{
+		`CREATE TABLE raw_activities (id INTEGER PRIMARY KEY AUTOINCREMENT, engineer_id INTEGER, activity TEXT, visibility TEXT NOT NULL DEFAULT 'shared', redactions TEXT, idempotency_key TEXT, created INTEGER);`,
+		`CREATE UNIQUE INDEX idx_activities_idempotency ON raw_activities(engineer_id, idempotency_key) WHERE idempotency_key IS NOT NULL;`,
+		`CREATE TABLE jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, type TEXT NOT NULL, payload TEXT, status TEXT NOT NULL DEFAULT 'queued', attempts INTEGER NOT NULL DEFAULT 0, max_attempts INTEGER NOT NULL DEFAULT 5, priority INTEGER NOT NULL DEFAULT 100, scheduled_at INTEGER NOT NULL, next_try_at INTEGER, last_error TEXT, locked_by TEXT, lease_until INTEGER, dedupe_key TEXT, result TEXT, engineer_id INTEGER, created INTEGER NOT NULL, updated INTEGER NOT NULL);`,
+		`CREATE UNIQUE INDEX idx_jobs_dedupe_pending ON jobs(dedupe_key) WHERE dedupe_key IS NOT NULL AND status IN ('queued', 'retry', 'running');`,
+	} {
+		if _, err := d.Exec(ctx, s); err != nil {
+			t.Fatalf("setup schema: %v", err)
+		}
+	}
+	repo := sqlite.New(d, nil)
+	ah := api.NewActivitiesHandler(repo, repo, nil, nil)
+
+	post := func(key string, body map[string]any) *httptest.ResponseRecorder {
+		req := authedRequest(http.MethodPost, "/v1/activities", body)
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		w := httptest.NewRecorder()
+		ah.CreateActivity(w, req)
+		return w
+	}
+	decodeID := func(w *httptest.ResponseRecorder) (int64, int64) {
+		var resp struct {
+			ID    int64 `json:"id"`
+			JobID int64 `json:"job_id"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp.ID, resp.JobID
+	}
+
+	w := post("retry-me", map[string]any{"activity": "shipped the release"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("first: expected 201 got %d body=%s", w.Code, w.Body.String())
+	}
+	id, jobID := decodeID(w)
+	if jobID == 0 {
+		t.Fatalf("first: expected the analysis job id")
+	}
+
+	w = post("retry-me", map[string]any{"activity": "shipped the release"})
+	if w.Code != http.StatusOK || w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("retry: expected replayed 200 got %d headers=%v", w.Code, w.Header())
+	}
+	if got, gotJob := decodeID(w); got != id || gotJob != jobID {
+		t.Fatalf("retry: expected activity %d job %d got %d job %d", id, jobID, got, gotJob)
+	}
+
+	w = post("retry-me", map[string]any{"activity": "something else"})
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("reused key: expected 422 got %d", w.Code)
+	}
+
+	// keys are scoped by the authenticated engineer, not the body
+	w = post("retry-me", map[string]any{"engineer_id": 2, "activity": "shipped the release"})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("other engineer_id: expected 403 got %d", w.Code)
+	}
+	req := authedRequest(http.MethodPost, "/v1/activities", map[string]any{"activity": "shipped the release"})
+	req = req.WithContext(context.WithValue(req.Context(), api.CtxEngineerID, int64(2)))
+	req.Header.Set("Idempotency-Key", "retry-me")
+	w = httptest.NewRecorder()
+	ah.CreateActivity(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("same key, other engineer: expected 201 got %d body=%s", w.Code, w.Body.String())
+	}
+	if other, _ := decodeID(w); other == id {
+		t.Fatalf("same key, other engineer: replayed activity %d of engineer 1", id)
+	}
+
+	// without a key every request creates an activity
+	if w := post("", map[string]any{"activity": "shipped the release"}); w.Code != http.StatusCreated {
+		t.Fatalf("no key: expected 201 got %d", w.Code)
+	}
+
+	if total, err := repo.CountActivitiesByEngineer(ctx, 1); err != nil || total != 2 {
+		t.Fatalf("expected 2 activities, got %d err=%v", total, err)
+	}
+	jobs, err := repo.ListJobs(ctx, models.JobFilter{Type: "ai.analyze_activity"})
+	if err != nil || len(jobs) != 3 {
+		t.Fatalf("expected one analysis job per activity, got %d err=%v", len(jobs), err)
+	}
+
+	// enqueueing the same activity's analysis again is a no-op while it is pending
+	again, err := repo.Enqueue(ctx, &models.BackgroundJob{Type: "ai.analyze_activity", DedupeKey: jobs[1].DedupeKey, ScheduledAt: time.Now()})
+	if err != nil || again != jobs[1].ID {
+		t.Fatalf("expected duplicate enqueue to return job %d, got %d err=%v", jobs[1].ID, again, err)
+	}
+}
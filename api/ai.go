@@ -148,6 +148,9 @@ type templatePayload struct {
 	Version     string  `json:"version"`
 	TemplateTxt string  `json:"template_text"`
 	SchemaVer   *string `json:"schema_version,omitempty"`
+	// Metadata is a JSON object; its "options" hold the generation options
+	// (temperature, top_p, num_ctx, seed, stop, keep_alive)
+	Metadata json.RawMessage `json:"metadata,omitempty"`
 }
 
 // CreateOrUpdateTemplateHandler stores a template, enforcing size limit
@@ -176,7 +179,17 @@ func (h *AIHandler) CreateOrUpdateTemplateHandler(w http.ResponseWriter, r *http
 		return
 	}
 
-	if _, err := h.templateRepo.CreateTemplate(r.Context(), p.Name, p.Version, p.TemplateTxt, p.SchemaVer, nil); err != nil {
+	var metadata *string
+	if len(p.Metadata) > 0 && string(p.Metadata) != "null" {
+		md := string(p.Metadata)
+		if _, err := ai.ParseTemplateOptions(&md); err != nil {
+			http.Error(w, fmt.Sprintf("invalid metadata: %v", err), http.StatusBadRequest)
+			return
+		}
+		metadata = &md
+	}
+
+	if _, err := h.templateRepo.CreateTemplate(r.Context(), p.Name, p.Version, p.TemplateTxt, p.SchemaVer, metadata); err != nil {
 		http.Error(w, fmt.Sprintf("store template: %v", err), http.StatusInternalServerError)
 		return
 	}
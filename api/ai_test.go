@@ -107,3 +107,57 @@ func TestRollbackHandler_Success(t *testing.T) {
 func muxSetVars(r *http.Request, vars map[string]string) *http.Request {
 	return mux.SetURLVars(r, vars)
 }
+
+// recordingTemplateRepo keeps the metadata of the last stored template.
+type recordingTemplateRepo struct {
+	stored   bool
+	metadata *string
+}
+
+func (f *recordingTemplateRepo) CreateTemplate(ctx context.Context, name, version, templateText string, schemaVersion *string, metadata *string) (int64, error) {
+	f.stored, f.metadata = true, metadata
+	return 1, nil
+}
+
+func (f *recordingTemplateRepo) GetTemplate(ctx context.Context, name, version string) (*models.Template, error) {
+	return nil, nil
+}
+
+func (f *recordingTemplateRepo) ListTemplates(ctx context.Context) ([]models.Template, error) {
+	return nil, nil
+}
+
+func (f *recordingTemplateRepo) DeleteTemplate(ctx context.Context, name, version string) error {
+	return nil
+}
+
+func TestCreateTemplate_Metadata(t *testing.T) {
+	cases := []struct {
+		name     string
+		metadata string
+		code     int
+	}{
+		{"options", `{"options":{"temperature":0.1,"stop":["\n\n"]}}`, http.StatusNoContent},
+		{"unknown option", `{"options":{"temprature":0.1}}`, http.StatusBadRequest},
+		{"out of range", `{"options":{"top_p":2}}`, http.StatusBadRequest},
+		{"not an object", `"fast"`, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &recordingTemplateRepo{}
+			h := api.NewAIHandler(nil, nil, repo, nil)
+			body := `{"name":"activity","version":"v2","template_text":"{{.Activity.Activity}}","metadata":` + tc.metadata + `}`
+			w := httptest.NewRecorder()
+			h.CreateOrUpdateTemplateHandler(w, httptest.NewRequest(http.MethodPost, "/v1/ai/templates", strings.NewReader(body)))
+			if w.Code != tc.code {
+				t.Fatalf("expected %d, got %d: %s", tc.code, w.Code, w.Body.String())
+			}
+			if tc.code == http.StatusNoContent && (repo.metadata == nil || *repo.metadata != tc.metadata) {
+				t.Fatalf("expected metadata %s to be stored, got %v", tc.metadata, repo.metadata)
+			}
+			if tc.code != http.StatusNoContent && repo.stored {
+				t.Fatal("invalid metadata must not be stored")
+			}
+		})
+	}
+}
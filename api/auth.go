@@ -2,9 +2,11 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
+	"unicode"
 
 	"log/slog"
 
@@ -19,11 +21,45 @@ type AuthHandler struct {
 	profileRepo   repository.ProfileRepo
 	jwtSecret     string
 	tokenDuration time.Duration
+	guard         *LoginGuard
 }
 
-// NewAuthHandler creates a new AuthHandler with required dependencies.
-func NewAuthHandler(er repository.EngineerRepo, pr repository.ProfileRepo, jwtSecret string, tokenDuration time.Duration) *AuthHandler {
-	return &AuthHandler{engineerRepo: er, profileRepo: pr, jwtSecret: jwtSecret, tokenDuration: tokenDuration}
+// NewAuthHandler creates a new AuthHandler with required dependencies. guard may
+// be nil to disable account lockout.
+func NewAuthHandler(er repository.EngineerRepo, pr repository.ProfileRepo, jwtSecret string, tokenDuration time.Duration, guard *LoginGuard) *AuthHandler {
+	return &AuthHandler{engineerRepo: er, profileRepo: pr, jwtSecret: jwtSecret, tokenDuration: tokenDuration, guard: guard}
+}
+
+const (
+	minPasswordLen = 8
+	// bcrypt ignores everything after 72 bytes; reject instead of silently truncating
+	maxPasswordLen = 72
+)
+
+// validatePassword enforces the password strength rules shared by signup,
+// password change and password reset.
+func validatePassword(pw string) error {
+	if len(pw) < minPasswordLen {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLen)
+	}
+	if len(pw) > maxPasswordLen {
+		return fmt.Errorf("password must be at most %d bytes", maxPasswordLen)
+	}
+
+	var hasLetter, hasDigit bool
+	for _, c := range pw {
+		switch {
+		case unicode.IsLetter(c):
+			hasLetter = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return errors.New("password must contain at least one letter and one digit")
+	}
+
+	return nil
 }
 
 type signupRequest struct {
@@ -51,6 +87,10 @@ func (h *AuthHandler) Signup(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Missing fields", http.StatusBadRequest)
 		return
 	}
+	if err := validatePassword(req.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Hash password
 	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
@@ -115,19 +155,28 @@ func (h *AuthHandler) Signin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Reject early while the account is locked out for this client; the attempt is not evaluated
+	if locked, wait := h.guard.Locked(req.Email, clientIP(r)); locked {
+		writeTooManyRequests(w, wait)
+		return
+	}
+
 	ctx := r.Context()
 
 	// Get password hash from engineers table
 	engineer, err := h.engineerRepo.GetByEmail(ctx, req.Email)
 	if err != nil || engineer == nil {
+		h.guard.Fail(req.Email, clientIP(r))
 		http.Error(w, "Credentials not found", http.StatusUnauthorized)
 		return
 	}
 
 	if bcrypt.CompareHashAndPassword([]byte(engineer.PasswordHash), []byte(req.Password)) != nil {
+		h.guard.Fail(req.Email, clientIP(r))
 		http.Error(w, "Credentials not found", http.StatusUnauthorized)
 		return
 	}
+	h.guard.Reset(req.Email, clientIP(r))
 
 	// Issue JWT
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
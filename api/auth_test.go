@@ -70,7 +70,7 @@ func TestAuthHandlers(t *testing.T) {
 			name:       "Signup_Success",
 			method:     http.MethodPost,
 			path:       "/signup",
-			body:       map[string]string{"name": "Alice", "email": "alice@example.com", "password": "s3cret"},
+			body:       map[string]string{"name": "Alice", "email": "alice@example.com", "password": "s3cretpass"},
 			prepare:    func(m *mock.Mocks) {},
 			wantStatus: http.StatusOK,
 			checkBody: func(t *testing.T, b []byte) {
@@ -88,11 +88,29 @@ func TestAuthHandlers(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:       "Signup_WeakPassword_TooShort",
+			method:     http.MethodPost,
+			path:       "/signup",
+			body:       map[string]string{"name": "Alice", "email": "alice@example.com", "password": "s3cret"},
+			prepare:    func(m *mock.Mocks) {},
+			wantStatus: http.StatusBadRequest,
+			checkBody:  func(t *testing.T, b []byte) {},
+		},
+		{
+			name:       "Signup_WeakPassword_NoDigit",
+			method:     http.MethodPost,
+			path:       "/signup",
+			body:       map[string]string{"name": "Alice", "email": "alice@example.com", "password": "onlyletters"},
+			prepare:    func(m *mock.Mocks) {},
+			wantStatus: http.StatusBadRequest,
+			checkBody:  func(t *testing.T, b []byte) {},
+		},
 		{
 			name:   "Signup_DuplicateEmail",
 			method: http.MethodPost,
 			path:   "/signup",
-			body:   map[string]string{"name": "Dup", "email": "dup@example.com", "password": "pw"},
+			body:   map[string]string{"name": "Dup", "email": "dup@example.com", "password": "passw0rd"},
 			prepare: func(m *mock.Mocks) {
 				m.EngRepo.CreateErr = fmt.Errorf("unique constraint")
 			},
@@ -197,7 +215,7 @@ func TestAuthHandlers(t *testing.T) {
 			if tt.prepare != nil {
 				tt.prepare(mocks)
 			}
-			handler := api.NewAuthHandler(mocks.EngRepo, mocks.ProfRepo, secret, tokenDur)
+			handler := api.NewAuthHandler(mocks.EngRepo, mocks.ProfRepo, secret, tokenDur, nil)
 
 			var bodyReader io.Reader
 			if tt.body != nil {
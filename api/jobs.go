@@ -0,0 +1,93 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/garnizeh/rag/pkg/repository"
+)
+
+// JobsHandler lets engineers poll the background jobs created on their behalf,
+// such as the analysis queued by CreateActivity.
+type JobsHandler struct {
+	jobRepo repository.JobRepo
+}
+
+func NewJobsHandler(jr repository.JobRepo) *JobsHandler {
+	return &JobsHandler{jobRepo: jr}
+}
+
+// jobStatusResponse is the engineer-facing view of a job; the payload is
+// internal and not returned.
+type jobStatusResponse struct {
+	ID          int64           `json:"id"`
+	Type        string          `json:"type"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts,omitempty"`
+	LastError   string          `json:"last_error,omitempty"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	Created     *time.Time      `json:"created,omitempty"`
+	Updated     time.Time       `json:"updated"`
+}
+
+// GetJob returns the status and, once done, the result of a job owned by the
+// caller. Jobs moved to the dead-letter table are reported as failed. Jobs of
+// other engineers are reported as not found.
+func (h *JobsHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	id, ok := idFromVars(r)
+	if !ok {
+		http.Error(w, "invalid job id", http.StatusBadRequest)
+		return
+	}
+	callerID, ok := engineerIDFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	j, err := h.jobRepo.GetJob(r.Context(), id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("get job: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if j != nil {
+		if j.EngineerID != callerID {
+			http.Error(w, "job not found", http.StatusNotFound)
+			return
+		}
+		created := j.Created
+		writeJSON(w, jobStatusResponse{
+			ID:          j.ID,
+			Type:        j.Type,
+			Status:      j.Status,
+			Attempts:    j.Attempts,
+			MaxAttempts: j.MaxAttempts,
+			LastError:   j.LastError,
+			Result:      j.Result,
+			Created:     &created,
+			Updated:     j.Updated,
+		}, http.StatusOK)
+		return
+	}
+
+	d, err := h.jobRepo.GetDeadLetterByJobID(r.Context(), id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("get dead letter: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if d == nil || d.EngineerID != callerID {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, jobStatusResponse{
+		ID:        d.JobID,
+		Type:      d.Type,
+		Status:    "failed",
+		Attempts:  d.Attempts,
+		LastError: d.LastError,
+		Updated:   d.FailedAt,
+	}, http.StatusOK)
+}
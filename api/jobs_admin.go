@@ -0,0 +1,193 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/garnizeh/rag/internal/models"
+	"github.com/garnizeh/rag/pkg/repository"
+	"github.com/gorilla/mux"
+)
+
+// JobsAdminHandler exposes the background job queue and its dead-letter table
+// for inspection and manual intervention. Routes are mounted behind AdminMiddleware.
+type JobsAdminHandler struct {
+	jobRepo repository.JobRepo
+}
+
+func NewJobsAdminHandler(jr repository.JobRepo) *JobsAdminHandler {
+	return &JobsAdminHandler{jobRepo: jr}
+}
+
+type purgeJobsRequest struct {
+	// OlderThan is a Go duration ("72h"); finished jobs last updated before now-OlderThan are removed
+	OlderThan string `json:"older_than"`
+}
+
+func jobFilterFromQuery(r *http.Request) models.JobFilter {
+	q := r.URL.Query()
+	f := models.JobFilter{Status: q.Get("status"), Type: q.Get("type")}
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil {
+		f.Limit = v
+	}
+	if v, err := strconv.Atoi(q.Get("offset")); err == nil {
+		f.Offset = v
+	}
+	return f
+}
+
+func idFromVars(r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	return id, err == nil && id > 0
+}
+
+// ListJobs lists jobs, optionally filtered by ?status= and ?type=.
+func (h *JobsAdminHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	f := jobFilterFromQuery(r)
+	jobs, err := h.jobRepo.ListJobs(r.Context(), f)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("list jobs: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if jobs == nil {
+		jobs = []models.BackgroundJob{}
+	}
+
+	limit, offset := f.Page()
+	writeJSON(w, map[string]any{"limit": limit, "offset": offset, "items": jobs}, http.StatusOK)
+}
+
+// GetJob returns a single job including its payload and last_error.
+func (h *JobsAdminHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	id, ok := idFromVars(r)
+	if !ok {
+		http.Error(w, "invalid job id", http.StatusBadRequest)
+		return
+	}
+
+	j, err := h.jobRepo.GetJob(r.Context(), id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("get job: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if j == nil {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, j, http.StatusOK)
+}
+
+// CancelJob cancels a job that has not started yet (queued or waiting to retry).
+func (h *JobsAdminHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	id, ok := idFromVars(r)
+	if !ok {
+		http.Error(w, "invalid job id", http.StatusBadRequest)
+		return
+	}
+
+	canceled, err := h.jobRepo.CancelJob(r.Context(), id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cancel job: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !canceled {
+		j, err := h.jobRepo.GetJob(r.Context(), id)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("get job: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if j == nil {
+			http.Error(w, "job not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("job is %s and cannot be canceled", j.Status), http.StatusConflict)
+		return
+	}
+
+	writeJSON(w, map[string]any{"id": id, "status": "canceled"}, http.StatusOK)
+}
+
+// PurgeJobs deletes finished jobs older than the given age.
+func (h *JobsAdminHandler) PurgeJobs(w http.ResponseWriter, r *http.Request) {
+	var req purgeJobsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	age, err := time.ParseDuration(req.OlderThan)
+	if err != nil || age <= 0 {
+		http.Error(w, "older_than must be a positive duration such as \"72h\"", http.StatusBadRequest)
+		return
+	}
+
+	cutoff := time.Now().Add(-age)
+	n, err := h.jobRepo.PurgeJobs(r.Context(), cutoff)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("purge jobs: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]any{"purged": n, "before": cutoff.UTC()}, http.StatusOK)
+}
+
+// ListDeadLetters lists dead-lettered jobs, optionally filtered by ?type=.
+func (h *JobsAdminHandler) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	f := jobFilterFromQuery(r)
+	items, err := h.jobRepo.ListDeadLetters(r.Context(), f)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("list dead letters: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if items == nil {
+		items = []models.DeadLetterJob{}
+	}
+
+	limit, offset := f.Page()
+	writeJSON(w, map[string]any{"limit": limit, "offset": offset, "items": items}, http.StatusOK)
+}
+
+// GetDeadLetter returns a single dead-lettered job.
+func (h *JobsAdminHandler) GetDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, ok := idFromVars(r)
+	if !ok {
+		http.Error(w, "invalid dead letter id", http.StatusBadRequest)
+		return
+	}
+
+	d, err := h.jobRepo.GetDeadLetter(r.Context(), id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("get dead letter: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if d == nil {
+		http.Error(w, "dead letter not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, d, http.StatusOK)
+}
+
+// RequeueDeadLetter moves a dead-lettered job back into the queue.
+func (h *JobsAdminHandler) RequeueDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, ok := idFromVars(r)
+	if !ok {
+		http.Error(w, "invalid dead letter id", http.StatusBadRequest)
+		return
+	}
+
+	jobID, err := h.jobRepo.RequeueDeadLetter(r.Context(), id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("requeue dead letter: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if jobID == 0 {
+		http.Error(w, "dead letter not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, map[string]any{"job_id": jobID, "status": "queued"}, http.StatusCreated)
+}
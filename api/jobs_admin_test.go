@@ -0,0 +1,110 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/garnizeh/rag/api"
+	"github.com/garnizeh/rag/internal/db"
+	"github.com/garnizeh/rag/internal/models"
+	sqlite "github.com/garnizeh/rag/internal/repository/sqlite"
+	"github.com/garnizeh/rag/pkg/repository/mock"
+	"github.com/gorilla/mux"
+)
+
+func TestAdminMiddleware(t *testing.T) {
+	m := mock.NewMocks()
+	m.EngRepo.Stored = &models.Engineer{ID: 1, Email: "Ops@Example.com"}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+
+	cases := []struct {
+		name   string
+		admins []string
+		req    *http.Request
+		want   int
+	}{
+		{"admin", []string{"ops@example.com"}, authedRequest(http.MethodGet, "/v1/admin/jobs", nil), http.StatusNoContent},
+		{"not admin", []string{"root@example.com"}, authedRequest(http.MethodGet, "/v1/admin/jobs", nil), http.StatusForbidden},
+		{"no admins configured", nil, authedRequest(http.MethodGet, "/v1/admin/jobs", nil), http.StatusForbidden},
+		{"unauthenticated", []string{"ops@example.com"}, httptest.NewRequest(http.MethodGet, "/v1/admin/jobs", nil), http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		api.AdminMiddleware(m.EngRepo, c.admins)(ok).ServeHTTP(w, c.req)
+		if w.Code != c.want {
+			t.Errorf("%s: expected %d got %d", c.name, c.want, w.Code)
+		}
+	}
+}
+
+func TestJobsAdmin_CancelAndRequeue(t *testing.T) {
+	ctx := context.Background()
+	d, err := db.New(ctx, "file:jobs_admin?mode=memory&cache=shared", nil)
+	if err != nil {
+		t.Fatalf("db.New: %v", err)
+	}
+	defer d.Close()
+	for _, s := range []string{
+		`CREATE TABLE jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, type TEXT NOT NULL, payload TEXT, status TEXT NOT NULL DEFAULT 'queued', attempts INTEGER NOT NULL DEFAULT 0, max_attempts INTEGER NOT NULL DEFAULT 5, priority INTEGER NOT NULL DEFAULT 100, scheduled_at INTEGER NOT NULL, next_try_at INTEGER, last_error TEXT, locked_by TEXT, lease_until INTEGER, dedupe_key TEXT, result TEXT, engineer_id INTEGER, created INTEGER NOT NULL, updated INTEGER NOT NULL);`,
+		`CREATE TABLE dead_letter_jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, job_id INTEGER NOT NULL, type TEXT NOT NULL, payload TEXT, attempts INTEGER NOT NULL, last_error TEXT, failed_at INTEGER NOT NULL, engineer_id INTEGER, priority INTEGER NOT NULL DEFAULT 100, max_attempts INTEGER NOT NULL DEFAULT 5, dedupe_key TEXT);`,
+	} {
+		if _, err := d.Exec(ctx, s); err != nil {
+			t.Fatalf("setup schema: %v", err)
+		}
+	}
+	repo := sqlite.New(d, nil)
+	h := api.NewJobsAdminHandler(repo)
+
+	id, err := repo.Enqueue(ctx, &models.BackgroundJob{Type: "ai.analyze_activity", Payload: []byte(`{"activity_id":1}`), ScheduledAt: time.Now()})
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	vars := map[string]string{"id": "1"}
+
+	w := httptest.NewRecorder()
+	h.CancelJob(w, mux.SetURLVars(authedRequest(http.MethodPost, "/v1/admin/jobs/1/cancel", nil), vars))
+	if w.Code != http.StatusOK {
+		t.Fatalf("cancel: expected 200 got %d body=%s", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	h.CancelJob(w, mux.SetURLVars(authedRequest(http.MethodPost, "/v1/admin/jobs/1/cancel", nil), vars))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("second cancel: expected 409 got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	h.CancelJob(w, mux.SetURLVars(authedRequest(http.MethodPost, "/v1/admin/jobs/99/cancel", nil), map[string]string{"id": "99"}))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("missing cancel: expected 404 got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ListJobs(w, authedRequest(http.MethodGet, "/v1/admin/jobs?status=canceled", nil))
+	var list struct {
+		Items []models.BackgroundJob `json:"items"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(list.Items) != 1 || list.Items[0].ID != id {
+		t.Fatalf("expected the canceled job, got %+v", list.Items)
+	}
+
+	if err := repo.MoveToDeadLetter(ctx, &models.BackgroundJob{ID: id, Type: "ai.analyze_activity", Payload: []byte(`{"activity_id":1}`), LastError: "no handler"}); err != nil {
+		t.Fatalf("dead letter: %v", err)
+	}
+	w = httptest.NewRecorder()
+	h.RequeueDeadLetter(w, mux.SetURLVars(authedRequest(http.MethodPost, "/v1/admin/jobs/dead/1/requeue", nil), vars))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("requeue: expected 201 got %d body=%s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.PurgeJobs(w, authedRequest(http.MethodPost, "/v1/admin/jobs/purge", map[string]string{"older_than": "soon"}))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("purge: expected 400 for invalid duration got %d", w.Code)
+	}
+}
@@ -0,0 +1,97 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/garnizeh/rag/api"
+	"github.com/garnizeh/rag/internal/db"
+	"github.com/garnizeh/rag/internal/models"
+	sqlite "github.com/garnizeh/rag/internal/repository/sqlite"
+	"github.com/gorilla/mux"
+)
+
+func TestJobs_GetJobStatus(t *testing.T) {
+	ctx := context.Background()
+	d, err := db.New(ctx, "file:jobs_status?mode=memory&cache=shared", nil)
+	if err != nil {
+		t.Fatalf("db.New: %v", err)
+	}
+	defer d.Close()
+	for _, s := range []string{
+		`CREATE TABLE jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, type TEXT NOT NULL, payload TEXT, status TEXT NOT NULL DEFAULT 'queued', attempts INTEGER NOT NULL DEFAULT 0, max_attempts INTEGER NOT NULL DEFAULT 5, priority INTEGER NOT NULL DEFAULT 100, scheduled_at INTEGER NOT NULL, next_try_at INTEGER, last_error TEXT, locked_by TEXT, lease_until INTEGER, dedupe_key TEXT, result TEXT, engineer_id INTEGER, created INTEGER NOT NULL, updated INTEGER NOT NULL);`,
+		`CREATE TABLE dead_letter_jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, job_id INTEGER NOT NULL, type TEXT NOT NULL, payload TEXT, attempts INTEGER NOT NULL, last_error TEXT, failed_at INTEGER NOT NULL, engineer_id INTEGER, priority INTEGER NOT NULL DEFAULT 100, max_attempts INTEGER NOT NULL DEFAULT 5, dedupe_key TEXT);`,
+	} {
+		if _, err := d.Exec(ctx, s); err != nil {
+			t.Fatalf("setup schema: %v", err)
+		}
+	}
+	repo := sqlite.New(d, nil)
+	h := api.NewJobsHandler(repo)
+
+	enqueue := func(engineerID int64) int64 {
+		id, err := repo.Enqueue(ctx, &models.BackgroundJob{Type: "ai.analyze_activity", Payload: []byte(`{}`), EngineerID: engineerID, ScheduledAt: time.Now()})
+		if err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+		return id
+	}
+	get := func(id int64) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.GetJob(w, mux.SetURLVars(authedRequest(http.MethodGet, "/v1/jobs/x", nil), map[string]string{"id": strconv.FormatInt(id, 10)}))
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) map[string]any {
+		var resp map[string]any
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp
+	}
+
+	done := enqueue(1)
+	if err := repo.UpdateJob(ctx, &models.BackgroundJob{ID: done, Status: "done", Attempts: 1, Result: json.RawMessage(`{"context_version":3}`)}); err != nil {
+		t.Fatalf("update job: %v", err)
+	}
+	w := get(done)
+	if w.Code != http.StatusOK {
+		t.Fatalf("done: expected 200 got %d body=%s", w.Code, w.Body.String())
+	}
+	resp := decode(w)
+	if resp["status"] != "done" {
+		t.Fatalf("done: unexpected status %v", resp["status"])
+	}
+	if res, ok := resp["result"].(map[string]any); !ok || res["context_version"] != float64(3) {
+		t.Fatalf("done: unexpected result %v", resp["result"])
+	}
+	if _, ok := resp["payload"]; ok {
+		t.Fatalf("done: payload must not be exposed")
+	}
+
+	// another engineer's job looks like it does not exist
+	if w := get(enqueue(2)); w.Code != http.StatusNotFound {
+		t.Fatalf("other engineer: expected 404 got %d", w.Code)
+	}
+
+	failed := enqueue(1)
+	if err := repo.MoveToDeadLetter(ctx, &models.BackgroundJob{ID: failed, Type: "ai.analyze_activity", Attempts: 3, LastError: "model unavailable", EngineerID: 1}); err != nil {
+		t.Fatalf("dead letter: %v", err)
+	}
+	w = get(failed)
+	if w.Code != http.StatusOK {
+		t.Fatalf("dead letter: expected 200 got %d", w.Code)
+	}
+	resp = decode(w)
+	if resp["status"] != "failed" || resp["last_error"] != "model unavailable" || resp["id"] != float64(failed) {
+		t.Fatalf("dead letter: unexpected response %v", resp)
+	}
+
+	if w := get(999); w.Code != http.StatusNotFound {
+		t.Fatalf("missing: expected 404 got %d", w.Code)
+	}
+}
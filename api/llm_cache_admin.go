@@ -0,0 +1,51 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/garnizeh/rag/pkg/repository"
+)
+
+// LLMCacheAdminHandler lets admins drop cached LLM responses, e.g. after a
+// model was re-pulled with new weights under the same tag. Routes are mounted
+// behind AdminMiddleware.
+type LLMCacheAdminHandler struct {
+	cacheRepo repository.LLMCacheRepo
+}
+
+func NewLLMCacheAdminHandler(cacheRepo repository.LLMCacheRepo) *LLMCacheAdminHandler {
+	return &LLMCacheAdminHandler{cacheRepo: cacheRepo}
+}
+
+type purgeLLMCacheRequest struct {
+	Model string `json:"model"`
+}
+
+// PurgeLLMCache deletes the cached responses of one model, or all of them
+// when no model is given.
+func (h *LLMCacheAdminHandler) PurgeLLMCache(w http.ResponseWriter, r *http.Request) {
+	var req purgeLLMCacheRequest
+	// an empty body purges everything
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	model := strings.TrimSpace(req.Model)
+
+	n, err := h.cacheRepo.PurgeLLMCache(r.Context(), model)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("purge llm cache: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	resp := map[string]any{"purged": n}
+	if model != "" {
+		resp["model"] = model
+	}
+	writeJSON(w, resp, http.StatusOK)
+}
@@ -0,0 +1,58 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/garnizeh/rag/api"
+	"github.com/garnizeh/rag/internal/db"
+	"github.com/garnizeh/rag/internal/models"
+	sqlite "github.com/garnizeh/rag/internal/repository/sqlite"
+)
+
+func TestLLMCacheAdmin_Purge(t *testing.T) {
+	ctx := context.Background()
+	d, err := db.New(ctx, "file:llm_cache_admin?mode=memory&cache=shared", nil)
+	if err != nil {
+		t.Fatalf("db.New: %v", err)
+	}
+	defer d.Close()
+	if _, err := d.Exec(ctx, `CREATE TABLE llm_cache (key TEXT PRIMARY KEY, model TEXT NOT NULL, response TEXT NOT NULL, size INTEGER NOT NULL, hits INTEGER NOT NULL DEFAULT 0, expires_at INTEGER NOT NULL, last_used INTEGER NOT NULL, created INTEGER NOT NULL);`); err != nil {
+		t.Fatalf("setup schema: %v", err)
+	}
+	repo := sqlite.New(d, nil)
+	expires := time.Now().Add(time.Hour).UnixMilli()
+	for _, e := range []models.LLMCacheEntry{
+		{Key: "a", Model: "m1", Response: "{}", ExpiresAt: expires},
+		{Key: "b", Model: "m2", Response: "{}", ExpiresAt: expires},
+		{Key: "c", Model: "m2", Response: "{}", ExpiresAt: expires},
+	} {
+		if err := repo.PutCachedResponse(ctx, &e); err != nil {
+			t.Fatalf("put: %v", err)
+		}
+	}
+	h := api.NewLLMCacheAdminHandler(repo)
+
+	for _, c := range []struct {
+		body any
+		want float64
+	}{
+		{map[string]string{"model": "m2"}, 2},
+		{nil, 1},
+	} {
+		w := httptest.NewRecorder()
+		h.PurgeLLMCache(w, authedRequest(http.MethodPost, "/v1/admin/llm-cache/purge", c.body))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200 got %d: %s", w.Code, w.Body.String())
+		}
+		var resp map[string]any
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		if resp["purged"] != c.want {
+			t.Fatalf("expected %v purged, got %v", c.want, resp)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/garnizeh/rag/internal/ai"
+	"github.com/garnizeh/rag/internal/models"
+	"github.com/garnizeh/rag/pkg/repository"
+)
+
+// defaultUsageWindow is how far back usage reports look without ?since=.
+const defaultUsageWindow = 7 * 24 * time.Hour
+
+// LLMUsageHandler reports LLM usage: engineers see their own day against
+// their quota, admins aggregate any period per engineer, template or model.
+type LLMUsageHandler struct {
+	usageRepo repository.LLMUsageRepo
+	quota     *ai.Quota
+}
+
+func NewLLMUsageHandler(ur repository.LLMUsageRepo, quota *ai.Quota) *LLMUsageHandler {
+	return &LLMUsageHandler{usageRepo: ur, quota: quota}
+}
+
+// MyUsage returns the authenticated engineer's usage today (UTC) and the
+// daily limits, zero when unlimited.
+func (h *LLMUsageHandler) MyUsage(w http.ResponseWriter, r *http.Request) {
+	id, ok := engineerIDFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	now := time.Now().UTC()
+	u, err := ai.DailyUsage(r.Context(), h.usageRepo, id, now)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("llm usage: %v", err), http.StatusInternalServerError)
+		return
+	}
+	calls, tokens := h.quota.Limits()
+	writeJSON(w, map[string]any{
+		"usage":             u,
+		"daily_call_limit":  calls,
+		"daily_token_limit": tokens,
+		"resets_at":         now.Truncate(24 * time.Hour).Add(24 * time.Hour),
+	}, http.StatusOK)
+}
+
+// UsageReport aggregates usage grouped by ?group_by=engineer|template|model
+// (default engineer) between ?since= and ?until= (RFC 3339 times or
+// YYYY-MM-DD dates, default the last 7 days), optionally for one
+// ?engineer_id=.
+func (h *LLMUsageHandler) UsageReport(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := models.LLMUsageFilter{GroupBy: q.Get("group_by")}
+	switch f.GroupBy {
+	case "":
+		f.GroupBy = models.LLMUsageByEngineer
+	case models.LLMUsageByEngineer, models.LLMUsageByTemplate, models.LLMUsageByModel:
+	default:
+		http.Error(w, "group_by must be engineer, template or model", http.StatusBadRequest)
+		return
+	}
+	if v := q.Get("engineer_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			http.Error(w, "invalid engineer_id", http.StatusBadRequest)
+			return
+		}
+		f.EngineerID = id
+	}
+
+	since := time.Now().UTC().Add(-defaultUsageWindow)
+	var until time.Time
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &since}, {"until", &until}} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := parseReportTime(v)
+		if err != nil {
+			http.Error(w, p.name+" must be an RFC 3339 time or a YYYY-MM-DD date", http.StatusBadRequest)
+			return
+		}
+		*p.dst = t
+	}
+	f.Since = since.UnixMilli()
+	if !until.IsZero() {
+		f.Until = until.UnixMilli()
+	}
+
+	items, err := h.usageRepo.LLMUsage(r.Context(), f)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("llm usage: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if items == nil {
+		items = []models.LLMUsage{}
+	}
+
+	resp := map[string]any{"group_by": f.GroupBy, "since": since, "items": items}
+	if !until.IsZero() {
+		resp["until"] = until
+	}
+	writeJSON(w, resp, http.StatusOK)
+}
+
+func parseReportTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Parse(time.DateOnly, v)
+}
@@ -0,0 +1,204 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/garnizeh/rag/api"
+	"github.com/garnizeh/rag/internal/ai"
+	"github.com/garnizeh/rag/internal/config"
+	"github.com/garnizeh/rag/internal/db"
+	"github.com/garnizeh/rag/internal/jobs"
+	"github.com/garnizeh/rag/internal/models"
+	sqlite "github.com/garnizeh/rag/internal/repository/sqlite"
+	"github.com/garnizeh/rag/pkg/ollama"
+	"github.com/garnizeh/rag/pkg/repository"
+)
+
+func TestLLMUsage_QuotaAndReports(t *testing.T) {
+	ctx := context.Background()
+	d, err := db.New(ctx, "file:llm_usage_api?mode=memory&cache=shared", nil)
+	if err != nil {
+		t.Fatalf("db.New: %v", err)
+	}
+	defer d.Close()
+	for _, s := range []string{
+		`CREATE TABLE raw_activities (id INTEGER PRIMARY KEY AUTOINCREMENT, engineer_id INTEGER, activity TEXT, visibility TEXT NOT NULL DEFAULT 'shared', redactions TEXT, idempotency_key TEXT, created INTEGER);`,
+		`CREATE TABLE jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, type TEXT NOT NULL, payload TEXT, status TEXT NOT NULL DEFAULT 'queued', attempts INTEGER NOT NULL DEFAULT 0, max_attempts INTEGER NOT NULL DEFAULT 5, priority INTEGER NOT NULL DEFAULT 100, scheduled_at INTEGER NOT NULL, next_try_at INTEGER, last_error TEXT, locked_by TEXT, lease_until INTEGER, dedupe_key TEXT, result TEXT, engineer_id INTEGER, created INTEGER NOT NULL, updated INTEGER NOT NULL);`,
+		`CREATE TABLE llm_calls (id INTEGER PRIMARY KEY AUTOINCREMENT, engineer_id INTEGER, template_name TEXT NOT NULL, template_version TEXT NOT NULL, model TEXT NOT NULL, outcome TEXT NOT NULL, prompt_tokens INTEGER NOT NULL DEFAULT 0, eval_tokens INTEGER NOT NULL DEFAULT 0, duration_ms INTEGER NOT NULL DEFAULT 0, error TEXT, created INTEGER NOT NULL);`,
+	} {
+		if _, err := d.Exec(ctx, s); err != nil {
+			t.Fatalf("setup schema: %v", err)
+		}
+	}
+	repo := sqlite.New(d, nil)
+	eng := int64(1)
+	for range 2 {
+		if _, err := repo.CreateLLMCall(ctx, &models.LLMCall{EngineerID: &eng, TemplateName: "activity", TemplateVersion: "v1", Model: "m", Outcome: models.LLMCallOK, PromptTokens: 10, EvalTokens: 5}); err != nil {
+			t.Fatalf("create call: %v", err)
+		}
+	}
+
+	quota := ai.NewQuota(repo, config.LLMUsageConfig{DailyCalls: 2})
+	ah := api.NewActivitiesHandler(repo, repo, nil, quota)
+
+	w := httptest.NewRecorder()
+	ah.CreateActivity(w, authedRequest(http.MethodPost, "/v1/activities", map[string]string{"activity": "Deployed svc"}))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 over quota, got %d: %s", w.Code, w.Body.String())
+	}
+	if ra, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || ra <= 0 {
+		t.Fatalf("expected a positive Retry-After, got %q", w.Header().Get("Retry-After"))
+	}
+	// naming another engineer neither escapes the caller's quota nor spends theirs
+	w = httptest.NewRecorder()
+	ah.CreateActivity(w, authedRequest(http.MethodPost, "/v1/activities", map[string]any{"engineer_id": 2, "activity": "Deployed svc"}))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for another engineer_id, got %d", w.Code)
+	}
+	other := authedRequest(http.MethodPost, "/v1/activities", map[string]string{"activity": "Deployed svc"})
+	other = other.WithContext(context.WithValue(other.Context(), api.CtxEngineerID, int64(2)))
+	w = httptest.NewRecorder()
+	ah.CreateActivity(w, other)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 for an engineer under quota, got %d", w.Code)
+	}
+
+	// private activities never reach the llm, so the quota does not apply
+	w = httptest.NewRecorder()
+	ah.CreateActivity(w, authedRequest(http.MethodPost, "/v1/activities", map[string]string{"activity": "Notes", "visibility": models.VisibilityPrivate}))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 for a private activity, got %d", w.Code)
+	}
+
+	h := api.NewLLMUsageHandler(repo, quota)
+	w = httptest.NewRecorder()
+	h.MyUsage(w, authedRequest(http.MethodGet, "/v1/me/llm-usage", nil))
+	var mine struct {
+		Usage          models.LLMUsage `json:"usage"`
+		DailyCallLimit int64           `json:"daily_call_limit"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &mine); err != nil || mine.Usage.Calls != 2 || mine.DailyCallLimit != 2 {
+		t.Fatalf("unexpected own usage: %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.UsageReport(w, authedRequest(http.MethodGet, "/v1/admin/llm-usage?group_by=model&since=2000-01-01", nil))
+	var report struct {
+		Items []models.LLMUsage `json:"items"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil || len(report.Items) != 1 || report.Items[0].Model != "m" || report.Items[0].PromptTokens != 20 {
+		t.Fatalf("unexpected report: %s", w.Body.String())
+	}
+
+	for _, q := range []string{"group_by=team", "since=yesterday", "engineer_id=x"} {
+		w = httptest.NewRecorder()
+		h.UsageReport(w, authedRequest(http.MethodGet, "/v1/admin/llm-usage?"+q, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", q, w.Code)
+		}
+	}
+}
+
+// TestLLMUsage_AccountedFromActivityToJob follows an activity from POST
+// /v1/activities through its analysis job to the llm_calls row its quota is
+// checked against.
+func TestLLMUsage_AccountedFromActivityToJob(t *testing.T) {
+	answer := `{"version":"v1","summary":"Deployed","entities":{"people":[],"projects":[],"technologies":[]},"confidence":0.9,"context_update":false,"reasoning":"r"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"model": "m", "response": answer, "done": true, "prompt_eval_count": 40, "eval_count": 12})
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	d, err := db.New(ctx, "file:llm_usage_e2e?mode=memory&cache=shared", nil)
+	if err != nil {
+		t.Fatalf("db.New: %v", err)
+	}
+	defer d.Close()
+	for _, s := range []string{
+		`CREATE TABLE raw_activities (id INTEGER PRIMARY KEY AUTOINCREMENT, engineer_id INTEGER, activity TEXT, visibility TEXT NOT NULL DEFAULT 'shared', redactions TEXT, idempotency_key TEXT, created INTEGER);`,
+		`CREATE TABLE jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, type TEXT NOT NULL, payload TEXT, status TEXT NOT NULL DEFAULT 'queued', attempts INTEGER NOT NULL DEFAULT 0, max_attempts INTEGER NOT NULL DEFAULT 5, priority INTEGER NOT NULL DEFAULT 100, scheduled_at INTEGER NOT NULL, next_try_at INTEGER, last_error TEXT, locked_by TEXT, lease_until INTEGER, dedupe_key TEXT, result TEXT, engineer_id INTEGER, created INTEGER NOT NULL, updated INTEGER NOT NULL);`,
+		`CREATE UNIQUE INDEX idx_jobs_dedupe_pending ON jobs(dedupe_key) WHERE dedupe_key IS NOT NULL AND status IN ('queued', 'retry', 'running');`,
+		`CREATE TABLE dead_letter_jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, job_id INTEGER NOT NULL, type TEXT NOT NULL, payload TEXT, attempts INTEGER NOT NULL, last_error TEXT, failed_at INTEGER NOT NULL, engineer_id INTEGER, priority INTEGER NOT NULL DEFAULT 100, max_attempts INTEGER NOT NULL DEFAULT 5, dedupe_key TEXT);`,
+		`CREATE TABLE engineer_contexts (id INTEGER PRIMARY KEY AUTOINCREMENT, engineer_id INTEGER NOT NULL UNIQUE, context_json TEXT NOT NULL, version INTEGER NOT NULL DEFAULT 1, updated INTEGER NOT NULL);`,
+		`CREATE TABLE engineer_profiles (id INTEGER PRIMARY KEY AUTOINCREMENT, engineer_id INTEGER, bio TEXT, updated INTEGER);`,
+		`CREATE TABLE ai_schemas (id INTEGER PRIMARY KEY AUTOINCREMENT, version TEXT UNIQUE, description TEXT, schema_json TEXT, created INTEGER, updated INTEGER);`,
+		`CREATE TABLE ai_templates (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, version TEXT, template_text TEXT, schema_version TEXT, metadata TEXT, created INTEGER, updated INTEGER, UNIQUE(name, version));`,
+		`CREATE TABLE llm_calls (id INTEGER PRIMARY KEY AUTOINCREMENT, engineer_id INTEGER, template_name TEXT NOT NULL, template_version TEXT NOT NULL, model TEXT NOT NULL, outcome TEXT NOT NULL, prompt_tokens INTEGER NOT NULL DEFAULT 0, eval_tokens INTEGER NOT NULL DEFAULT 0, duration_ms INTEGER NOT NULL DEFAULT 0, error TEXT, created INTEGER NOT NULL);`,
+	} {
+		if _, err := d.Exec(ctx, s); err != nil {
+			t.Fatalf("setup schema: %v", err)
+		}
+	}
+	store := sqlite.New(d, nil)
+	if _, err := store.CreateSchema(ctx, "v1", "v1", `{"type":"object","required":["version","summary"]}`); err != nil {
+		t.Fatalf("seed schema: %v", err)
+	}
+	if _, err := store.CreateTemplate(ctx, "activity", "v1", "{{.Activity.Activity}}", nil, nil); err != nil {
+		t.Fatalf("seed template: %v", err)
+	}
+
+	client, err := ollama.NewClient(config.OllamaConfig{BaseURL: srv.URL, Timeout: 2 * time.Second}, srv.Client())
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	defer client.Close()
+	engine, err := ai.NewEngine(ctx, client, config.EngineConfig{Model: "m", TemplateVersion: "v1"}, store, store)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	engine.SetUsageRepo(store)
+
+	repo := &repository.Repository{Activity: store, Context: store, Profile: store, Job: store, LLMUsage: store}
+	pool := jobs.NewWorkerPool(store, map[string]jobs.Handler{ai.AnalyzeActivityJob: ai.AnalyzeActivityHandler(engine, repo)}, slog.New(slog.DiscardHandler), 1)
+	pool.Start(ctx)
+	defer pool.Stop()
+
+	quota := ai.NewQuota(store, config.LLMUsageConfig{DailyCalls: 1})
+	ah := api.NewActivitiesHandler(store, store, nil, quota)
+	w := httptest.NewRecorder()
+	ah.CreateActivity(w, authedRequest(http.MethodPost, "/v1/activities", map[string]string{"activity": "Deployed svc"}))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		JobID int64 `json:"job_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || created.JobID == 0 {
+		t.Fatalf("expected an analysis job, got %s", w.Body.String())
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		j, err := store.GetJob(ctx, created.JobID)
+		if err != nil {
+			t.Fatalf("get job: %v", err)
+		}
+		if j.Status == "done" {
+			break
+		}
+		if j.Status == "failed" || time.Now().After(deadline) {
+			t.Fatalf("analysis job did not finish: status %s, last error %q", j.Status, j.LastError)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	u, err := ai.DailyUsage(ctx, store, 1, time.Now())
+	if err != nil || u.Calls != 1 || u.PromptTokens != 40 || u.EvalTokens != 12 {
+		t.Fatalf("expected the analysis accounted to engineer 1, got %+v err=%v", u, err)
+	}
+
+	// the call used up the daily quota
+	w = httptest.NewRecorder()
+	ah.CreateActivity(w, authedRequest(http.MethodPost, "/v1/activities", map[string]string{"activity": "Deployed it again"}))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 once the quota is used, got %d", w.Code)
+	}
+}
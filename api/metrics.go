@@ -0,0 +1,75 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/garnizeh/rag/internal/metrics"
+	"github.com/gorilla/mux"
+)
+
+var (
+	httpRequests = metrics.NewCounter("rag_http_requests_total",
+		"HTTP requests by method, route template and status code.", "method", "route", "code")
+	httpDuration = metrics.NewHistogram("rag_http_request_duration_seconds",
+		"HTTP request latency by method and route template.", metrics.DefBuckets, "method", "route")
+)
+
+// statusRecorder captures the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, so
+// streaming handlers can flush and adjust deadlines.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// MetricsMiddleware records request counts and latency. Requests are labelled
+// with the matched route template rather than the raw path so ids do not
+// create a series per resource.
+func MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unmatched"
+		if cr := mux.CurrentRoute(r); cr != nil {
+			if tpl, err := cr.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+
+		httpDuration.With(r.Method, route).Observe(time.Since(start).Seconds())
+		httpRequests.With(r.Method, route, strconv.Itoa(rec.status)).Inc()
+	})
+}
+
+// MetricsHandler serves all metrics in the Prometheus text format. It reads
+// only in-memory values: stored job counts are kept current by
+// jobs.QueueMetrics, so a scrape never queries the database. With a token
+// set, scrapers must send it as "Authorization: Bearer <token>".
+func MetricsHandler(token string) http.Handler {
+	serve := metrics.Default.Handler()
+	if token == "" {
+		return serve
+	}
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			http.Error(w, "Invalid or missing metrics token", http.StatusUnauthorized)
+			return
+		}
+		serve.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,103 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/garnizeh/rag/api"
+	"github.com/garnizeh/rag/internal/db"
+	"github.com/garnizeh/rag/internal/jobs"
+	"github.com/garnizeh/rag/internal/models"
+	sqlite "github.com/garnizeh/rag/internal/repository/sqlite"
+	"github.com/gorilla/mux"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	ctx := context.Background()
+	d, err := db.New(ctx, "file:metrics_endpoint?mode=memory&cache=shared", nil)
+	if err != nil {
+		t.Fatalf("db.New: %v", err)
+	}
+	defer d.Close()
+	for _, s := range []string{
+		`CREATE TABLE jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, type TEXT NOT NULL, payload TEXT, status TEXT NOT NULL DEFAULT 'queued', attempts INTEGER NOT NULL DEFAULT 0, max_attempts INTEGER NOT NULL DEFAULT 5, priority INTEGER NOT NULL DEFAULT 100, scheduled_at INTEGER NOT NULL, next_try_at INTEGER, last_error TEXT, locked_by TEXT, lease_until INTEGER, dedupe_key TEXT, result TEXT, engineer_id INTEGER, created INTEGER NOT NULL, updated INTEGER NOT NULL);`,
+		`CREATE TABLE dead_letter_jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, job_id INTEGER NOT NULL, type TEXT NOT NULL, payload TEXT, attempts INTEGER NOT NULL, last_error TEXT, failed_at INTEGER NOT NULL, engineer_id INTEGER, priority INTEGER NOT NULL DEFAULT 100, max_attempts INTEGER NOT NULL DEFAULT 5, dedupe_key TEXT);`,
+	} {
+		if _, err := d.Exec(ctx, s); err != nil {
+			t.Fatalf("setup schema: %v", err)
+		}
+	}
+	repo := sqlite.New(d, nil)
+	for range 2 {
+		if _, err := repo.Enqueue(ctx, &models.BackgroundJob{Type: "ai.analyze_activity", Payload: []byte(`{}`), ScheduledAt: time.Now()}); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+	if err := repo.MoveToDeadLetter(ctx, &models.BackgroundJob{ID: 1, Type: "ai.analyze_activity", LastError: "boom"}); err != nil {
+		t.Fatalf("dead letter: %v", err)
+	}
+
+	r := mux.NewRouter()
+	r.Use(api.MetricsMiddleware)
+	r.HandleFunc("/v1/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}).Methods("GET")
+	r.Handle("/metrics", api.MetricsHandler("")).Methods("GET")
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/items/42", nil))
+
+	// job counts come from the background refresher, not the scrape
+	qm := jobs.NewQueueMetrics(repo, nil, time.Hour)
+	qm.Start(ctx)
+	qm.Stop()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("metrics: expected 200 got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+
+	body := w.Body.String()
+	for _, want := range []string{
+		`rag_http_requests_total{method="GET",route="/v1/items/{id}",code="418"} 1`,
+		`rag_http_request_duration_seconds_count{method="GET",route="/v1/items/{id}"} 1`,
+		`rag_jobs{status="queued",type="ai.analyze_activity"} 1`,
+		`rag_jobs{status="dead_letter",type="ai.analyze_activity"} 1`,
+		`# TYPE rag_job_duration_seconds histogram`,
+		`# TYPE rag_ollama_circuit_state gauge`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics output missing %q", want)
+		}
+	}
+}
+
+func TestMetricsEndpoint_Token(t *testing.T) {
+	h := api.MetricsHandler("scrape-secret")
+	for name, c := range map[string]struct {
+		header string
+		want   int
+	}{
+		"no token":     {"", http.StatusUnauthorized},
+		"wrong token":  {"Bearer nope", http.StatusUnauthorized},
+		"user jwt":     {"Bearer eyJhbGciOiJIUzI1NiJ9.e30.x", http.StatusUnauthorized},
+		"scrape token": {"Bearer scrape-secret", http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if c.header != "" {
+			req.Header.Set("Authorization", c.header)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != c.want {
+			t.Errorf("%s: expected %d got %d", name, c.want, w.Code)
+		}
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
 
 	"log/slog"
 
+	"github.com/garnizeh/rag/pkg/repository"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/mux"
 )
@@ -16,6 +18,16 @@ type ctxKey string
 
 const CtxEngineerID ctxKey = "engineer_id"
 
+// engineerIDFromContext returns the authenticated engineer id placed in the
+// request context by the JWT middleware.
+func engineerIDFromContext(r *http.Request) (int64, bool) {
+	id, ok := r.Context().Value(CtxEngineerID).(int64)
+	if !ok || id <= 0 {
+		return 0, false
+	}
+	return id, true
+}
+
 // package-level logger used by middleware and helpers; can be set via SetLogger from caller
 var logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
 
@@ -40,8 +52,8 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 func CORSMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Idempotency-Key")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusNoContent)
@@ -119,3 +131,41 @@ func JWTAuthMiddlewareWithSecret(secret string) mux.MiddlewareFunc {
 		})
 	}
 }
+
+// AdminMiddleware restricts a route to engineers whose email is listed in
+// adminEmails (case-insensitive). It must run after the JWT middleware. With no
+// admins configured every request is rejected.
+func AdminMiddleware(er repository.EngineerRepo, adminEmails []string) mux.MiddlewareFunc {
+	admins := make(map[string]struct{}, len(adminEmails))
+	for _, e := range adminEmails {
+		if e = strings.ToLower(strings.TrimSpace(e)); e != "" {
+			admins[e] = struct{}{}
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			engineerID, ok := engineerIDFromContext(r)
+			if !ok {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			e, err := er.GetByID(r.Context(), engineerID)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("get engineer: %v", err), http.StatusInternalServerError)
+				return
+			}
+			if e == nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if _, ok := admins[strings.ToLower(e.Email)]; !ok {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
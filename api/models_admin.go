@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/garnizeh/rag/pkg/ollama"
+	"github.com/gorilla/mux"
+)
+
+// ModelManager is the part of the AI engine the models admin API needs;
+// *ai.Engine implements it.
+type ModelManager interface {
+	// Client returns the current Ollama client, or nil in degraded mode.
+	Client() *ollama.Client
+	// RefreshModels re-checks which configured models are installed.
+	RefreshModels(ctx context.Context) error
+}
+
+// ModelsAdminHandler lets admins inspect and pull Ollama models without shell
+// access to the Ollama hosts. Routes are mounted behind AdminMiddleware.
+type ModelsAdminHandler struct {
+	models ModelManager
+}
+
+func NewModelsAdminHandler(m ModelManager) *ModelsAdminHandler {
+	return &ModelsAdminHandler{models: m}
+}
+
+type pullModelRequest struct {
+	Model string `json:"model"`
+}
+
+// client returns the Ollama client or answers 503 when the engine is degraded.
+func (h *ModelsAdminHandler) client(w http.ResponseWriter) *ollama.Client {
+	c := h.models.Client()
+	if c == nil {
+		http.Error(w, "llm unavailable: engine running in degraded mode", http.StatusServiceUnavailable)
+	}
+	return c
+}
+
+// ListModels lists the installed models with their size, family and quantization.
+func (h *ModelsAdminHandler) ListModels(w http.ResponseWriter, r *http.Request) {
+	c := h.client(w)
+	if c == nil {
+		return
+	}
+
+	list, err := c.ListModels(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("list models: %v", err), http.StatusBadGateway)
+		return
+	}
+	if list == nil {
+		list = []ollama.ModelInfo{}
+	}
+
+	writeJSON(w, map[string]any{"items": list}, http.StatusOK)
+}
+
+// GetModel returns the details of one model.
+func (h *ModelsAdminHandler) GetModel(w http.ResponseWriter, r *http.Request) {
+	c := h.client(w)
+	if c == nil {
+		return
+	}
+
+	d, err := c.ShowModel(r.Context(), mux.Vars(r)["name"])
+	if errors.Is(err, ollama.ErrModelNotFound) {
+		http.Error(w, "model not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("show model: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	writeJSON(w, d, http.StatusOK)
+}
+
+// PullModel downloads a model onto every Ollama endpoint and streams progress
+// as newline-delimited JSON. The last line is {"status":"success"} or
+// {"status":"error","error":"..."}; errors after the stream started cannot
+// change the 200 status.
+func (h *ModelsAdminHandler) PullModel(w http.ResponseWriter, r *http.Request) {
+	var req pullModelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	req.Model = strings.TrimSpace(req.Model)
+	if req.Model == "" {
+		http.Error(w, "model is required", http.StatusBadRequest)
+		return
+	}
+	c := h.client(w)
+	if c == nil {
+		return
+	}
+
+	// pulls take far longer than the server write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		logger.Warn("pull model: clear write deadline", slog.Any("err", err))
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	send := func(v any) error {
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		return nil
+	}
+
+	err := c.PullModel(r.Context(), req.Model, func(p ollama.PullProgress) error { return send(p) })
+	if err != nil {
+		logger.Warn("pull model failed", slog.String("model", req.Model), slog.Any("err", err))
+		_ = send(map[string]string{"status": "error", "error": err.Error()})
+		return
+	}
+
+	// pick up the new model in the engine's fallback chain
+	if err := h.models.RefreshModels(r.Context()); err != nil {
+		logger.Warn("pull model: refresh models", slog.Any("err", err))
+	}
+	_ = send(map[string]string{"status": "success"})
+}
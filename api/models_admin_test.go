@@ -0,0 +1,128 @@
+package api_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/garnizeh/rag/api"
+	"github.com/garnizeh/rag/internal/config"
+	"github.com/garnizeh/rag/pkg/ollama"
+	"github.com/gorilla/mux"
+)
+
+type fakeModelManager struct {
+	client    *ollama.Client
+	refreshed int
+}
+
+func (f *fakeModelManager) Client() *ollama.Client { return f.client }
+
+func (f *fakeModelManager) RefreshModels(ctx context.Context) error {
+	f.refreshed++
+	return nil
+}
+
+func TestModelsAdmin(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			_, _ = w.Write([]byte(`{"models":[{"name":"library/llama3:8b","size":42,"details":{"family":"llama","quantization_level":"Q4_0"}}]}`))
+		case "/api/show":
+			var req struct {
+				Model string `json:"model"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			if req.Model != "library/llama3:8b" {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"error":"not found"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"details":{"family":"llama"},"model_info":{"llama.context_length":8192}}`))
+		case "/api/pull":
+			_, _ = w.Write([]byte("{\"status\":\"pulling manifest\"}\n{\"status\":\"success\"}\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := ollama.NewClient(config.OllamaConfig{BaseURL: srv.URL, Timeout: 2 * time.Second}, srv.Client())
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	defer client.Close()
+	mm := &fakeModelManager{client: client}
+	h := api.NewModelsAdminHandler(mm)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/v1/admin/models", h.ListModels).Methods("GET")
+	r.HandleFunc("/v1/admin/models/pull", h.PullModel).Methods("POST")
+	r.HandleFunc("/v1/admin/models/{name:.+}", h.GetModel).Methods("GET")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, authedRequest(http.MethodGet, "/v1/admin/models", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("list: expected 200 got %d", w.Code)
+	}
+	var list struct {
+		Items []map[string]any `json:"items"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(list.Items) != 1 || list.Items[0]["family"] != "llama" || list.Items[0]["quantization"] != "Q4_0" || list.Items[0]["size"] != float64(42) {
+		t.Fatalf("unexpected models %v", list.Items)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, authedRequest(http.MethodGet, "/v1/admin/models/library/llama3:8b", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"context_length":8192`) {
+		t.Fatalf("show: expected details, got %d %s", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, authedRequest(http.MethodGet, "/v1/admin/models/missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("show missing: expected 404 got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, authedRequest(http.MethodPost, "/v1/admin/models/pull", map[string]string{"model": " "}))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("pull without model: expected 400 got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, authedRequest(http.MethodPost, "/v1/admin/models/pull", map[string]string{"model": "qwen2:7b"}))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("pull: expected ndjson stream, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	var lines []map[string]any
+	sc := bufio.NewScanner(w.Body)
+	for sc.Scan() {
+		var m map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			t.Fatalf("decode progress line %q: %v", sc.Text(), err)
+		}
+		lines = append(lines, m)
+	}
+	if len(lines) != 3 || lines[0]["status"] != "pulling manifest" || lines[0]["endpoint"] != srv.URL || lines[2]["status"] != "success" {
+		t.Fatalf("unexpected progress %v", lines)
+	}
+	if mm.refreshed != 1 {
+		t.Fatalf("expected the model chain to be refreshed after a pull")
+	}
+
+	// degraded engine
+	mm.client = nil
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, authedRequest(http.MethodGet, "/v1/admin/models", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("degraded: expected 503 got %d", w.Code)
+	}
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"log/slog"
+
+	"github.com/garnizeh/rag/internal/ai"
+	"github.com/garnizeh/rag/internal/models"
+	"github.com/garnizeh/rag/pkg/repository"
+	"github.com/qri-io/jsonschema"
+)
+
+// ProfileHandler serves the authenticated engineer's structured profile.
+type ProfileHandler struct {
+	profileRepo repository.ProfileRepo
+	schemaRepo  repository.SchemaRepo
+}
+
+func NewProfileHandler(pr repository.ProfileRepo, sr repository.SchemaRepo) *ProfileHandler {
+	return &ProfileHandler{profileRepo: pr, schemaRepo: sr}
+}
+
+type profileResponse struct {
+	EngineerID int64 `json:"engineer_id"`
+	models.ProfileData
+	Updated int64 `json:"updated,omitempty"`
+}
+
+// GetProfile returns the structured profile; engineers without a stored profile get an empty one.
+func (h *ProfileHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	engineerID, ok := engineerIDFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	p, err := h.profileRepo.GetByEngineerID(r.Context(), engineerID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("get profile: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	resp := profileResponse{EngineerID: engineerID}
+	if p != nil {
+		data, err := ai.ParseProfile(p.Bio)
+		if err != nil {
+			logger.Warn("stored profile is not valid JSON", slog.Int64("engineer_id", engineerID), slog.Any("err", err))
+		} else {
+			resp.ProfileData = *data
+		}
+		resp.Updated = p.Updated
+	}
+
+	writeJSON(w, resp, http.StatusOK)
+}
+
+// PutProfile replaces the structured profile after validating it against the
+// profile JSON schema stored in ai_schemas.
+func (h *ProfileHandler) PutProfile(w http.ResponseWriter, r *http.Request) {
+	engineerID, ok := engineerIDFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	const maxSize = 64 * 1024
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSize+1))
+	if err != nil {
+		http.Error(w, "read body failed", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxSize {
+		http.Error(w, "profile too large", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := h.validate(r, body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var data models.ProfileData
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&data); err != nil {
+		http.Error(w, "invalid profile", http.StatusBadRequest)
+		return
+	}
+	data.Role = strings.TrimSpace(data.Role)
+	data.Team = strings.TrimSpace(data.Team)
+
+	bio, err := json.Marshal(data)
+	if err != nil {
+		http.Error(w, "encode profile failed", http.StatusInternalServerError)
+		return
+	}
+
+	p, err := h.profileRepo.GetByEngineerID(ctx, engineerID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("get profile: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if p == nil {
+		if _, err := h.profileRepo.CreateProfile(ctx, &models.Profile{EngineerID: engineerID, Bio: string(bio)}); err != nil {
+			http.Error(w, fmt.Sprintf("store profile: %v", err), http.StatusInternalServerError)
+			return
+		}
+	} else {
+		p.Bio = string(bio)
+		if err := h.profileRepo.UpdateProfile(ctx, p); err != nil {
+			http.Error(w, fmt.Sprintf("store profile: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writeJSON(w, profileResponse{EngineerID: engineerID, ProfileData: data}, http.StatusOK)
+}
+
+// validate checks body against the stored profile schema. A missing schema is
+// logged and tolerated so profiles keep working before seeds are applied; the
+// strict struct decode in PutProfile still rejects unknown fields.
+func (h *ProfileHandler) validate(r *http.Request, body []byte) error {
+	if h.schemaRepo == nil {
+		return nil
+	}
+
+	s, err := h.schemaRepo.GetSchemaByVersion(r.Context(), ai.ProfileSchemaVersion)
+	if err != nil {
+		return fmt.Errorf("load profile schema: %w", err)
+	}
+	if s == nil {
+		logger.Warn("profile schema not found; skipping schema validation", slog.String("version", ai.ProfileSchemaVersion))
+		return nil
+	}
+
+	rs := &jsonschema.Schema{}
+	if err := json.Unmarshal([]byte(s.SchemaJSON), rs); err != nil {
+		return fmt.Errorf("compile profile schema: %w", err)
+	}
+
+	verrs, err := rs.ValidateBytes(r.Context(), body)
+	if err != nil {
+		return fmt.Errorf("invalid profile: %w", err)
+	}
+	if len(verrs) > 0 {
+		msgs := make([]string, 0, len(verrs))
+		for _, v := range verrs {
+			msgs = append(msgs, fmt.Sprintf("%s: %s", v.PropertyPath, v.Message))
+		}
+		return fmt.Errorf("profile does not match schema: %s", strings.Join(msgs, "; "))
+	}
+
+	return nil
+}
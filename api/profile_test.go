@@ -0,0 +1,103 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/garnizeh/rag/api"
+	"github.com/garnizeh/rag/internal/ai"
+	"github.com/garnizeh/rag/internal/models"
+	"github.com/garnizeh/rag/pkg/repository/mock"
+)
+
+// seedSchemaRepo serves the profile schema shipped in db/seed.
+type seedSchemaRepo struct {
+	schema string
+}
+
+func (s *seedSchemaRepo) CreateSchema(ctx context.Context, version, description, schemaJSON string) (int64, error) {
+	return 1, nil
+}
+
+func (s *seedSchemaRepo) GetSchemaByVersion(ctx context.Context, version string) (*models.Schema, error) {
+	if version != ai.ProfileSchemaVersion {
+		return nil, nil
+	}
+	return &models.Schema{Version: version, SchemaJSON: s.schema}, nil
+}
+
+func (s *seedSchemaRepo) ListSchemas(ctx context.Context) ([]models.Schema, error) { return nil, nil }
+
+func (s *seedSchemaRepo) DeleteSchema(ctx context.Context, version string) error { return nil }
+
+func newProfileFixture(t *testing.T) (*api.ProfileHandler, *mock.Mocks) {
+	t.Helper()
+	b, err := os.ReadFile("../db/seed/schema_profile_v1.json")
+	if err != nil {
+		t.Fatalf("read profile schema: %v", err)
+	}
+	m := mock.NewMocks()
+	m.ProfRepo.Stored = &models.Profile{ID: 1, EngineerID: 1, Bio: "{}"}
+	return api.NewProfileHandler(m.ProfRepo, &seedSchemaRepo{schema: string(b)}), m
+}
+
+func TestProfile_GetEmptyThenPut(t *testing.T) {
+	h, m := newProfileFixture(t)
+
+	w := httptest.NewRecorder()
+	h.GetProfile(w, authedRequest(http.MethodGet, "/v1/profile", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("get: expected 200 got %d body=%s", w.Code, w.Body.String())
+	}
+
+	body := map[string]any{"role": "SRE", "team": "platform", "skills": []string{"Terraform", "Go"}, "goals": []string{"Learn Rust"}}
+	w = httptest.NewRecorder()
+	h.PutProfile(w, authedRequest(http.MethodPut, "/v1/profile", body))
+	if w.Code != http.StatusOK {
+		t.Fatalf("put: expected 200 got %d body=%s", w.Code, w.Body.String())
+	}
+
+	stored, err := ai.ParseProfile(m.ProfRepo.Stored.Bio)
+	if err != nil {
+		t.Fatalf("stored bio is not a profile: %v", err)
+	}
+	if stored.Role != "SRE" || len(stored.Skills) != 2 {
+		t.Fatalf("unexpected stored profile: %#v", stored)
+	}
+
+	w = httptest.NewRecorder()
+	h.GetProfile(w, authedRequest(http.MethodGet, "/v1/profile", nil))
+	var got map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got["team"] != "platform" {
+		t.Fatalf("expected team in response, got %v", got)
+	}
+}
+
+func TestProfile_PutRejectsSchemaViolations(t *testing.T) {
+	h, _ := newProfileFixture(t)
+
+	cases := []struct {
+		name string
+		body any
+	}{
+		{name: "UnknownField", body: map[string]any{"role": "dev", "salary": 1}},
+		{name: "WrongType", body: map[string]any{"skills": "Go"}},
+		{name: "EmptySkill", body: map[string]any{"skills": []string{""}}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.PutProfile(w, authedRequest(http.MethodPut, "/v1/profile", c.body))
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400 got %d body=%s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
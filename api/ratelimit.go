@@ -0,0 +1,283 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"log/slog"
+
+	"github.com/gorilla/mux"
+)
+
+// bucket is a single token bucket. Tokens refill continuously at the limiter rate.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter is an in-memory keyed token bucket limiter. It is safe for
+// concurrent use; idle buckets are swept periodically to bound memory.
+type RateLimiter struct {
+	rate  float64 // tokens per second
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewRateLimiter creates a limiter that allows `rate` requests per second per key
+// with bursts up to `burst`. A non-positive rate returns nil, which disables limiting.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow consumes a token for key. When no token is available it returns false
+// and how long the caller should wait before retrying.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	} else {
+		b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+		b.last = now
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep drops buckets that have been idle long enough to be full again.
+// Must be called with l.mu held.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for k, b := range l.buckets {
+		if now.Sub(b.last) > full {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// RateLimitMiddleware rejects requests with 429 and a Retry-After header when
+// the bucket selected by key is empty. A nil limiter passes everything through.
+func RateLimitMiddleware(l *RateLimiter, key func(*http.Request) string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if l == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			k := key(r)
+			if ok, wait := l.Allow(k); !ok {
+				logger.Warn("rate limited", slog.String("key", k), slog.String("path", r.URL.Path))
+				writeTooManyRequests(w, wait)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// KeyByIP keys requests on the client IP address.
+func KeyByIP(r *http.Request) string {
+	return "ip:" + clientIP(r)
+}
+
+// KeyByEngineer keys requests on the authenticated engineer, falling back to
+// the client IP for anonymous requests.
+func KeyByEngineer(r *http.Request) string {
+	if id, ok := engineerIDFromContext(r); ok {
+		return "eng:" + strconv.FormatInt(id, 10)
+	}
+	return KeyByIP(r)
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func writeTooManyRequests(w http.ResponseWriter, wait time.Duration) {
+	secs := int(math.Ceil(wait.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+}
+
+// maxTrackedAccounts bounds LoginGuard memory: stale entries are pruned first,
+// then the least recently failed one is evicted.
+const maxTrackedAccounts = 10000
+
+type loginState struct {
+	failures    int
+	lockedUntil time.Time
+	last        time.Time
+}
+
+// LoginGuard tracks consecutive failed sign-ins per account and client IP and
+// locks that pair temporarily once maxFailures is reached. Keying on the IP
+// too means bad passwords sent from one source cannot lock the owner out from
+// another; guessing from many sources is left to the per-IP rate limit.
+type LoginGuard struct {
+	maxFailures int
+	lockout     time.Duration
+
+	mu    sync.Mutex
+	state map[string]*loginState
+	now   func() time.Time
+}
+
+// NewLoginGuard returns a guard locking an account and IP for `lockout` after
+// maxFailures consecutive failures. A non-positive maxFailures returns nil,
+// which disables lockout.
+func NewLoginGuard(maxFailures int, lockout time.Duration) *LoginGuard {
+	if maxFailures <= 0 {
+		return nil
+	}
+	if lockout <= 0 {
+		lockout = 15 * time.Minute
+	}
+	return &LoginGuard{maxFailures: maxFailures, lockout: lockout, state: make(map[string]*loginState), now: time.Now}
+}
+
+// Locked reports whether the account is locked for ip and for how long.
+func (g *LoginGuard) Locked(account, ip string) (bool, time.Duration) {
+	if g == nil {
+		return false, 0
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	s, ok := g.state[loginKey(account, ip)]
+	if !ok {
+		return false, 0
+	}
+	if wait := s.lockedUntil.Sub(g.now()); wait > 0 {
+		return true, wait
+	}
+	return false, 0
+}
+
+// Fail records a failed attempt from ip and locks the account for ip when the
+// threshold is hit.
+func (g *LoginGuard) Fail(account, ip string) {
+	if g == nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	key := loginKey(account, ip)
+	s, ok := g.state[key]
+	if !ok {
+		if len(g.state) >= maxTrackedAccounts {
+			g.prune(now)
+		}
+		if len(g.state) >= maxTrackedAccounts {
+			g.evictOldest()
+		}
+		s = &loginState{}
+		g.state[key] = s
+	}
+	// failures older than the lockout window no longer count
+	if now.Sub(s.last) > g.lockout {
+		s.failures = 0
+	}
+	s.failures++
+	s.last = now
+	if s.failures >= g.maxFailures {
+		s.lockedUntil = now.Add(g.lockout)
+		s.failures = 0
+		logger.Warn("account locked after repeated sign-in failures", slog.String("account", normalizeAccount(account)), slog.String("ip", ip), slog.Duration("lockout", g.lockout))
+	}
+}
+
+// Reset clears failure tracking for account and ip after a successful sign-in.
+func (g *LoginGuard) Reset(account, ip string) {
+	if g == nil {
+		return
+	}
+
+	g.mu.Lock()
+	delete(g.state, loginKey(account, ip))
+	g.mu.Unlock()
+}
+
+// prune forgets accounts whose failures and lockout have both expired.
+// Must be called with g.mu held.
+func (g *LoginGuard) prune(now time.Time) {
+	for k, s := range g.state {
+		if now.After(s.lockedUntil) && now.Sub(s.last) > g.lockout {
+			delete(g.state, k)
+		}
+	}
+}
+
+// evictOldest forgets the entry whose last failure is oldest, locked or not,
+// so the map stays bounded when every entry is still active.
+// Must be called with g.mu held.
+func (g *LoginGuard) evictOldest() {
+	var (
+		oldest string
+		at     time.Time
+	)
+	for k, s := range g.state {
+		if oldest == "" || s.last.Before(at) {
+			oldest, at = k, s.last
+		}
+	}
+	delete(g.state, oldest)
+}
+
+func loginKey(account, ip string) string {
+	return normalizeAccount(account) + "|" + ip
+}
+
+func normalizeAccount(account string) string {
+	return strings.ToLower(strings.TrimSpace(account))
+}
@@ -0,0 +1,136 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/garnizeh/rag/api"
+	"github.com/garnizeh/rag/internal/models"
+	"github.com/garnizeh/rag/pkg/repository/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRateLimiter_AllowsBurstThenRejects(t *testing.T) {
+	l := api.NewRateLimiter(1, 2)
+
+	for i := range 2 {
+		if ok, _ := l.Allow("k"); !ok {
+			t.Fatalf("request %d within burst was rejected", i+1)
+		}
+	}
+	ok, wait := l.Allow("k")
+	if ok {
+		t.Fatalf("expected request over burst to be rejected")
+	}
+	if wait <= 0 || wait > time.Second {
+		t.Fatalf("unexpected wait %v", wait)
+	}
+
+	// other keys have their own bucket
+	if ok, _ := l.Allow("other"); !ok {
+		t.Fatalf("expected independent bucket per key")
+	}
+
+	// nil limiter never limits
+	var disabled *api.RateLimiter
+	if ok, _ := disabled.Allow("k"); !ok {
+		t.Fatalf("nil limiter should allow")
+	}
+}
+
+func TestRateLimitMiddleware_Returns429WithRetryAfter(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	handler := api.RateLimitMiddleware(api.NewRateLimiter(0.5, 1), api.KeyByIP)(next)
+
+	req := httptest.NewRequest(http.MethodGet, "/x", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("first request: expected 200 got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: expected 429 got %d", w.Code)
+	}
+	ra, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	if err != nil || ra < 1 {
+		t.Fatalf("expected positive Retry-After, got %q", w.Header().Get("Retry-After"))
+	}
+
+	// a different client IP is not affected
+	req2 := httptest.NewRequest(http.MethodGet, "/x", nil)
+	req2.RemoteAddr = "10.0.0.2:1234"
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req2)
+	if w.Code != http.StatusOK {
+		t.Fatalf("other ip: expected 200 got %d", w.Code)
+	}
+}
+
+func TestSignin_LocksAccountAfterRepeatedFailures(t *testing.T) {
+	m := mock.NewMocks()
+	hash, _ := bcrypt.GenerateFromPassword([]byte("rightpass1"), bcrypt.MinCost)
+	m.EngRepo.Stored = &models.Engineer{ID: 7, Email: "eve@example.com", PasswordHash: string(hash)}
+	h := api.NewAuthHandler(m.EngRepo, m.ProfRepo, "secret", time.Hour, api.NewLoginGuard(3, time.Minute))
+
+	signin := func(pw string, ip ...string) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(map[string]string{"email": "eve@example.com", "password": pw})
+		req := httptest.NewRequest(http.MethodPost, "/signin", bytes.NewReader(b))
+		if len(ip) > 0 {
+			req.RemoteAddr = ip[0] + ":1234"
+		}
+		w := httptest.NewRecorder()
+		h.Signin(w, req)
+		return w
+	}
+
+	for i := range 3 {
+		if w := signin("wrong"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("failure %d: expected 401 got %d", i+1, w.Code)
+		}
+	}
+
+	// locked: even the right password is rejected until the lockout expires
+	w := signin("rightpass1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 while locked got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected Retry-After header while locked")
+	}
+
+	// the lockout only applies to the client that failed: the owner signing
+	// in from elsewhere is not locked out by someone guessing their password
+	if w := signin("rightpass1", "10.0.0.9"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 from another client got %d", w.Code)
+	}
+}
+
+func TestLoginGuard_EvictsOldestWhenFull(t *testing.T) {
+	g := api.NewLoginGuard(1, time.Hour)
+	// fill the guard (maxTrackedAccounts) with entries that are all locked
+	const tracked = 10000
+	for i := range tracked {
+		g.Fail(fmt.Sprintf("user%d@example.com", i), "10.0.0.1")
+	}
+	g.Fail("new@example.com", "10.0.0.1")
+
+	if locked, _ := g.Locked("user0@example.com", "10.0.0.1"); locked {
+		t.Fatal("expected the oldest entry to be evicted")
+	}
+	for _, account := range []string{"user1@example.com", "new@example.com"} {
+		if locked, _ := g.Locked(account, "10.0.0.1"); !locked {
+			t.Fatalf("expected %s to stay locked", account)
+		}
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/garnizeh/rag/internal/ai"
 	"github.com/garnizeh/rag/internal/config"
 	"github.com/garnizeh/rag/internal/db"
+	"github.com/garnizeh/rag/internal/notify"
+	"github.com/garnizeh/rag/internal/privacy"
 	"github.com/garnizeh/rag/pkg/repository"
 	"github.com/gorilla/mux"
 )
@@ -20,22 +22,48 @@ func SetupRoutes(
 	repo repository.Repository,
 	aiEngine *ai.Engine,
 	database *db.DB,
+	notifier notify.Notifier,
+	redactor *privacy.Redactor,
 	logger *slog.Logger,
 ) *mux.Router {
 	r := mux.NewRouter()
 
+	// Rate limiters; nil limiters are pass-through when limiting is disabled
+	var ipLimiter, accountLimiter, aiLimiter *RateLimiter
+	var loginGuard *LoginGuard
+	if !cfg.RateLimit.Disabled {
+		ipLimiter = NewRateLimiter(cfg.RateLimit.IPRate, cfg.RateLimit.IPBurst)
+		accountLimiter = NewRateLimiter(cfg.RateLimit.AccountRate, cfg.RateLimit.AccountBurst)
+		aiLimiter = NewRateLimiter(cfg.RateLimit.AIRate, cfg.RateLimit.AIBurst)
+		loginGuard = NewLoginGuard(cfg.RateLimit.LoginMaxFailures, cfg.RateLimit.LoginLockout)
+	}
+	aiLimit := RateLimitMiddleware(aiLimiter, KeyByEngineer)
+
 	// Middleware chain
 	r.Use(LoggingMiddleware)
+	r.Use(MetricsMiddleware)
 	r.Use(CORSMiddleware)
 	r.Use(RecoveryMiddleware)
+	r.Use(RateLimitMiddleware(ipLimiter, KeyByIP))
 
 	// Install logger for package-level helpers and middleware
 	SetLogger(logger)
 
 	// Create handlers
 	systemHandler := NewSystemHandler(version, buildTime, database, aiEngine)
-	authHandler := NewAuthHandler(repo.Engineer, repo.Profile, cfg.JWTSecret, cfg.TokenDuration)
-	activitiesHandler := NewActivitiesHandler(repo.Activity, repo.Job)
+	authHandler := NewAuthHandler(repo.Engineer, repo.Profile, cfg.JWTSecret, cfg.TokenDuration, loginGuard)
+	accountHandler := NewAccountHandler(repo.Engineer, repo.Profile, repo.Reset, notifier, cfg.PasswordResetTTL)
+	profileHandler := NewProfileHandler(repo.Profile, repo.Schema)
+	teamsHandler := NewTeamsHandler(repo.Team, repo.Engineer, repo.Context)
+	// per-engineer daily LLM quotas; nil when none are configured
+	quota := ai.NewQuota(repo.LLMUsage, cfg.LLMUsage)
+	activitiesHandler := NewActivitiesHandler(repo.Activity, repo.Job, redactor, quota)
+	jobsHandler := NewJobsHandler(repo.Job)
+	jobsAdminHandler := NewJobsAdminHandler(repo.Job)
+	schedulesAdminHandler := NewSchedulesAdminHandler(repo.Schedule)
+	modelsAdminHandler := NewModelsAdminHandler(aiEngine)
+	llmCacheAdminHandler := NewLLMCacheAdminHandler(repo.LLMCache)
+	llmUsageHandler := NewLLMUsageHandler(repo.LLMUsage, quota)
 	aiHandler := NewAIHandler(aiEngine, repo.Schema, repo.Template, repo.Context)
 
 	// Open endpoints
@@ -43,22 +71,57 @@ func SetupRoutes(
 	r.HandleFunc("/health", systemHandler.HealthHandler).Methods("GET")
 	r.HandleFunc("/ready", systemHandler.ReadinessHandler).Methods("GET")
 	r.HandleFunc("/live", systemHandler.LiveHandler).Methods("GET")
+	// scraped by Prometheus; guarded by metrics_token when one is configured
+	r.Handle("/metrics", MetricsHandler(cfg.MetricsToken)).Methods("GET")
 	r.HandleFunc("/v1/auth/signup", authHandler.Signup).Methods("POST")
 	r.HandleFunc("/v1/auth/signin", authHandler.Signin).Methods("POST")
+	r.HandleFunc("/v1/auth/password/forgot", accountHandler.ForgotPassword).Methods("POST")
+	r.HandleFunc("/v1/auth/password/reset", accountHandler.ResetPassword).Methods("POST")
 
 	// API v1 Protected routes
 	apiV1 := r.PathPrefix("/v1").Subrouter()
 	apiV1.Use(JWTAuthMiddlewareWithSecret(cfg.JWTSecret))
+	apiV1.Use(RateLimitMiddleware(accountLimiter, KeyByEngineer))
 
 	// Auth endpoints
 	authV1 := apiV1.PathPrefix("/auth").Subrouter()
 	authV1.HandleFunc("/signout", authHandler.Signout).Methods("POST")
 
+	// Account endpoints (the authenticated engineer)
+	meV1 := apiV1.PathPrefix("/me").Subrouter()
+	meV1.HandleFunc("", accountHandler.GetMe).Methods("GET")
+	meV1.HandleFunc("", accountHandler.UpdateMe).Methods("PATCH")
+	meV1.HandleFunc("", accountHandler.DeleteMe).Methods("DELETE")
+	meV1.HandleFunc("/password", accountHandler.ChangePassword).Methods("POST")
+	meV1.HandleFunc("/llm-usage", llmUsageHandler.MyUsage).Methods("GET")
+
+	// Profile endpoints
+	profileV1 := apiV1.PathPrefix("/profile").Subrouter()
+	profileV1.HandleFunc("", profileHandler.GetProfile).Methods("GET")
+	profileV1.HandleFunc("", profileHandler.PutProfile).Methods("PUT")
+
+	// Team endpoints; team-scoped routes enforce membership in the handler
+	teamsV1 := apiV1.PathPrefix("/teams").Subrouter()
+	teamsV1.HandleFunc("", teamsHandler.CreateTeam).Methods("POST")
+	teamsV1.HandleFunc("", teamsHandler.ListTeams).Methods("GET")
+	teamsV1.HandleFunc("/{team_id}", teamsHandler.GetTeam).Methods("GET")
+	teamsV1.HandleFunc("/{team_id}", teamsHandler.DeleteTeam).Methods("DELETE")
+	teamsV1.HandleFunc("/{team_id}/members", teamsHandler.ListMembers).Methods("GET")
+	teamsV1.HandleFunc("/{team_id}/members", teamsHandler.AddMember).Methods("POST")
+	teamsV1.HandleFunc("/{team_id}/members/{engineer_id}", teamsHandler.RemoveMember).Methods("DELETE")
+	teamsV1.HandleFunc("/{team_id}/context", teamsHandler.GetTeamContext).Methods("GET")
+	teamsV1.HandleFunc("/{team_id}/experts", teamsHandler.FindExperts).Methods("GET")
+
 	// Activities endpoints
 	activitiesV1 := apiV1.PathPrefix("/activities").Subrouter()
-	activitiesV1.HandleFunc("", activitiesHandler.CreateActivity).Methods("POST")
+	// creating an activity enqueues an LLM analysis job, so it shares the AI budget
+	activitiesV1.Handle("", aiLimit(http.HandlerFunc(activitiesHandler.CreateActivity))).Methods("POST")
 	activitiesV1.HandleFunc("", activitiesHandler.ListActivities).Methods("GET")
 
+	// Job status endpoints; engineers can only see their own jobs
+	jobsV1 := apiV1.PathPrefix("/jobs").Subrouter()
+	jobsV1.HandleFunc("/{id:[0-9]+}", jobsHandler.GetJob).Methods("GET")
+
 	// AI management endpoints
 	aiV1 := apiV1.PathPrefix("/ai").Subrouter()
 
@@ -68,7 +131,7 @@ func SetupRoutes(
 	schemaV1.HandleFunc("", aiHandler.CreateOrUpdateSchemaHandler).Methods("POST")
 	schemaV1.HandleFunc("/get", aiHandler.GetSchemaHandler).Methods("GET")
 	schemaV1.HandleFunc("/delete", aiHandler.DeleteSchemaHandler).Methods("DELETE")
-	schemaV1.HandleFunc("/reload", aiHandler.ReloadHandler).Methods("POST")
+	schemaV1.Handle("/reload", aiLimit(http.HandlerFunc(aiHandler.ReloadHandler))).Methods("POST")
 
 	// AI template endpoints
 	templateV1 := aiV1.PathPrefix("/templates").Subrouter()
@@ -81,6 +144,35 @@ func SetupRoutes(
 	contextV1 := aiV1.PathPrefix("/context").Subrouter()
 	contextV1.HandleFunc("/rollback/{engineer_id}", aiHandler.RollbackContextHandler).Methods("POST")
 
+	// Admin endpoints: restricted to the configured admin_emails
+	adminV1 := apiV1.PathPrefix("/admin").Subrouter()
+	adminV1.Use(AdminMiddleware(repo.Engineer, cfg.AdminEmails))
+
+	jobsAdminV1 := adminV1.PathPrefix("/jobs").Subrouter()
+	jobsAdminV1.HandleFunc("", jobsAdminHandler.ListJobs).Methods("GET")
+	jobsAdminV1.HandleFunc("/purge", jobsAdminHandler.PurgeJobs).Methods("POST")
+	jobsAdminV1.HandleFunc("/dead", jobsAdminHandler.ListDeadLetters).Methods("GET")
+	jobsAdminV1.HandleFunc("/dead/{id:[0-9]+}", jobsAdminHandler.GetDeadLetter).Methods("GET")
+	jobsAdminV1.HandleFunc("/dead/{id:[0-9]+}/requeue", jobsAdminHandler.RequeueDeadLetter).Methods("POST")
+	jobsAdminV1.HandleFunc("/{id:[0-9]+}", jobsAdminHandler.GetJob).Methods("GET")
+	jobsAdminV1.HandleFunc("/{id:[0-9]+}/cancel", jobsAdminHandler.CancelJob).Methods("POST")
+
+	schedulesAdminV1 := adminV1.PathPrefix("/schedules").Subrouter()
+	schedulesAdminV1.HandleFunc("", schedulesAdminHandler.ListSchedules).Methods("GET")
+	schedulesAdminV1.HandleFunc("", schedulesAdminHandler.CreateSchedule).Methods("POST")
+	schedulesAdminV1.HandleFunc("/{id:[0-9]+}", schedulesAdminHandler.GetSchedule).Methods("GET")
+	schedulesAdminV1.HandleFunc("/{id:[0-9]+}", schedulesAdminHandler.UpdateSchedule).Methods("PATCH")
+	schedulesAdminV1.HandleFunc("/{id:[0-9]+}", schedulesAdminHandler.DeleteSchedule).Methods("DELETE")
+
+	// model names may contain '/' (namespaced models), so the name matches the rest of the path
+	modelsAdminV1 := adminV1.PathPrefix("/models").Subrouter()
+	modelsAdminV1.HandleFunc("", modelsAdminHandler.ListModels).Methods("GET")
+	modelsAdminV1.HandleFunc("/pull", modelsAdminHandler.PullModel).Methods("POST")
+	modelsAdminV1.HandleFunc("/{name:.+}", modelsAdminHandler.GetModel).Methods("GET")
+
+	adminV1.HandleFunc("/llm-cache/purge", llmCacheAdminHandler.PurgeLLMCache).Methods("POST")
+	adminV1.HandleFunc("/llm-usage", llmUsageHandler.UsageReport).Methods("GET")
+
 	return r
 }
 
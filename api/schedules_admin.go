@@ -0,0 +1,238 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/garnizeh/rag/internal/jobs"
+	"github.com/garnizeh/rag/internal/models"
+	"github.com/garnizeh/rag/pkg/repository"
+)
+
+// SchedulesAdminHandler manages recurring job schedules. Routes are mounted
+// behind AdminMiddleware.
+type SchedulesAdminHandler struct {
+	scheduleRepo repository.ScheduleRepo
+}
+
+func NewSchedulesAdminHandler(sr repository.ScheduleRepo) *SchedulesAdminHandler {
+	return &SchedulesAdminHandler{scheduleRepo: sr}
+}
+
+type createScheduleRequest struct {
+	Name        string          `json:"name"`
+	Spec        string          `json:"spec"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	Priority    *int            `json:"priority"`
+	MaxAttempts int             `json:"max_attempts"`
+	Enabled     *bool           `json:"enabled"`
+}
+
+// updateScheduleRequest changes only the fields that are present.
+type updateScheduleRequest struct {
+	Spec        *string         `json:"spec"`
+	Type        *string         `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	Priority    *int            `json:"priority"`
+	MaxAttempts *int            `json:"max_attempts"`
+	Enabled     *bool           `json:"enabled"`
+}
+
+// nextRun validates spec and returns its first run after now.
+func nextRun(spec string) (time.Time, error) {
+	sched, err := jobs.ParseSchedule(spec)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := sched.Next(time.Now())
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("spec %q never fires", spec)
+	}
+
+	return next, nil
+}
+
+// ListSchedules lists every recurring job schedule.
+func (h *SchedulesAdminHandler) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	items, err := h.scheduleRepo.ListSchedules(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("list schedules: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if items == nil {
+		items = []models.JobSchedule{}
+	}
+
+	writeJSON(w, map[string]any{"items": items}, http.StatusOK)
+}
+
+// CreateSchedule registers a recurring job. Schedules are enabled unless
+// "enabled": false is given.
+func (h *SchedulesAdminHandler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	var req createScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" || len(req.Name) > 128 || req.Type == "" {
+		http.Error(w, "name (max 128 chars) and type are required", http.StatusBadRequest)
+		return
+	}
+	if req.MaxAttempts < 0 {
+		http.Error(w, "max_attempts must not be negative", http.StatusBadRequest)
+		return
+	}
+	next, err := nextRun(req.Spec)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid spec: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	s := &models.JobSchedule{
+		Name:        req.Name,
+		Spec:        req.Spec,
+		Type:        req.Type,
+		Payload:     req.Payload,
+		Priority:    100,
+		MaxAttempts: req.MaxAttempts,
+		Enabled:     req.Enabled == nil || *req.Enabled,
+		NextRunAt:   next,
+	}
+	if len(s.Payload) == 0 {
+		s.Payload = json.RawMessage(`{}`)
+	}
+	if req.Priority != nil {
+		s.Priority = *req.Priority
+	}
+
+	id, err := h.scheduleRepo.CreateSchedule(r.Context(), s)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("create schedule: %v", err), http.StatusConflict)
+		return
+	}
+
+	created, err := h.scheduleRepo.GetSchedule(r.Context(), id)
+	if err != nil || created == nil {
+		http.Error(w, fmt.Sprintf("get schedule: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, created, http.StatusCreated)
+}
+
+// GetSchedule returns a single schedule with its next and last run.
+func (h *SchedulesAdminHandler) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.schedule(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, s, http.StatusOK)
+}
+
+// UpdateSchedule edits a schedule. Changing the spec or re-enabling a
+// schedule recomputes its next run from now, so runs missed while it was
+// disabled are not enqueued.
+func (h *SchedulesAdminHandler) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.schedule(w, r)
+	if !ok {
+		return
+	}
+
+	var req updateScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	reschedule := false
+	if req.Spec != nil && *req.Spec != s.Spec {
+		s.Spec = *req.Spec
+		reschedule = true
+	}
+	if req.Enabled != nil {
+		reschedule = reschedule || (*req.Enabled && !s.Enabled)
+		s.Enabled = *req.Enabled
+	}
+	if req.Type != nil {
+		if *req.Type == "" {
+			http.Error(w, "type must not be empty", http.StatusBadRequest)
+			return
+		}
+		s.Type = *req.Type
+	}
+	if len(req.Payload) > 0 {
+		s.Payload = req.Payload
+	}
+	if req.Priority != nil {
+		s.Priority = *req.Priority
+	}
+	if req.MaxAttempts != nil {
+		if *req.MaxAttempts <= 0 {
+			http.Error(w, "max_attempts must be positive", http.StatusBadRequest)
+			return
+		}
+		s.MaxAttempts = *req.MaxAttempts
+	}
+	if reschedule {
+		next, err := nextRun(s.Spec)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid spec: %v", err), http.StatusBadRequest)
+			return
+		}
+		s.NextRunAt = next
+	}
+
+	if err := h.scheduleRepo.UpdateSchedule(r.Context(), s); err != nil {
+		http.Error(w, fmt.Sprintf("update schedule: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, s, http.StatusOK)
+}
+
+// DeleteSchedule removes a schedule; jobs it already enqueued are kept.
+func (h *SchedulesAdminHandler) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	id, ok := idFromVars(r)
+	if !ok {
+		http.Error(w, "invalid schedule id", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := h.scheduleRepo.DeleteSchedule(r.Context(), id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("delete schedule: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.Error(w, "schedule not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// schedule loads the schedule named by the {id} route variable, writing the
+// error response itself when it cannot.
+func (h *SchedulesAdminHandler) schedule(w http.ResponseWriter, r *http.Request) (*models.JobSchedule, bool) {
+	id, ok := idFromVars(r)
+	if !ok {
+		http.Error(w, "invalid schedule id", http.StatusBadRequest)
+		return nil, false
+	}
+
+	s, err := h.scheduleRepo.GetSchedule(r.Context(), id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("get schedule: %v", err), http.StatusInternalServerError)
+		return nil, false
+	}
+	if s == nil {
+		http.Error(w, "schedule not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	return s, true
+}
@@ -0,0 +1,91 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/garnizeh/rag/api"
+	"github.com/garnizeh/rag/internal/db"
+	"github.com/garnizeh/rag/internal/models"
+	sqlite "github.com/garnizeh/rag/internal/repository/sqlite"
+	"github.com/gorilla/mux"
+)
+
+func TestSchedulesAdmin_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	d, err := db.New(ctx, "file:schedules_admin?mode=memory&cache=shared", nil)
+	if err != nil {
+		t.Fatalf("db.New: %v", err)
+	}
+	defer d.Close()
+	if _, err := d.Exec(ctx, `CREATE TABLE job_schedules (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL UNIQUE, spec TEXT NOT NULL, type TEXT NOT NULL, payload TEXT, priority INTEGER NOT NULL DEFAULT 100, max_attempts INTEGER NOT NULL DEFAULT 5, enabled INTEGER NOT NULL DEFAULT 1, next_run_at INTEGER NOT NULL, last_run_at INTEGER, last_job_id INTEGER, created INTEGER NOT NULL, updated INTEGER NOT NULL);`); err != nil {
+		t.Fatalf("setup schema: %v", err)
+	}
+	h := api.NewSchedulesAdminHandler(sqlite.New(d, nil))
+
+	w := httptest.NewRecorder()
+	h.CreateSchedule(w, authedRequest(http.MethodPost, "/v1/admin/schedules", map[string]any{"name": "bad", "spec": "61 * * * *", "type": "report"}))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid spec: expected 400 got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.CreateSchedule(w, authedRequest(http.MethodPost, "/v1/admin/schedules", map[string]any{"name": "digest", "spec": "0 8 * * 1-5", "type": "digest.weekday"}))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201 got %d body=%s", w.Code, w.Body.String())
+	}
+	var created models.JobSchedule
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !created.Enabled || created.Priority != 100 || created.NextRunAt.UTC().Hour() != 8 || !created.NextRunAt.After(time.Now()) {
+		t.Fatalf("unexpected created schedule: %+v", created)
+	}
+
+	w = httptest.NewRecorder()
+	h.CreateSchedule(w, authedRequest(http.MethodPost, "/v1/admin/schedules", map[string]any{"name": "digest", "spec": "@hourly", "type": "digest.weekday"}))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("duplicate: expected 409 got %d", w.Code)
+	}
+
+	vars := map[string]string{"id": "1"}
+	w = httptest.NewRecorder()
+	h.UpdateSchedule(w, mux.SetURLVars(authedRequest(http.MethodPatch, "/v1/admin/schedules/1", map[string]any{"spec": "@every 5m", "enabled": false}), vars))
+	if w.Code != http.StatusOK {
+		t.Fatalf("update: expected 200 got %d body=%s", w.Code, w.Body.String())
+	}
+	var updated models.JobSchedule
+	if err := json.NewDecoder(w.Body).Decode(&updated); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if updated.Enabled || updated.Spec != "@every 5m" || updated.NextRunAt.After(time.Now().Add(6*time.Minute)) {
+		t.Fatalf("unexpected updated schedule: %+v", updated)
+	}
+
+	w = httptest.NewRecorder()
+	h.ListSchedules(w, authedRequest(http.MethodGet, "/v1/admin/schedules", nil))
+	var list struct {
+		Items []models.JobSchedule `json:"items"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Enabled {
+		t.Fatalf("expected the disabled schedule, got %+v", list.Items)
+	}
+
+	w = httptest.NewRecorder()
+	h.DeleteSchedule(w, mux.SetURLVars(authedRequest(http.MethodDelete, "/v1/admin/schedules/1", nil), vars))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("delete: expected 204 got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	h.GetSchedule(w, mux.SetURLVars(authedRequest(http.MethodGet, "/v1/admin/schedules/1", nil), vars))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("get deleted: expected 404 got %d", w.Code)
+	}
+}
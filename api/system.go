@@ -7,6 +7,7 @@ import (
 
 	"github.com/garnizeh/rag/internal/ai"
 	"github.com/garnizeh/rag/internal/db"
+	"github.com/garnizeh/rag/pkg/ollama"
 )
 
 type SystemHandler struct {
@@ -46,8 +47,9 @@ func (h *SystemHandler) VersionHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // ReadinessHandler returns readiness of the application considering DB and AI availability.
-// DB must be reachable for the service to be considered ready. AI being unavailable marks
-// the service as degraded but does not make it unready.
+// DB must be reachable for the service to be considered ready. AI being unavailable, or
+// every Ollama circuit being open or half-open, marks the service as degraded but does not
+// make it unready. The circuit state of each Ollama endpoint is listed under "ollama".
 func (h *SystemHandler) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
 	status := "ready"
 	details := map[string]string{}
@@ -67,11 +69,20 @@ func (h *SystemHandler) ReadinessHandler(w http.ResponseWriter, r *http.Request)
 		status = "unready"
 	}
 
-	if h.ai == nil {
-		details["ai"] = "degraded"
-		// AI degraded does not change overall readiness; keep status as-is
-	} else {
-		details["ai"] = "ok"
+	// AI degraded does not change overall readiness; keep status as-is. With a
+	// client, AI is ok while at least one endpoint's circuit is closed.
+	details["ai"] = "degraded"
+	var endpoints []ollama.EndpointStatus
+	if h.ai != nil {
+		if c := h.ai.Client(); c != nil {
+			endpoints = c.Endpoints()
+			for _, ep := range endpoints {
+				if ep.State == ollama.CircuitClosed {
+					details["ai"] = "ok"
+					break
+				}
+			}
+		}
 	}
 
 	resp := map[string]any{
@@ -79,6 +90,9 @@ func (h *SystemHandler) ReadinessHandler(w http.ResponseWriter, r *http.Request)
 		"service": "rag",
 		"details": details,
 	}
+	if endpoints != nil {
+		resp["ollama"] = endpoints
+	}
 
 	if status == "ready" {
 		writeJSON(w, resp, http.StatusOK)
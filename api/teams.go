@@ -0,0 +1,343 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"log/slog"
+
+	"github.com/garnizeh/rag/internal/ai"
+	"github.com/garnizeh/rag/internal/models"
+	"github.com/garnizeh/rag/pkg/repository"
+	"github.com/gorilla/mux"
+)
+
+// TeamsHandler manages teams, their membership and the aggregated team context.
+// Every team-scoped endpoint requires the caller to be a member of the team;
+// membership changes require the owner or lead role.
+type TeamsHandler struct {
+	teamRepo     repository.TeamRepo
+	engineerRepo repository.EngineerRepo
+	contextRepo  repository.ContextRepo
+}
+
+func NewTeamsHandler(tr repository.TeamRepo, er repository.EngineerRepo, cr repository.ContextRepo) *TeamsHandler {
+	return &TeamsHandler{teamRepo: tr, engineerRepo: er, contextRepo: cr}
+}
+
+type createTeamRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+type addMemberRequest struct {
+	EngineerID int64  `json:"engineer_id,omitempty"`
+	Email      string `json:"email,omitempty"`
+	Role       string `json:"role,omitempty"`
+}
+
+// membership resolves the {team_id} route variable and the caller's membership.
+// It writes the error response itself and returns nil when the request cannot continue.
+// Non-members get 404 so team existence is not disclosed.
+func (h *TeamsHandler) membership(w http.ResponseWriter, r *http.Request) *models.TeamMember {
+	engineerID, ok := engineerIDFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil
+	}
+
+	teamID, err := strconv.ParseInt(mux.Vars(r)["team_id"], 10, 64)
+	if err != nil || teamID <= 0 {
+		http.Error(w, "invalid team_id", http.StatusBadRequest)
+		return nil
+	}
+
+	m, err := h.teamRepo.GetMember(r.Context(), teamID, engineerID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("get membership: %v", err), http.StatusInternalServerError)
+		return nil
+	}
+	if m == nil {
+		http.Error(w, "team not found", http.StatusNotFound)
+		return nil
+	}
+
+	return m
+}
+
+func canManage(m *models.TeamMember) bool {
+	return m.Role == models.TeamRoleOwner || m.Role == models.TeamRoleLead
+}
+
+// CreateTeam creates a team owned by the caller.
+func (h *TeamsHandler) CreateTeam(w http.ResponseWriter, r *http.Request) {
+	engineerID, ok := engineerIDFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req createTeamRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 128 {
+		http.Error(w, "name is required (max 128 chars)", http.StatusBadRequest)
+		return
+	}
+
+	t := &models.Team{Name: req.Name, Description: strings.TrimSpace(req.Description), CreatedBy: engineerID}
+	id, err := h.teamRepo.CreateTeam(r.Context(), t)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("create team: %v", err), http.StatusConflict)
+		return
+	}
+	t.ID = id
+
+	writeJSON(w, t, http.StatusCreated)
+}
+
+// ListTeams returns the teams the caller belongs to.
+func (h *TeamsHandler) ListTeams(w http.ResponseWriter, r *http.Request) {
+	engineerID, ok := engineerIDFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	teams, err := h.teamRepo.ListTeamsByEngineer(r.Context(), engineerID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("list teams: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if teams == nil {
+		teams = []models.Team{}
+	}
+
+	writeJSON(w, teams, http.StatusOK)
+}
+
+// GetTeam returns a team the caller belongs to.
+func (h *TeamsHandler) GetTeam(w http.ResponseWriter, r *http.Request) {
+	m := h.membership(w, r)
+	if m == nil {
+		return
+	}
+
+	t, err := h.teamRepo.GetTeam(r.Context(), m.TeamID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("get team: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if t == nil {
+		http.Error(w, "team not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, t, http.StatusOK)
+}
+
+// DeleteTeam removes a team; only the owner may do so.
+func (h *TeamsHandler) DeleteTeam(w http.ResponseWriter, r *http.Request) {
+	m := h.membership(w, r)
+	if m == nil {
+		return
+	}
+	if m.Role != models.TeamRoleOwner {
+		http.Error(w, "only the team owner can delete the team", http.StatusForbidden)
+		return
+	}
+
+	if err := h.teamRepo.DeleteTeam(r.Context(), m.TeamID); err != nil {
+		http.Error(w, fmt.Sprintf("delete team: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListMembers returns the members of a team the caller belongs to.
+func (h *TeamsHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	m := h.membership(w, r)
+	if m == nil {
+		return
+	}
+
+	members, err := h.teamRepo.ListMembers(r.Context(), m.TeamID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("list members: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if members == nil {
+		members = []models.TeamMember{}
+	}
+
+	writeJSON(w, members, http.StatusOK)
+}
+
+// AddMember adds an engineer (by id or email) to the team. Owners and leads may
+// add members; only the owner may grant the lead or owner role.
+func (h *TeamsHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	m := h.membership(w, r)
+	if m == nil {
+		return
+	}
+	if !canManage(m) {
+		http.Error(w, "only team owners and leads can add members", http.StatusForbidden)
+		return
+	}
+
+	var req addMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	switch req.Role {
+	case "":
+		req.Role = models.TeamRoleMember
+	case models.TeamRoleMember:
+	case models.TeamRoleLead, models.TeamRoleOwner:
+		if m.Role != models.TeamRoleOwner {
+			http.Error(w, "only the team owner can grant this role", http.StatusForbidden)
+			return
+		}
+	default:
+		http.Error(w, "invalid role", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	var target *models.Engineer
+	var err error
+	switch {
+	case req.EngineerID > 0:
+		target, err = h.engineerRepo.GetByID(ctx, req.EngineerID)
+	case strings.TrimSpace(req.Email) != "":
+		target, err = h.engineerRepo.GetByEmail(ctx, strings.TrimSpace(req.Email))
+	default:
+		http.Error(w, "engineer_id or email required", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("get engineer: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if target == nil {
+		http.Error(w, "engineer not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.teamRepo.AddMember(ctx, m.TeamID, target.ID, req.Role); err != nil {
+		http.Error(w, fmt.Sprintf("add member: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, models.TeamMember{TeamID: m.TeamID, EngineerID: target.ID, Role: req.Role, Name: target.Name, Email: target.Email}, http.StatusCreated)
+}
+
+// RemoveMember removes an engineer from the team. Members may remove
+// themselves; owners and leads may remove others. The owner cannot be removed.
+func (h *TeamsHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	m := h.membership(w, r)
+	if m == nil {
+		return
+	}
+
+	targetID, err := strconv.ParseInt(mux.Vars(r)["engineer_id"], 10, 64)
+	if err != nil || targetID <= 0 {
+		http.Error(w, "invalid engineer_id", http.StatusBadRequest)
+		return
+	}
+	if targetID != m.EngineerID && !canManage(m) {
+		http.Error(w, "only team owners and leads can remove members", http.StatusForbidden)
+		return
+	}
+
+	ctx := r.Context()
+	target, err := h.teamRepo.GetMember(ctx, m.TeamID, targetID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("get member: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if target == nil {
+		http.Error(w, "member not found", http.StatusNotFound)
+		return
+	}
+	if target.Role == models.TeamRoleOwner {
+		http.Error(w, "the team owner cannot be removed", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.teamRepo.RemoveMember(ctx, m.TeamID, targetID); err != nil {
+		http.Error(w, fmt.Sprintf("remove member: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// teamContext aggregates the stored contexts of the team's members.
+func (h *TeamsHandler) teamContext(r *http.Request, teamID int64) (*ai.TeamContext, error) {
+	ctx := r.Context()
+	members, err := h.teamRepo.ListMembers(ctx, teamID)
+	if err != nil {
+		return nil, fmt.Errorf("list members: %w", err)
+	}
+
+	inputs := make([]ai.MemberContext, 0, len(members))
+	for _, m := range members {
+		ctxJSON, _, err := h.contextRepo.GetEngineerContext(ctx, m.EngineerID)
+		if err != nil {
+			logger.Warn("team context: load member context failed", slog.Int64("team_id", teamID), slog.Int64("engineer_id", m.EngineerID), slog.Any("err", err))
+		}
+		inputs = append(inputs, ai.MemberContext{EngineerID: m.EngineerID, Name: m.Name, ContextJSON: ctxJSON})
+	}
+
+	return ai.AggregateTeamContext(teamID, inputs), nil
+}
+
+// GetTeamContext returns the team context document aggregated from members' contexts.
+func (h *TeamsHandler) GetTeamContext(w http.ResponseWriter, r *http.Request) {
+	m := h.membership(w, r)
+	if m == nil {
+		return
+	}
+
+	tc, err := h.teamContext(r, m.TeamID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, tc, http.StatusOK)
+}
+
+// FindExperts answers "who on the team knows X" from members' contexts.
+// Expects ?topic=...
+func (h *TeamsHandler) FindExperts(w http.ResponseWriter, r *http.Request) {
+	m := h.membership(w, r)
+	if m == nil {
+		return
+	}
+
+	topic := strings.TrimSpace(r.URL.Query().Get("topic"))
+	if topic == "" {
+		http.Error(w, "topic required", http.StatusBadRequest)
+		return
+	}
+
+	tc, err := h.teamContext(r, m.TeamID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]any{"team_id": m.TeamID, "topic": topic, "experts": ai.FindExperts(tc, topic)}, http.StatusOK)
+}
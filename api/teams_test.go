@@ -0,0 +1,191 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/garnizeh/rag/api"
+	"github.com/garnizeh/rag/internal/ai"
+	"github.com/garnizeh/rag/internal/models"
+	"github.com/garnizeh/rag/pkg/repository/mock"
+	"github.com/gorilla/mux"
+)
+
+// fakeTeamRepo keeps a single team's membership in memory.
+type fakeTeamRepo struct {
+	team    *models.Team
+	members map[int64]string
+}
+
+func (f *fakeTeamRepo) CreateTeam(ctx context.Context, t *models.Team) (int64, error) {
+	f.team = &models.Team{ID: 7, Name: t.Name, Description: t.Description, CreatedBy: t.CreatedBy}
+	f.members = map[int64]string{t.CreatedBy: models.TeamRoleOwner}
+	return 7, nil
+}
+
+func (f *fakeTeamRepo) GetTeam(ctx context.Context, id int64) (*models.Team, error) {
+	if f.team != nil && f.team.ID == id {
+		return f.team, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeTeamRepo) ListTeamsByEngineer(ctx context.Context, engineerID int64) ([]models.Team, error) {
+	if _, ok := f.members[engineerID]; ok && f.team != nil {
+		return []models.Team{*f.team}, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeTeamRepo) DeleteTeam(ctx context.Context, id int64) error {
+	f.team, f.members = nil, nil
+	return nil
+}
+
+func (f *fakeTeamRepo) AddMember(ctx context.Context, teamID, engineerID int64, role string) error {
+	f.members[engineerID] = role
+	return nil
+}
+
+func (f *fakeTeamRepo) RemoveMember(ctx context.Context, teamID, engineerID int64) error {
+	delete(f.members, engineerID)
+	return nil
+}
+
+func (f *fakeTeamRepo) GetMember(ctx context.Context, teamID, engineerID int64) (*models.TeamMember, error) {
+	if f.team == nil || f.team.ID != teamID {
+		return nil, nil
+	}
+	role, ok := f.members[engineerID]
+	if !ok {
+		return nil, nil
+	}
+	return &models.TeamMember{TeamID: teamID, EngineerID: engineerID, Role: role}, nil
+}
+
+func (f *fakeTeamRepo) ListMembers(ctx context.Context, teamID int64) ([]models.TeamMember, error) {
+	var out []models.TeamMember
+	for id, role := range f.members {
+		out = append(out, models.TeamMember{TeamID: teamID, EngineerID: id, Role: role, Name: "eng"})
+	}
+	return out, nil
+}
+
+// teamContextRepo serves fixed per-engineer contexts.
+type teamContextRepo struct {
+	fakeContextRepo
+	contexts map[int64]string
+}
+
+func (f *teamContextRepo) GetEngineerContext(ctx context.Context, engineerID int64) (string, int64, error) {
+	return f.contexts[engineerID], 1, nil
+}
+
+func teamRequest(method, path string, body any, engineerID int64, vars map[string]string) *http.Request {
+	req := authedRequest(method, path, body)
+	req = req.WithContext(context.WithValue(req.Context(), api.CtxEngineerID, engineerID))
+	return mux.SetURLVars(req, vars)
+}
+
+func newTeamsFixture(t *testing.T) (*api.TeamsHandler, *fakeTeamRepo, *mock.Mocks) {
+	t.Helper()
+	m := mock.NewMocks()
+	m.EngRepo.Stored = &models.Engineer{ID: 2, Name: "Bob", Email: "bob@example.com"}
+	tr := &fakeTeamRepo{}
+	cr := &teamContextRepo{contexts: map[int64]string{
+		1: `{"technologies":["Go"]}`,
+		2: `{"technologies":["Terraform","Go"],"projects":["terraform-modules"]}`,
+	}}
+	h := api.NewTeamsHandler(tr, m.EngRepo, cr)
+
+	w := httptest.NewRecorder()
+	h.CreateTeam(w, authedRequest(http.MethodPost, "/v1/teams", map[string]string{"name": "platform"}))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create team: expected 201 got %d body=%s", w.Code, w.Body.String())
+	}
+	return h, tr, m
+}
+
+func TestTeams_MembershipAndExperts(t *testing.T) {
+	h, tr, _ := newTeamsFixture(t)
+	vars := map[string]string{"team_id": "7"}
+
+	// non-member cannot see the team
+	w := httptest.NewRecorder()
+	h.GetTeam(w, teamRequest(http.MethodGet, "/v1/teams/7", nil, 2, vars))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("non-member get: expected 404 got %d", w.Code)
+	}
+
+	// owner adds Bob by email
+	w = httptest.NewRecorder()
+	h.AddMember(w, teamRequest(http.MethodPost, "/v1/teams/7/members", map[string]string{"email": "bob@example.com"}, 1, vars))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("add member: expected 201 got %d body=%s", w.Code, w.Body.String())
+	}
+	if tr.members[2] != models.TeamRoleMember {
+		t.Fatalf("expected bob to be a member, got %q", tr.members[2])
+	}
+
+	// plain member cannot add others or delete the team
+	w = httptest.NewRecorder()
+	h.AddMember(w, teamRequest(http.MethodPost, "/v1/teams/7/members", map[string]any{"engineer_id": 1}, 2, vars))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("member add: expected 403 got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	h.DeleteTeam(w, teamRequest(http.MethodDelete, "/v1/teams/7", nil, 2, vars))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("member delete: expected 403 got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.FindExperts(w, teamRequest(http.MethodGet, "/v1/teams/7/experts?topic=terraform", nil, 2, vars))
+	if w.Code != http.StatusOK {
+		t.Fatalf("experts: expected 200 got %d body=%s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Experts []ai.Expert `json:"experts"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Experts) != 1 || resp.Experts[0].EngineerID != 2 {
+		t.Fatalf("expected bob as the only terraform expert, got %+v", resp.Experts)
+	}
+
+	// the owner cannot be removed
+	w = httptest.NewRecorder()
+	h.RemoveMember(w, teamRequest(http.MethodDelete, "/v1/teams/7/members/1", nil, 1, map[string]string{"team_id": "7", "engineer_id": "1"}))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("remove owner: expected 400 got %d", w.Code)
+	}
+
+	// members may leave on their own
+	w = httptest.NewRecorder()
+	h.RemoveMember(w, teamRequest(http.MethodDelete, "/v1/teams/7/members/2", nil, 2, map[string]string{"team_id": "7", "engineer_id": "2"}))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("leave: expected 204 got %d", w.Code)
+	}
+}
+
+func TestTeams_OnlyOwnerGrantsLead(t *testing.T) {
+	h, tr, _ := newTeamsFixture(t)
+	vars := map[string]string{"team_id": "7"}
+	tr.members[3] = models.TeamRoleLead
+
+	w := httptest.NewRecorder()
+	h.AddMember(w, teamRequest(http.MethodPost, "/v1/teams/7/members", map[string]any{"engineer_id": 2, "role": "lead"}, 3, vars))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("lead granting lead: expected 403 got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.AddMember(w, teamRequest(http.MethodPost, "/v1/teams/7/members", map[string]any{"engineer_id": 2, "role": "bogus"}, 1, vars))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid role: expected 400 got %d", w.Code)
+	}
+}
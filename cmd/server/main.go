@@ -20,6 +20,8 @@ import (
 	"github.com/garnizeh/rag/internal/db"
 	"github.com/garnizeh/rag/internal/jobs"
 	"github.com/garnizeh/rag/internal/models"
+	"github.com/garnizeh/rag/internal/notify"
+	"github.com/garnizeh/rag/internal/privacy"
 	"github.com/garnizeh/rag/internal/repository/sqlite"
 	"github.com/garnizeh/rag/pkg/ollama"
 	"github.com/garnizeh/rag/pkg/repository"
@@ -76,14 +78,19 @@ func main() {
 	// Repository
 	sqliteRepo := sqlite.New(database, logger)
 	repo := repository.Repository{
-		Engineer:      sqliteRepo,
-		Profile:       sqliteRepo,
-		Activity:      sqliteRepo,
-		Question:      sqliteRepo,
-		Job:           sqliteRepo,
-		Context:       sqliteRepo,
-		Schema:        sqliteRepo,
-		Template:      sqliteRepo,
+		Engineer: sqliteRepo,
+		Profile:  sqliteRepo,
+		Activity: sqliteRepo,
+		Question: sqliteRepo,
+		Job:      sqliteRepo,
+		Schedule: sqliteRepo,
+		Context:  sqliteRepo,
+		Schema:   sqliteRepo,
+		Template: sqliteRepo,
+		Reset:    sqliteRepo,
+		Team:     sqliteRepo,
+		LLMCache: sqliteRepo,
+		LLMUsage: sqliteRepo,
 	}
 
 	// Ollama client
@@ -120,6 +127,27 @@ func main() {
 		logger.Error("Failed to initialize AI engine", slog.Any("err", err))
 		os.Exit(1)
 	}
+	// PII redaction applied to everything sent to the LLM and to activities shown to others
+	redactor, err := privacy.NewRedactor(cfg.Privacy)
+	if err != nil {
+		logger.Error("Invalid privacy configuration", slog.Any("err", err))
+		os.Exit(1)
+	}
+	aiEngine.SetRedactor(redactor)
+	// fall back through ollama.models when engine.model is missing or failing
+	aiEngine.SetFallbackModels(cfg.Ollama.DefaultModelNames)
+	// repeated generations are served from llm_cache unless llm_cache.disabled
+	aiEngine.SetCache(ai.NewResponseCache(sqliteRepo, cfg.LLMCache))
+	// every analysis is accounted in llm_calls for usage reports and quotas
+	aiEngine.SetUsageRepo(sqliteRepo)
+	if client != nil {
+		modelsCtx, modelsCancel := context.WithTimeout(rootCtx, cfg.Ollama.Timeout)
+		if err := aiEngine.RefreshModels(modelsCtx); err != nil {
+			logger.Warn("Ollama model check failed", slog.Any("err", err))
+		}
+		modelsCancel()
+		logger.Info("AI model chain", slog.Any("models", aiEngine.Models()))
+	}
 	// propagate logger into AI subsystem for consistent structured logs
 	ai.SetLogger(logger)
 	// ensure processor logs are wired
@@ -132,29 +160,47 @@ func main() {
 	aiEngine.StartOllamaProbe(probeCtx, cfg.Ollama)
 	defer probeCancel()
 
-	handler := api.SetupRoutes(cfg, version, buildTime, repo, aiEngine, database, logger)
+	// Notifications (password reset tokens, ...) are logged until a delivery channel is configured
+	notifier := notify.NewLogNotifier(logger)
+
+	handler := api.SetupRoutes(cfg, version, buildTime, repo, aiEngine, database, notifier, redactor, logger)
 
 	// Start background worker pool for jobs, including AI processing handler
 	handlers := map[string]jobs.Handler{
-		"ai.process_response": func(ctx context.Context, j *models.BackgroundJob) error {
+		// analysis of a shared activity, queued by POST /v1/activities
+		ai.AnalyzeActivityJob: ai.AnalyzeActivityHandler(aiEngine, &repo),
+		"ai.process_response": func(ctx context.Context, j *models.BackgroundJob) (any, error) {
 			var pl struct {
 				EngineerID int64           `json:"engineer_id"`
 				Response   json.RawMessage `json:"response"`
 			}
+			// a payload that does not decode now never will
 			if err := json.Unmarshal(j.Payload, &pl); err != nil {
-				return err
+				return nil, jobs.Permanent(err)
 			}
 			var resp ai.AIResponse
 			if err := json.Unmarshal(pl.Response, &resp); err != nil {
-				return err
+				return nil, jobs.Permanent(err)
+			}
+			version, err := ai.ProcessAIResponse(ctx, &repo, pl.EngineerID, &resp)
+			if err != nil {
+				return nil, err
 			}
-			_, err := ai.ProcessAIResponse(ctx, &repo, pl.EngineerID, &resp)
-			return err
+			return map[string]int64{"context_version": version}, nil
 		},
 	}
-	pool := jobs.NewWorkerPool(sqliteRepo, handlers, logger, 4)
+	pool := jobs.NewWorkerPool(sqliteRepo, handlers, logger, cfg.Jobs.Workers)
+	pool.Configure(cfg.Jobs)
 	pool.Start(rootCtx)
 
+	// Enqueue recurring jobs; safe to run on every instance sharing the database
+	scheduler := jobs.NewScheduler(sqliteRepo, logger, cfg.Jobs.ScheduleInterval)
+	scheduler.Start(rootCtx)
+
+	// Stored job counts for the metrics endpoint, recounted in the background
+	queueMetrics := jobs.NewQueueMetrics(sqliteRepo, logger, cfg.Jobs.MetricsInterval)
+	queueMetrics.Start(rootCtx)
+
 	// Create HTTP server
 	server := &http.Server{
 		Addr:         cfg.Addr,
@@ -188,7 +234,10 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Stop worker pool
+	// Stop claiming jobs and drain in-flight ones; jobs still running after
+	// jobs.drain_timeout are interrupted and requeued
+	scheduler.Stop()
+	queueMetrics.Stop()
 	pool.Stop()
 
 	// Close database connection
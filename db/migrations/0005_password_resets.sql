@@ -0,0 +1,13 @@
+-- Migration: add password reset tokens for the account recovery flow
+
+CREATE TABLE IF NOT EXISTS password_resets (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  engineer_id INTEGER NOT NULL,
+  token_hash TEXT NOT NULL UNIQUE, -- sha256 of the token delivered to the user
+  expires_at INTEGER NOT NULL,
+  used_at INTEGER,
+  created INTEGER NOT NULL,
+  FOREIGN KEY(engineer_id) REFERENCES engineers(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_resets_engineer ON password_resets(engineer_id);
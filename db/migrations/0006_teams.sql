@@ -0,0 +1,23 @@
+-- Migration: add teams and team membership for shared context
+
+CREATE TABLE IF NOT EXISTS teams (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name TEXT NOT NULL UNIQUE,
+  description TEXT,
+  created_by INTEGER NOT NULL,
+  created INTEGER NOT NULL,
+  updated INTEGER NOT NULL,
+  FOREIGN KEY(created_by) REFERENCES engineers(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS team_members (
+  team_id INTEGER NOT NULL,
+  engineer_id INTEGER NOT NULL,
+  role TEXT NOT NULL DEFAULT 'member', -- owner, lead or member
+  created INTEGER NOT NULL,
+  PRIMARY KEY(team_id, engineer_id),
+  FOREIGN KEY(team_id) REFERENCES teams(id) ON DELETE CASCADE,
+  FOREIGN KEY(engineer_id) REFERENCES engineers(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_team_members_engineer ON team_members(engineer_id);
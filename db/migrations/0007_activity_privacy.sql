@@ -0,0 +1,8 @@
+-- Migration: activity visibility and recorded PII redaction spans
+-- 'private' activities are owner-only and never sent to the LLM.
+-- redactions is a JSON array of redacted spans; NULL for rows written before this migration.
+
+ALTER TABLE raw_activities ADD COLUMN visibility TEXT NOT NULL DEFAULT 'shared';
+ALTER TABLE raw_activities ADD COLUMN redactions TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_activities_engineer_visibility ON raw_activities(engineer_id, visibility, created);
@@ -0,0 +1,8 @@
+-- Migration: claim-and-lease columns for background jobs
+-- A worker claims a job by setting status 'running', locked_by and lease_until (unix seconds)
+-- in one statement; running jobs whose lease has expired are reclaimed by other workers.
+
+ALTER TABLE jobs ADD COLUMN locked_by TEXT;
+ALTER TABLE jobs ADD COLUMN lease_until INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_jobs_status_lease ON jobs(status, lease_until);
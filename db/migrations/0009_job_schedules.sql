@@ -0,0 +1,22 @@
+-- Migration: recurring job schedules
+-- next_run_at and last_run_at are unix seconds. A scheduler enqueues a job when next_run_at
+-- is due and advances it in the same transaction, guarded on the previous value, so only one
+-- of several server instances sharing the database fires each run.
+
+CREATE TABLE IF NOT EXISTS job_schedules (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name TEXT NOT NULL UNIQUE,
+  spec TEXT NOT NULL, -- cron expression or "@every <duration>"
+  type TEXT NOT NULL,
+  payload TEXT,
+  priority INTEGER NOT NULL DEFAULT 100,
+  max_attempts INTEGER NOT NULL DEFAULT 5,
+  enabled INTEGER NOT NULL DEFAULT 1,
+  next_run_at INTEGER NOT NULL,
+  last_run_at INTEGER,
+  last_job_id INTEGER,
+  created INTEGER NOT NULL,
+  updated INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_schedules_due ON job_schedules(enabled, next_run_at);
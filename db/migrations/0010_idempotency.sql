@@ -0,0 +1,10 @@
+-- Migration: idempotency keys for activities and dedupe keys for background jobs
+-- An activity created with an Idempotency-Key header is stored once per engineer and key;
+-- retries of the same request return the original activity.
+-- Enqueueing a job whose dedupe_key matches a job that has not finished yet is a no-op.
+
+ALTER TABLE raw_activities ADD COLUMN idempotency_key TEXT;
+ALTER TABLE jobs ADD COLUMN dedupe_key TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_activities_idempotency ON raw_activities(engineer_id, idempotency_key) WHERE idempotency_key IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_dedupe_pending ON jobs(dedupe_key) WHERE dedupe_key IS NOT NULL AND status IN ('queued', 'retry', 'running');
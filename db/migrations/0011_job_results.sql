@@ -0,0 +1,11 @@
+-- Migration: job results and ownership
+-- result holds the JSON a handler returned for a finished job.
+-- engineer_id records who a job was created for, so they can poll its status;
+-- it is kept when the job is dead-lettered.
+
+ALTER TABLE jobs ADD COLUMN result TEXT;
+ALTER TABLE jobs ADD COLUMN engineer_id INTEGER;
+ALTER TABLE dead_letter_jobs ADD COLUMN engineer_id INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_jobs_dedupe_key ON jobs(dedupe_key) WHERE dedupe_key IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_dead_letter_jobs_job_id ON dead_letter_jobs(job_id);
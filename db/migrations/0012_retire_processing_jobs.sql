@@ -0,0 +1,20 @@
+-- Migration: retire the legacy processing_jobs table
+-- processing_jobs only ever held placeholder rows written next to the real jobs queue.
+-- Its rows are folded into jobs as type 'legacy.processing' so jobs is the single record of
+-- processing state: finished rows keep their status, anything else was never picked up by a
+-- worker and is marked canceled. processing_jobs.created is in unix milliseconds like jobs.created.
+
+INSERT INTO jobs(type, payload, status, scheduled_at, last_error, created, updated)
+SELECT
+  'legacy.processing',
+  json_object('processing_job_id', id),
+  CASE WHEN status IN ('done', 'failed') THEN status ELSE 'canceled' END,
+  created / 1000,
+  CASE WHEN status IN ('done', 'failed') THEN NULL ELSE 'retired legacy processing job (status ' || status || ')' END,
+  created,
+  created
+FROM processing_jobs
+ORDER BY id;
+
+DROP INDEX IF EXISTS idx_jobs_status_created;
+DROP TABLE IF EXISTS processing_jobs;
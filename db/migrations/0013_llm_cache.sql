@@ -0,0 +1,19 @@
+-- Migration: persistent cache of LLM responses
+-- key is the sha256 of the model, generation options and rendered prompt, so a repeated
+-- generation is served without calling Ollama. expires_at and last_used are in unix
+-- milliseconds; expired rows are removed first, then the least recently used ones, to keep
+-- the summed size under the configured cap.
+
+CREATE TABLE IF NOT EXISTS llm_cache (
+  key TEXT PRIMARY KEY,
+  model TEXT NOT NULL,
+  response TEXT NOT NULL, -- JSON encoded generate result
+  size INTEGER NOT NULL,
+  hits INTEGER NOT NULL DEFAULT 0,
+  expires_at INTEGER NOT NULL,
+  last_used INTEGER NOT NULL,
+  created INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_llm_cache_expires ON llm_cache(expires_at);
+CREATE INDEX IF NOT EXISTS idx_llm_cache_last_used ON llm_cache(last_used);
@@ -0,0 +1,23 @@
+-- Migration: per-call LLM usage accounting
+-- One row per analysis sent to the LLM, with the engineer it was made for, the template used,
+-- the model that answered and what it cost as reported by Ollama. outcome is ok, cached (served
+-- from llm_cache, no model time), invalid (the answer was rejected) or error (no answer).
+-- created is in unix milliseconds; usage reports and daily quotas aggregate over it.
+
+CREATE TABLE IF NOT EXISTS llm_calls (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  engineer_id INTEGER,
+  template_name TEXT NOT NULL,
+  template_version TEXT NOT NULL,
+  model TEXT NOT NULL,
+  outcome TEXT NOT NULL,
+  prompt_tokens INTEGER NOT NULL DEFAULT 0,
+  eval_tokens INTEGER NOT NULL DEFAULT 0,
+  duration_ms INTEGER NOT NULL DEFAULT 0,
+  error TEXT,
+  created INTEGER NOT NULL,
+  FOREIGN KEY(engineer_id) REFERENCES engineers(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_llm_calls_engineer_created ON llm_calls(engineer_id, created);
+CREATE INDEX IF NOT EXISTS idx_llm_calls_created ON llm_calls(created);
@@ -0,0 +1,8 @@
+-- Migration: keep how a job was enqueued when it is dead-lettered
+-- priority, max_attempts and dedupe_key are restored when a dead letter is requeued, so the job
+-- gets its own retry budget back and does not run next to a pending job with the same dedupe key.
+-- Rows dead-lettered before this migration get the jobs defaults.
+
+ALTER TABLE dead_letter_jobs ADD COLUMN priority INTEGER NOT NULL DEFAULT 100;
+ALTER TABLE dead_letter_jobs ADD COLUMN max_attempts INTEGER NOT NULL DEFAULT 5;
+ALTER TABLE dead_letter_jobs ADD COLUMN dedupe_key TEXT;
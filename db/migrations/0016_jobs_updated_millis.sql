@@ -0,0 +1,7 @@
+-- Migration: store jobs.created and jobs.updated in unix milliseconds
+-- UpdateJob used to write updated in unix seconds while Enqueue wrote created and updated in
+-- milliseconds, so finished rows from that time compared as decades old and any purge cutoff
+-- removed them. Values below 100000000000 (March 1973 in milliseconds) can only be seconds.
+
+UPDATE jobs SET updated = updated * 1000 WHERE updated < 100000000000;
+UPDATE jobs SET created = created * 1000 WHERE created < 100000000000;
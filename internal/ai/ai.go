@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/garnizeh/rag/internal/config"
 	"github.com/garnizeh/rag/internal/models"
+	"github.com/garnizeh/rag/internal/privacy"
 	"github.com/garnizeh/rag/pkg/ollama"
 	"github.com/garnizeh/rag/pkg/repository"
 )
@@ -30,6 +32,13 @@ type AIResponse struct {
 	Confidence    *float64 `json:"confidence,omitempty"`
 	ContextUpdate bool     `json:"context_update"`
 	Reasoning     string   `json:"reasoning"`
+	// Model is the model that produced the response, set by the engine
+	Model string `json:"model,omitempty"`
+	// ContextDropped lists the context left out of the prompt to fit the
+	// context window, set by the engine
+	ContextDropped []DroppedContext `json:"context_dropped,omitempty"`
+	// Cached is set when the model output came from the response cache
+	Cached bool `json:"cached,omitempty"`
 
 	// Raw captures the original model output for auditing/logging.
 	Raw string `json:"-"`
@@ -43,8 +52,31 @@ type Engine struct {
 	templateSchemaVersion *string
 	mu                    sync.RWMutex
 	client                *ollama.Client
+	redactor              *privacy.Redactor
+	// options are the generation options from the template metadata
+	options *ollama.GenerateOptions
+	// fallbacks are tried in order after cfg.Model when it is missing or failing
+	fallbacks []string
+	// available is the part of the model chain the server has, in chain order;
+	// nil until verified, in which case the whole chain is tried
+	available []string
+	// windows holds the context length the server reports per model
+	windows map[string]int
+	// cache serves repeated generations; nil disables caching
+	cache *ResponseCache
+	// usage records every analysis sent to the llm; nil disables accounting
+	usage repository.LLMUsageRepo
 }
 
+// activityTemplate is the name of the template activities are analysed with.
+const activityTemplate = "activity"
+
+const (
+	// defaultContextWindow matches Ollama's default num_ctx
+	defaultContextWindow  = 4096
+	defaultResponseTokens = 1024
+)
+
 // package logger for ai; can be set by callers via SetLogger
 var logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
 
@@ -67,6 +99,12 @@ func NewEngine(ctx context.Context, client *ollama.Client, cfg config.EngineConf
 	if cfg.MinConfidence <= 0 {
 		cfg.MinConfidence = 0.5
 	}
+	if cfg.ContextWindow <= 0 {
+		cfg.ContextWindow = defaultContextWindow
+	}
+	if cfg.ResponseTokens <= 0 {
+		cfg.ResponseTokens = defaultResponseTokens
+	}
 
 	if sr == nil {
 		return nil, fmt.Errorf("schema repo is required")
@@ -82,7 +120,7 @@ func NewEngine(ctx context.Context, client *ollama.Client, cfg config.EngineConf
 	}
 
 	// load template for activity from DB; fail if not present
-	tpl, terr := tr.GetTemplate(ctx, "activity", cfg.TemplateVersion)
+	tpl, terr := tr.GetTemplate(ctx, activityTemplate, cfg.TemplateVersion)
 	if terr != nil {
 		return nil, fmt.Errorf("load template: %w", terr)
 	}
@@ -99,24 +137,71 @@ func NewEngine(ctx context.Context, client *ollama.Client, cfg config.EngineConf
 	// persist template text by setting an internal field on Engine if needed; keep cfg minimal
 	// (Engine will read tpl.TemplateTxt when producing prompts)
 
-	eng := &Engine{client: client, cfg: cfg, loader: loader, templateText: tpl.TemplateTxt, templateSchemaVersion: templateSchema}
+	eng := &Engine{client: client, cfg: cfg, loader: loader, templateText: tpl.TemplateTxt, templateSchemaVersion: templateSchema, redactor: privacy.DefaultRedactor()}
+
+	// a bad options block must not take the engine down; the model defaults still work
+	opts, oerr := ParseTemplateOptions(tpl.Metadata)
+	if oerr != nil {
+		logger.Warn("ignoring invalid template generation options", slog.String("template", tpl.Name), slog.String("version", tpl.Version), slog.Any("err", oerr))
+	}
+	eng.options = opts
 
 	return eng, nil
 }
 
 // AnalyzeActivity renders a prompt for an activity, sends it to Ollama, and parses the structured response.
-func (e *Engine) AnalyzeActivity(ctx context.Context, activity models.Activity, contextText string) (*AIResponse, error) {
+// profile is optional; when present it is exposed to the template as .Profile.
+// Private activities are refused, and the activity, context sections and profile are
+// redacted before the prompt is rendered. The sections (see ContextSections) are trimmed to
+// the token budget of the models' context window and exposed to the template as
+// .Context; what had to be left out is reported in the response's ContextDropped.
+func (e *Engine) AnalyzeActivity(ctx context.Context, activity models.Activity, sections []ContextSection, profile *models.ProfileData) (_ *AIResponse, err error) {
+	if activity.Visibility == models.VisibilityPrivate {
+		return nil, fmt.Errorf("activity %d is private and cannot be sent to the llm", activity.ID)
+	}
+
 	// If client is nil we are running in degraded mode (no LLM). Return a clear error
 	// so callers can handle or fallback to raw processing. Use a read lock to allow
 	// a background probe to replace the client concurrently.
 	e.mu.RLock()
 	client := e.client
+	redactor := e.redactor
 	e.mu.RUnlock()
 	if client == nil {
 		return nil, fmt.Errorf("llm unavailable: engine running in degraded mode")
 	}
-	// prepare prompt
-	data := map[string]any{"Activity": activity, "Context": contextText}
+
+	// strip PII before anything reaches the model
+	var spans []models.Redaction
+	activity.Activity, spans = redactor.Redact(activity.Activity)
+	n := len(spans)
+	redacted := make([]ContextSection, len(sections))
+	for i, sec := range sections {
+		redacted[i] = ContextSection{Name: sec.Name, Priority: sec.Priority, Items: make([]string, len(sec.Items))}
+		for j, it := range sec.Items {
+			var itemSpans []models.Redaction
+			redacted[i].Items[j], itemSpans = redactor.Redact(it)
+			n += len(itemSpans)
+		}
+	}
+	profile, profileSpans := redactProfile(redactor, profile)
+	n += profileSpans
+	if n > 0 {
+		logger.Info("redacted prompt input", slog.Int64("activity_id", activity.ID), slog.Int("spans", n))
+	}
+
+	// prepare prompt: measure it without context, then fit the context into what is left
+	data := map[string]any{"Activity": activity, "Context": "", "Profile": profile}
+	base, err := ollama.RenderTemplate(e.templateText, data)
+	if err != nil {
+		return nil, fmt.Errorf("render template: %w", err)
+	}
+	assembly := AssembleContext(redacted, e.contextBudget(base))
+	if len(assembly.Dropped) > 0 {
+		logger.Warn("prompt context trimmed to fit the context window", slog.Int64("activity_id", activity.ID),
+			slog.Int("budget", assembly.Budget), slog.Any("dropped", assembly.Dropped))
+	}
+	data["Context"] = assembly.Text
 	prompt, err := ollama.RenderTemplate(e.templateText, data)
 	if err != nil {
 		return nil, fmt.Errorf("render template: %w", err)
@@ -126,8 +211,12 @@ func (e *Engine) AnalyzeActivity(ctx context.Context, activity models.Activity,
 	ctxReq, cancel := context.WithTimeout(ctx, e.cfg.Timeout)
 	defer cancel()
 
-	res, err := client.Generate(ctxReq, e.cfg.Model, prompt)
+	start := time.Now()
+	res, model, err := e.generate(ctxReq, client, prompt)
+	call := e.newCall(activity.EngineerID, model, res, time.Since(start))
+	defer func() { e.recordCall(ctx, call, err) }()
 	if err != nil {
+		call.Outcome = models.LLMCallError
 		return nil, fmt.Errorf("generate: %w", err)
 	}
 
@@ -140,6 +229,10 @@ func (e *Engine) AnalyzeActivity(ctx context.Context, activity models.Activity,
 	}
 	// store raw textual output for auditing
 	resp.Raw = res.Text
+	resp.Model = model
+	resp.ContextDropped = assembly.Dropped
+	cached, _ := res.Meta["cached"].(bool)
+	resp.Cached = cached
 
 	// fill missing version
 	if resp.Version == "" {
@@ -200,9 +293,239 @@ func (e *Engine) AnalyzeActivity(ctx context.Context, activity models.Activity,
 		resp.Entities.Technologies = []string{}
 	}
 
+	// only responses that passed validation are worth serving again
+	if !cached {
+		e.responseCache().put(ctx, CacheKey(model, prompt, e.options), model, res)
+	}
+
 	return resp, nil
 }
 
+// redactProfile returns a copy of p with PII masked in its free-text fields,
+// and the number of spans removed.
+func redactProfile(r *privacy.Redactor, p *models.ProfileData) (*models.ProfileData, int) {
+	if p == nil {
+		return nil, 0
+	}
+	n := 0
+	redact := func(s string) string {
+		out, spans := r.Redact(s)
+		n += len(spans)
+		return out
+	}
+	list := func(in []string) []string {
+		if in == nil {
+			return nil
+		}
+		out := make([]string, len(in))
+		for i, s := range in {
+			out[i] = redact(s)
+		}
+		return out
+	}
+	return &models.ProfileData{Role: redact(p.Role), Team: redact(p.Team), Skills: list(p.Skills), Goals: list(p.Goals)}, n
+}
+
+// generate sends prompt to the first model of the chain that answers. A
+// failing model is skipped in favor of the next one, and a model the server
+// reports missing is left out of later generations until RefreshModels; an
+// open circuit or an expired context ends the chain since no other model
+// would fare better. The joined errors keep the ollama error types, so
+// callers can tell retryable failures apart with ollama.IsRetryable. A
+// model's cached result is used before the model is asked.
+func (e *Engine) generate(ctx context.Context, client *ollama.Client, prompt string) (ollama.GenerateResult, string, error) {
+	cache := e.responseCache()
+	var errs []error
+	for _, model := range e.Models() {
+		if res, ok := cache.get(ctx, CacheKey(model, prompt, e.options)); ok {
+			return res, model, nil
+		}
+		res, err := client.GenerateWithOptions(ctx, model, prompt, e.options)
+		if err == nil {
+			return res, model, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", model, err))
+		if errors.Is(err, ollama.ErrCircuitOpen) || ctx.Err() != nil {
+			break
+		}
+		if errors.Is(err, ollama.ErrModelNotFound) {
+			e.dropModel(model)
+		}
+		logger.Warn("model failed, trying next in chain", slog.String("model", model), slog.Any("err", err))
+	}
+
+	return ollama.GenerateResult{}, "", errors.Join(errs...)
+}
+
+// dropModel removes model from the models generation tries. The last model
+// is kept so there is always something to try.
+func (e *Engine) dropModel(model string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	models := e.available
+	if len(models) == 0 {
+		models = e.modelChain()
+	}
+	rest := slices.DeleteFunc(slices.Clone(models), func(m string) bool { return sameModel(m, model) })
+	if len(rest) == 0 || len(rest) == len(models) {
+		return
+	}
+	e.available = rest
+	logger.Warn("model not found on ollama, skipping it until models are refreshed", slog.String("model", model))
+}
+
+// ContextWindow returns the prompt window in tokens: the template's num_ctx
+// or the configured window, lowered to the smallest context length reported
+// for the models generation may use, so the prompt fits any of them.
+func (e *Engine) ContextWindow() int {
+	w := e.cfg.ContextWindow
+	if e.options != nil && e.options.NumCtx != nil {
+		w = *e.options.NumCtx
+	}
+	models := e.Models()
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, m := range models {
+		if n, ok := e.windows[m]; ok && n > 0 && n < w {
+			w = n
+		}
+	}
+	return w
+}
+
+// contextBudget returns the tokens left for context in a prompt that is base
+// without context.
+func (e *Engine) contextBudget(base string) int {
+	return max(e.ContextWindow()-e.cfg.ResponseTokens-EstimateTokens(base), 0)
+}
+
+// templateMetadata is the part of a template's metadata the engine reads.
+type templateMetadata struct {
+	// Options are passed to every generate request made with the template
+	Options json.RawMessage `json:"options"`
+}
+
+// ParseTemplateOptions returns the generation options stored under "options"
+// in a template's metadata JSON object, or nil when there are none.
+func ParseTemplateOptions(metadata *string) (*ollama.GenerateOptions, error) {
+	if metadata == nil || strings.TrimSpace(*metadata) == "" {
+		return nil, nil
+	}
+	var md templateMetadata
+	if err := json.Unmarshal([]byte(*metadata), &md); err != nil {
+		return nil, fmt.Errorf("template metadata must be a JSON object: %w", err)
+	}
+	return ollama.ParseGenerateOptions(md.Options)
+}
+
+// Options returns the generation options of the loaded template, nil when it
+// has none.
+func (e *Engine) Options() *ollama.GenerateOptions {
+	return e.options
+}
+
+// SetFallbackModels sets the models tried, in order, after the engine model
+// when it is missing or failing. It resets the verified chain, so
+// RefreshModels should be called again afterwards.
+func (e *Engine) SetFallbackModels(names []string) {
+	e.mu.Lock()
+	e.fallbacks = slices.Clone(names)
+	e.available = nil
+	e.mu.Unlock()
+}
+
+// modelChain returns the engine model followed by the fallbacks, without duplicates.
+func (e *Engine) modelChain() []string {
+	chain := []string{e.cfg.Model}
+	for _, m := range e.fallbacks {
+		if m != "" && !slices.ContainsFunc(chain, func(c string) bool { return sameModel(c, m) }) {
+			chain = append(chain, m)
+		}
+	}
+	return chain
+}
+
+// Models returns the models generation tries, in order: the part of the chain
+// found on the server once verified, otherwise the whole configured chain.
+func (e *Engine) Models() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if len(e.available) > 0 {
+		return slices.Clone(e.available)
+	}
+	return e.modelChain()
+}
+
+// RefreshModels checks which models of the chain are installed on any of the
+// current client's endpoints. Missing models are skipped by later generations;
+// when none is present an error is returned and the whole chain keeps being
+// tried.
+func (e *Engine) RefreshModels(ctx context.Context) error {
+	e.mu.RLock()
+	client := e.client
+	e.mu.RUnlock()
+	if client == nil {
+		return fmt.Errorf("llm unavailable: engine running in degraded mode")
+	}
+
+	return e.verifyModels(ctx, client)
+}
+
+func (e *Engine) verifyModels(ctx context.Context, client *ollama.Client) error {
+	list, err := client.ListModels(ctx)
+	if err != nil {
+		return fmt.Errorf("list models: %w", err)
+	}
+
+	e.mu.RLock()
+	chain := e.modelChain()
+	e.mu.RUnlock()
+	var available, missing []string
+	for _, m := range chain {
+		if slices.ContainsFunc(list, func(info ollama.ModelInfo) bool { return sameModel(info.Name, m) }) {
+			available = append(available, m)
+		} else {
+			missing = append(missing, m)
+		}
+	}
+	// context lengths size the prompt budget; without one the configured window applies
+	windows := map[string]int{}
+	for _, m := range available {
+		d, err := client.ShowModel(ctx, m)
+		if err != nil {
+			logger.Warn("show model failed, using the configured context window", slog.String("model", m), slog.Any("err", err))
+			continue
+		}
+		windows[m] = int(d.ContextLength)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.available = available
+	e.windows = windows
+
+	if len(available) == 0 {
+		return fmt.Errorf("none of the configured models %v is available", chain)
+	}
+	if len(missing) > 0 {
+		logger.Warn("configured models missing on ollama", slog.Any("missing", missing), slog.String("using", available[0]))
+	}
+	return nil
+}
+
+// sameModel compares model names the way Ollama resolves them: a name without
+// a tag refers to its ":latest" tag.
+func sameModel(a, b string) bool {
+	withTag := func(s string) string {
+		if strings.Contains(s, ":") {
+			return s
+		}
+		return s + ":latest"
+	}
+	return withTag(a) == withTag(b)
+}
+
 func (e *Engine) ReloadSchemas(ctx context.Context) error {
 	return e.loader.Reload(ctx)
 }
@@ -221,6 +544,82 @@ func (e *Engine) SetClient(c *ollama.Client) {
 	}
 }
 
+// SetRedactor replaces the redactor applied to prompt inputs. Passing nil
+// disables redaction.
+func (e *Engine) SetRedactor(r *privacy.Redactor) {
+	e.mu.Lock()
+	e.redactor = r
+	e.mu.Unlock()
+}
+
+// SetCache replaces the cache generations are served from. Passing nil
+// disables caching.
+func (e *Engine) SetCache(c *ResponseCache) {
+	e.mu.Lock()
+	e.cache = c
+	e.mu.Unlock()
+}
+
+func (e *Engine) responseCache() *ResponseCache {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.cache
+}
+
+// SetUsageRepo sets where analyses sent to the llm are accounted. Passing
+// nil disables accounting.
+func (e *Engine) SetUsageRepo(r repository.LLMUsageRepo) {
+	e.mu.Lock()
+	e.usage = r
+	e.mu.Unlock()
+}
+
+// newCall describes one analysis for usage accounting. Cached answers cost no
+// model time; otherwise the cost is what Ollama reports, or the wall time
+// when it reports nothing.
+func (e *Engine) newCall(engineerID int64, model string, res ollama.GenerateResult, elapsed time.Duration) *models.LLMCall {
+	call := &models.LLMCall{TemplateName: activityTemplate, TemplateVersion: e.cfg.TemplateVersion, Model: model, Outcome: models.LLMCallOK}
+	if engineerID > 0 {
+		call.EngineerID = &engineerID
+	}
+	if model == "" {
+		call.Model = e.cfg.Model
+	}
+	if cached, _ := res.Meta["cached"].(bool); cached {
+		call.Outcome = models.LLMCallCached
+		return call
+	}
+	u := res.Usage()
+	call.PromptTokens, call.EvalTokens = u.PromptTokens, u.EvalTokens
+	call.DurationMS = u.Duration.Milliseconds()
+	if call.DurationMS == 0 {
+		call.DurationMS = elapsed.Milliseconds()
+	}
+	return call
+}
+
+// recordCall stores call with the outcome err implies: an error after the
+// model answered means the answer was rejected. Failures are logged only;
+// accounting must not fail an analysis.
+func (e *Engine) recordCall(ctx context.Context, call *models.LLMCall, err error) {
+	e.mu.RLock()
+	usage := e.usage
+	e.mu.RUnlock()
+	if usage == nil {
+		return
+	}
+	if err != nil {
+		if call.Outcome != models.LLMCallError {
+			call.Outcome = models.LLMCallInvalid
+		}
+		msg := err.Error()
+		call.Error = &msg
+	}
+	if _, rerr := usage.CreateLLMCall(context.WithoutCancel(ctx), call); rerr != nil {
+		logger.Warn("failed to record llm call", slog.String("outcome", call.Outcome), slog.Any("err", rerr))
+	}
+}
+
 // StartOllamaProbe runs a background goroutine that attempts to (re)create and
 // health-check an Ollama client when the engine is in degraded mode (no client).
 // The probe uses the provided Ollama config for connection parameters and
@@ -256,6 +655,9 @@ func (e *Engine) StartOllamaProbe(ctx context.Context, cfg config.OllamaConfig)
 					_ = c.Close()
 					continue
 				}
+				if verr := e.verifyModels(probeCtx, c); verr != nil {
+					logger.Warn("Ollama probe: model check failed", slog.Any("err", verr))
+				}
 				probeCancel()
 
 				// success: update engine client
@@ -266,6 +668,13 @@ func (e *Engine) StartOllamaProbe(ctx context.Context, cfg config.OllamaConfig)
 	}()
 }
 
+// Client returns the current Ollama client, or nil in degraded mode.
+func (e *Engine) Client() *ollama.Client {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.client
+}
+
 // Available reports whether an LLM client is currently configured.
 func (e *Engine) Available() bool {
 	e.mu.RLock()
@@ -2,7 +2,14 @@ package ai_test
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/garnizeh/rag/internal/ai"
 	"github.com/garnizeh/rag/internal/config"
@@ -115,6 +122,239 @@ Example:
 	// end
 }
 
+func TestAnalyzeActivity_RefusesPrivate(t *testing.T) {
+	ctx := context.Background()
+	e, err := ai.NewEngine(ctx, &ollama.Client{}, config.EngineConfig{Model: "m", TemplateVersion: "v1"}, newFakeSchemaRepo(), newFakeTemplateRepo("{{.Activity.Activity}}"))
+	if err != nil {
+		t.Fatalf("new engine failed: %v", err)
+	}
+
+	act := models.Activity{ID: 9, EngineerID: 1, Activity: "1:1 notes", Visibility: models.VisibilityPrivate}
+	if _, err := e.AnalyzeActivity(ctx, act, nil, nil); err == nil || !strings.Contains(err.Error(), "private") {
+		t.Fatalf("expected private activity to be refused, got %v", err)
+	}
+}
+
+func TestAnalyzeActivity_ModelFallback(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		requested []string
+	)
+	answer := `{"version":"v1","summary":"Deployed","entities":{"people":[],"projects":["svc"],"technologies":["Docker"]},"confidence":0.9,"context_update":true,"reasoning":"r"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			_, _ = w.Write([]byte(`{"models":[{"name":"flaky:latest"},{"name":"backup:latest"}]}`))
+		case "/api/generate":
+			var req struct {
+				Model string `json:"model"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			mu.Lock()
+			requested = append(requested, req.Model)
+			mu.Unlock()
+			if req.Model != "backup" {
+				http.Error(w, "model crashed", http.StatusInternalServerError)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"model": req.Model, "response": answer, "done": true})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client, err := ollama.NewClient(config.OllamaConfig{BaseURL: srv.URL, Timeout: 2 * time.Second, CircuitFailureThreshold: 5, CircuitReset: time.Minute}, srv.Client())
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	defer client.Close()
+
+	schemas := newFakeSchemaRepo()
+	if _, err := schemas.CreateSchema(ctx, "v1", "v1", `{"type":"object","required":["version","summary"]}`); err != nil {
+		t.Fatalf("seed schema: %v", err)
+	}
+	e, err := ai.NewEngine(ctx, client, config.EngineConfig{Model: "primary", TemplateVersion: "v1"}, schemas, newFakeTemplateRepo("{{.Activity.Activity}}"))
+	if err != nil {
+		t.Fatalf("new engine failed: %v", err)
+	}
+	e.SetFallbackModels([]string{"flaky", "primary", "backup"})
+
+	if got := e.Models(); !slices.Equal(got, []string{"primary", "flaky", "backup"}) {
+		t.Fatalf("unexpected configured chain %v", got)
+	}
+	if err := e.RefreshModels(ctx); err != nil {
+		t.Fatalf("refresh models: %v", err)
+	}
+	if got := e.Models(); !slices.Equal(got, []string{"flaky", "backup"}) {
+		t.Fatalf("expected missing primary to be dropped, got %v", got)
+	}
+
+	resp, err := e.AnalyzeActivity(ctx, models.Activity{ID: 1, EngineerID: 1, Activity: "Deployed svc"}, nil, nil)
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if resp.Model != "backup" || resp.Summary != "Deployed" {
+		t.Fatalf("expected analysis from backup, got model=%q summary=%q", resp.Model, resp.Summary)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(requested, []string{"flaky", "backup"}) {
+		t.Fatalf("unexpected generate calls %v", requested)
+	}
+}
+
+func TestRefreshModels_AcrossEndpoints(t *testing.T) {
+	tags := func(body string) *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/tags":
+				_, _ = w.Write([]byte(body))
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	a := tags(`{"models":[{"name":"primary:latest"}]}`)
+	b := tags(`{"models":[{"name":"backup:latest"}]}`)
+
+	ctx := context.Background()
+	client, err := ollama.NewClient(config.OllamaConfig{Endpoints: []string{a.URL, b.URL}, Timeout: 2 * time.Second, CircuitFailureThreshold: 5, CircuitReset: time.Minute}, http.DefaultClient)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	defer client.Close()
+	e, err := ai.NewEngine(ctx, client, config.EngineConfig{Model: "primary", TemplateVersion: "v1"}, newFakeSchemaRepo(), newFakeTemplateRepo("{{.Activity.Activity}}"))
+	if err != nil {
+		t.Fatalf("new engine failed: %v", err)
+	}
+	e.SetFallbackModels([]string{"backup"})
+
+	// the fallback is only installed on the second endpoint
+	if err := e.RefreshModels(ctx); err != nil {
+		t.Fatalf("refresh models: %v", err)
+	}
+	if got := e.Models(); !slices.Equal(got, []string{"primary", "backup"}) {
+		t.Fatalf("expected both models kept, got %v", got)
+	}
+}
+
+func TestAnalyzeActivity_MissingModelSkipped(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		requested []string
+	)
+	answer := `{"version":"v1","summary":"Deployed","entities":{"people":[],"projects":[],"technologies":[]},"confidence":0.9,"context_update":false,"reasoning":"r"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		requested = append(requested, req.Model)
+		mu.Unlock()
+		if req.Model != "backup" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"model \"gone\" not found, try pulling it first"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"model": req.Model, "response": answer, "done": true})
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client, err := ollama.NewClient(config.OllamaConfig{BaseURL: srv.URL, Timeout: 2 * time.Second, Retries: 2, CircuitFailureThreshold: 5, CircuitReset: time.Minute}, srv.Client())
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	defer client.Close()
+
+	schemas := newFakeSchemaRepo()
+	if _, err := schemas.CreateSchema(ctx, "v1", "v1", `{"type":"object","required":["version","summary"]}`); err != nil {
+		t.Fatalf("seed schema: %v", err)
+	}
+	e, err := ai.NewEngine(ctx, client, config.EngineConfig{Model: "gone", TemplateVersion: "v1"}, schemas, newFakeTemplateRepo("{{.Activity.Activity}}"))
+	if err != nil {
+		t.Fatalf("new engine failed: %v", err)
+	}
+	e.SetFallbackModels([]string{"backup"})
+
+	for range 2 {
+		if _, err := e.AnalyzeActivity(ctx, models.Activity{ID: 1, EngineerID: 1, Activity: "Deployed svc"}, nil, nil); err != nil {
+			t.Fatalf("analyze: %v", err)
+		}
+	}
+	if got := e.Models(); !slices.Equal(got, []string{"backup"}) {
+		t.Fatalf("expected the missing model to be dropped, got %v", got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	// the 404 is not retried and the second analysis skips the missing model
+	if !slices.Equal(requested, []string{"gone", "backup", "backup"}) {
+		t.Fatalf("unexpected generate calls %v", requested)
+	}
+}
+
+func TestAnalyzeActivity_TemplateOptions(t *testing.T) {
+	var got struct {
+		Options   map[string]any `json:"options"`
+		KeepAlive any            `json:"keep_alive"`
+	}
+	answer := `{"version":"v1","summary":"Deployed","entities":{"people":[],"projects":[],"technologies":[]},"confidence":0.9,"context_update":false,"reasoning":"r"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_ = json.NewEncoder(w).Encode(map[string]any{"model": "m", "response": answer, "done": true})
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client, err := ollama.NewClient(config.OllamaConfig{BaseURL: srv.URL, Timeout: 2 * time.Second}, srv.Client())
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	defer client.Close()
+
+	schemas := newFakeSchemaRepo()
+	if _, err := schemas.CreateSchema(ctx, "v1", "v1", `{"type":"object","required":["version","summary"]}`); err != nil {
+		t.Fatalf("seed schema: %v", err)
+	}
+	md := `{"owner":"prompt-team","options":{"temperature":0,"seed":7,"num_ctx":8192,"stop":["</json>"],"keep_alive":"10m"}}`
+	tpls := &fakeTemplateRepo{tpl: "{{.Activity.Activity}}", metadata: &md}
+	e, err := ai.NewEngine(ctx, client, config.EngineConfig{Model: "m", TemplateVersion: "v1"}, schemas, tpls)
+	if err != nil {
+		t.Fatalf("new engine failed: %v", err)
+	}
+	if _, err := e.AnalyzeActivity(ctx, models.Activity{ID: 1, EngineerID: 1, Activity: "Deployed svc"}, nil, nil); err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+
+	if got.Options["temperature"] != 0.0 || got.Options["seed"] != 7.0 || got.Options["num_ctx"] != 8192.0 {
+		t.Fatalf("unexpected options sent: %v", got.Options)
+	}
+	if stop, _ := got.Options["stop"].([]any); len(stop) != 1 || stop[0] != "</json>" {
+		t.Fatalf("unexpected stop sequences: %v", got.Options["stop"])
+	}
+	if got.KeepAlive == nil {
+		t.Fatal("expected keep_alive to be sent")
+	}
+}
+
+func TestParseTemplateOptions(t *testing.T) {
+	for _, md := range []string{`[]`, `{"options":{"temprature":0.2}}`, `{"options":{"top_p":1.5}}`, `{"options":{"keep_alive":"forever"}}`} {
+		if _, err := ai.ParseTemplateOptions(&md); err == nil {
+			t.Errorf("expected an error for %s", md)
+		}
+	}
+	for _, md := range []string{``, `{}`, `{"owner":"x"}`, `{"options":null}`} {
+		if o, err := ai.ParseTemplateOptions(&md); err != nil || o != nil {
+			t.Errorf("expected no options for %q, got %+v, %v", md, o, err)
+		}
+	}
+}
+
 func TestParseAIResponse_SchemaFailure(t *testing.T) {
 	// missing required fields like summary and reasoning
 	bad := "{\"version\":\"v1\",\"entities\":{\"people\":[],\"projects\":[],\"technologies\":[]},\"context_update\":false}"
@@ -127,7 +367,10 @@ func TestParseAIResponse_SchemaFailure(t *testing.T) {
 }
 
 // fakeTemplateRepo is a small in-memory TemplateRepo used for tests.
-type fakeTemplateRepo struct{ tpl string }
+type fakeTemplateRepo struct {
+	tpl      string
+	metadata *string
+}
 
 func newFakeTemplateRepo(tpl string) *fakeTemplateRepo { return &fakeTemplateRepo{tpl: tpl} }
 
@@ -137,7 +380,7 @@ func (f *fakeTemplateRepo) CreateTemplate(ctx context.Context, name, version, te
 
 func (f *fakeTemplateRepo) GetTemplate(ctx context.Context, name, version string) (*models.Template, error) {
 	if name == "activity" && version == "v1" {
-		return &models.Template{ID: 1, Name: name, Version: version, TemplateTxt: f.tpl}, nil
+		return &models.Template{ID: 1, Name: name, Version: version, TemplateTxt: f.tpl, Metadata: f.metadata}, nil
 	}
 	return nil, nil
 }
@@ -0,0 +1,151 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/garnizeh/rag/internal/jobs"
+	"github.com/garnizeh/rag/internal/models"
+	"github.com/garnizeh/rag/pkg/ollama"
+	"github.com/garnizeh/rag/pkg/repository"
+)
+
+// AnalyzeActivityJob is the job type queued for every shared activity.
+const AnalyzeActivityJob = "ai.analyze_activity"
+
+// relatedActivities is how many recent shared activities of the engineer are
+// offered to the prompt as context.
+const relatedActivities = 5
+
+// AnalyzeActivityHandler returns the handler for AnalyzeActivityJob. It loads
+// the activity named in the payload, the engineer's context, recent activities
+// and profile, analyzes the activity and returns the AIResponse as the job
+// result. A payload with "no_cache": true skips the cached answer and
+// refreshes it. When the answer asks for a context update it is merged into
+// the engineer's context through ProcessAIResponse. Failures retrying cannot
+// fix are permanent: a malformed payload, an activity that is gone or private,
+// and answers Ollama will give again for every model tried, such as a missing
+// model or a rejected request.
+func AnalyzeActivityHandler(e *Engine, repo *repository.Repository) jobs.Handler {
+	return func(ctx context.Context, j *models.BackgroundJob) (any, error) {
+		var pl struct {
+			ActivityID int64 `json:"activity_id"`
+			// NoCache re-runs the analysis on the model and refreshes the cached answer
+			NoCache bool `json:"no_cache"`
+		}
+		if err := json.Unmarshal(j.Payload, &pl); err != nil {
+			return nil, jobs.Permanent(err)
+		}
+
+		a, err := repo.Activity.GetActivity(ctx, pl.ActivityID)
+		if err != nil {
+			return nil, fmt.Errorf("get activity: %w", err)
+		}
+		if a == nil {
+			return nil, jobs.Permanent(fmt.Errorf("activity %d not found", pl.ActivityID))
+		}
+		if a.Visibility == models.VisibilityPrivate {
+			return nil, jobs.Permanent(fmt.Errorf("activity %d is private", a.ID))
+		}
+
+		contextJSON, _, err := repo.Context.GetEngineerContext(ctx, a.EngineerID)
+		if err != nil {
+			return nil, fmt.Errorf("get context: %w", err)
+		}
+		recent, err := repo.Activity.ListSharedByEngineer(ctx, a.EngineerID, relatedActivities+1, 0)
+		if err != nil {
+			return nil, fmt.Errorf("list related activities: %w", err)
+		}
+		related := make([]models.Activity, 0, len(recent))
+		for _, r := range recent {
+			if r.ID != a.ID && len(related) < relatedActivities {
+				related = append(related, r)
+			}
+		}
+		sections, err := ContextSections([]byte(contextJSON), related)
+		if err != nil {
+			// a context that does not parse only costs the analysis its context
+			logger.Warn("engineer context unreadable", slog.Int64("engineer_id", a.EngineerID), slog.Any("err", err))
+			sections, _ = ContextSections(nil, related)
+		}
+
+		profile, err := loadProfile(ctx, repo.Profile, a.EngineerID)
+		if err != nil {
+			return nil, err
+		}
+
+		if pl.NoCache {
+			ctx = WithoutCache(ctx)
+		}
+		resp, err := e.AnalyzeActivity(ctx, *a, sections, profile)
+		if err != nil {
+			if !retryableLLMError(err) {
+				return nil, jobs.Permanent(err)
+			}
+			return nil, err
+		}
+		if resp.ContextUpdate {
+			// a retry after a failed merge is answered from the response cache
+			if _, err := ProcessAIResponse(ctx, repo, a.EngineerID, resp); err != nil {
+				return nil, fmt.Errorf("apply analysis: %w", err)
+			}
+		}
+		return resp, nil
+	}
+}
+
+// loadProfile returns the engineer's parsed profile, or nil when they have
+// none or it does not parse.
+func loadProfile(ctx context.Context, repo repository.ProfileRepo, engineerID int64) (*models.ProfileData, error) {
+	if repo == nil {
+		return nil, nil
+	}
+	p, err := repo.GetByEngineerID(ctx, engineerID)
+	if err != nil {
+		return nil, fmt.Errorf("get profile: %w", err)
+	}
+	if p == nil {
+		return nil, nil
+	}
+	data, err := ParseProfile(p.Bio)
+	if err != nil {
+		logger.Warn("engineer profile unreadable", slog.Int64("engineer_id", engineerID), slog.Any("err", err))
+		return nil, nil
+	}
+	return data, nil
+}
+
+// retryableLLMError reports whether an AnalyzeActivity error may go away on a
+// later attempt. Generation tries each model in turn and joins their errors,
+// so it is permanent only when every Ollama error in it is; errors that did
+// not come from Ollama, such as an unparsable answer, are retried.
+func retryableLLMError(err error) bool {
+	var re *ollama.RequestError
+	if !errors.As(err, &re) {
+		return true
+	}
+	return anyRetryable(err)
+}
+
+func anyRetryable(err error) bool {
+	if re, ok := err.(*ollama.RequestError); ok {
+		return ollama.IsRetryable(re)
+	}
+	switch u := err.(type) {
+	case interface{ Unwrap() []error }:
+		for _, e := range u.Unwrap() {
+			if anyRetryable(e) {
+				return true
+			}
+		}
+		return false
+	case interface{ Unwrap() error }:
+		return anyRetryable(u.Unwrap())
+	default:
+		// a model that failed without an Ollama error, e.g. an open circuit
+		return true
+	}
+}
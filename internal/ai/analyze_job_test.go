@@ -0,0 +1,191 @@
+package ai_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/garnizeh/rag/internal/ai"
+	"github.com/garnizeh/rag/internal/config"
+	"github.com/garnizeh/rag/internal/db"
+	"github.com/garnizeh/rag/internal/jobs"
+	"github.com/garnizeh/rag/internal/models"
+	sqlite "github.com/garnizeh/rag/internal/repository/sqlite"
+	"github.com/garnizeh/rag/pkg/ollama"
+	"github.com/garnizeh/rag/pkg/repository"
+)
+
+func TestAnalyzeActivityHandler(t *testing.T) {
+	answer := `{"version":"v1","summary":"Deployed","entities":{"people":[],"projects":[],"technologies":[]},"confidence":0.9,"context_update":false,"reasoning":"r"}`
+	update := `{"version":"v1","summary":"Moved billing to Postgres","entities":{"people":[],"projects":["billing"],"technologies":["Postgres"]},"confidence":0.9,"context_update":true,"reasoning":"r"}`
+	var (
+		mu      sync.Mutex
+		prompts []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model  string `json:"model"`
+			Prompt string `json:"prompt"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		switch req.Model {
+		case "gone":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"model \"gone\" not found, try pulling it first"}`))
+			return
+		case "down":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"error":"loading model"}`))
+			return
+		}
+		mu.Lock()
+		prompts = append(prompts, req.Prompt)
+		mu.Unlock()
+		out := answer
+		if strings.HasPrefix(req.Prompt, "Migrated") {
+			out = update
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"model": req.Model, "response": out, "done": true})
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	d, err := db.New(ctx, "file:ai_analyze_job?mode=memory&cache=shared", nil)
+	if err != nil {
+		t.Fatalf("db.New: %v", err)
+	}
+	defer d.Close()
+	for _, s := range []string{
+		`CREATE TABLE raw_activities (id INTEGER PRIMARY KEY AUTOINCREMENT, engineer_id INTEGER, activity TEXT, visibility TEXT NOT NULL DEFAULT 'shared', redactions TEXT, idempotency_key TEXT, created INTEGER);`,
+		`CREATE TABLE engineer_contexts (id INTEGER PRIMARY KEY AUTOINCREMENT, engineer_id INTEGER NOT NULL UNIQUE, context_json TEXT NOT NULL, version INTEGER NOT NULL DEFAULT 1, updated INTEGER NOT NULL);`,
+		`CREATE TABLE engineer_context_history (id INTEGER PRIMARY KEY AUTOINCREMENT, engineer_id INTEGER NOT NULL, context_json TEXT NOT NULL, changes_json TEXT, conflicts_json TEXT, applied_by TEXT, created INTEGER NOT NULL, version INTEGER NOT NULL);`,
+		`CREATE TABLE engineer_profiles (id INTEGER PRIMARY KEY AUTOINCREMENT, engineer_id INTEGER, bio TEXT, updated INTEGER);`,
+		`CREATE TABLE llm_cache (key TEXT PRIMARY KEY, model TEXT NOT NULL, response TEXT NOT NULL, size INTEGER NOT NULL, hits INTEGER NOT NULL DEFAULT 0, expires_at INTEGER NOT NULL, last_used INTEGER NOT NULL, created INTEGER NOT NULL);`,
+	} {
+		if _, err := d.Exec(ctx, s); err != nil {
+			t.Fatalf("setup schema: %v", err)
+		}
+	}
+	store := sqlite.New(d, nil)
+	repo := &repository.Repository{Activity: store, Context: store, Profile: store}
+
+	related, _ := store.CreateActivity(ctx, &models.Activity{EngineerID: 7, Activity: "Reviewed the rollout plan", Created: 1})
+	private, _ := store.CreateActivity(ctx, &models.Activity{EngineerID: 7, Activity: "Dentist at noon", Visibility: models.VisibilityPrivate, Created: 2})
+	id, _ := store.CreateActivity(ctx, &models.Activity{EngineerID: 7, Activity: "Deployed svc to staging", Created: 3})
+	if _, err := store.UpsertEngineerContext(ctx, 7, `{"summary":"Runs the deploy pipeline"}`, "test"); err != nil {
+		t.Fatalf("seed context: %v", err)
+	}
+	if _, err := store.CreateProfile(ctx, &models.Profile{EngineerID: 7, Bio: `{"role":"SRE","team":"platform","goals":["pair with bob@example.com"]}`}); err != nil {
+		t.Fatalf("seed profile: %v", err)
+	}
+
+	client, err := ollama.NewClient(config.OllamaConfig{BaseURL: srv.URL, Timeout: 2 * time.Second, CircuitFailureThreshold: 5, CircuitReset: time.Minute}, srv.Client())
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	defer client.Close()
+	schemas := newFakeSchemaRepo()
+	if _, err := schemas.CreateSchema(ctx, "v1", "v1", `{"type":"object","required":["version","summary"]}`); err != nil {
+		t.Fatalf("seed schema: %v", err)
+	}
+	tpl := "{{.Activity.Activity}}\n{{.Context}}\n{{with .Profile}}role: {{.Role}} goals: {{.Goals}}{{end}}"
+	engine := func(model string) *ai.Engine {
+		e, err := ai.NewEngine(ctx, client, config.EngineConfig{Model: model, TemplateVersion: "v1"}, schemas, newFakeTemplateRepo(tpl))
+		if err != nil {
+			t.Fatalf("new engine failed: %v", err)
+		}
+		return e
+	}
+	job := func(activityID int64, extra ...string) *models.BackgroundJob {
+		pl := map[string]any{"engineer_id": 7, "activity_id": activityID}
+		for _, k := range extra {
+			pl[k] = true
+		}
+		b, _ := json.Marshal(pl)
+		return &models.BackgroundJob{Type: ai.AnalyzeActivityJob, Payload: b}
+	}
+
+	handle := ai.AnalyzeActivityHandler(engine("m"), repo)
+	res, err := handle(ctx, job(id))
+	if err != nil {
+		t.Fatalf("analyze job: %v", err)
+	}
+	if resp, ok := res.(*ai.AIResponse); !ok || resp.Summary != "Deployed" {
+		t.Fatalf("expected the AIResponse as result, got %#v", res)
+	}
+	mu.Lock()
+	prompt := prompts[0]
+	mu.Unlock()
+	for _, want := range []string{"Deployed svc to staging", "Runs the deploy pipeline", "Reviewed the rollout plan", "role: SRE", "[REDACTED:email]"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt is missing %q:\n%s", want, prompt)
+		}
+	}
+	if strings.Contains(prompt, "Dentist") {
+		t.Errorf("private activity leaked into the prompt:\n%s", prompt)
+	}
+	if strings.Contains(prompt, "bob@example.com") {
+		t.Errorf("profile PII leaked into the prompt:\n%s", prompt)
+	}
+	if _, version, _ := store.GetEngineerContext(ctx, 7); version != 1 {
+		t.Fatalf("an answer without context_update changed the context to version %d", version)
+	}
+
+	// an answer asking for a context update is merged into the engineer's context
+	migrated, _ := store.CreateActivity(ctx, &models.Activity{EngineerID: 7, Activity: "Migrated billing to Postgres", Created: 4})
+	if _, err := handle(ctx, job(migrated)); err != nil {
+		t.Fatalf("analyze job: %v", err)
+	}
+	contextJSON, version, err := store.GetEngineerContext(ctx, 7)
+	if err != nil {
+		t.Fatalf("get context: %v", err)
+	}
+	if version != 2 || !strings.Contains(contextJSON, "Postgres") || !strings.Contains(contextJSON, "Moved billing to Postgres") {
+		t.Fatalf("expected the analysis merged into the context, got version %d: %s", version, contextJSON)
+	}
+
+	// no_cache skips the cached answer and reaches the model again
+	cached := engine("m")
+	cached.SetCache(ai.NewResponseCache(store, config.LLMCacheConfig{TTL: time.Hour, MaxBytes: 1 << 20}))
+	handleCached := ai.AnalyzeActivityHandler(cached, repo)
+	sent := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(prompts)
+	}
+	before := sent()
+	for _, j := range []*models.BackgroundJob{job(related), job(related), job(related, "no_cache")} {
+		if _, err := handleCached(ctx, j); err != nil {
+			t.Fatalf("analyze job: %v", err)
+		}
+	}
+	if n := sent() - before; n != 2 {
+		t.Fatalf("expected the first and the no_cache run to reach the model, got %d calls", n)
+	}
+
+	for name, c := range map[string]struct {
+		model     string
+		payload   *models.BackgroundJob
+		permanent bool
+	}{
+		"bad payload":      {model: "m", payload: &models.BackgroundJob{Payload: []byte("{")}, permanent: true},
+		"missing activity": {model: "m", payload: job(9999), permanent: true},
+		"private activity": {model: "m", payload: job(private), permanent: true},
+		"missing model":    {model: "gone", payload: job(related), permanent: true},
+		"ollama down":      {model: "down", payload: job(related)},
+	} {
+		_, err := ai.AnalyzeActivityHandler(engine(c.model), repo)(ctx, c.payload)
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+			continue
+		}
+		if jobs.IsPermanent(err) != c.permanent {
+			t.Errorf("%s: expected permanent=%v, got %v", name, c.permanent, err)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/garnizeh/rag/internal/config"
+	"github.com/garnizeh/rag/internal/metrics"
+	"github.com/garnizeh/rag/internal/models"
+	"github.com/garnizeh/rag/pkg/ollama"
+	"github.com/garnizeh/rag/pkg/repository"
+)
+
+var (
+	cacheLookups = metrics.NewCounter("rag_llm_cache_lookups_total",
+		"LLM response cache lookups by outcome (hit, miss, bypass, error).", "outcome")
+	cacheEvictions = metrics.NewCounter("rag_llm_cache_evictions_total",
+		"LLM response cache entries removed because they expired or the cache was over its size cap.")
+)
+
+// cacheKeyVersion is part of every key so a change to what is hashed or
+// stored invalidates old entries instead of misreading them.
+const cacheKeyVersion = "v1"
+
+// pruneEvery is how many writes pass between prunes. Pruning ranks the whole
+// table, so the cap may be overshot by up to that many entries in between.
+const pruneEvery = 64
+
+// ResponseCache serves repeated generations from the llm_cache table. A nil
+// *ResponseCache is valid and caches nothing.
+type ResponseCache struct {
+	repo     repository.LLMCacheRepo
+	ttl      time.Duration
+	maxBytes int64
+	puts     atomic.Uint64
+}
+
+// NewResponseCache returns a cache backed by repo, or nil when caching is
+// disabled.
+func NewResponseCache(repo repository.LLMCacheRepo, cfg config.LLMCacheConfig) *ResponseCache {
+	if repo == nil || cfg.Disabled {
+		return nil
+	}
+	return &ResponseCache{repo: repo, ttl: cfg.TTL, maxBytes: cfg.MaxBytes}
+}
+
+type cacheBypassKey struct{}
+
+// WithoutCache returns a context whose generations skip the cache lookup and
+// always reach the model. Their results still replace what is cached, so
+// re-running analyses, e.g. after a template change, refreshes the entries.
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheBypassKey{}, true)
+}
+
+func cacheBypassed(ctx context.Context) bool {
+	v, _ := ctx.Value(cacheBypassKey{}).(bool)
+	return v
+}
+
+// CacheKey identifies a generation: the same model, options and rendered
+// prompt give the same key.
+func CacheKey(model, prompt string, opts *ollama.GenerateOptions) string {
+	b, _ := json.Marshal(struct {
+		Version string                  `json:"v"`
+		Op      string                  `json:"op"`
+		Model   string                  `json:"model"`
+		Options *ollama.GenerateOptions `json:"options"`
+		Prompt  string                  `json:"prompt"`
+	}{cacheKeyVersion, "generate", model, opts, prompt})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// get returns the cached result for key. Cached results carry
+// Meta["cached"] = true. Errors are logged and count as a miss.
+func (c *ResponseCache) get(ctx context.Context, key string) (ollama.GenerateResult, bool) {
+	if c == nil {
+		return ollama.GenerateResult{}, false
+	}
+	if cacheBypassed(ctx) {
+		cacheLookups.With("bypass").Inc()
+		return ollama.GenerateResult{}, false
+	}
+
+	entry, err := c.repo.GetCachedResponse(ctx, key)
+	if err != nil {
+		cacheLookups.With("error").Inc()
+		logger.Warn("llm cache lookup failed", slog.Any("err", err))
+		return ollama.GenerateResult{}, false
+	}
+	if entry == nil {
+		cacheLookups.With("miss").Inc()
+		return ollama.GenerateResult{}, false
+	}
+
+	var res ollama.GenerateResult
+	if err := json.Unmarshal([]byte(entry.Response), &res); err != nil {
+		cacheLookups.With("error").Inc()
+		logger.Warn("llm cache entry unreadable", slog.String("key", key), slog.Any("err", err))
+		return ollama.GenerateResult{}, false
+	}
+	cacheLookups.With("hit").Inc()
+	if res.Meta == nil {
+		res.Meta = map[string]any{}
+	}
+	res.Meta["cached"] = true
+	return res, true
+}
+
+// put stores res under key and, on the first and then every pruneEvery-th
+// write, prunes the cache back under its cap. Errors are logged: a failed
+// write only costs a later miss.
+func (c *ResponseCache) put(ctx context.Context, key, model string, res ollama.GenerateResult) {
+	if c == nil {
+		return
+	}
+	b, err := json.Marshal(res)
+	if err != nil {
+		logger.Warn("llm cache encode failed", slog.Any("err", err))
+		return
+	}
+	entry := &models.LLMCacheEntry{
+		Key:       key,
+		Model:     model,
+		Response:  string(b),
+		Size:      int64(len(b)),
+		ExpiresAt: time.Now().Add(c.ttl).UTC().UnixMilli(),
+	}
+	if err := c.repo.PutCachedResponse(ctx, entry); err != nil {
+		logger.Warn("llm cache write failed", slog.Any("err", err))
+		return
+	}
+	if c.puts.Add(1)%pruneEvery != 1 {
+		return
+	}
+	n, err := c.repo.PruneLLMCache(ctx, c.maxBytes)
+	if err != nil {
+		logger.Warn("llm cache prune failed", slog.Any("err", err))
+	}
+	cacheEvictions.With().Add(float64(n))
+}
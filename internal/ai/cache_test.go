@@ -0,0 +1,140 @@
+package ai_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/garnizeh/rag/internal/ai"
+	"github.com/garnizeh/rag/internal/config"
+	"github.com/garnizeh/rag/internal/db"
+	"github.com/garnizeh/rag/internal/models"
+	sqlite "github.com/garnizeh/rag/internal/repository/sqlite"
+	"github.com/garnizeh/rag/pkg/ollama"
+	"github.com/garnizeh/rag/pkg/repository"
+)
+
+// countingCache counts the prunes that reach the wrapped repo.
+type countingCache struct {
+	repository.LLMCacheRepo
+	prunes atomic.Int32
+}
+
+func (c *countingCache) PruneLLMCache(ctx context.Context, maxBytes int64) (int64, error) {
+	c.prunes.Add(1)
+	return c.LLMCacheRepo.PruneLLMCache(ctx, maxBytes)
+}
+
+func TestAnalyzeActivity_ResponseCache(t *testing.T) {
+	var (
+		calls   atomic.Int32
+		revised atomic.Bool
+	)
+	answer := `{"version":"v1","summary":"Deployed","entities":{"people":[],"projects":[],"technologies":[]},"confidence":0.9,"context_update":false,"reasoning":"r"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var req struct {
+			Prompt string `json:"prompt"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		out := answer
+		if strings.Contains(req.Prompt, "garbled") {
+			out = "not json"
+		} else if revised.Load() {
+			out = strings.Replace(answer, `"Deployed"`, `"Deployed v2"`, 1)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"model": "m", "response": out, "done": true})
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	d, err := db.New(ctx, "file:ai_llm_cache?mode=memory&cache=shared", nil)
+	if err != nil {
+		t.Fatalf("db.New: %v", err)
+	}
+	defer d.Close()
+	if _, err := d.Exec(ctx, `CREATE TABLE llm_cache (key TEXT PRIMARY KEY, model TEXT NOT NULL, response TEXT NOT NULL, size INTEGER NOT NULL, hits INTEGER NOT NULL DEFAULT 0, expires_at INTEGER NOT NULL, last_used INTEGER NOT NULL, created INTEGER NOT NULL);`); err != nil {
+		t.Fatalf("setup schema: %v", err)
+	}
+
+	client, err := ollama.NewClient(config.OllamaConfig{BaseURL: srv.URL, Timeout: 2 * time.Second}, srv.Client())
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	defer client.Close()
+	schemas := newFakeSchemaRepo()
+	if _, err := schemas.CreateSchema(ctx, "v1", "v1", `{"type":"object","required":["version","summary"]}`); err != nil {
+		t.Fatalf("seed schema: %v", err)
+	}
+	e, err := ai.NewEngine(ctx, client, config.EngineConfig{Model: "m", TemplateVersion: "v1"}, schemas, newFakeTemplateRepo("{{.Activity.Activity}}"))
+	if err != nil {
+		t.Fatalf("new engine failed: %v", err)
+	}
+	store := &countingCache{LLMCacheRepo: sqlite.New(d, nil)}
+	e.SetCache(ai.NewResponseCache(store, config.LLMCacheConfig{TTL: time.Hour, MaxBytes: 1 << 20}))
+
+	act := models.Activity{ID: 1, EngineerID: 1, Activity: "Deployed svc"}
+	first, err := e.AnalyzeActivity(ctx, act, nil, nil)
+	if err != nil || first.Cached {
+		t.Fatalf("first analyze: %+v, %v", first, err)
+	}
+	second, err := e.AnalyzeActivity(ctx, act, nil, nil)
+	if err != nil || !second.Cached || second.Summary != "Deployed" || calls.Load() != 1 {
+		t.Fatalf("expected a cache hit, got %+v, %v after %d calls", second, err, calls.Load())
+	}
+
+	// a bypassed call reaches the model and refreshes the entry
+	revised.Store(true)
+	third, err := e.AnalyzeActivity(ai.WithoutCache(ctx), act, nil, nil)
+	if err != nil || third.Cached || third.Summary != "Deployed v2" || calls.Load() != 2 {
+		t.Fatalf("expected the bypass to reach the model, got %+v, %v after %d calls", third, err, calls.Load())
+	}
+	fourth, err := e.AnalyzeActivity(ctx, act, nil, nil)
+	if err != nil || !fourth.Cached || fourth.Summary != "Deployed v2" || calls.Load() != 2 {
+		t.Fatalf("expected the refreshed entry, got %+v, %v after %d calls", fourth, err, calls.Load())
+	}
+
+	// output that fails parsing is not cached
+	bad := models.Activity{ID: 2, EngineerID: 1, Activity: "garbled"}
+	for range 2 {
+		if _, err := e.AnalyzeActivity(ctx, bad, nil, nil); err == nil {
+			t.Fatal("expected a parse error")
+		}
+	}
+	if calls.Load() != 4 {
+		t.Fatalf("expected invalid output to be regenerated, got %d calls", calls.Load())
+	}
+
+	// the first write prunes, then every 64th
+	if n := store.prunes.Load(); n != 1 {
+		t.Fatalf("expected 1 prune after two writes, got %d", n)
+	}
+	for i := range 63 {
+		act := models.Activity{ID: int64(10 + i), EngineerID: 1, Activity: fmt.Sprintf("Deployed svc %d", i)}
+		if _, err := e.AnalyzeActivity(ctx, act, nil, nil); err != nil {
+			t.Fatalf("analyze %d: %v", i, err)
+		}
+	}
+	if n := store.prunes.Load(); n != 2 {
+		t.Fatalf("expected 2 prunes after 65 writes, got %d", n)
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	seed := 1
+	k := ai.CacheKey("m", "p", nil)
+	if k != ai.CacheKey("m", "p", nil) {
+		t.Fatal("expected a stable key")
+	}
+	for _, other := range []string{ai.CacheKey("m2", "p", nil), ai.CacheKey("m", "p2", nil), ai.CacheKey("m", "p", &ollama.GenerateOptions{Seed: &seed})} {
+		if other == k {
+			t.Fatal("expected model, prompt and options to change the key")
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 
 	"log/slog"
 
@@ -11,7 +12,7 @@ import (
 	"github.com/garnizeh/rag/pkg/repository"
 )
 
-var processorLogger = slog.New(slog.NewJSONHandler(nil, nil))
+var processorLogger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
 
 func SetProcessorLogger(l *slog.Logger) {
 	if l != nil {
@@ -0,0 +1,25 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/garnizeh/rag/internal/models"
+)
+
+// ProfileSchemaVersion is the ai_schemas version used to validate engineer profiles.
+const ProfileSchemaVersion = "profile_v1"
+
+// ParseProfile decodes the JSON stored in models.Profile.Bio. Empty bios (and the
+// "{}" written at signup) yield an empty profile rather than an error.
+func ParseProfile(bio string) (*models.ProfileData, error) {
+	p := &models.ProfileData{}
+	if strings.TrimSpace(bio) == "" {
+		return p, nil
+	}
+	if err := json.Unmarshal([]byte(bio), p); err != nil {
+		return nil, fmt.Errorf("parse profile: %w", err)
+	}
+	return p, nil
+}
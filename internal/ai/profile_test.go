@@ -0,0 +1,58 @@
+package ai_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/garnizeh/rag/internal/ai"
+	"github.com/garnizeh/rag/internal/models"
+	"github.com/garnizeh/rag/pkg/ollama"
+)
+
+func TestParseProfile(t *testing.T) {
+	for _, bio := range []string{"", "{}"} {
+		p, err := ai.ParseProfile(bio)
+		if err != nil || p == nil {
+			t.Fatalf("expected empty profile for %q, got %#v err=%v", bio, p, err)
+		}
+	}
+
+	p, err := ai.ParseProfile(`{"role":"SRE","skills":["Terraform"]}`)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if p.Role != "SRE" || len(p.Skills) != 1 {
+		t.Fatalf("unexpected profile: %#v", p)
+	}
+
+	if _, err := ai.ParseProfile("not json"); err == nil {
+		t.Fatalf("expected error for invalid bio")
+	}
+}
+
+func TestSeedTemplate_RendersProfile(t *testing.T) {
+	b, err := os.ReadFile("../../db/seed/template_activity_v1.txt")
+	if err != nil {
+		t.Fatalf("read seed template: %v", err)
+	}
+	act := models.Activity{ID: 1, EngineerID: 1, Activity: "Wrote terraform module"}
+
+	// without a profile the section is omitted
+	out, err := ollama.RenderTemplate(string(b), map[string]any{"Activity": act, "Context": "", "Profile": (*models.ProfileData)(nil)})
+	if err != nil {
+		t.Fatalf("render without profile: %v", err)
+	}
+	if strings.Contains(out, "Engineer profile") {
+		t.Fatalf("expected no profile section, got:\n%s", out)
+	}
+
+	profile := &models.ProfileData{Role: "SRE", Team: "platform", Skills: []string{"Terraform"}}
+	out, err = ollama.RenderTemplate(string(b), map[string]any{"Activity": act, "Context": "", "Profile": profile})
+	if err != nil {
+		t.Fatalf("render with profile: %v", err)
+	}
+	if !strings.Contains(out, "role=SRE") || !strings.Contains(out, "Terraform") {
+		t.Fatalf("expected profile in prompt, got:\n%s", out)
+	}
+}
@@ -0,0 +1,203 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/garnizeh/rag/internal/models"
+)
+
+// Context section priorities: when the prompt does not fit the model's
+// window, lower priorities are trimmed first.
+const (
+	PriorityActivities = 1
+	PriorityEntities   = 2
+	PrioritySummary    = 3
+)
+
+// recentEntitiesPerKind caps how many entities of each kind are offered to
+// the prompt before budgeting.
+const recentEntitiesPerKind = 20
+
+// ContextSection is a named part of the context given to the prompt. Items
+// are ordered most important first, so trimming drops them from the end.
+type ContextSection struct {
+	Name     string
+	Priority int
+	Items    []string
+}
+
+// DroppedContext reports what was left out of one section to fit the budget.
+type DroppedContext struct {
+	Section string `json:"section"`
+	// Items is the number of items removed entirely
+	Items int `json:"items,omitempty"`
+	// Truncated is set when the last kept item was shortened
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Assembly is context text fitted to a token budget. Tokens is the estimate
+// the budget was checked against, summed over the rendered lines.
+type Assembly struct {
+	Text    string           `json:"-"`
+	Budget  int              `json:"budget"`
+	Tokens  int              `json:"tokens"`
+	Dropped []DroppedContext `json:"dropped,omitempty"`
+}
+
+// EstimateTokens approximates the token count of s without a tokenizer:
+// about four characters per token for prose, at least one per word so short
+// words and code are not underestimated.
+func EstimateTokens(s string) int {
+	return max((utf8.RuneCountInString(s)+3)/4, len(strings.Fields(s)))
+}
+
+// ContextSections splits an engineer context (the JSON stored by
+// MergeAIResponse) and related activities into prompt sections: the summary,
+// the most recent entities and the activities, most relevant first.
+func ContextSections(contextJSON []byte, related []models.Activity) ([]ContextSection, error) {
+	var sections []ContextSection
+	if len(contextJSON) > 0 {
+		var cm ContextModel
+		if err := json.Unmarshal(contextJSON, &cm); err != nil {
+			return nil, fmt.Errorf("parse context: %w", err)
+		}
+		if s, ok := cm["summary"].(string); ok && strings.TrimSpace(s) != "" {
+			sections = append(sections, ContextSection{Name: "Summary", Priority: PrioritySummary, Items: []string{s}})
+		}
+		// entity lists are appended to, so the newest entries are at the end
+		var entities []string
+		for _, kind := range []string{"projects", "technologies", "people"} {
+			list, _ := cm[kind].([]any)
+			n := 0
+			for i := len(list) - 1; i >= 0 && n < recentEntitiesPerKind; i-- {
+				if s, ok := list[i].(string); ok {
+					entities = append(entities, kind+": "+s)
+					n++
+				}
+			}
+		}
+		if len(entities) > 0 {
+			sections = append(sections, ContextSection{Name: "Recent entities", Priority: PriorityEntities, Items: entities})
+		}
+	}
+
+	var acts []string
+	for _, a := range related {
+		if a.Visibility == models.VisibilityPrivate || strings.TrimSpace(a.Activity) == "" {
+			continue
+		}
+		acts = append(acts, a.Activity)
+	}
+	if len(acts) > 0 {
+		sections = append(sections, ContextSection{Name: "Related activities", Priority: PriorityActivities, Items: acts})
+	}
+
+	return sections, nil
+}
+
+// AssembleContext renders sections as markdown lists within budget tokens.
+// While the text is too long, the last item of the lowest-priority section is
+// dropped; the last item a section has left is truncated instead when that
+// is enough to fit. Sections keep their order in the output.
+func AssembleContext(sections []ContextSection, budget int) Assembly {
+	type part struct {
+		header  int
+		items   []string
+		costs   []int
+		dropped int
+		trunc   bool
+	}
+	parts := make([]*part, len(sections))
+	total := 0
+	for i, s := range sections {
+		p := &part{header: EstimateTokens("## " + s.Name)}
+		for _, it := range s.Items {
+			p.items = append(p.items, it)
+			p.costs = append(p.costs, EstimateTokens("- "+it))
+		}
+		if len(p.items) > 0 {
+			total += p.header + sum(p.costs)
+		}
+		parts[i] = p
+	}
+
+	// trim order: lowest priority first, later sections first among equals
+	order := make([]int, len(sections))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		if d := sections[a].Priority - sections[b].Priority; d != 0 {
+			return d
+		}
+		return b - a
+	})
+
+	for _, i := range order {
+		p := parts[i]
+		for total > budget && len(p.items) > 0 {
+			last := len(p.items) - 1
+			cost := p.costs[last]
+			over := total - budget
+			// the section's last item shrinks rather than disappears when that suffices
+			if last == 0 && cost-over > EstimateTokens("- …") {
+				p.items[0] = truncateTokens(p.items[0], cost-over)
+				p.costs[0] = EstimateTokens("- " + p.items[0])
+				p.trunc = true
+				total += p.costs[0] - cost
+				continue
+			}
+			p.items, p.costs = p.items[:last], p.costs[:last]
+			p.dropped++
+			total -= cost
+			if len(p.items) == 0 {
+				total -= p.header
+			}
+		}
+	}
+
+	a := Assembly{Budget: budget}
+	var sb strings.Builder
+	for i, s := range sections {
+		p := parts[i]
+		if p.dropped > 0 || p.trunc {
+			a.Dropped = append(a.Dropped, DroppedContext{Section: s.Name, Items: p.dropped, Truncated: p.trunc})
+		}
+		if len(p.items) == 0 {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("## " + s.Name + "\n")
+		for _, it := range p.items {
+			sb.WriteString("- " + it + "\n")
+		}
+	}
+	a.Text = sb.String()
+	a.Tokens = total
+	return a
+}
+
+// truncateTokens shortens s to about tokens tokens, including the list
+// marker, and marks the cut with an ellipsis.
+func truncateTokens(s string, tokens int) string {
+	r := []rune(s)
+	n := min(len(r), tokens*4)
+	for n > 0 && EstimateTokens("- "+string(r[:n])+"…") > tokens {
+		n -= max(n/8, 1)
+	}
+	return strings.TrimSpace(string(r[:n])) + "…"
+}
+
+func sum(xs []int) int {
+	n := 0
+	for _, x := range xs {
+		n += x
+	}
+	return n
+}
@@ -0,0 +1,149 @@
+package ai_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/garnizeh/rag/internal/ai"
+	"github.com/garnizeh/rag/internal/config"
+	"github.com/garnizeh/rag/internal/models"
+	"github.com/garnizeh/rag/pkg/ollama"
+)
+
+func TestContextSections(t *testing.T) {
+	ctxJSON := []byte(`{"summary":"Works on billing","projects":["old-svc","billing"],"people":["Ann"],"_meta":{"last_ai_update":1}}`)
+	related := []models.Activity{
+		{ID: 1, Activity: "Fixed invoice rounding"},
+		{ID: 2, Activity: "1:1 with manager", Visibility: models.VisibilityPrivate},
+		{ID: 3, Activity: "Reviewed billing PR"},
+	}
+	sections, err := ai.ContextSections(ctxJSON, related)
+	if err != nil {
+		t.Fatalf("sections: %v", err)
+	}
+	if len(sections) != 3 {
+		t.Fatalf("expected summary, entities and activities, got %+v", sections)
+	}
+	if got := sections[1].Items; strings.Join(got, ",") != "projects: billing,projects: old-svc,people: Ann" {
+		t.Fatalf("expected newest entities first, got %v", got)
+	}
+	if got := sections[2].Items; strings.Join(got, ",") != "Fixed invoice rounding,Reviewed billing PR" {
+		t.Fatalf("expected private activities to be skipped, got %v", got)
+	}
+	if _, err := ai.ContextSections([]byte(`[1]`), nil); err == nil {
+		t.Fatal("expected an error for a context that is not an object")
+	}
+}
+
+func TestAssembleContext(t *testing.T) {
+	act := strings.Repeat("a ", 30)
+	sections := []ai.ContextSection{
+		{Name: "Summary", Priority: ai.PrioritySummary, Items: []string{strings.Repeat("summary ", 40)}},
+		{Name: "Recent entities", Priority: ai.PriorityEntities, Items: []string{"projects: billing", "projects: search"}},
+		{Name: "Related activities", Priority: ai.PriorityActivities, Items: []string{act, act}},
+	}
+
+	all := ai.AssembleContext(sections, 1000)
+	if len(all.Dropped) != 0 || !strings.Contains(all.Text, "## Related activities") {
+		t.Fatalf("expected everything to fit, got %+v", all)
+	}
+
+	// room for the summary and entities only: both activities go, lowest priority first
+	activities := ai.EstimateTokens("## Related activities") + 2*ai.EstimateTokens("- "+act)
+	fit := ai.AssembleContext(sections, all.Tokens-activities)
+	if strings.Contains(fit.Text, "Related activities") || !strings.Contains(fit.Text, "projects: search") {
+		t.Fatalf("expected activities to be dropped first, got %q", fit.Text)
+	}
+	if len(fit.Dropped) != 1 || fit.Dropped[0].Section != "Related activities" || fit.Dropped[0].Items != 2 {
+		t.Fatalf("unexpected dropped report %+v", fit.Dropped)
+	}
+
+	// a tight budget keeps a truncated summary rather than nothing
+	tight := ai.AssembleContext(sections, 30)
+	if !strings.HasPrefix(tight.Text, "## Summary\n- summary") || !strings.Contains(tight.Text, "…") {
+		t.Fatalf("expected a truncated summary, got %q", tight.Text)
+	}
+	if tight.Tokens > tight.Budget {
+		t.Fatalf("expected at most %d tokens, got %d", tight.Budget, tight.Tokens)
+	}
+	// reported in section order
+	if first := tight.Dropped[0]; first.Section != "Summary" || !first.Truncated || len(tight.Dropped) != 3 {
+		t.Fatalf("expected the summary to be reported truncated, got %+v", tight.Dropped)
+	}
+
+	if empty := ai.AssembleContext(sections, 0); empty.Text != "" {
+		t.Fatalf("expected no context for a zero budget, got %q", empty.Text)
+	}
+}
+
+func TestAnalyzeActivity_ContextFitsWindow(t *testing.T) {
+	var prompt string
+	answer := `{"version":"v1","summary":"Deployed","entities":{"people":[],"projects":[],"technologies":[]},"confidence":0.9,"context_update":false,"reasoning":"r"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			_, _ = w.Write([]byte(`{"models":[{"name":"m:latest"}]}`))
+		case "/api/show":
+			_, _ = w.Write([]byte(`{"model_info":{"llama.context_length":400}}`))
+		case "/api/generate":
+			var req struct {
+				Prompt string `json:"prompt"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			prompt = req.Prompt
+			_ = json.NewEncoder(w).Encode(map[string]any{"model": "m", "response": answer, "done": true})
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client, err := ollama.NewClient(config.OllamaConfig{BaseURL: srv.URL, Timeout: 2 * time.Second}, srv.Client())
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	defer client.Close()
+
+	schemas := newFakeSchemaRepo()
+	if _, err := schemas.CreateSchema(ctx, "v1", "v1", `{"type":"object","required":["version","summary"]}`); err != nil {
+		t.Fatalf("seed schema: %v", err)
+	}
+	e, err := ai.NewEngine(ctx, client, config.EngineConfig{Model: "m", TemplateVersion: "v1", ContextWindow: 8192, ResponseTokens: 100}, schemas, newFakeTemplateRepo("Activity: {{.Activity.Activity}}\n{{.Context}}"))
+	if err != nil {
+		t.Fatalf("new engine failed: %v", err)
+	}
+	if err := e.RefreshModels(ctx); err != nil {
+		t.Fatalf("refresh models: %v", err)
+	}
+	if w := e.ContextWindow(); w != 400 {
+		t.Fatalf("expected the model's context length to cap the window, got %d", w)
+	}
+
+	var related []models.Activity
+	for i := range 50 {
+		related = append(related, models.Activity{ID: int64(i), Activity: fmt.Sprintf("activity %d: %s", i, strings.Repeat("word ", 20))})
+	}
+	sections, err := ai.ContextSections([]byte(`{"summary":"Owns the deploy pipeline","projects":["deploy"]}`), related)
+	if err != nil {
+		t.Fatalf("sections: %v", err)
+	}
+	resp, err := e.AnalyzeActivity(ctx, models.Activity{ID: 1, EngineerID: 1, Activity: "Deployed svc"}, sections, nil)
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+
+	if n := ai.EstimateTokens(prompt); n > 300 {
+		t.Fatalf("expected the prompt to leave room for the answer, got %d tokens", n)
+	}
+	if !strings.Contains(prompt, "Owns the deploy pipeline") || !strings.Contains(prompt, "activity 0:") || strings.Contains(prompt, "activity 49:") {
+		t.Fatalf("expected the summary and the first activities to be kept, got %q", prompt)
+	}
+	if len(resp.ContextDropped) != 1 || resp.ContextDropped[0].Section != "Related activities" || resp.ContextDropped[0].Items == 0 {
+		t.Fatalf("unexpected dropped report %+v", resp.ContextDropped)
+	}
+}
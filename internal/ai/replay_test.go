@@ -0,0 +1,120 @@
+package ai_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	dbfs "github.com/garnizeh/rag/db"
+	"github.com/garnizeh/rag/internal/ai"
+	"github.com/garnizeh/rag/internal/config"
+	"github.com/garnizeh/rag/internal/models"
+	"github.com/garnizeh/rag/pkg/ollama"
+)
+
+// fixtureModel is the model the fixtures in testdata/replay are requested with.
+const fixtureModel = "deepseek-r1:1.5b"
+
+// TestAnalyzeActivity_Replay replays Ollama answers to the seeded v1 activity
+// template. It is not a golden test: the fixtures checked in are synthetic, as
+// their note says, written from a stub imitating fixtureModel rather than
+// captured from it. It pins prompt rendering and response parsing only. The
+// fixtures hold the rendered prompts, so a template or context change fails
+// here until they are re-recorded against a running Ollama (OLLAMA_URL,
+// default http://localhost:11434), which also replaces them with real answers:
+//
+//	OLLAMA_RECORD=1 go test ./internal/ai -run Replay
+func TestAnalyzeActivity_Replay(t *testing.T) {
+	tpl, err := dbfs.SeedFiles.ReadFile("seed/template_activity_v1.txt")
+	if err != nil {
+		t.Fatalf("read seed template: %v", err)
+	}
+	schema, err := dbfs.SeedFiles.ReadFile("seed/schema_v1.json")
+	if err != nil {
+		t.Fatalf("read seed schema: %v", err)
+	}
+
+	cases := []struct {
+		name     string
+		activity string
+		context  []byte
+		profile  *models.ProfileData
+		project  string
+		tech     string
+	}{
+		{
+			name:     "deploy",
+			activity: "Deployed deploy-svc v2.3 to staging with Docker and fixed the health check",
+			context:  []byte(`{"summary":"Works on the deployment pipeline","projects":["deploy-svc"],"technologies":["Docker","Go"]}`),
+			profile:  &models.ProfileData{Role: "SRE", Team: "platform"},
+			project:  "deploy-svc",
+			tech:     "Docker",
+		},
+		{
+			// the model thinks aloud and fences its JSON
+			name:     "review_fenced",
+			activity: "Reviewed Bob's pull request adding Postgres migrations to billing-api",
+			project:  "billing-api",
+			tech:     "Postgres",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			mode := ollama.RecorderModeFromEnv()
+			rec, err := ollama.NewRecorder(filepath.Join("testdata", "replay", c.name+".json"), mode, nil)
+			if err != nil {
+				t.Fatalf("recorder: %v", err)
+			}
+			baseURL := "http://ollama.invalid:11434"
+			if mode == ollama.ModeRecord {
+				baseURL = os.Getenv("OLLAMA_URL")
+				if baseURL == "" {
+					baseURL = "http://localhost:11434"
+				}
+				defer func() {
+					if err := rec.Save(); err != nil {
+						t.Errorf("save fixture: %v", err)
+					}
+				}()
+			}
+			client, err := ollama.NewClient(config.OllamaConfig{BaseURL: baseURL, Timeout: 2 * time.Minute}, &http.Client{Transport: rec})
+			if err != nil {
+				t.Fatalf("new client: %v", err)
+			}
+			defer client.Close()
+
+			schemas := newFakeSchemaRepo()
+			if _, err := schemas.CreateSchema(ctx, "v1", "v1", string(schema)); err != nil {
+				t.Fatalf("seed schema: %v", err)
+			}
+			e, err := ai.NewEngine(ctx, client, config.EngineConfig{Model: fixtureModel, TemplateVersion: "v1", Timeout: 2 * time.Minute}, schemas, newFakeTemplateRepo(string(tpl)))
+			if err != nil {
+				t.Fatalf("new engine failed: %v", err)
+			}
+			sections, err := ai.ContextSections(c.context, nil)
+			if err != nil {
+				t.Fatalf("context sections: %v", err)
+			}
+
+			resp, err := e.AnalyzeActivity(ctx, models.Activity{ID: 1, EngineerID: 1, Activity: c.activity}, sections, c.profile)
+			if err != nil {
+				t.Fatalf("analyze: %v", err)
+			}
+			if resp.Summary == "" || resp.Model != fixtureModel || resp.Confidence == nil {
+				t.Fatalf("incomplete response: %+v", resp)
+			}
+			if !slices.Contains(resp.Entities.Projects, c.project) || !slices.Contains(resp.Entities.Technologies, c.tech) {
+				t.Fatalf("expected project %q and technology %q, got %+v", c.project, c.tech, resp.Entities)
+			}
+			if mode == ollama.ModeReplay && rec.Remaining() != 0 {
+				t.Fatalf("%d recorded requests were not made", rec.Remaining())
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MemberContext is one team member's stored context used as aggregation input.
+type MemberContext struct {
+	EngineerID  int64
+	Name        string
+	ContextJSON string
+}
+
+// TeamMemberSummary is the per-member part of a team context document.
+type TeamMemberSummary struct {
+	EngineerID int64  `json:"engineer_id"`
+	Name       string `json:"name"`
+	Summary    string `json:"summary,omitempty"`
+}
+
+// TeamContext aggregates members' contexts. Entity maps go from entity name to
+// the ids of the members whose context mentions it.
+type TeamContext struct {
+	TeamID       int64               `json:"team_id"`
+	Members      []TeamMemberSummary `json:"members"`
+	People       map[string][]int64  `json:"people"`
+	Projects     map[string][]int64  `json:"projects"`
+	Technologies map[string][]int64  `json:"technologies"`
+}
+
+// Expert is a team member whose context matches a topic.
+type Expert struct {
+	EngineerID int64    `json:"engineer_id"`
+	Name       string   `json:"name"`
+	Matches    []string `json:"matches"`
+}
+
+// AggregateTeamContext builds a team context document from member contexts.
+// Members whose context cannot be parsed are still listed but contribute no
+// entities. The function is pure; callers are responsible for only passing
+// contexts of actual team members.
+func AggregateTeamContext(teamID int64, members []MemberContext) *TeamContext {
+	tc := &TeamContext{
+		TeamID:       teamID,
+		Members:      make([]TeamMemberSummary, 0, len(members)),
+		People:       map[string][]int64{},
+		Projects:     map[string][]int64{},
+		Technologies: map[string][]int64{},
+	}
+
+	var parseErrs []string
+	for _, m := range members {
+		summary := TeamMemberSummary{EngineerID: m.EngineerID, Name: m.Name}
+
+		var cm ContextModel
+		if strings.TrimSpace(m.ContextJSON) != "" {
+			if err := json.Unmarshal([]byte(m.ContextJSON), &cm); err != nil {
+				parseErrs = append(parseErrs, fmt.Sprintf("engineer %d: %v", m.EngineerID, err))
+			}
+		}
+		if s, ok := cm["summary"].(string); ok {
+			summary.Summary = s
+		}
+		addEntities(tc.People, cm["people"], m.EngineerID)
+		addEntities(tc.Projects, cm["projects"], m.EngineerID)
+		addEntities(tc.Technologies, cm["technologies"], m.EngineerID)
+
+		tc.Members = append(tc.Members, summary)
+	}
+
+	if len(parseErrs) > 0 {
+		logger.Warn("team context: skipped unparsable member contexts", "team_id", teamID, "errors", parseErrs)
+	}
+
+	return tc
+}
+
+// addEntities records engineerID under each entity name found in v. Names are
+// de-duplicated case-insensitively, keeping the first spelling seen.
+func addEntities(dst map[string][]int64, v any, engineerID int64) {
+	list, ok := v.([]any)
+	if !ok {
+		return
+	}
+
+	for _, it := range list {
+		name, ok := it.(string)
+		if !ok || strings.TrimSpace(name) == "" {
+			continue
+		}
+		key := canonicalKey(dst, strings.TrimSpace(name))
+		ids := dst[key]
+		if len(ids) > 0 && ids[len(ids)-1] == engineerID {
+			continue
+		}
+		dst[key] = append(ids, engineerID)
+	}
+}
+
+func canonicalKey(m map[string][]int64, name string) string {
+	for k := range m {
+		if strings.EqualFold(k, name) {
+			return k
+		}
+	}
+	return name
+}
+
+// FindExperts returns members whose context mentions topic in technologies,
+// projects or people (case-insensitive substring match), ordered by number of
+// matches and then name.
+func FindExperts(tc *TeamContext, topic string) []Expert {
+	topic = strings.ToLower(strings.TrimSpace(topic))
+	if tc == nil || topic == "" {
+		return []Expert{}
+	}
+
+	names := make(map[int64]string, len(tc.Members))
+	for _, m := range tc.Members {
+		names[m.EngineerID] = m.Name
+	}
+
+	matches := map[int64][]string{}
+	collect := func(kind string, entities map[string][]int64) {
+		for entity, ids := range entities {
+			if !strings.Contains(strings.ToLower(entity), topic) {
+				continue
+			}
+			for _, id := range ids {
+				matches[id] = append(matches[id], kind+":"+entity)
+			}
+		}
+	}
+	collect("technologies", tc.Technologies)
+	collect("projects", tc.Projects)
+	collect("people", tc.People)
+
+	out := make([]Expert, 0, len(matches))
+	for id, m := range matches {
+		sort.Strings(m)
+		out = append(out, Expert{EngineerID: id, Name: names[id], Matches: m})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if len(out[i].Matches) != len(out[j].Matches) {
+			return len(out[i].Matches) > len(out[j].Matches)
+		}
+		return out[i].Name < out[j].Name
+	})
+
+	return out
+}
@@ -0,0 +1,47 @@
+package ai_test
+
+import (
+	"testing"
+
+	"github.com/garnizeh/rag/internal/ai"
+)
+
+func TestAggregateTeamContext(t *testing.T) {
+	tc := ai.AggregateTeamContext(1, []ai.MemberContext{
+		{EngineerID: 1, Name: "Alice", ContextJSON: `{"summary":"backend","technologies":["Go","Terraform"],"projects":["billing"]}`},
+		{EngineerID: 2, Name: "Bob", ContextJSON: `{"technologies":["terraform"],"people":["Alice"]}`},
+		{EngineerID: 3, Name: "Carol", ContextJSON: `not json`},
+	})
+
+	if len(tc.Members) != 3 {
+		t.Fatalf("expected 3 members, got %d", len(tc.Members))
+	}
+	if tc.Members[0].Summary != "backend" {
+		t.Fatalf("expected summary for alice, got %q", tc.Members[0].Summary)
+	}
+	if ids := tc.Technologies["Terraform"]; len(ids) != 2 {
+		t.Fatalf("expected terraform de-duplicated across members, got %v", tc.Technologies)
+	}
+	if _, ok := tc.Technologies["terraform"]; ok {
+		t.Fatalf("expected case-insensitive de-duplication, got %v", tc.Technologies)
+	}
+}
+
+func TestFindExperts(t *testing.T) {
+	tc := ai.AggregateTeamContext(1, []ai.MemberContext{
+		{EngineerID: 1, Name: "Alice", ContextJSON: `{"technologies":["Terraform"],"projects":["terraform-modules"]}`},
+		{EngineerID: 2, Name: "Bob", ContextJSON: `{"technologies":["Terraform"]}`},
+		{EngineerID: 3, Name: "Carol", ContextJSON: `{"technologies":["Go"]}`},
+	})
+
+	experts := ai.FindExperts(tc, "terraform")
+	if len(experts) != 2 {
+		t.Fatalf("expected 2 experts, got %+v", experts)
+	}
+	if experts[0].Name != "Alice" || len(experts[0].Matches) != 2 {
+		t.Fatalf("expected alice first with 2 matches, got %+v", experts[0])
+	}
+	if got := ai.FindExperts(tc, "  "); len(got) != 0 {
+		t.Fatalf("expected no experts for empty topic, got %+v", got)
+	}
+}
@@ -0,0 +1,99 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/garnizeh/rag/internal/config"
+	"github.com/garnizeh/rag/internal/models"
+	"github.com/garnizeh/rag/pkg/repository"
+)
+
+// ErrQuotaExceeded is returned by Quota.Check once an engineer has used up a
+// daily quota.
+var ErrQuotaExceeded = errors.New("daily llm quota exceeded")
+
+// QuotaError reports which daily quota was exceeded and when it resets.
+type QuotaError struct {
+	// Quota is "calls" or "tokens"
+	Quota string
+	Limit int64
+	Used  int64
+	Reset time.Time
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%v: %d of %d %s used, resets at %s", ErrQuotaExceeded, e.Used, e.Limit, e.Quota, e.Reset.Format(time.RFC3339))
+}
+
+func (e *QuotaError) Unwrap() error { return ErrQuotaExceeded }
+
+// Quota enforces per-engineer daily limits on llm_calls over the UTC day.
+// Only calls that used model time count: cached answers and calls no model
+// answered, e.g. during an Ollama outage, are free. A nil *Quota allows
+// everything.
+type Quota struct {
+	repo   repository.LLMUsageRepo
+	calls  int64
+	tokens int64
+}
+
+// NewQuota returns the quota configured in cfg, or nil when no limit is set.
+func NewQuota(repo repository.LLMUsageRepo, cfg config.LLMUsageConfig) *Quota {
+	if repo == nil || (cfg.DailyCalls <= 0 && cfg.DailyTokens <= 0) {
+		return nil
+	}
+	return &Quota{repo: repo, calls: cfg.DailyCalls, tokens: cfg.DailyTokens}
+}
+
+// Limits returns the daily call and token limits; zero means unlimited.
+func (q *Quota) Limits() (calls, tokens int64) {
+	if q == nil {
+		return 0, 0
+	}
+	return q.calls, q.tokens
+}
+
+// dayBounds returns the start of the UTC day of now and of the next one.
+func dayBounds(now time.Time) (time.Time, time.Time) {
+	start := now.UTC().Truncate(24 * time.Hour)
+	return start, start.Add(24 * time.Hour)
+}
+
+// DailyUsage returns what engineerID used on the UTC day of now.
+func DailyUsage(ctx context.Context, repo repository.LLMUsageRepo, engineerID int64, now time.Time) (models.LLMUsage, error) {
+	start, end := dayBounds(now)
+	id := engineerID
+	usage := models.LLMUsage{EngineerID: &id}
+	rows, err := repo.LLMUsage(ctx, models.LLMUsageFilter{GroupBy: models.LLMUsageByEngineer, EngineerID: engineerID, Since: start.UnixMilli(), Until: end.UnixMilli()})
+	if err != nil {
+		return usage, err
+	}
+	if len(rows) > 0 {
+		usage = rows[0]
+	}
+	return usage, nil
+}
+
+// Check returns a *QuotaError when engineerID has no calls or tokens left
+// today. Usage is counted when analyses finish, so queued ones may overrun a
+// quota by what they use.
+func (q *Quota) Check(ctx context.Context, engineerID int64, now time.Time) error {
+	if q == nil {
+		return nil
+	}
+	u, err := DailyUsage(ctx, q.repo, engineerID, now)
+	if err != nil {
+		return fmt.Errorf("llm usage: %w", err)
+	}
+	_, reset := dayBounds(now)
+	if used := u.BilledCalls; q.calls > 0 && used >= q.calls {
+		return &QuotaError{Quota: "calls", Limit: q.calls, Used: used, Reset: reset}
+	}
+	if used := u.PromptTokens + u.EvalTokens; q.tokens > 0 && used >= q.tokens {
+		return &QuotaError{Quota: "tokens", Limit: q.tokens, Used: used, Reset: reset}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package ai_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/garnizeh/rag/internal/ai"
+	"github.com/garnizeh/rag/internal/config"
+	"github.com/garnizeh/rag/internal/db"
+	"github.com/garnizeh/rag/internal/models"
+	sqlite "github.com/garnizeh/rag/internal/repository/sqlite"
+	"github.com/garnizeh/rag/pkg/ollama"
+)
+
+func TestAnalyzeActivity_RecordsUsageAndQuota(t *testing.T) {
+	answer := `{"version":"v1","summary":"Deployed","entities":{"people":[],"projects":[],"technologies":[]},"confidence":0.9,"context_update":false,"reasoning":"r"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Prompt string `json:"prompt"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		switch {
+		case strings.Contains(req.Prompt, "garbled"):
+			_ = json.NewEncoder(w).Encode(map[string]any{"model": "m", "response": "not json", "done": true, "eval_count": 3})
+			return
+		case strings.Contains(req.Prompt, "outage"):
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"error":"loading model"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"model": "m", "response": answer, "done": true,
+			"prompt_eval_count": 40, "eval_count": 12, "total_duration": 1500 * time.Millisecond})
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	d, err := db.New(ctx, "file:ai_llm_usage?mode=memory&cache=shared", nil)
+	if err != nil {
+		t.Fatalf("db.New: %v", err)
+	}
+	defer d.Close()
+	for _, s := range []string{
+		`CREATE TABLE llm_calls (id INTEGER PRIMARY KEY AUTOINCREMENT, engineer_id INTEGER, template_name TEXT NOT NULL, template_version TEXT NOT NULL, model TEXT NOT NULL, outcome TEXT NOT NULL, prompt_tokens INTEGER NOT NULL DEFAULT 0, eval_tokens INTEGER NOT NULL DEFAULT 0, duration_ms INTEGER NOT NULL DEFAULT 0, error TEXT, created INTEGER NOT NULL);`,
+		`CREATE TABLE llm_cache (key TEXT PRIMARY KEY, model TEXT NOT NULL, response TEXT NOT NULL, size INTEGER NOT NULL, hits INTEGER NOT NULL DEFAULT 0, expires_at INTEGER NOT NULL, last_used INTEGER NOT NULL, created INTEGER NOT NULL);`,
+	} {
+		if _, err := d.Exec(ctx, s); err != nil {
+			t.Fatalf("setup schema: %v", err)
+		}
+	}
+	repo := sqlite.New(d, nil)
+
+	client, err := ollama.NewClient(config.OllamaConfig{BaseURL: srv.URL, Timeout: 2 * time.Second}, srv.Client())
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	defer client.Close()
+	schemas := newFakeSchemaRepo()
+	if _, err := schemas.CreateSchema(ctx, "v1", "v1", `{"type":"object","required":["version","summary"]}`); err != nil {
+		t.Fatalf("seed schema: %v", err)
+	}
+	e, err := ai.NewEngine(ctx, client, config.EngineConfig{Model: "m", TemplateVersion: "v1"}, schemas, newFakeTemplateRepo("{{.Activity.Activity}}"))
+	if err != nil {
+		t.Fatalf("new engine failed: %v", err)
+	}
+	e.SetCache(ai.NewResponseCache(repo, config.LLMCacheConfig{TTL: time.Hour, MaxBytes: 1 << 20}))
+	e.SetUsageRepo(repo)
+
+	act := models.Activity{ID: 1, EngineerID: 7, Activity: "Deployed svc"}
+	for range 2 {
+		if _, err := e.AnalyzeActivity(ctx, act, nil, nil); err != nil {
+			t.Fatalf("analyze: %v", err)
+		}
+	}
+	if _, err := e.AnalyzeActivity(ctx, models.Activity{ID: 2, EngineerID: 7, Activity: "garbled"}, nil, nil); err == nil {
+		t.Fatal("expected a parse error")
+	}
+
+	now := time.Now()
+	u, err := ai.DailyUsage(ctx, repo, 7, now)
+	if err != nil {
+		t.Fatalf("daily usage: %v", err)
+	}
+	if u.Calls != 3 || u.CachedCalls != 1 || u.FailedCalls != 1 || u.BilledCalls != 2 || u.PromptTokens != 40 || u.EvalTokens != 15 || u.DurationMS < 1500 {
+		t.Fatalf("unexpected usage: %+v", u)
+	}
+	byTemplate, err := repo.LLMUsage(ctx, models.LLMUsageFilter{GroupBy: models.LLMUsageByTemplate})
+	if err != nil || len(byTemplate) != 1 || byTemplate[0].TemplateName != "activity" || byTemplate[0].TemplateVersion != "v1" {
+		t.Fatalf("unexpected template usage: %+v, %v", byTemplate, err)
+	}
+
+	// the cached answer is free; the rejected answer used the model: two calls used
+	var qe *ai.QuotaError
+	if err := ai.NewQuota(repo, config.LLMUsageConfig{DailyCalls: 2}).Check(ctx, 7, now); !errors.As(err, &qe) || qe.Quota != "calls" || qe.Used != 2 || !errors.Is(err, ai.ErrQuotaExceeded) {
+		t.Fatalf("expected the call quota exceeded, got %v", err)
+	}
+	if err := ai.NewQuota(repo, config.LLMUsageConfig{DailyCalls: 3, DailyTokens: 1000}).Check(ctx, 7, now); err != nil {
+		t.Fatalf("expected quota left, got %v", err)
+	}
+	if err := ai.NewQuota(repo, config.LLMUsageConfig{DailyTokens: 50}).Check(ctx, 7, now); !errors.As(err, &qe) || qe.Quota != "tokens" {
+		t.Fatalf("expected the token quota exceeded, got %v", err)
+	}
+	// usage from yesterday does not count today
+	if err := ai.NewQuota(repo, config.LLMUsageConfig{DailyCalls: 1}).Check(ctx, 7, now.Add(24*time.Hour)); err != nil {
+		t.Fatalf("expected a fresh quota tomorrow, got %v", err)
+	}
+	// calls no model answered, e.g. retries during an outage, are free
+	for range 3 {
+		if _, err := e.AnalyzeActivity(ctx, models.Activity{ID: 3, EngineerID: 8, Activity: "outage"}, nil, nil); err == nil {
+			t.Fatal("expected the outage to fail the analysis")
+		}
+	}
+	if u, err := ai.DailyUsage(ctx, repo, 8, now); err != nil || u.FailedCalls != 3 || u.BilledCalls != 0 {
+		t.Fatalf("unexpected outage usage: %+v, %v", u, err)
+	}
+	if err := ai.NewQuota(repo, config.LLMUsageConfig{DailyCalls: 1}).Check(ctx, 8, now); err != nil {
+		t.Fatalf("expected failed calls not to use the quota, got %v", err)
+	}
+	if ai.NewQuota(repo, config.LLMUsageConfig{}) != nil {
+		t.Fatal("expected no quota without limits")
+	}
+}
@@ -15,8 +15,94 @@ type Config struct {
 	APITimeout     time.Duration `yaml:"timeout"`
 	TokenDuration  time.Duration `yaml:"token_duration"`
 	MigrateOnStart bool          `yaml:"migrate_on_start"`
-	EngineConfig   EngineConfig  `yaml:"engine"`
-	Ollama         OllamaConfig  `yaml:"ollama"`
+	// PasswordResetTTL bounds how long a password reset token stays valid
+	PasswordResetTTL time.Duration `yaml:"password_reset_ttl"`
+	// AdminEmails lists the engineers allowed to use the /v1/admin endpoints
+	AdminEmails []string `yaml:"admin_emails"`
+	// MetricsToken, when set, is the bearer token scrapers must send to /metrics
+	MetricsToken string          `yaml:"metrics_token"`
+	EngineConfig EngineConfig    `yaml:"engine"`
+	Ollama       OllamaConfig    `yaml:"ollama"`
+	RateLimit    RateLimitConfig `yaml:"rate_limit"`
+	Privacy      PrivacyConfig   `yaml:"privacy"`
+	Jobs         JobsConfig      `yaml:"jobs"`
+	LLMCache     LLMCacheConfig  `yaml:"llm_cache"`
+	LLMUsage     LLMUsageConfig  `yaml:"llm_usage"`
+}
+
+// LLMUsageConfig sets optional per-engineer daily LLM quotas, counted over
+// the UTC day. Cached answers do not count. Zero leaves a quota off.
+type LLMUsageConfig struct {
+	DailyCalls  int64 `yaml:"daily_calls"`
+	DailyTokens int64 `yaml:"daily_tokens"`
+}
+
+// LLMCacheConfig controls the persistent cache of LLM responses. Entries
+// expire after TTL; once the cache holds more than MaxBytes the least recently
+// used entries are evicted.
+type LLMCacheConfig struct {
+	Disabled bool          `yaml:"disabled"`
+	TTL      time.Duration `yaml:"ttl"`
+	MaxBytes int64         `yaml:"max_bytes"`
+}
+
+// JobsConfig tunes the background worker pool.
+type JobsConfig struct {
+	Workers int `yaml:"workers"`
+	// Lease is how long a claimed job is reserved for its worker
+	Lease time.Duration `yaml:"lease"`
+	// HandlerTimeout bounds a single handler run; capped at Lease
+	HandlerTimeout time.Duration `yaml:"handler_timeout"`
+	// DrainTimeout is how long shutdown waits for in-flight jobs before interrupting them
+	DrainTimeout time.Duration `yaml:"drain_timeout"`
+	// ScheduleInterval is how often recurring job schedules are checked for due runs
+	ScheduleInterval time.Duration `yaml:"schedule_interval"`
+	// MetricsInterval is how often the stored job counts served on metrics are recounted
+	MetricsInterval time.Duration `yaml:"metrics_interval"`
+	// Queues splits job types into named queues with their own concurrency
+	// caps; types not listed run in the "default" queue
+	Queues []JobQueueConfig `yaml:"queues"`
+}
+
+// DefaultJobQueue is the queue of job types not assigned to any other queue.
+const DefaultJobQueue = "default"
+
+// JobQueueConfig groups job types into a named queue. A queue named "default"
+// takes no types and configures the queue of all unlisted types.
+type JobQueueConfig struct {
+	Name  string   `yaml:"name"`
+	Types []string `yaml:"types"`
+	// Concurrency caps how many jobs of this queue one process runs at once; 0 means only the worker count applies
+	Concurrency int `yaml:"concurrency"`
+	// Weight is the queue's relative share of free workers when several queues have work; defaults to 1
+	Weight int `yaml:"weight"`
+}
+
+// PrivacyConfig controls PII redaction of text sent to the LLM. Built-in rules
+// (emails, tokens, IPs) always apply unless redaction is disabled; Patterns adds
+// deployment-specific regexes on top.
+type PrivacyConfig struct {
+	DisableRedaction bool               `yaml:"disable_redaction"`
+	Patterns         []RedactionPattern `yaml:"redact_patterns"`
+}
+
+type RedactionPattern struct {
+	Name    string `yaml:"name"`
+	Pattern string `yaml:"pattern"`
+}
+
+// RateLimitConfig configures request throttling and sign-in lockout. Rates are
+// requests per second refilled into a token bucket of size Burst.
+type RateLimitConfig struct {
+	Disabled         bool          `yaml:"disabled"`
+	IPRate           float64       `yaml:"ip_rate"`
+	IPBurst          int           `yaml:"ip_burst"`
+	AccountRate      float64       `yaml:"account_rate"`
+	AccountBurst     int           `yaml:"account_burst"`
+	AIRate           float64       `yaml:"ai_rate"`
+	AIBurst          int           `yaml:"ai_burst"`
+	LoginMaxFailures int           `yaml:"login_max_failures"`
+	LoginLockout     time.Duration `yaml:"login_lockout"`
 }
 
 type EngineConfig struct {
@@ -24,16 +110,37 @@ type EngineConfig struct {
 	TemplateVersion string        `yaml:"template_version"`
 	Timeout         time.Duration `yaml:"timeout"`
 	MinConfidence   float64       `yaml:"min_confidence"`
+	// ContextWindow is the prompt window in tokens when the template sets no
+	// num_ctx; it is lowered to a model's own context length when smaller
+	ContextWindow int `yaml:"context_window"`
+	// ResponseTokens is the part of the window kept free for the answer
+	ResponseTokens int `yaml:"response_tokens"`
 }
 
+// Ollama load-balancing strategies for OllamaConfig.Balance.
+const (
+	BalanceRoundRobin    = "round_robin"
+	BalanceLeastInFlight = "least_in_flight"
+)
+
 type OllamaConfig struct {
-	BaseURL                 string        `yaml:"base_url"`
-	DefaultModelNames       []string      `yaml:"models"`
-	Timeout                 time.Duration `yaml:"timeout"`
-	Retries                 int           `yaml:"retries"`
-	Backoff                 time.Duration `yaml:"backoff"`
+	BaseURL string `yaml:"base_url"`
+	// Endpoints lists several Ollama servers to spread requests over; when
+	// empty, BaseURL is the only endpoint
+	Endpoints []string `yaml:"endpoints"`
+	// Balance picks the endpoint tried first: round_robin or least_in_flight
+	Balance           string        `yaml:"balance"`
+	DefaultModelNames []string      `yaml:"models"`
+	Timeout           time.Duration `yaml:"timeout"`
+	Retries           int           `yaml:"retries"`
+	Backoff           time.Duration `yaml:"backoff"`
+	// MaxBackoff caps the exponential backoff between Generate retries
+	MaxBackoff              time.Duration `yaml:"max_backoff"`
 	CircuitFailureThreshold int           `yaml:"circuit_failure_threshold"`
 	CircuitReset            time.Duration `yaml:"circuit_reset"`
+	// CircuitHalfOpenRequests is how many trial requests a half-open circuit
+	// lets through at once after the reset period
+	CircuitHalfOpenRequests int `yaml:"circuit_half_open_requests"`
 }
 
 func LoadConfig(path string) (*Config, error) {
@@ -43,6 +150,7 @@ func LoadConfig(path string) (*Config, error) {
 	cfg := &Config{
 		Addr:           getEnv("RAG_ADDR", ":8080"),
 		JWTSecret:      getEnv("RAG_JWT_SECRET", "supersecretkey"),
+		MetricsToken:   getEnv("RAG_METRICS_TOKEN", ""),
 		APITimeout:     apiTimeout,
 		DatabasePath:   getEnv("RAG_DATABASE_PATH", "rag.db"),
 		TokenDuration:  tokenDuration,
@@ -79,6 +187,9 @@ func (c *Config) Validate() error {
 	if c.TokenDuration <= 0 {
 		return fmt.Errorf("token_duration must be > 0")
 	}
+	if c.PasswordResetTTL <= 0 {
+		c.PasswordResetTTL = 1 * time.Hour
+	}
 	if c.EngineConfig.Model == "" {
 		// engine model is required for AI features
 		return fmt.Errorf("engine.model must be set")
@@ -104,15 +215,133 @@ func (c *Config) Validate() error {
 	if c.Ollama.Backoff == 0 {
 		c.Ollama.Backoff = 500 * time.Millisecond
 	}
+	if c.Ollama.MaxBackoff == 0 {
+		c.Ollama.MaxBackoff = 10 * time.Second
+	}
+	if c.Ollama.MaxBackoff < c.Ollama.Backoff {
+		return fmt.Errorf("ollama.max_backoff (%s) must not be below ollama.backoff (%s)", c.Ollama.MaxBackoff, c.Ollama.Backoff)
+	}
 	if c.Ollama.CircuitFailureThreshold == 0 {
 		c.Ollama.CircuitFailureThreshold = 5
 	}
 	if c.Ollama.CircuitReset == 0 {
 		c.Ollama.CircuitReset = 30 * time.Second
 	}
+	if c.Ollama.CircuitHalfOpenRequests == 0 {
+		c.Ollama.CircuitHalfOpenRequests = 1
+	}
 	if len(c.Ollama.DefaultModelNames) == 0 {
 		c.Ollama.DefaultModelNames = []string{"deepseek-r1:32b", "llama3"}
 	}
+	switch c.Ollama.Balance {
+	case "":
+		c.Ollama.Balance = BalanceRoundRobin
+	case BalanceRoundRobin, BalanceLeastInFlight:
+	default:
+		return fmt.Errorf("ollama.balance must be %s or %s, got %q", BalanceRoundRobin, BalanceLeastInFlight, c.Ollama.Balance)
+	}
+
+	// Rate limiting defaults
+	if c.RateLimit.IPRate == 0 {
+		c.RateLimit.IPRate = 10
+	}
+	if c.RateLimit.IPBurst == 0 {
+		c.RateLimit.IPBurst = 20
+	}
+	if c.RateLimit.AccountRate == 0 {
+		c.RateLimit.AccountRate = 5
+	}
+	if c.RateLimit.AccountBurst == 0 {
+		c.RateLimit.AccountBurst = 10
+	}
+	if c.RateLimit.AIRate == 0 {
+		// expensive endpoints: one every 10 seconds on average
+		c.RateLimit.AIRate = 0.1
+	}
+	if c.RateLimit.AIBurst == 0 {
+		c.RateLimit.AIBurst = 5
+	}
+	if c.RateLimit.LoginMaxFailures == 0 {
+		c.RateLimit.LoginMaxFailures = 5
+	}
+	if c.RateLimit.LoginLockout == 0 {
+		c.RateLimit.LoginLockout = 15 * time.Minute
+	}
+
+	// Background job defaults
+	if c.Jobs.Workers <= 0 {
+		c.Jobs.Workers = 4
+	}
+	if c.Jobs.Lease <= 0 {
+		c.Jobs.Lease = 5 * time.Minute
+	}
+	if c.Jobs.HandlerTimeout <= 0 {
+		c.Jobs.HandlerTimeout = 2 * time.Minute
+	}
+	if c.Jobs.DrainTimeout <= 0 {
+		c.Jobs.DrainTimeout = 30 * time.Second
+	}
+	if c.Jobs.ScheduleInterval <= 0 {
+		c.Jobs.ScheduleInterval = 15 * time.Second
+	}
+	if c.Jobs.MetricsInterval <= 0 {
+		c.Jobs.MetricsInterval = 30 * time.Second
+	}
+	if c.Jobs.HandlerTimeout > c.Jobs.Lease {
+		return fmt.Errorf("jobs.handler_timeout (%s) must not exceed jobs.lease (%s)", c.Jobs.HandlerTimeout, c.Jobs.Lease)
+	}
+	if err := validateJobQueues(c.Jobs.Queues); err != nil {
+		return err
+	}
+
+	// LLM response cache defaults
+	if c.LLMCache.TTL <= 0 {
+		c.LLMCache.TTL = 7 * 24 * time.Hour
+	}
+	if c.LLMCache.MaxBytes <= 0 {
+		c.LLMCache.MaxBytes = 64 << 20
+	}
+	if c.LLMUsage.DailyCalls < 0 || c.LLMUsage.DailyTokens < 0 {
+		return fmt.Errorf("llm_usage quotas must not be negative")
+	}
+
+	return nil
+}
+
+func validateJobQueues(queues []JobQueueConfig) error {
+	names := map[string]bool{}
+	owner := map[string]string{}
+	for i := range queues {
+		q := &queues[i]
+		if q.Name == "" {
+			return fmt.Errorf("jobs.queues[%d]: name is required", i)
+		}
+		if names[q.Name] {
+			return fmt.Errorf("jobs.queues: duplicate queue %q", q.Name)
+		}
+		names[q.Name] = true
+		if q.Concurrency < 0 || q.Weight < 0 {
+			return fmt.Errorf("jobs.queues %q: concurrency and weight must not be negative", q.Name)
+		}
+		if q.Weight == 0 {
+			q.Weight = 1
+		}
+		if q.Name == DefaultJobQueue {
+			if len(q.Types) > 0 {
+				return fmt.Errorf("jobs.queues %q: the default queue takes every unlisted type and cannot list types", q.Name)
+			}
+			continue
+		}
+		if len(q.Types) == 0 {
+			return fmt.Errorf("jobs.queues %q: at least one job type is required", q.Name)
+		}
+		for _, t := range q.Types {
+			if prev, ok := owner[t]; ok {
+				return fmt.Errorf("jobs.queues: job type %q is in both %q and %q", t, prev, q.Name)
+			}
+			owner[t] = q.Name
+		}
+	}
 
 	return nil
 }
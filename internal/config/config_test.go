@@ -87,6 +87,33 @@ func TestValidate_OllamaDefaultsPopulated(t *testing.T) {
 	if cfg.Ollama.Retries == 0 {
 		t.Fatalf("expected Ollama.Retries default to be non-zero")
 	}
+	if cfg.Ollama.Balance != config.BalanceRoundRobin {
+		t.Fatalf("expected Ollama.Balance default %q, got %q", config.BalanceRoundRobin, cfg.Ollama.Balance)
+	}
+
+	if cfg.Ollama.MaxBackoff < cfg.Ollama.Backoff || cfg.Ollama.CircuitHalfOpenRequests != 1 {
+		t.Fatalf("unexpected backoff/half-open defaults: %+v", cfg.Ollama)
+	}
+	if cfg.LLMCache.TTL <= 0 || cfg.LLMCache.MaxBytes <= 0 {
+		t.Fatalf("expected llm_cache defaults, got %+v", cfg.LLMCache)
+	}
+
+	cfg.Ollama.Balance = "random"
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expected validation error for unknown ollama.balance")
+	}
+
+	cfg.Ollama.Balance = config.BalanceRoundRobin
+	cfg.Ollama.MaxBackoff = cfg.Ollama.Backoff / 2
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expected validation error for max_backoff below backoff")
+	}
+
+	cfg.Ollama.MaxBackoff = cfg.Ollama.Backoff
+	cfg.LLMUsage.DailyCalls = -1
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expected validation error for a negative llm_usage quota")
+	}
 }
 
 func TestLoadConfig_Defaults(t *testing.T) {
@@ -195,3 +222,96 @@ func TestValidate_InsecureJWT(t *testing.T) {
 		t.Fatalf("expected validation error for insecure jwt secret, got nil")
 	}
 }
+
+func TestValidate_RateLimitDefaultsPopulated(t *testing.T) {
+	os.Setenv("RAG_ENV", "development")
+	defer os.Unsetenv("RAG_ENV")
+
+	cfg := &config.Config{
+		Addr:          ":8080",
+		JWTSecret:     "strongsecret",
+		APITimeout:    5 * time.Second,
+		DatabasePath:  "rag.db",
+		TokenDuration: 1 * time.Hour,
+		EngineConfig:  config.EngineConfig{Model: "m"},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate failed unexpectedly: %v", err)
+	}
+
+	rl := cfg.RateLimit
+	if rl.IPRate <= 0 || rl.IPBurst <= 0 || rl.AccountRate <= 0 || rl.AIRate <= 0 {
+		t.Fatalf("expected positive rate defaults, got %+v", rl)
+	}
+	if rl.LoginMaxFailures <= 0 || rl.LoginLockout <= 0 {
+		t.Fatalf("expected lockout defaults, got %+v", rl)
+	}
+}
+
+func TestValidate_JobsDefaultsAndTimeoutBound(t *testing.T) {
+	os.Setenv("RAG_ENV", "development")
+	defer os.Unsetenv("RAG_ENV")
+
+	cfg := &config.Config{
+		Addr:          ":8080",
+		JWTSecret:     "strongsecret",
+		APITimeout:    5 * time.Second,
+		DatabasePath:  "rag.db",
+		TokenDuration: 1 * time.Hour,
+		EngineConfig:  config.EngineConfig{Model: "m"},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate failed unexpectedly: %v", err)
+	}
+	if j := cfg.Jobs; j.Workers <= 0 || j.Lease <= 0 || j.HandlerTimeout <= 0 || j.DrainTimeout <= 0 || j.ScheduleInterval <= 0 {
+		t.Fatalf("expected positive job defaults, got %+v", j)
+	}
+
+	cfg.Jobs.HandlerTimeout = cfg.Jobs.Lease + time.Second
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expected error when handler_timeout exceeds lease")
+	}
+}
+
+func TestValidate_JobQueues(t *testing.T) {
+	os.Setenv("RAG_ENV", "development")
+	defer os.Unsetenv("RAG_ENV")
+
+	base := config.Config{
+		Addr:          ":8080",
+		JWTSecret:     "strongsecret",
+		APITimeout:    5 * time.Second,
+		DatabasePath:  "rag.db",
+		TokenDuration: 1 * time.Hour,
+		EngineConfig:  config.EngineConfig{Model: "m"},
+	}
+
+	cfg := base
+	cfg.Jobs.Queues = []config.JobQueueConfig{
+		{Name: "ai", Types: []string{"ai.analyze_activity"}, Concurrency: 1},
+		{Name: config.DefaultJobQueue, Weight: 3},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate failed unexpectedly: %v", err)
+	}
+	if cfg.Jobs.Queues[0].Weight != 1 {
+		t.Fatalf("expected default weight 1, got %d", cfg.Jobs.Queues[0].Weight)
+	}
+
+	bad := map[string][]config.JobQueueConfig{
+		"missing name":     {{Types: []string{"a"}}},
+		"duplicate name":   {{Name: "q", Types: []string{"a"}}, {Name: "q", Types: []string{"b"}}},
+		"no types":         {{Name: "q"}},
+		"type in two":      {{Name: "q1", Types: []string{"a"}}, {Name: "q2", Types: []string{"a"}}},
+		"default types":    {{Name: config.DefaultJobQueue, Types: []string{"a"}}},
+		"negative weight":  {{Name: "q", Types: []string{"a"}, Weight: -1}},
+		"negative workers": {{Name: "q", Types: []string{"a"}, Concurrency: -1}},
+	}
+	for name, queues := range bad {
+		cfg := base
+		cfg.Jobs.Queues = queues
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
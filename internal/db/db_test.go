@@ -73,6 +73,8 @@ func TestNew_BadDSN(t *testing.T) {
 	// filename and succeed. To avoid a flaky test across environments, allow
 	// either an error (driver rejects the DSN) or a successful DB. If a DB is
 	// returned ensure it is usable and closed.
+	// a driver that accepts it creates the file in the working directory
+	t.Chdir(t.TempDir())
 	d, err := dbpkg.New(ctx, ":invalid-dsn:", nil)
 	if err != nil {
 		// driver rejected the DSN — acceptable
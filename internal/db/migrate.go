@@ -82,6 +82,13 @@ func Migrate(ctx context.Context, d *DB, migrationFS embed.FS, seedFS embed.FS)
 		}
 	}
 
+	profileSchemaPath := path.Join("seed", "schema_profile_v1.json")
+	if b, err := fs.ReadFile(seedFS, profileSchemaPath); err == nil {
+		if _, err := d.Exec(ctx, `INSERT OR REPLACE INTO ai_schemas (version, description, schema_json, created, updated) VALUES ('profile_v1', 'engineer profile schema', ?, strftime('%s','now'), strftime('%s','now'))`, string(b)); err != nil {
+			return fmt.Errorf("seed profile schema exec: %w", err)
+		}
+	}
+
 	templatePath := path.Join("seed", "template_activity_v1.txt")
 	if b, err := fs.ReadFile(seedFS, templatePath); err == nil {
 		if _, err := d.Exec(ctx, `INSERT OR REPLACE INTO ai_templates (name, version, template_text, schema_version, metadata, created, updated) VALUES ('activity', 'v1', ?, ?, ?, strftime('%s','now'), strftime('%s','now'))`, string(b), "v1", `{"owner":"system","description":"default activity template"}`); err != nil {
@@ -2,7 +2,11 @@ package db_test
 
 import (
 	"context"
+	"fmt"
+	"io/fs"
+	"slices"
 	"testing"
+	"time"
 
 	dbfs "github.com/garnizeh/rag/db"
 	"github.com/garnizeh/rag/internal/db"
@@ -47,3 +51,122 @@ func TestMigrate_Idempotent(t *testing.T) {
 		t.Fatalf("expected engineers table exists: %v", err)
 	}
 }
+
+// TestMigrate_RetireProcessingJobs applies the processing_jobs retirement to a
+// database holding legacy rows and checks they are folded into jobs.
+func TestMigrate_RetireProcessingJobs(t *testing.T) {
+	ctx := context.Background()
+
+	d, err := db.New(ctx, ":memory:", nil)
+	if err != nil {
+		t.Fatalf("failed to open in-memory db: %v", err)
+	}
+	defer d.Close()
+
+	for _, s := range []string{
+		`CREATE TABLE processing_jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, status TEXT NOT NULL, created INTEGER NOT NULL)`,
+		`CREATE INDEX idx_jobs_status_created ON processing_jobs(status, created)`,
+		`CREATE TABLE jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, type TEXT NOT NULL, payload TEXT, status TEXT NOT NULL DEFAULT 'queued', attempts INTEGER NOT NULL DEFAULT 0, max_attempts INTEGER NOT NULL DEFAULT 5, priority INTEGER NOT NULL DEFAULT 100, scheduled_at INTEGER NOT NULL, next_try_at INTEGER, last_error TEXT, created INTEGER NOT NULL, updated INTEGER NOT NULL)`,
+		`INSERT INTO processing_jobs (status, created) VALUES ('pending', 1760000000000), ('done', 1760000001000)`,
+	} {
+		if _, err := d.Exec(ctx, s); err != nil {
+			t.Fatalf("setup schema: %v", err)
+		}
+	}
+
+	b, err := fs.ReadFile(dbfs.Migrations, "migrations/0012_retire_processing_jobs.sql")
+	if err != nil {
+		t.Fatalf("read migration: %v", err)
+	}
+	if _, err := d.Exec(ctx, string(b)); err != nil {
+		t.Fatalf("apply migration: %v", err)
+	}
+
+	rows, err := d.QueryRows(ctx, `SELECT type, status, scheduled_at, created FROM jobs ORDER BY id`)
+	if err != nil {
+		t.Fatalf("query jobs: %v", err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var (
+			typ, status        string
+			scheduled, created int64
+		)
+		if err := rows.Scan(&typ, &status, &scheduled, &created); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		if typ != "legacy.processing" || scheduled != created/1000 {
+			t.Errorf("unexpected folded row: type=%q scheduled_at=%d created=%d", typ, scheduled, created)
+		}
+		got = append(got, status)
+	}
+	if len(got) != 2 || got[0] != "canceled" || got[1] != "done" {
+		t.Fatalf("expected statuses [canceled done], got %v", got)
+	}
+
+	var n int
+	if err := d.QueryRow(ctx, `SELECT COUNT(1) FROM sqlite_master WHERE name = 'processing_jobs'`).Scan(&n); err != nil || n != 0 {
+		t.Fatalf("expected processing_jobs to be dropped, count=%d err=%v", n, err)
+	}
+}
+
+// TestMigrate_JobsUpdatedMillis checks that job timestamps written in unix
+// seconds are converted to milliseconds, so purge cutoffs compare them by age.
+func TestMigrate_JobsUpdatedMillis(t *testing.T) {
+	ctx := context.Background()
+
+	d, err := db.New(ctx, ":memory:", nil)
+	if err != nil {
+		t.Fatalf("failed to open in-memory db: %v", err)
+	}
+	defer d.Close()
+
+	recent := time.Now().Add(-time.Hour)
+	old := time.Now().Add(-90 * 24 * time.Hour)
+	for _, s := range []string{
+		`CREATE TABLE jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, type TEXT NOT NULL, payload TEXT, status TEXT NOT NULL DEFAULT 'queued', attempts INTEGER NOT NULL DEFAULT 0, max_attempts INTEGER NOT NULL DEFAULT 5, priority INTEGER NOT NULL DEFAULT 100, scheduled_at INTEGER NOT NULL, next_try_at INTEGER, last_error TEXT, created INTEGER NOT NULL, updated INTEGER NOT NULL)`,
+		// finished by the old UpdateJob: updated in seconds
+		fmt.Sprintf(`INSERT INTO jobs (type, status, scheduled_at, created, updated) VALUES ('t', 'done', 0, %d, %d)`, recent.UnixMilli(), recent.Unix()),
+		fmt.Sprintf(`INSERT INTO jobs (type, status, scheduled_at, created, updated) VALUES ('t', 'done', 0, %d, %d)`, old.UnixMilli(), old.Unix()),
+		// already in milliseconds
+		fmt.Sprintf(`INSERT INTO jobs (type, status, scheduled_at, created, updated) VALUES ('t', 'done', 0, %d, %d)`, recent.UnixMilli(), recent.UnixMilli()),
+	} {
+		if _, err := d.Exec(ctx, s); err != nil {
+			t.Fatalf("setup schema: %v", err)
+		}
+	}
+
+	b, err := fs.ReadFile(dbfs.Migrations, "migrations/0016_jobs_updated_millis.sql")
+	if err != nil {
+		t.Fatalf("read migration: %v", err)
+	}
+	if _, err := d.Exec(ctx, string(b)); err != nil {
+		t.Fatalf("apply migration: %v", err)
+	}
+
+	rows, err := d.QueryRows(ctx, `SELECT updated FROM jobs ORDER BY id`)
+	if err != nil {
+		t.Fatalf("query jobs: %v", err)
+	}
+	defer rows.Close()
+	var got []int64
+	for rows.Next() {
+		var updated int64
+		if err := rows.Scan(&updated); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		got = append(got, updated)
+	}
+	want := []int64{recent.Unix() * 1000, old.Unix() * 1000, recent.UnixMilli()}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected updated %v, got %v", want, got)
+	}
+
+	// only the old row is past a 30 day cutoff
+	var n int
+	cutoff := time.Now().Add(-30 * 24 * time.Hour).UnixMilli()
+	if err := d.QueryRow(ctx, `SELECT COUNT(1) FROM jobs WHERE updated < ?`, cutoff).Scan(&n); err != nil || n != 1 {
+		t.Fatalf("expected 1 job older than the cutoff, got %d err=%v", n, err)
+	}
+}
@@ -28,7 +28,7 @@ func TestAIProcessJobIntegration(t *testing.T) {
 	defer d.Close()
 
 	// create minimal tables: jobs and contexts + history
-	if _, err := d.Exec(ctx, `CREATE TABLE IF NOT EXISTS jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, type TEXT NOT NULL, payload TEXT, status TEXT NOT NULL DEFAULT 'queued', attempts INTEGER NOT NULL DEFAULT 0, max_attempts INTEGER NOT NULL DEFAULT 5, priority INTEGER NOT NULL DEFAULT 100, scheduled_at INTEGER NOT NULL, next_try_at INTEGER, last_error TEXT, created INTEGER NOT NULL, updated INTEGER NOT NULL)`); err != nil {
+	if _, err := d.Exec(ctx, `CREATE TABLE IF NOT EXISTS jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, type TEXT NOT NULL, payload TEXT, status TEXT NOT NULL DEFAULT 'queued', attempts INTEGER NOT NULL DEFAULT 0, max_attempts INTEGER NOT NULL DEFAULT 5, priority INTEGER NOT NULL DEFAULT 100, scheduled_at INTEGER NOT NULL, next_try_at INTEGER, last_error TEXT, locked_by TEXT, lease_until INTEGER, dedupe_key TEXT, result TEXT, engineer_id INTEGER, created INTEGER NOT NULL, updated INTEGER NOT NULL)`); err != nil {
 		t.Fatalf("create jobs table: %v", err)
 	}
 	if _, err := d.Exec(ctx, `CREATE TABLE IF NOT EXISTS engineer_contexts (id INTEGER PRIMARY KEY AUTOINCREMENT, engineer_id INTEGER NOT NULL UNIQUE, context_json TEXT NOT NULL, version INTEGER NOT NULL DEFAULT 1, updated INTEGER NOT NULL)`); err != nil {
@@ -48,23 +48,23 @@ func TestAIProcessJobIntegration(t *testing.T) {
 	// reuse sqliteRepo for domain repositories used by ProcessAIResponse
 	// build handler map
 	handlers := map[string]jobs.Handler{
-		"ai.process_response": func(ctx context.Context, j *models.BackgroundJob) error {
+		"ai.process_response": func(ctx context.Context, j *models.BackgroundJob) (any, error) {
 			// payload expected: {"engineer_id":123, "response": <AIResponse JSON>}
 			var pl struct {
 				EngineerID int64           `json:"engineer_id"`
 				Response   json.RawMessage `json:"response"`
 			}
 			if err := json.Unmarshal(j.Payload, &pl); err != nil {
-				return err
+				return nil, err
 			}
 			var resp ai.AIResponse
 			if err := json.Unmarshal(pl.Response, &resp); err != nil {
-				return err
+				return nil, err
 			}
 			// construct repository.Repository mapping to sqliteRepo implementations
 			r := &repository.Repository{Context: sqliteRepo, Question: sqliteRepo}
 			_, err := ai.ProcessAIResponse(ctx, r, pl.EngineerID, &resp)
-			return err
+			return nil, err
 		},
 	}
 
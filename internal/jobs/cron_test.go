@@ -0,0 +1,57 @@
+package jobs_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/garnizeh/rag/internal/jobs"
+)
+
+func TestParseScheduleNext(t *testing.T) {
+	// Friday 2026-10-16 10:07:30 UTC
+	from := time.Date(2026, 10, 16, 10, 7, 30, 0, time.UTC)
+
+	cases := []struct {
+		spec string
+		want time.Time
+	}{
+		{"@every 15m", from.Add(15 * time.Minute)},
+		{"*/10 * * * *", time.Date(2026, 10, 16, 10, 10, 0, 0, time.UTC)},
+		{"0 9-17 * * 1-5", time.Date(2026, 10, 16, 11, 0, 0, 0, time.UTC)},
+		{"30 2 * * 1", time.Date(2026, 10, 19, 2, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		// restricted day-of-month and day-of-week match either
+		{"0 0 20 * 6", time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// a stepped "*" still counts as unrestricted, so both fields must match
+		{"0 0 */2 * 1", time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 20 * */7", time.Date(2026, 12, 20, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		s, err := jobs.ParseSchedule(c.spec)
+		if err != nil {
+			t.Fatalf("%q: %v", c.spec, err)
+		}
+		if got := s.Next(from); !got.Equal(c.want) {
+			t.Errorf("%q: expected %s got %s", c.spec, c.want, got)
+		}
+	}
+
+	never, err := jobs.ParseSchedule("0 0 30 2 *")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := never.Next(from); !got.IsZero() {
+		t.Errorf("expected impossible date never to fire, got %s", got)
+	}
+}
+
+func TestParseScheduleInvalid(t *testing.T) {
+	for _, spec := range []string{"", "@every 10ms", "@every soon", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		if _, err := jobs.ParseSchedule(spec); err == nil {
+			t.Errorf("%q: expected error", spec)
+		}
+	}
+}
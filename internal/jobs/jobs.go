@@ -8,12 +8,37 @@ import (
 	"github.com/garnizeh/rag/internal/models"
 )
 
-// Handler is the function that processes a job
-type Handler func(ctx context.Context, j *models.BackgroundJob) error
+// Handler is the function that processes a job. A non-nil result is stored as
+// JSON on the job row once it is done.
+type Handler func(ctx context.Context, j *models.BackgroundJob) (any, error)
 
 // ErrMaxAttempts indicates the job reached max attempts
 var ErrMaxAttempts = errors.New("max attempts reached")
 
+// permanentError marks a handler error that retrying cannot fix.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps a handler error so the job is moved to the dead-letter
+// table right away instead of being retried, e.g. for a malformed payload or
+// a model that is not installed. Permanent(nil) is nil.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether err was wrapped with Permanent.
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
 // BackoffDuration returns exponential backoff duration for attempt n
 func BackoffDuration(attempt int) time.Duration {
 	if attempt <= 0 {
@@ -2,6 +2,8 @@ package jobs_test
 
 import (
 	"context"
+	"errors"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -24,19 +26,19 @@ func TestEnqueueAndProcess(t *testing.T) {
 	defer d.Close()
 
 	// run migrations - create jobs tables
-	if _, err := d.Exec(ctx, `CREATE TABLE IF NOT EXISTS jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, type TEXT NOT NULL, payload TEXT, status TEXT NOT NULL DEFAULT 'queued', attempts INTEGER NOT NULL DEFAULT 0, max_attempts INTEGER NOT NULL DEFAULT 5, priority INTEGER NOT NULL DEFAULT 100, scheduled_at INTEGER NOT NULL DEFAULT (strftime('%s','now')), next_try_at INTEGER, last_error TEXT, created INTEGER NOT NULL DEFAULT (strftime('%s','now')), updated INTEGER NOT NULL DEFAULT (strftime('%s','now')))`); err != nil {
+	if _, err := d.Exec(ctx, `CREATE TABLE IF NOT EXISTS jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, type TEXT NOT NULL, payload TEXT, status TEXT NOT NULL DEFAULT 'queued', attempts INTEGER NOT NULL DEFAULT 0, max_attempts INTEGER NOT NULL DEFAULT 5, priority INTEGER NOT NULL DEFAULT 100, scheduled_at INTEGER NOT NULL DEFAULT (strftime('%s','now')), next_try_at INTEGER, last_error TEXT, locked_by TEXT, lease_until INTEGER, dedupe_key TEXT, result TEXT, engineer_id INTEGER, created INTEGER NOT NULL DEFAULT (strftime('%s','now')), updated INTEGER NOT NULL DEFAULT (strftime('%s','now')))`); err != nil {
 		t.Fatalf("create jobs table: %v", err)
 	}
-	if _, err := d.Exec(ctx, `CREATE TABLE IF NOT EXISTS dead_letter_jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, job_id INTEGER NOT NULL, type TEXT NOT NULL, payload TEXT, attempts INTEGER NOT NULL, last_error TEXT, failed_at INTEGER NOT NULL DEFAULT (strftime('%s','now'))) `); err != nil {
+	if _, err := d.Exec(ctx, `CREATE TABLE IF NOT EXISTS dead_letter_jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, job_id INTEGER NOT NULL, type TEXT NOT NULL, payload TEXT, attempts INTEGER NOT NULL, last_error TEXT, failed_at INTEGER NOT NULL DEFAULT (strftime('%s','now')), engineer_id INTEGER, priority INTEGER NOT NULL DEFAULT 100, max_attempts INTEGER NOT NULL DEFAULT 5, dedupe_key TEXT) `); err != nil {
 		t.Fatalf("create dlq table: %v", err)
 	}
 
 	repo := sqlite.New(d, logger)
 	handled := make(chan struct{}, 1)
 	handlers := map[string]jobs.Handler{
-		"test": func(ctx context.Context, j *models.BackgroundJob) error {
+		"test": func(ctx context.Context, j *models.BackgroundJob) (any, error) {
 			handled <- struct{}{}
-			return nil
+			return nil, nil
 		},
 	}
 	pool := jobs.NewWorkerPool(repo, handlers, logger, 1)
@@ -54,3 +56,94 @@ func TestEnqueueAndProcess(t *testing.T) {
 		t.Fatalf("handler was not called")
 	}
 }
+
+// TestHandlerResultStored checks that the value returned by a handler is
+// stored as JSON on the finished job.
+func TestHandlerResultStored(t *testing.T) {
+	ctx := context.Background()
+	repo := newWakeupRepo(t, "handler_result")
+
+	handlers := map[string]jobs.Handler{
+		"sum": func(ctx context.Context, j *models.BackgroundJob) (any, error) {
+			return map[string]int{"total": 42}, nil
+		},
+	}
+	pool := jobs.NewWorkerPool(repo, handlers, slog.New(slog.DiscardHandler), 1)
+	pool.Start(ctx)
+	defer pool.Stop()
+
+	id, err := repo.Enqueue(ctx, &models.BackgroundJob{Type: "sum", Payload: []byte(`{}`), ScheduledAt: time.Now()})
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		j, err := repo.GetJob(ctx, id)
+		if err != nil {
+			t.Fatalf("get job: %v", err)
+		}
+		if j.Status == "done" {
+			if string(j.Result) != `{"total":42}` {
+				t.Fatalf("unexpected result %q", j.Result)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job not done, status %q", j.Status)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// TestPermanentErrorSkipsRetries checks that a handler error wrapped with
+// jobs.Permanent dead-letters the job after a single attempt.
+func TestPermanentErrorSkipsRetries(t *testing.T) {
+	ctx := context.Background()
+	repo := newWakeupRepo(t, "permanent_error")
+	d, err := db.New(ctx, "file:permanent_error?mode=memory&cache=shared", slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("db.New: %v", err)
+	}
+	defer d.Close()
+	if _, err := d.Exec(ctx, `CREATE TABLE dead_letter_jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, job_id INTEGER NOT NULL, type TEXT NOT NULL, payload TEXT, attempts INTEGER NOT NULL, last_error TEXT, failed_at INTEGER NOT NULL, engineer_id INTEGER, priority INTEGER NOT NULL DEFAULT 100, max_attempts INTEGER NOT NULL DEFAULT 5, dedupe_key TEXT)`); err != nil {
+		t.Fatalf("create dlq table: %v", err)
+	}
+
+	var calls atomic.Int32
+	handlers := map[string]jobs.Handler{
+		"bad": func(ctx context.Context, j *models.BackgroundJob) (any, error) {
+			calls.Add(1)
+			return nil, jobs.Permanent(errors.New("malformed payload"))
+		},
+	}
+	pool := jobs.NewWorkerPool(repo, handlers, slog.New(slog.DiscardHandler), 1)
+	pool.Start(ctx)
+	defer pool.Stop()
+
+	id, err := repo.Enqueue(ctx, &models.BackgroundJob{Type: "bad", Payload: []byte(`{}`), MaxAttempts: 5, ScheduledAt: time.Now()})
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		dl, err := repo.GetDeadLetterByJobID(ctx, id)
+		if err != nil {
+			t.Fatalf("get dead letter: %v", err)
+		}
+		if dl != nil {
+			if dl.Attempts != 1 || dl.LastError != "malformed payload" {
+				t.Fatalf("unexpected dead letter %+v", dl)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("job was not dead-lettered")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("expected a single handler call, got %d", got)
+	}
+}
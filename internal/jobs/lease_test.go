@@ -0,0 +1,104 @@
+package jobs_test
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"log/slog"
+
+	"github.com/garnizeh/rag/internal/db"
+	"github.com/garnizeh/rag/internal/jobs"
+	"github.com/garnizeh/rag/internal/models"
+	"github.com/garnizeh/rag/internal/repository/sqlite"
+)
+
+// TestWorkerPoolRunsEachJobOnce enqueues many jobs for a multi-worker pool and
+// checks that no job is handed to more than one worker.
+func TestWorkerPoolRunsEachJobOnce(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.DiscardHandler)
+
+	// a file database so every pooled connection sees the same data with real locking
+	dsn := "file:" + filepath.Join(t.TempDir(), "jobs.db") + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	d, err := db.New(ctx, dsn, logger)
+	if err != nil {
+		t.Fatalf("db.New: %v", err)
+	}
+	defer d.Close()
+
+	if _, err := d.Exec(ctx, `CREATE TABLE jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, type TEXT NOT NULL, payload TEXT, status TEXT NOT NULL DEFAULT 'queued', attempts INTEGER NOT NULL DEFAULT 0, max_attempts INTEGER NOT NULL DEFAULT 5, priority INTEGER NOT NULL DEFAULT 100, scheduled_at INTEGER NOT NULL, next_try_at INTEGER, last_error TEXT, locked_by TEXT, lease_until INTEGER, dedupe_key TEXT, result TEXT, engineer_id INTEGER, created INTEGER NOT NULL, updated INTEGER NOT NULL)`); err != nil {
+		t.Fatalf("create jobs table: %v", err)
+	}
+	if _, err := d.Exec(ctx, `CREATE TABLE dead_letter_jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, job_id INTEGER NOT NULL, type TEXT NOT NULL, payload TEXT, attempts INTEGER NOT NULL, last_error TEXT, failed_at INTEGER NOT NULL, engineer_id INTEGER, priority INTEGER NOT NULL DEFAULT 100, max_attempts INTEGER NOT NULL DEFAULT 5, dedupe_key TEXT)`); err != nil {
+		t.Fatalf("create dlq table: %v", err)
+	}
+
+	repo := sqlite.New(d, logger)
+
+	const total = 40
+	for i := range total {
+		if _, err := repo.Enqueue(ctx, &models.BackgroundJob{Type: "count", Payload: []byte(`{}`), Priority: i % 3, ScheduledAt: time.Now()}); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+
+	var (
+		mu   sync.Mutex
+		runs = map[int64]int{}
+		all  = make(chan struct{})
+	)
+	handlers := map[string]jobs.Handler{
+		"count": func(ctx context.Context, j *models.BackgroundJob) (any, error) {
+			// widen the window in which a non-atomic claim would double-dispatch
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			runs[j.ID]++
+			if len(runs) == total {
+				select {
+				case <-all:
+				default:
+					close(all)
+				}
+			}
+			return nil, nil
+		},
+	}
+
+	pool := jobs.NewWorkerPool(repo, handlers, logger, 8)
+	pool.Start(ctx)
+
+	select {
+	case <-all:
+	case <-time.After(20 * time.Second):
+		pool.Stop()
+		t.Fatalf("only %d of %d jobs ran", len(runs), total)
+	}
+	// give any duplicate dispatch a chance to show up before counting
+	time.Sleep(100 * time.Millisecond)
+	pool.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	for id, n := range runs {
+		if n != 1 {
+			t.Errorf("job %d ran %d times", id, n)
+		}
+	}
+
+	done, err := repo.ListJobs(ctx, models.JobFilter{Status: "done", Limit: 500})
+	if err != nil {
+		t.Fatalf("list jobs: %v", err)
+	}
+	if len(done) != total {
+		t.Fatalf("expected %d done jobs, got %d", total, len(done))
+	}
+	for _, j := range done {
+		if j.LockedBy != "" || j.LeaseUntil != nil {
+			t.Fatalf("job %d still holds a lease: %+v", j.ID, j)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/garnizeh/rag/internal/metrics"
+	"github.com/garnizeh/rag/pkg/repository"
+)
+
+var (
+	jobDuration = metrics.NewHistogram("rag_job_duration_seconds",
+		"Time spent running job handlers, by job type and outcome (done, retry, failed, interrupted).",
+		metrics.SlowBuckets, "type", "outcome")
+	jobRetries = metrics.NewCounter("rag_job_retries_total",
+		"Job runs that failed and were scheduled for another attempt.", "type")
+	jobDeadLetters = metrics.NewCounter("rag_job_dead_letters_total",
+		"Jobs moved to the dead-letter table, by job type and reason (exhausted, permanent, no_handler).", "type", "reason")
+	queueRunning = metrics.NewGauge("rag_job_queue_running",
+		"Jobs currently running in this process, by queue.", "queue")
+	jobsByStatus = metrics.NewGauge("rag_jobs",
+		"Jobs stored in the database by status and type, refreshed every jobs.metrics_interval.", "status", "type")
+)
+
+// DefaultMetricsInterval is how often QueueMetrics recounts the stored jobs
+// when not configured otherwise.
+const DefaultMetricsInterval = 30 * time.Second
+
+// QueueMetrics keeps the stored job counts current by counting them on an
+// interval, so scrapes never query the database.
+type QueueMetrics struct {
+	repo     repository.JobRepo
+	logger   *slog.Logger
+	interval time.Duration
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func NewQueueMetrics(repo repository.JobRepo, logger *slog.Logger, interval time.Duration) *QueueMetrics {
+	if interval <= 0 {
+		interval = DefaultMetricsInterval
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &QueueMetrics{
+		repo:     repo,
+		logger:   logger,
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start counts the jobs immediately and then on every interval until Stop is
+// called or ctx is done. A failed count is logged and keeps the last values.
+func (m *QueueMetrics) Start(ctx context.Context) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		t := time.NewTicker(m.interval)
+		defer t.Stop()
+		for {
+			if err := UpdateQueueMetrics(ctx, m.repo); err != nil {
+				m.logger.Warn("count jobs for metrics", "err", err)
+			}
+			select {
+			case <-t.C:
+			case <-m.stop:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the refresh loop and waits for a count in progress. Stop is idempotent.
+func (m *QueueMetrics) Stop() {
+	m.stopOnce.Do(func() { close(m.stop) })
+	m.wg.Wait()
+}
+
+// UpdateQueueMetrics refreshes the stored job counts from the database.
+func UpdateQueueMetrics(ctx context.Context, jr repository.JobRepo) error {
+	counts, err := jr.CountJobs(ctx)
+	if err != nil {
+		return err
+	}
+
+	jobsByStatus.Reset()
+	for _, c := range counts {
+		jobsByStatus.With(c.Status, c.Type).Set(float64(c.Count))
+	}
+
+	return nil
+}
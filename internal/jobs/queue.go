@@ -0,0 +1,143 @@
+package jobs
+
+import (
+	"math/rand/v2"
+	"sync"
+
+	"github.com/garnizeh/rag/internal/config"
+	"github.com/garnizeh/rag/internal/models"
+)
+
+// queue is a named group of job types sharing a concurrency cap.
+type queue struct {
+	name   string
+	types  []string
+	limit  int // 0 means no cap beyond the worker count
+	weight int
+	// running is guarded by queueSet.mu
+	running int
+}
+
+// queueSet tracks how many jobs of each queue this pool is running and decides
+// which queues a free worker should claim from.
+type queueSet struct {
+	mu     sync.Mutex
+	queues []*queue
+	// def holds every type not assigned to a named queue
+	def *queue
+	// named lists the types of all named queues, i.e. what def must skip
+	named []string
+}
+
+// newQueueSet builds the queues from validated config. The default queue
+// always exists; a config entry named config.DefaultJobQueue only tunes it.
+func newQueueSet(cfgs []config.JobQueueConfig) *queueSet {
+	s := &queueSet{def: &queue{name: config.DefaultJobQueue, weight: 1}}
+	for _, c := range cfgs {
+		if c.Name == config.DefaultJobQueue {
+			s.def.limit = c.Concurrency
+			s.def.weight = max(c.Weight, 1)
+			continue
+		}
+		s.queues = append(s.queues, &queue{name: c.Name, types: c.Types, limit: c.Concurrency, weight: max(c.Weight, 1)})
+		s.named = append(s.named, c.Types...)
+	}
+	s.queues = append(s.queues, s.def)
+
+	return s
+}
+
+// filter returns the job types a worker may claim for q.
+func (s *queueSet) filter(q *queue) models.JobTypeFilter {
+	if q == s.def {
+		return models.JobTypeFilter{Except: s.named}
+	}
+
+	return models.JobTypeFilter{Only: q.types}
+}
+
+func (q *queue) full() bool {
+	return q.limit > 0 && q.running >= q.limit
+}
+
+// candidates returns the queues with free capacity in the order a worker
+// should try them: a random permutation where each queue's chance of coming
+// first is proportional to its weight.
+func (s *queueSet) candidates() []*queue {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var open []*queue
+	total := 0
+	for _, q := range s.queues {
+		if !q.full() {
+			open = append(open, q)
+			total += q.weight
+		}
+	}
+
+	for i := range open {
+		n := rand.IntN(total)
+		for j := i; j < len(open); j++ {
+			if n < open[j].weight {
+				open[i], open[j] = open[j], open[i]
+				break
+			}
+			n -= open[j].weight
+		}
+		total -= open[i].weight
+	}
+
+	return open
+}
+
+// acquire reserves a run slot in q, reporting false when q is at its cap.
+func (s *queueSet) acquire(q *queue) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if q.full() {
+		return false
+	}
+	q.running++
+	queueRunning.With(q.name).Set(float64(q.running))
+
+	return true
+}
+
+// release frees a slot in q and reports whether q was at its cap, in which
+// case idle workers skipping q should look again.
+func (s *queueSet) release(q *queue) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wasFull := q.full()
+	q.running--
+	queueRunning.With(q.name).Set(float64(q.running))
+
+	return wasFull
+}
+
+// idleFilter returns the job types of every queue with free capacity, for
+// deciding how long an idle worker may sleep. ok is false when all queues are
+// at their cap, so only a release can make work claimable.
+func (s *queueSet) idleFilter() (f models.JobTypeFilter, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.def.full() {
+		// everything except the types of saturated named queues
+		for _, q := range s.queues {
+			if q != s.def && q.full() {
+				f.Except = append(f.Except, q.types...)
+			}
+		}
+		return f, true
+	}
+
+	for _, q := range s.queues {
+		if q != s.def && !q.full() {
+			f.Only = append(f.Only, q.types...)
+		}
+	}
+
+	return f, len(f.Only) > 0
+}
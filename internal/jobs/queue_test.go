@@ -0,0 +1,90 @@
+package jobs_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"log/slog"
+
+	"github.com/garnizeh/rag/internal/config"
+	"github.com/garnizeh/rag/internal/jobs"
+	"github.com/garnizeh/rag/internal/models"
+)
+
+// TestQueueConcurrencyCap checks that a capped queue never runs more jobs at
+// once than its limit and that other types keep flowing while it is saturated.
+func TestQueueConcurrencyCap(t *testing.T) {
+	ctx := context.Background()
+	repo := newWakeupRepo(t, "queue_cap")
+
+	// slow jobs go first by priority, so without a cap they would take every worker
+	for range 3 {
+		if _, err := repo.Enqueue(ctx, &models.BackgroundJob{Type: "llm", Payload: []byte(`{}`), Priority: 1, ScheduledAt: time.Now()}); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+	const cheap = 5
+	for range cheap {
+		if _, err := repo.Enqueue(ctx, &models.BackgroundJob{Type: "bookkeeping", Payload: []byte(`{}`), Priority: 100, ScheduledAt: time.Now()}); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+
+	var (
+		mu         sync.Mutex
+		running    int
+		maxRunning int
+		llmDone    = make(chan struct{}, 3)
+		cheapDone  = make(chan struct{}, cheap)
+		release    = make(chan struct{})
+	)
+	handlers := map[string]jobs.Handler{
+		"llm": func(ctx context.Context, j *models.BackgroundJob) (any, error) {
+			mu.Lock()
+			running++
+			maxRunning = max(maxRunning, running)
+			mu.Unlock()
+			<-release
+			mu.Lock()
+			running--
+			mu.Unlock()
+			llmDone <- struct{}{}
+			return nil, nil
+		},
+		"bookkeeping": func(ctx context.Context, j *models.BackgroundJob) (any, error) {
+			cheapDone <- struct{}{}
+			return nil, nil
+		},
+	}
+
+	pool := jobs.NewWorkerPool(repo, handlers, slog.New(slog.DiscardHandler), 4)
+	pool.Configure(config.JobsConfig{Queues: []config.JobQueueConfig{{Name: "ai", Types: []string{"llm"}, Concurrency: 1, Weight: 1}}})
+	pool.Start(ctx)
+	defer pool.Stop()
+
+	// every cheap job finishes while the one allowed llm job is blocked
+	for range cheap {
+		select {
+		case <-cheapDone:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("bookkeeping jobs starved behind the llm queue")
+		}
+	}
+
+	close(release)
+	for range 3 {
+		select {
+		case <-llmDone:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("llm jobs did not finish after release")
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if maxRunning != 1 {
+		t.Fatalf("expected at most 1 concurrent llm job, got %d", maxRunning)
+	}
+}
@@ -0,0 +1,111 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/garnizeh/rag/pkg/repository"
+)
+
+// DefaultScheduleInterval is how often the scheduler looks for due schedules
+// when not configured otherwise.
+const DefaultScheduleInterval = 15 * time.Second
+
+// Scheduler turns recurring job schedules into queued jobs. Every instance
+// sharing the database may run one: each run of a schedule is enqueued
+// exactly once because firing is guarded in the repository.
+type Scheduler struct {
+	repo     repository.ScheduleRepo
+	logger   *slog.Logger
+	interval time.Duration
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func NewScheduler(repo repository.ScheduleRepo, logger *slog.Logger, interval time.Duration) *Scheduler {
+	if interval <= 0 {
+		interval = DefaultScheduleInterval
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &Scheduler{
+		repo:     repo,
+		logger:   logger,
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start checks for due schedules immediately and then on every interval until
+// Stop is called or ctx is done.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		t := time.NewTicker(s.interval)
+		defer t.Stop()
+		for {
+			if _, err := s.Tick(ctx); err != nil {
+				s.logger.Error("schedule tick", "err", err)
+			}
+			select {
+			case <-t.C:
+			case <-s.stop:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the scheduling loop and waits for a tick in progress. Stop is idempotent.
+func (s *Scheduler) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	s.wg.Wait()
+}
+
+// Tick enqueues one job for every due schedule and returns how many jobs this
+// instance enqueued. Runs missed while no scheduler was active are collapsed
+// into a single run; the next run is computed from now.
+func (s *Scheduler) Tick(ctx context.Context) (int, error) {
+	now := time.Now()
+	due, err := s.repo.DueSchedules(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	fired := 0
+	for i := range due {
+		sc := &due[i]
+		sched, err := ParseSchedule(sc.Spec)
+		if err != nil {
+			s.logger.Error("invalid schedule spec", "schedule", sc.Name, "spec", sc.Spec, "err", err)
+			continue
+		}
+		next := sched.Next(now)
+		if next.IsZero() {
+			s.logger.Warn("schedule never fires again", "schedule", sc.Name, "spec", sc.Spec)
+			continue
+		}
+
+		jobID, err := s.repo.FireSchedule(ctx, sc, next)
+		if err != nil {
+			s.logger.Error("fire schedule", "schedule", sc.Name, "err", err)
+			continue
+		}
+		if jobID == 0 {
+			// another instance fired this run first
+			continue
+		}
+		fired++
+		s.logger.Info("scheduled job enqueued", "schedule", sc.Name, "type", sc.Type, "job_id", jobID, "next_run_at", next)
+	}
+
+	return fired, nil
+}
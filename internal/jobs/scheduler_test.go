@@ -0,0 +1,106 @@
+package jobs_test
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"log/slog"
+
+	"github.com/garnizeh/rag/internal/db"
+	"github.com/garnizeh/rag/internal/jobs"
+	"github.com/garnizeh/rag/internal/models"
+	"github.com/garnizeh/rag/internal/repository/sqlite"
+)
+
+// TestSchedulerFiresOncePerRunAcrossInstances runs several schedulers, as
+// separate server instances would, against one database and checks that each
+// due run is enqueued exactly once.
+func TestSchedulerFiresOncePerRunAcrossInstances(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.DiscardHandler)
+
+	dsn := "file:" + filepath.Join(t.TempDir(), "schedules.db") + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	d, err := db.New(ctx, dsn, logger)
+	if err != nil {
+		t.Fatalf("db.New: %v", err)
+	}
+	defer d.Close()
+
+	for _, s := range []string{
+		`CREATE TABLE jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, type TEXT NOT NULL, payload TEXT, status TEXT NOT NULL DEFAULT 'queued', attempts INTEGER NOT NULL DEFAULT 0, max_attempts INTEGER NOT NULL DEFAULT 5, priority INTEGER NOT NULL DEFAULT 100, scheduled_at INTEGER NOT NULL, next_try_at INTEGER, last_error TEXT, locked_by TEXT, lease_until INTEGER, dedupe_key TEXT, result TEXT, engineer_id INTEGER, created INTEGER NOT NULL, updated INTEGER NOT NULL)`,
+		`CREATE TABLE job_schedules (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL UNIQUE, spec TEXT NOT NULL, type TEXT NOT NULL, payload TEXT, priority INTEGER NOT NULL DEFAULT 100, max_attempts INTEGER NOT NULL DEFAULT 5, enabled INTEGER NOT NULL DEFAULT 1, next_run_at INTEGER NOT NULL, last_run_at INTEGER, last_job_id INTEGER, created INTEGER NOT NULL, updated INTEGER NOT NULL)`,
+	} {
+		if _, err := d.Exec(ctx, s); err != nil {
+			t.Fatalf("setup schema: %v", err)
+		}
+	}
+	repo := sqlite.New(d, logger)
+
+	due := time.Now().Add(-time.Minute)
+	for _, name := range []string{"digest", "cleanup", "reindex"} {
+		if _, err := repo.CreateSchedule(ctx, &models.JobSchedule{Name: name, Spec: "@every 1h", Type: name, Payload: []byte(`{}`), Enabled: true, NextRunAt: due}); err != nil {
+			t.Fatalf("create schedule: %v", err)
+		}
+	}
+	if _, err := repo.CreateSchedule(ctx, &models.JobSchedule{Name: "paused", Spec: "@every 1h", Type: "paused", Enabled: false, NextRunAt: due}); err != nil {
+		t.Fatalf("create schedule: %v", err)
+	}
+
+	const instances = 4
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		total int
+	)
+	for range instances {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := jobs.NewScheduler(repo, logger, time.Hour).Tick(ctx)
+			if err != nil {
+				t.Errorf("tick: %v", err)
+				return
+			}
+			mu.Lock()
+			total += n
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if total != 3 {
+		t.Fatalf("expected 3 jobs enqueued across instances, got %d", total)
+	}
+	queued, err := repo.ListJobs(ctx, models.JobFilter{Status: "queued"})
+	if err != nil || len(queued) != 3 {
+		t.Fatalf("expected 3 queued jobs, got %d err=%v", len(queued), err)
+	}
+	for _, j := range queued {
+		if j.ScheduledAt.Unix() != due.Unix() {
+			t.Errorf("job %d: expected scheduled_at %s got %s", j.ID, due, j.ScheduledAt)
+		}
+	}
+
+	// the schedules moved on, so a second tick finds nothing due
+	if n, err := jobs.NewScheduler(repo, logger, time.Hour).Tick(ctx); err != nil || n != 0 {
+		t.Fatalf("expected nothing due on second tick, got %d err=%v", n, err)
+	}
+	list, err := repo.ListSchedules(ctx)
+	if err != nil {
+		t.Fatalf("list schedules: %v", err)
+	}
+	for _, s := range list {
+		if !s.Enabled {
+			if s.LastRunAt != nil {
+				t.Errorf("disabled schedule %q should not have run", s.Name)
+			}
+			continue
+		}
+		if s.LastJobID == 0 || s.LastRunAt == nil || !s.NextRunAt.After(time.Now().Add(59*time.Minute)) {
+			t.Errorf("schedule %q not advanced: %+v", s.Name, s)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package jobs_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"log/slog"
+
+	"github.com/garnizeh/rag/internal/config"
+	"github.com/garnizeh/rag/internal/jobs"
+	"github.com/garnizeh/rag/internal/models"
+	"github.com/garnizeh/rag/internal/repository/sqlite"
+)
+
+// startOne enqueues a single job and starts a one-worker pool, returning once
+// the handler has begun running.
+func startOne(t *testing.T, repo *sqlite.SQLiteRepo, cfg config.JobsConfig, h jobs.Handler) (*jobs.WorkerPool, int64) {
+	t.Helper()
+	ctx := context.Background()
+
+	started := make(chan struct{})
+	handlers := map[string]jobs.Handler{
+		"slow": func(ctx context.Context, j *models.BackgroundJob) (any, error) {
+			close(started)
+			return h(ctx, j)
+		},
+	}
+	id, err := repo.Enqueue(ctx, &models.BackgroundJob{Type: "slow", Payload: []byte(`{}`), MaxAttempts: 3, ScheduledAt: time.Now()})
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	pool := jobs.NewWorkerPool(repo, handlers, slog.New(slog.DiscardHandler), 1)
+	pool.Configure(cfg)
+	pool.Start(ctx)
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		pool.Stop()
+		t.Fatalf("handler did not start")
+	}
+	return pool, id
+}
+
+func getJob(t *testing.T, repo *sqlite.SQLiteRepo, id int64) *models.BackgroundJob {
+	t.Helper()
+	j, err := repo.GetJob(context.Background(), id)
+	if err != nil || j == nil {
+		t.Fatalf("get job %d: %#v err=%v", id, j, err)
+	}
+	return j
+}
+
+func TestStopDrainsInFlightJob(t *testing.T) {
+	repo := newWakeupRepo(t, "shutdown_drain")
+	pool, id := startOne(t, repo, config.JobsConfig{DrainTimeout: 5 * time.Second}, func(ctx context.Context, j *models.BackgroundJob) (any, error) {
+		time.Sleep(200 * time.Millisecond)
+		return nil, ctx.Err()
+	})
+
+	pool.Stop()
+
+	if j := getJob(t, repo, id); j.Status != "done" {
+		t.Fatalf("expected in-flight job to finish during drain, got %#v", j)
+	}
+}
+
+func TestStopRequeuesInterruptedJob(t *testing.T) {
+	repo := newWakeupRepo(t, "shutdown_interrupt")
+	pool, id := startOne(t, repo, config.JobsConfig{DrainTimeout: 100 * time.Millisecond}, func(ctx context.Context, j *models.BackgroundJob) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	start := time.Now()
+	pool.Stop()
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("Stop took %v; expected it to give up after the drain timeout", d)
+	}
+
+	j := getJob(t, repo, id)
+	if j.Status != "queued" || j.Attempts != 0 || j.LockedBy != "" || j.NextTryAt != nil {
+		t.Fatalf("expected interrupted job back in the queue without using an attempt, got %#v", j)
+	}
+	if !strings.Contains(j.LastError, "shutdown") {
+		t.Fatalf("expected shutdown noted in last_error, got %q", j.LastError)
+	}
+}
+
+func TestHandlerTimeoutConsumesAttempt(t *testing.T) {
+	repo := newWakeupRepo(t, "shutdown_timeout")
+	pool, id := startOne(t, repo, config.JobsConfig{HandlerTimeout: 50 * time.Millisecond}, func(ctx context.Context, j *models.BackgroundJob) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	defer pool.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if j := getJob(t, repo, id); j.Status == "retry" {
+			if j.Attempts != 1 || !strings.Contains(j.LastError, "deadline") {
+				t.Fatalf("unexpected job after timeout: %#v", j)
+			}
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("job was not scheduled for retry after its handler timed out")
+}
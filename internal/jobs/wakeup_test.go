@@ -0,0 +1,100 @@
+package jobs_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"log/slog"
+
+	"github.com/garnizeh/rag/internal/db"
+	"github.com/garnizeh/rag/internal/jobs"
+	"github.com/garnizeh/rag/internal/models"
+	"github.com/garnizeh/rag/internal/repository/sqlite"
+)
+
+func newWakeupRepo(t *testing.T, name string) *sqlite.SQLiteRepo {
+	t.Helper()
+	ctx := context.Background()
+	logger := slog.New(slog.DiscardHandler)
+	d, err := db.New(ctx, "file:"+name+"?mode=memory&cache=shared", logger)
+	if err != nil {
+		t.Fatalf("db.New: %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+	if _, err := d.Exec(ctx, `CREATE TABLE jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, type TEXT NOT NULL, payload TEXT, status TEXT NOT NULL DEFAULT 'queued', attempts INTEGER NOT NULL DEFAULT 0, max_attempts INTEGER NOT NULL DEFAULT 5, priority INTEGER NOT NULL DEFAULT 100, scheduled_at INTEGER NOT NULL, next_try_at INTEGER, last_error TEXT, locked_by TEXT, lease_until INTEGER, dedupe_key TEXT, result TEXT, engineer_id INTEGER, created INTEGER NOT NULL, updated INTEGER NOT NULL)`); err != nil {
+		t.Fatalf("create jobs table: %v", err)
+	}
+	return sqlite.New(d, logger)
+}
+
+// TestWorkerWakesOnEnqueue checks that a job stored straight through the
+// repository (as the API does) is picked up without waiting for the idle timer.
+func TestWorkerWakesOnEnqueue(t *testing.T) {
+	ctx := context.Background()
+	repo := newWakeupRepo(t, "wakeup_enqueue")
+
+	handled := make(chan time.Time, 1)
+	handlers := map[string]jobs.Handler{
+		"ping": func(ctx context.Context, j *models.BackgroundJob) (any, error) {
+			handled <- time.Now()
+			return nil, nil
+		},
+	}
+	pool := jobs.NewWorkerPool(repo, handlers, slog.New(slog.DiscardHandler), 2)
+	pool.Start(ctx)
+	defer pool.Stop()
+
+	// let the workers go idle on an empty queue
+	time.Sleep(200 * time.Millisecond)
+
+	start := time.Now()
+	if _, err := repo.Enqueue(ctx, &models.BackgroundJob{Type: "ping", Payload: []byte(`{}`), ScheduledAt: start}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	select {
+	case at := <-handled:
+		if d := at.Sub(start); d > time.Second {
+			t.Fatalf("job started %v after enqueue; expected an immediate wakeup", d)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("handler was not called")
+	}
+}
+
+// TestWorkerWakesForScheduledJob checks that idle workers time their sleep to
+// the earliest scheduled job instead of polling.
+func TestWorkerWakesForScheduledJob(t *testing.T) {
+	ctx := context.Background()
+	repo := newWakeupRepo(t, "wakeup_scheduled")
+
+	runAt := time.Now().Add(2 * time.Second)
+	if _, err := repo.Enqueue(ctx, &models.BackgroundJob{Type: "later", Payload: []byte(`{}`), ScheduledAt: runAt}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	handled := make(chan time.Time, 1)
+	handlers := map[string]jobs.Handler{
+		"later": func(ctx context.Context, j *models.BackgroundJob) (any, error) {
+			handled <- time.Now()
+			return nil, nil
+		},
+	}
+	pool := jobs.NewWorkerPool(repo, handlers, slog.New(slog.DiscardHandler), 1)
+	pool.Start(ctx)
+	defer pool.Stop()
+
+	select {
+	case at := <-handled:
+		// scheduled_at has second resolution, so allow the job to start up to a second early
+		if at.Before(runAt.Add(-time.Second)) {
+			t.Fatalf("job ran %v before its schedule", runAt.Sub(at))
+		}
+		if at.After(runAt.Add(2 * time.Second)) {
+			t.Fatalf("job ran %v after its schedule", at.Sub(runAt))
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("scheduled job was not run")
+	}
+}
@@ -3,25 +3,67 @@ package jobs
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"os"
 	"sync"
 	"time"
 
+	"github.com/garnizeh/rag/internal/config"
 	"github.com/garnizeh/rag/internal/models"
 	"github.com/garnizeh/rag/pkg/repository"
 )
 
+const (
+	// maxIdleWait bounds how long an idle worker sleeps without a notification,
+	// so jobs inserted by other processes sharing the database are still picked up.
+	maxIdleWait = 30 * time.Second
+	// minIdleWait keeps a worker from spinning when the next job is due now but
+	// another worker claimed it first.
+	minIdleWait = 100 * time.Millisecond
+	// errorWait is the pause after a failed fetch.
+	errorWait = 1 * time.Second
+)
+
+// Defaults used when the pool is not configured otherwise.
+const (
+	// DefaultLease is how long a worker holds a claimed job before other
+	// workers may reclaim it.
+	DefaultLease = 5 * time.Minute
+	// DefaultHandlerTimeout bounds a single handler run.
+	DefaultHandlerTimeout = 2 * time.Minute
+	// DefaultDrainTimeout is how long Stop waits for in-flight jobs before
+	// cancelling them.
+	DefaultDrainTimeout = 30 * time.Second
+)
+
+// errShutdown is the cancellation cause given to handlers interrupted by Stop.
+var errShutdown = errors.New("worker pool shutting down")
+
 type WorkerPool struct {
-	jobRepo     repository.JobRepo
-	handlers    map[string]Handler
-	logger      *slog.Logger
-	workerCount int
-	stop        chan struct{}
-	wg          sync.WaitGroup
+	jobRepo        repository.JobQueue
+	handlers       map[string]Handler
+	logger         *slog.Logger
+	workerCount    int
+	lease          time.Duration
+	handlerTimeout time.Duration
+	drainTimeout   time.Duration
+	idPrefix       string
+	queues         *queueSet
+	// wake carries one token per enqueued job to idle workers
+	wake chan struct{}
+	stop chan struct{}
+	// jobCtx is the parent of every handler context; cancelJobs interrupts
+	// in-flight handlers once the drain period is over
+	jobCtx     context.Context
+	cancelJobs context.CancelCauseFunc
+	stopOnce   sync.Once
+	wg         sync.WaitGroup
 }
 
 func NewWorkerPool(
-	jobRepo repository.JobRepo,
+	jobRepo repository.JobQueue,
 	handlers map[string]Handler,
 	logger *slog.Logger,
 	workerCount int,
@@ -33,31 +75,99 @@ func NewWorkerPool(
 		logger = slog.Default()
 	}
 
-	return &WorkerPool{
-		jobRepo:     jobRepo,
-		handlers:    handlers,
-		logger:      logger,
-		workerCount: workerCount,
-		stop:        make(chan struct{}),
+	host, _ := os.Hostname()
+
+	p := &WorkerPool{
+		jobRepo:        jobRepo,
+		handlers:       handlers,
+		logger:         logger,
+		workerCount:    workerCount,
+		lease:          DefaultLease,
+		handlerTimeout: DefaultHandlerTimeout,
+		drainTimeout:   DefaultDrainTimeout,
+		idPrefix:       fmt.Sprintf("%s-%d", host, os.Getpid()),
+		queues:         newQueueSet(nil),
+		wake:           make(chan struct{}, workerCount),
+		stop:           make(chan struct{}),
+	}
+
+	// wake idle workers whenever any code path stores a job through the repository
+	if n, ok := jobRepo.(repository.EnqueueNotifier); ok {
+		n.OnEnqueue(func(time.Time) { p.notify() })
+	}
+
+	return p
+}
+
+// notify wakes one idle worker without blocking. When every worker is busy the
+// token is dropped; busy workers fetch again as soon as they finish.
+func (p *WorkerPool) notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Configure applies lease, handler timeout, drain and queue settings. Zero
+// values keep the defaults. The handler timeout is capped at the lease so a job
+// is never still running when another worker may reclaim it. Must be called before Start.
+func (p *WorkerPool) Configure(cfg config.JobsConfig) {
+	if cfg.Lease > 0 {
+		p.lease = cfg.Lease
+	}
+	if cfg.HandlerTimeout > 0 {
+		p.handlerTimeout = cfg.HandlerTimeout
+	}
+	if cfg.DrainTimeout > 0 {
+		p.drainTimeout = cfg.DrainTimeout
+	}
+	if p.handlerTimeout > p.lease {
+		p.handlerTimeout = p.lease
 	}
+	p.queues = newQueueSet(cfg.Queues)
 }
 
-// Start launches the worker goroutines
+// Start launches the worker goroutines. Cancelling ctx interrupts in-flight
+// handlers immediately, without a drain period.
 func (p *WorkerPool) Start(ctx context.Context) {
+	p.jobCtx, p.cancelJobs = context.WithCancelCause(ctx)
 	for i := range p.workerCount {
 		p.wg.Add(1)
 		go p.worker(ctx, i)
 	}
 }
 
-// Stop signals workers to stop and waits for them
+// Stop stops claiming new jobs and waits up to the drain timeout for in-flight
+// jobs to finish. Handlers still running after that are cancelled and their
+// jobs returned to the queue without consuming an attempt. Stop is idempotent.
 func (p *WorkerPool) Stop() {
-	close(p.stop)
-	p.wg.Wait()
+	p.stopOnce.Do(func() {
+		close(p.stop)
+
+		drained := make(chan struct{})
+		go func() {
+			p.wg.Wait()
+			close(drained)
+		}()
+
+		t := time.NewTimer(p.drainTimeout)
+		defer t.Stop()
+		select {
+		case <-drained:
+		case <-t.C:
+			p.logger.Warn("drain timeout reached, interrupting in-flight jobs", "timeout", p.drainTimeout)
+			p.cancelJobs(errShutdown)
+			<-drained
+		}
+		if p.cancelJobs != nil {
+			p.cancelJobs(errShutdown)
+		}
+	})
 }
 
 func (p *WorkerPool) worker(ctx context.Context, id int) {
 	defer p.wg.Done()
+	workerID := fmt.Sprintf("%s-%d", p.idPrefix, id)
 	for {
 		select {
 		case <-p.stop:
@@ -69,57 +179,176 @@ func (p *WorkerPool) worker(ctx context.Context, id int) {
 			return
 
 		default:
-			job, err := p.jobRepo.FetchNext(ctx)
+			job, q, err := p.claim(ctx, workerID)
 			if err != nil {
 				p.logger.Error("fetch job", "err", err)
-				time.Sleep(1 * time.Second)
+				p.wait(ctx, errorWait)
 				continue
 			}
 
 			if job == nil {
-				// nothing to do
-				time.Sleep(500 * time.Millisecond)
+				// nothing to do: sleep until the next job is due or a new one is enqueued
+				p.wait(ctx, p.idleWait(ctx))
 				continue
 			}
 
-			h, ok := p.handlers[job.Type]
-			if !ok {
-				job.Status = "failed"
-				job.LastError = "no handler"
-				_ = p.jobRepo.MoveToDeadLetter(ctx, job)
-				continue
+			p.run(ctx, job)
+			if p.queues.release(q) {
+				// the queue was at its cap: a worker skipping it may claim now
+				p.notify()
 			}
+		}
+	}
+}
 
-			// run handler with context and cancellation
-			err = h(ctx, job)
-			if err == nil {
-				job.Status = "done"
-				_ = p.jobRepo.UpdateJob(ctx, job)
-				continue
-			}
+// claim reserves a slot in a queue with free capacity and claims one of its
+// jobs. Queues are tried in weighted random order so a busy queue cannot
+// starve the others. On success the caller must release the returned queue.
+func (p *WorkerPool) claim(ctx context.Context, workerID string) (*models.BackgroundJob, *queue, error) {
+	for _, q := range p.queues.candidates() {
+		if !p.queues.acquire(q) {
+			continue
+		}
+		job, err := p.jobRepo.FetchNext(ctx, workerID, p.lease, p.queues.filter(q))
+		if err != nil {
+			p.queues.release(q)
+			return nil, nil, err
+		}
+		if job != nil {
+			return job, q, nil
+		}
+		p.queues.release(q)
+	}
 
-			// handler returned error
-			job.Attempts++
-			job.LastError = err.Error()
-			if job.Attempts >= job.MaxAttempts {
-				// move to dead letter
-				job.Status = "failed"
-				if mvErr := p.jobRepo.MoveToDeadLetter(ctx, job); mvErr != nil {
-					p.logger.Error("move to dead letter", "err", mvErr)
-				}
-				continue
-			}
+	return nil, nil, nil
+}
 
-			// schedule retry with backoff
-			backoff := BackoffDuration(job.Attempts)
-			t := time.Now().Add(backoff)
-			job.NextTryAt = &t
-			job.Status = "retry"
-			if upErr := p.jobRepo.UpdateJob(ctx, job); upErr != nil {
-				p.logger.Error("update job for retry", "err", upErr)
-			}
+// run executes a claimed job and records its outcome. Bookkeeping writes use a
+// context detached from cancellation so outcomes are still persisted while the
+// pool is shutting down.
+func (p *WorkerPool) run(ctx context.Context, job *models.BackgroundJob) {
+	bctx := context.WithoutCancel(ctx)
+
+	h, ok := p.handlers[job.Type]
+	if !ok {
+		job.Status = "failed"
+		job.LastError = "no handler"
+		p.deadLetter(bctx, job, "no_handler")
+		return
+	}
+
+	// a reclaimed job whose previous runs all lost their lease is not retried again
+	if job.Attempts >= job.MaxAttempts {
+		job.Status = "failed"
+		p.deadLetter(bctx, job, "exhausted")
+		return
+	}
+
+	hctx, cancel := context.WithTimeout(p.jobCtx, p.handlerTimeout)
+	start := time.Now()
+	result, err := h(hctx, job)
+	elapsed := time.Since(start).Seconds()
+	cancel()
+	if err == nil && result != nil {
+		job.Result, err = json.Marshal(result)
+		if err != nil {
+			err = fmt.Errorf("encode result: %w", err)
 		}
 	}
+	if err == nil {
+		jobDuration.With(job.Type, "done").Observe(elapsed)
+		job.Status = "done"
+		p.update(bctx, job)
+		return
+	}
+
+	// interrupted by shutdown: hand the job back untouched for the next worker
+	if p.jobCtx.Err() != nil {
+		p.logger.Info("job interrupted by shutdown, requeueing", "job_id", job.ID, "type", job.Type)
+		jobDuration.With(job.Type, "interrupted").Observe(elapsed)
+		job.Status = "queued"
+		job.NextTryAt = nil
+		job.LastError = "interrupted by shutdown: " + err.Error()
+		p.update(bctx, job)
+		return
+	}
+
+	// handler returned error (including exceeding the handler timeout)
+	job.Attempts++
+	job.LastError = err.Error()
+	if IsPermanent(err) {
+		jobDuration.With(job.Type, "failed").Observe(elapsed)
+		job.Status = "failed"
+		p.deadLetter(bctx, job, "permanent")
+		return
+	}
+	if job.Attempts >= job.MaxAttempts {
+		jobDuration.With(job.Type, "failed").Observe(elapsed)
+		job.Status = "failed"
+		p.deadLetter(bctx, job, "exhausted")
+		return
+	}
+
+	jobDuration.With(job.Type, "retry").Observe(elapsed)
+	jobRetries.With(job.Type).Inc()
+
+	// schedule retry with backoff
+	backoff := BackoffDuration(job.Attempts)
+	t := time.Now().Add(backoff)
+	job.NextTryAt = &t
+	job.Status = "retry"
+	p.update(bctx, job)
+}
+
+func (p *WorkerPool) deadLetter(ctx context.Context, job *models.BackgroundJob, reason string) {
+	if err := p.jobRepo.MoveToDeadLetter(ctx, job); err != nil {
+		p.logger.Error("move to dead letter", "job_id", job.ID, "err", err)
+		return
+	}
+	jobDeadLetters.With(job.Type, reason).Inc()
+}
+
+// idleWait returns how long an idle worker may sleep before the next known job
+// in a queue with free capacity becomes claimable, clamped to [minIdleWait, maxIdleWait].
+func (p *WorkerPool) idleWait(ctx context.Context) time.Duration {
+	f, ok := p.queues.idleFilter()
+	if !ok {
+		// every queue is at its cap; a finishing job wakes us
+		return maxIdleWait
+	}
+	next, err := p.jobRepo.NextJobTime(ctx, f)
+	if err != nil {
+		p.logger.Error("next job time", "err", err)
+		return errorWait
+	}
+	if next == nil {
+		return maxIdleWait
+	}
+
+	return min(max(time.Until(*next), minIdleWait), maxIdleWait)
+}
+
+// wait blocks for d or until the pool is stopped, ctx is done or a new job is enqueued.
+func (p *WorkerPool) wait(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-p.wake:
+	case <-p.stop:
+	case <-ctx.Done():
+	}
+}
+
+// update persists the job outcome and releases the lease.
+func (p *WorkerPool) update(ctx context.Context, job *models.BackgroundJob) {
+	err := p.jobRepo.UpdateJob(ctx, job)
+	switch {
+	case errors.Is(err, repository.ErrLeaseLost):
+		p.logger.Warn("job lease lost before completion; result discarded", "job_id", job.ID, "worker", job.LockedBy)
+	case err != nil:
+		p.logger.Error("update job", "job_id", job.ID, "status", job.Status, "err", err)
+	}
 }
 
 // Enqueue convenience helper that creates a job and persists it
@@ -130,5 +359,14 @@ func (p *WorkerPool) Enqueue(ctx context.Context, typ string, payload any, prior
 	}
 
 	j := &models.BackgroundJob{Type: typ, Payload: b, Priority: priority, MaxAttempts: maxAttempts, ScheduledAt: time.Now()}
-	return p.jobRepo.Enqueue(ctx, j)
+	id, err := p.jobRepo.Enqueue(ctx, j)
+	if err != nil {
+		return 0, err
+	}
+	// repositories implementing EnqueueNotifier already woke a worker
+	if _, ok := p.jobRepo.(repository.EnqueueNotifier); !ok {
+		p.notify()
+	}
+
+	return id, nil
 }
@@ -0,0 +1,302 @@
+// Package metrics implements the counters, gauges and histograms the server
+// exposes on /metrics, rendered in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefBuckets are histogram buckets in seconds suited to HTTP handlers and
+// short jobs.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// SlowBuckets are histogram buckets in seconds for LLM calls and the jobs
+// wrapping them, which take seconds to minutes.
+var SlowBuckets = []float64{.1, .5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// Registry holds metric families and renders them in registration order.
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+	names    map[string]bool
+}
+
+func NewRegistry() *Registry {
+	return &Registry{names: map[string]bool{}}
+}
+
+// Default is the registry served by the /metrics endpoint. Packages register
+// their metrics with it at init time through the package-level constructors.
+var Default = NewRegistry()
+
+// family is one metric name with a fixed label set and its series keyed by
+// label values.
+type family struct {
+	name    string
+	help    string
+	typ     string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	values []string
+	// bits holds the float64 value of counters and gauges
+	bits atomic.Uint64
+
+	// histogram state, guarded by mu
+	mu     sync.Mutex
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (r *Registry) register(name, help, typ string, buckets []float64, labels []string) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[name] {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+	r.names[name] = true
+
+	f := &family{name: name, help: help, typ: typ, labels: labels, buckets: buckets, series: map[string]*series{}}
+	r.families = append(r.families, f)
+
+	return f
+}
+
+// with returns the series for the given label values, creating it on first use.
+func (f *family) with(values []string) *series {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{values: slices.Clone(values)}
+		if f.typ == "histogram" {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+
+	return s
+}
+
+func (f *family) reset() {
+	f.mu.Lock()
+	f.series = map[string]*series{}
+	f.mu.Unlock()
+}
+
+func (s *series) add(v float64) {
+	for {
+		old := s.bits.Load()
+		if s.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+// CounterVec is a monotonically increasing value per label combination.
+type CounterVec struct{ f *family }
+
+// Counter is one series of a CounterVec.
+type Counter struct{ s *series }
+
+func (r *Registry) Counter(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{r.register(name, help, "counter", nil, labels)}
+}
+
+// NewCounter registers a counter with the Default registry.
+func NewCounter(name, help string, labels ...string) *CounterVec {
+	return Default.Counter(name, help, labels...)
+}
+
+func (c *CounterVec) With(values ...string) Counter { return Counter{c.f.with(values)} }
+
+func (c Counter) Inc() { c.s.add(1) }
+
+// Add increases the counter; negative values are ignored.
+func (c Counter) Add(v float64) {
+	if v > 0 {
+		c.s.add(v)
+	}
+}
+
+// GaugeVec is a value that can go up and down per label combination.
+type GaugeVec struct{ f *family }
+
+// Gauge is one series of a GaugeVec.
+type Gauge struct{ s *series }
+
+func (r *Registry) Gauge(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{r.register(name, help, "gauge", nil, labels)}
+}
+
+// NewGauge registers a gauge with the Default registry.
+func NewGauge(name, help string, labels ...string) *GaugeVec {
+	return Default.Gauge(name, help, labels...)
+}
+
+func (g *GaugeVec) With(values ...string) Gauge { return Gauge{g.f.with(values)} }
+
+// Reset drops every series, for gauges rebuilt from a snapshot on each scrape.
+func (g *GaugeVec) Reset() { g.f.reset() }
+
+func (g Gauge) Set(v float64) { g.s.bits.Store(math.Float64bits(v)) }
+func (g Gauge) Add(v float64) { g.s.add(v) }
+func (g Gauge) Inc()          { g.s.add(1) }
+func (g Gauge) Dec()          { g.s.add(-1) }
+
+// HistogramVec counts observations into cumulative buckets per label combination.
+type HistogramVec struct{ f *family }
+
+// Histogram is one series of a HistogramVec.
+type Histogram struct {
+	s       *series
+	buckets []float64
+}
+
+// Histogram registers a histogram with the given upper bounds, which must be
+// sorted ascending; the +Inf bucket is implicit.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if !slices.IsSorted(buckets) {
+		panic(fmt.Sprintf("metrics: %s buckets are not sorted", name))
+	}
+	return &HistogramVec{r.register(name, help, "histogram", buckets, labels)}
+}
+
+// NewHistogram registers a histogram with the Default registry.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return Default.Histogram(name, help, buckets, labels...)
+}
+
+func (h *HistogramVec) With(values ...string) Histogram {
+	return Histogram{h.f.with(values), h.f.buckets}
+}
+
+func (h Histogram) Observe(v float64) {
+	i, _ := slices.BinarySearch(h.buckets, v)
+	h.s.mu.Lock()
+	if i < len(h.s.counts) {
+		h.s.counts[i]++
+	}
+	h.s.sum += v
+	h.s.count++
+	h.s.mu.Unlock()
+}
+
+// WriteText renders every metric in the Prometheus text format, version 0.0.4.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	families := slices.Clone(r.families)
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(bw)
+	}
+
+	return bw.Flush()
+}
+
+// Handler serves the registry in the Prometheus text format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = r.WriteText(w)
+	})
+}
+
+func (f *family) write(w *bufio.Writer) {
+	f.mu.Lock()
+	all := make([]*series, 0, len(f.series))
+	for _, s := range f.series {
+		all = append(all, s)
+	}
+	f.mu.Unlock()
+	slices.SortFunc(all, func(a, b *series) int { return slices.Compare(a.values, b.values) })
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.typ)
+	for _, s := range all {
+		if f.typ != "histogram" {
+			fmt.Fprintf(w, "%s%s %s\n", f.name, labelString(f.labels, s.values, ""), formatFloat(math.Float64frombits(s.bits.Load())))
+			continue
+		}
+
+		s.mu.Lock()
+		counts, sum, count := slices.Clone(s.counts), s.sum, s.count
+		s.mu.Unlock()
+		var cum uint64
+		for i, ub := range f.buckets {
+			cum += counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, labelString(f.labels, s.values, formatFloat(ub)), cum)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, labelString(f.labels, s.values, "+Inf"), count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", f.name, labelString(f.labels, s.values, ""), formatFloat(sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", f.name, labelString(f.labels, s.values, ""), count)
+	}
+}
+
+// labelString renders {k="v",...}, appending le when set (histogram buckets).
+func labelString(names, values []string, le string) string {
+	if len(names) == 0 && le == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, n := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(n)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	if le != "" {
+		if len(names) > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(`le="`)
+		b.WriteString(le)
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
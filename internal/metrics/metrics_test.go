@@ -0,0 +1,74 @@
+package metrics_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/garnizeh/rag/internal/metrics"
+)
+
+func TestWriteText(t *testing.T) {
+	r := metrics.NewRegistry()
+	c := r.Counter("app_requests_total", "Requests handled.", "code")
+	g := r.Gauge("app_in_flight", "Requests in flight.")
+	h := r.Histogram("app_latency_seconds", "Request latency.", []float64{0.1, 1}, "route")
+
+	c.With("200").Inc()
+	c.With("200").Add(2)
+	c.With("200").Add(-5)
+	c.With(`we"ird\`).Inc()
+	g.With().Set(3)
+	g.With().Dec()
+	h.With("/x").Observe(0.05)
+	h.With("/x").Observe(0.1)
+	h.With("/x").Observe(7)
+
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+
+	want := `# HELP app_requests_total Requests handled.
+# TYPE app_requests_total counter
+app_requests_total{code="200"} 3
+app_requests_total{code="we\"ird\\"} 1
+# HELP app_in_flight Requests in flight.
+# TYPE app_in_flight gauge
+app_in_flight 2
+# HELP app_latency_seconds Request latency.
+# TYPE app_latency_seconds histogram
+app_latency_seconds_bucket{route="/x",le="0.1"} 2
+app_latency_seconds_bucket{route="/x",le="1"} 2
+app_latency_seconds_bucket{route="/x",le="+Inf"} 3
+app_latency_seconds_sum{route="/x"} 7.15
+app_latency_seconds_count{route="/x"} 3
+`
+	if b.String() != want {
+		t.Fatalf("unexpected exposition:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestGaugeReset(t *testing.T) {
+	r := metrics.NewRegistry()
+	g := r.Gauge("jobs", "Jobs by status.", "status")
+	g.With("queued").Set(4)
+	g.Reset()
+	g.With("done").Set(1)
+
+	var b strings.Builder
+	_ = r.WriteText(&b)
+	if strings.Contains(b.String(), "queued") || !strings.Contains(b.String(), `jobs{status="done"} 1`) {
+		t.Fatalf("unexpected output after reset:\n%s", b.String())
+	}
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	r := metrics.NewRegistry()
+	r.Counter("dup", "first")
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic on duplicate name")
+		}
+	}()
+	r.Gauge("dup", "second")
+}
@@ -20,11 +20,38 @@ type Profile struct {
 	Updated    int64  `json:"updated" db:"updated"`
 }
 
+// ProfileData is the structured engineer profile stored as JSON in Profile.Bio.
+type ProfileData struct {
+	Role   string   `json:"role,omitempty"`
+	Team   string   `json:"team,omitempty"`
+	Skills []string `json:"skills,omitempty"`
+	Goals  []string `json:"goals,omitempty"`
+}
+
+// Activity visibility values
+const (
+	VisibilityShared  = "shared"
+	VisibilityPrivate = "private"
+)
+
 type Activity struct {
 	ID         int64  `json:"id" db:"id"`
 	EngineerID int64  `json:"engineer_id" db:"engineer_id"`
 	Activity   string `json:"activity" db:"activity"`
-	Created    int64  `json:"created" db:"created"`
+	Visibility string `json:"visibility" db:"visibility"`
+	// Redactions are the PII spans of Activity that are masked for anyone but the owner.
+	// Nil means the activity predates redaction and has never been scanned.
+	Redactions []Redaction `json:"redactions,omitempty" db:"redactions"`
+	// IdempotencyKey is the client-supplied Idempotency-Key the activity was created with, if any.
+	IdempotencyKey string `json:"-" db:"idempotency_key"`
+	Created        int64  `json:"created" db:"created"`
+}
+
+// Redaction is a masked span of text, as byte offsets into the original.
+type Redaction struct {
+	Kind  string `json:"kind"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
 }
 
 type Question struct {
@@ -35,12 +62,6 @@ type Question struct {
 	Created    int64  `json:"created" db:"created"`
 }
 
-type Job struct {
-	ID      int64  `json:"id" db:"id"`
-	Status  string `json:"status" db:"status"`
-	Created int64  `json:"created" db:"created"`
-}
-
 type Schema struct {
 	ID          int64  `json:"id" db:"id"`
 	Version     string `json:"version" db:"version"`
@@ -83,6 +104,193 @@ type BackgroundJob struct {
 	ScheduledAt time.Time       `json:"scheduled_at"`
 	NextTryAt   *time.Time      `json:"next_try_at,omitempty"`
 	LastError   string          `json:"last_error,omitempty"`
+	// LockedBy and LeaseUntil are set while a worker holds the job (status "running")
+	LockedBy   string     `json:"locked_by,omitempty"`
+	LeaseUntil *time.Time `json:"lease_until,omitempty"`
+	// DedupeKey, when set, makes Enqueue a no-op while an unfinished job with the same key exists
+	DedupeKey string `json:"dedupe_key,omitempty"`
+	// EngineerID is the engineer the job was created for, if any; they may poll its status
+	EngineerID int64 `json:"engineer_id,omitempty"`
+	// Result is the JSON returned by the handler once the job is done
+	Result  json.RawMessage `json:"result,omitempty"`
+	Created time.Time       `json:"created"`
+	Updated time.Time       `json:"updated"`
+}
+
+// DeadLetterJob is a job that exhausted its attempts or had no handler.
+type DeadLetterJob struct {
+	ID        int64           `json:"id"`
+	JobID     int64           `json:"job_id"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"last_error,omitempty"`
+	FailedAt  time.Time       `json:"failed_at"`
+	// EngineerID is carried over from the failed job
+	EngineerID int64 `json:"engineer_id,omitempty"`
+	// Priority, MaxAttempts and DedupeKey are restored when the job is requeued
+	Priority    int    `json:"priority"`
+	MaxAttempts int    `json:"max_attempts"`
+	DedupeKey   string `json:"dedupe_key,omitempty"`
+}
+
+// JobCount is the number of jobs of one type in one status. Dead-lettered
+// jobs are reported with status "dead_letter".
+type JobCount struct {
+	Status string `json:"status"`
+	Type   string `json:"type"`
+	Count  int64  `json:"count"`
+}
+
+// JobTypeFilter restricts the job types a worker may claim. The zero value
+// matches every type; Only keeps just the listed types and Except drops them.
+type JobTypeFilter struct {
+	Only   []string
+	Except []string
+}
+
+// JobSchedule is a recurring job: every time Spec fires the scheduler enqueues
+// a job of Type with Payload. Spec is a five-field cron expression (UTC) or
+// "@every <duration>".
+type JobSchedule struct {
+	ID          int64           `json:"id"`
+	Name        string          `json:"name"`
+	Spec        string          `json:"spec"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	Priority    int             `json:"priority"`
+	MaxAttempts int             `json:"max_attempts"`
+	Enabled     bool            `json:"enabled"`
+	NextRunAt   time.Time       `json:"next_run_at"`
+	LastRunAt   *time.Time      `json:"last_run_at,omitempty"`
+	LastJobID   int64           `json:"last_job_id,omitempty"`
 	Created     time.Time       `json:"created"`
 	Updated     time.Time       `json:"updated"`
 }
+
+// JobFilter selects jobs for the admin API. Empty fields match everything.
+type JobFilter struct {
+	Status string
+	Type   string
+	Limit  int
+	Offset int
+}
+
+// Page returns Limit and Offset clamped to sane values (default 50, max 500).
+func (f JobFilter) Page() (limit, offset int) {
+	limit, offset = f.Limit, f.Offset
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+// Team member roles, in decreasing order of privilege.
+const (
+	TeamRoleOwner  = "owner"
+	TeamRoleLead   = "lead"
+	TeamRoleMember = "member"
+)
+
+type Team struct {
+	ID          int64  `json:"id" db:"id"`
+	Name        string `json:"name" db:"name"`
+	Description string `json:"description,omitempty" db:"description"`
+	CreatedBy   int64  `json:"created_by" db:"created_by"`
+	Created     int64  `json:"created" db:"created"`
+	Updated     int64  `json:"updated" db:"updated"`
+}
+
+type TeamMember struct {
+	TeamID     int64  `json:"team_id" db:"team_id"`
+	EngineerID int64  `json:"engineer_id" db:"engineer_id"`
+	Role       string `json:"role" db:"role"`
+	Name       string `json:"name,omitempty"`
+	Email      string `json:"email,omitempty"`
+	Created    int64  `json:"created" db:"created"`
+}
+
+type PasswordReset struct {
+	ID         int64  `json:"id" db:"id"`
+	EngineerID int64  `json:"engineer_id" db:"engineer_id"`
+	TokenHash  string `json:"-" db:"token_hash"`
+	ExpiresAt  int64  `json:"expires_at" db:"expires_at"`
+	UsedAt     *int64 `json:"used_at,omitempty" db:"used_at"`
+	Created    int64  `json:"created" db:"created"`
+}
+
+// LLMCacheEntry is a cached LLM response. Key hashes the model, options and
+// prompt; Response is the JSON encoded generate result.
+type LLMCacheEntry struct {
+	Key       string `json:"key" db:"key"`
+	Model     string `json:"model" db:"model"`
+	Response  string `json:"-" db:"response"`
+	Size      int64  `json:"size" db:"size"`
+	Hits      int64  `json:"hits" db:"hits"`
+	ExpiresAt int64  `json:"expires_at" db:"expires_at"`
+	LastUsed  int64  `json:"last_used" db:"last_used"`
+	Created   int64  `json:"created" db:"created"`
+}
+
+// LLM call outcomes.
+const (
+	// LLMCallOK: the model answered and the answer was accepted
+	LLMCallOK = "ok"
+	// LLMCallCached: the answer came from the response cache, no model time was used
+	LLMCallCached = "cached"
+	// LLMCallInvalid: the model answered but the answer was rejected
+	LLMCallInvalid = "invalid"
+	// LLMCallError: no model answered
+	LLMCallError = "error"
+)
+
+// LLMCall records one analysis sent to the LLM and what it cost.
+type LLMCall struct {
+	ID              int64   `json:"id" db:"id"`
+	EngineerID      *int64  `json:"engineer_id,omitempty" db:"engineer_id"`
+	TemplateName    string  `json:"template_name" db:"template_name"`
+	TemplateVersion string  `json:"template_version" db:"template_version"`
+	Model           string  `json:"model" db:"model"`
+	Outcome         string  `json:"outcome" db:"outcome"`
+	PromptTokens    int64   `json:"prompt_tokens" db:"prompt_tokens"`
+	EvalTokens      int64   `json:"eval_tokens" db:"eval_tokens"`
+	DurationMS      int64   `json:"duration_ms" db:"duration_ms"`
+	Error           *string `json:"error,omitempty" db:"error"`
+	Created         int64   `json:"created" db:"created"`
+}
+
+// LLM usage groupings.
+const (
+	LLMUsageByEngineer = "engineer"
+	LLMUsageByTemplate = "template"
+	LLMUsageByModel    = "model"
+)
+
+// LLMUsageFilter selects the calls a usage report aggregates. Times are unix
+// milliseconds; zero leaves that end open. EngineerID 0 matches everyone.
+type LLMUsageFilter struct {
+	GroupBy    string
+	EngineerID int64
+	Since      int64
+	Until      int64
+}
+
+// LLMUsage aggregates LLM calls. Only the fields of the grouping are set.
+// BilledCalls are the calls that used model time: accepted answers and
+// rejected ones the model generated tokens for. Quotas count these.
+type LLMUsage struct {
+	EngineerID      *int64 `json:"engineer_id,omitempty"`
+	TemplateName    string `json:"template_name,omitempty"`
+	TemplateVersion string `json:"template_version,omitempty"`
+	Model           string `json:"model,omitempty"`
+	Calls           int64  `json:"calls"`
+	CachedCalls     int64  `json:"cached_calls"`
+	FailedCalls     int64  `json:"failed_calls"`
+	BilledCalls     int64  `json:"billed_calls"`
+	PromptTokens    int64  `json:"prompt_tokens"`
+	EvalTokens      int64  `json:"eval_tokens"`
+	DurationMS      int64  `json:"duration_ms"`
+}
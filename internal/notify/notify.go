@@ -0,0 +1,47 @@
+package notify
+
+import (
+	"context"
+	"log/slog"
+	"os"
+)
+
+// Message is a single notification addressed to an engineer.
+type Message struct {
+	To      string            `json:"to"`
+	Subject string            `json:"subject"`
+	Body    string            `json:"body"`
+	Meta    map[string]string `json:"meta,omitempty"`
+}
+
+// Notifier delivers messages to users (email, chat, ...). Implementations must be
+// safe for concurrent use.
+type Notifier interface {
+	Notify(ctx context.Context, m Message) error
+}
+
+// LogNotifier is the default sink: it writes messages to a structured logger
+// instead of delivering them. Useful for development and for deployments that
+// have not configured a real delivery channel yet.
+type LogNotifier struct {
+	logger *slog.Logger
+}
+
+// NewLogNotifier returns a notifier that logs messages. A nil logger falls back
+// to a JSON logger on stdout.
+func NewLogNotifier(l *slog.Logger) *LogNotifier {
+	if l == nil {
+		l = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	}
+	return &LogNotifier{logger: l}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, m Message) error {
+	n.logger.InfoContext(ctx, "notification",
+		slog.String("to", m.To),
+		slog.String("subject", m.Subject),
+		slog.String("body", m.Body),
+		slog.Any("meta", m.Meta),
+	)
+	return nil
+}
@@ -0,0 +1,132 @@
+// Package privacy strips personal and secret data from text before it is sent
+// to a language model. Every path that hands user-authored text to a model
+// (prompt rendering, embeddings) should run it through a Redactor first.
+package privacy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/garnizeh/rag/internal/config"
+	"github.com/garnizeh/rag/internal/models"
+)
+
+// Rule is a named pattern whose matches are replaced by a placeholder.
+type Rule struct {
+	Kind    string
+	Pattern *regexp.Regexp
+}
+
+// builtinRules covers the common leaks in free-form activity logs. IPv6 only
+// matches the full eight-group form; compressed forms collide with too much
+// ordinary text (timestamps, C++ scopes) to be worth the false positives.
+var builtinRules = []Rule{
+	{Kind: "email", Pattern: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)},
+	{Kind: "jwt", Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+`)},
+	{Kind: "token", Pattern: regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/\-]+=*`)},
+	{Kind: "token", Pattern: regexp.MustCompile(`\b(?:ghp|gho|ghs|ghu|github_pat|glpat|xox[abprs]|sk)[-_][A-Za-z0-9_\-]{10,}`)},
+	{Kind: "token", Pattern: regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`)},
+	{Kind: "secret", Pattern: regexp.MustCompile(`(?i)\b(?:api[_-]?key|secret|token|password|passwd)\s*[:=]\s*\S+`)},
+	{Kind: "ipv4", Pattern: regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`)},
+	{Kind: "ipv6", Pattern: regexp.MustCompile(`(?i)\b(?:[0-9a-f]{1,4}:){7}[0-9a-f]{1,4}\b`)},
+}
+
+// Redactor replaces sensitive spans with "[REDACTED:<kind>]" placeholders.
+// A nil *Redactor is valid and leaves text untouched.
+type Redactor struct {
+	rules []Rule
+}
+
+// NewRedactor builds a redactor from the built-in rules plus the configured
+// patterns. It returns nil (no redaction) when redaction is disabled.
+func NewRedactor(cfg config.PrivacyConfig) (*Redactor, error) {
+	if cfg.DisableRedaction {
+		return nil, nil
+	}
+
+	rules := append([]Rule(nil), builtinRules...)
+	for _, p := range cfg.Patterns {
+		if strings.TrimSpace(p.Pattern) == "" {
+			continue
+		}
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("compile redaction pattern %q: %w", p.Name, err)
+		}
+		kind := p.Name
+		if kind == "" {
+			kind = "custom"
+		}
+		rules = append(rules, Rule{Kind: kind, Pattern: re})
+	}
+
+	return &Redactor{rules: rules}, nil
+}
+
+// DefaultRedactor returns a redactor with only the built-in rules.
+func DefaultRedactor() *Redactor {
+	return &Redactor{rules: builtinRules}
+}
+
+// Redact returns s with every sensitive span replaced, together with the spans
+// (byte offsets into s) that were removed. Overlapping matches are merged into
+// the earliest, longest one.
+func (r *Redactor) Redact(s string) (string, []models.Redaction) {
+	if r == nil || s == "" {
+		return s, nil
+	}
+
+	var spans []models.Redaction
+	for _, rule := range r.rules {
+		for _, loc := range rule.Pattern.FindAllStringIndex(s, -1) {
+			if loc[1] > loc[0] {
+				spans = append(spans, models.Redaction{Kind: rule.Kind, Start: loc[0], End: loc[1]})
+			}
+		}
+	}
+	if len(spans) == 0 {
+		return s, nil
+	}
+
+	sort.SliceStable(spans, func(i, j int) bool {
+		if spans[i].Start != spans[j].Start {
+			return spans[i].Start < spans[j].Start
+		}
+		return spans[i].End > spans[j].End
+	})
+	merged := spans[:1]
+	for _, sp := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if sp.Start < last.End {
+			if sp.End > last.End {
+				last.End = sp.End
+			}
+			continue
+		}
+		merged = append(merged, sp)
+	}
+
+	return Apply(s, merged), merged
+}
+
+// Apply replaces the given spans of s with placeholders. Spans must be sorted
+// and non-overlapping, as returned by Redact; out-of-range spans are ignored.
+// It lets callers rebuild the redacted view of a stored original without
+// re-running the (possibly since reconfigured) rules.
+func Apply(s string, spans []models.Redaction) string {
+	var sb strings.Builder
+	pos := 0
+	for _, sp := range spans {
+		if sp.Start < pos || sp.End > len(s) || sp.End <= sp.Start {
+			continue
+		}
+		sb.WriteString(s[pos:sp.Start])
+		sb.WriteString("[REDACTED:" + sp.Kind + "]")
+		pos = sp.End
+	}
+	sb.WriteString(s[pos:])
+
+	return sb.String()
+}
@@ -2,25 +2,94 @@ package sqlite
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"fmt"
 
 	"github.com/garnizeh/rag/internal/models"
+	"github.com/garnizeh/rag/pkg/repository"
 )
 
+const activityColumns = `id, engineer_id, activity, visibility, redactions, created`
+
 func (r *SQLiteRepo) CreateActivity(ctx context.Context, a *models.Activity) (int64, error) {
 	if a == nil {
 		return 0, fmt.Errorf("activity is nil")
 	}
 
-	res, err := r.conn.Exec(ctx, `INSERT INTO raw_activities (engineer_id, activity, created) VALUES (?, ?, ?)`, a.EngineerID, a.Activity, a.Created)
+	visibility := a.Visibility
+	if visibility == "" {
+		visibility = models.VisibilityShared
+	}
+
+	var redactions *string
+	if a.Redactions != nil {
+		b, err := json.Marshal(a.Redactions)
+		if err != nil {
+			return 0, fmt.Errorf("marshal redactions: %w", err)
+		}
+		s := string(b)
+		redactions = &s
+	}
+
+	var key *string
+	if a.IdempotencyKey != "" {
+		key = &a.IdempotencyKey
+	}
+
+	q := `INSERT INTO raw_activities (engineer_id, activity, visibility, redactions, idempotency_key, created) VALUES (?, ?, ?, ?, ?, ?)`
+	if key != nil {
+		q += ` ON CONFLICT(engineer_id, idempotency_key) WHERE idempotency_key IS NOT NULL DO NOTHING`
+	}
+	res, err := r.conn.Exec(ctx, q, a.EngineerID, a.Activity, visibility, redactions, key, a.Created)
 	if err != nil {
 		return 0, err
 	}
+	if key != nil {
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return 0, repository.ErrDuplicateKey
+		}
+	}
 
 	return res.LastInsertId()
 }
 
+func (r *SQLiteRepo) GetActivity(ctx context.Context, id int64) (*models.Activity, error) {
+	a, err := scanActivity(r.conn.QueryRow(ctx, `SELECT `+activityColumns+` FROM raw_activities WHERE id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (r *SQLiteRepo) GetActivityByIdempotencyKey(ctx context.Context, engineerID int64, key string) (*models.Activity, error) {
+	a, err := scanActivity(r.conn.QueryRow(ctx, `SELECT `+activityColumns+` FROM raw_activities WHERE engineer_id = ? AND idempotency_key = ?`, engineerID, key))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+	a.IdempotencyKey = key
+
+	return a, nil
+}
+
 func (r *SQLiteRepo) ListByEngineer(ctx context.Context, engineerID int64, limit, offset int) ([]models.Activity, error) {
+	return r.listActivities(ctx, `SELECT `+activityColumns+` FROM raw_activities WHERE engineer_id = ? ORDER BY created DESC LIMIT ? OFFSET ?`, engineerID, limit, offset)
+}
+
+func (r *SQLiteRepo) ListSharedByEngineer(ctx context.Context, engineerID int64, limit, offset int) ([]models.Activity, error) {
+	return r.listActivities(ctx, `SELECT `+activityColumns+` FROM raw_activities WHERE engineer_id = ? AND visibility <> 'private' ORDER BY created DESC LIMIT ? OFFSET ?`, engineerID, limit, offset)
+}
+
+func (r *SQLiteRepo) listActivities(ctx context.Context, query string, engineerID int64, limit, offset int) ([]models.Activity, error) {
 	if limit <= 0 {
 		limit = 50
 	}
@@ -28,7 +97,7 @@ func (r *SQLiteRepo) ListByEngineer(ctx context.Context, engineerID int64, limit
 		offset = 0
 	}
 
-	rows, err := r.conn.QueryRows(ctx, `SELECT id, engineer_id, activity, created FROM raw_activities WHERE engineer_id = ? ORDER BY created DESC LIMIT ? OFFSET ?`, engineerID, limit, offset)
+	rows, err := r.conn.QueryRows(ctx, query, engineerID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -36,17 +105,33 @@ func (r *SQLiteRepo) ListByEngineer(ctx context.Context, engineerID int64, limit
 
 	var out []models.Activity
 	for rows.Next() {
-		var a models.Activity
-		if err := rows.Scan(&a.ID, &a.EngineerID, &a.Activity, &a.Created); err != nil {
+		a, err := scanActivity(rows)
+		if err != nil {
 			return nil, err
 		}
 
-		out = append(out, a)
+		out = append(out, *a)
 	}
 
 	return out, nil
 }
 
+// scanActivity reads a raw_activities row selected with activityColumns.
+func scanActivity(row interface{ Scan(dest ...any) error }) (*models.Activity, error) {
+	var a models.Activity
+	var redactions sql.NullString
+	if err := row.Scan(&a.ID, &a.EngineerID, &a.Activity, &a.Visibility, &redactions, &a.Created); err != nil {
+		return nil, err
+	}
+	if redactions.Valid {
+		if err := json.Unmarshal([]byte(redactions.String), &a.Redactions); err != nil {
+			return nil, fmt.Errorf("unmarshal redactions for activity %d: %w", a.ID, err)
+		}
+	}
+
+	return &a, nil
+}
+
 func (r *SQLiteRepo) CountActivitiesByEngineer(ctx context.Context, engineerID int64) (int64, error) {
 	row := r.conn.QueryRow(ctx, `SELECT COUNT(*) FROM raw_activities WHERE engineer_id = ?`, engineerID)
 	var cnt int64
@@ -55,3 +140,12 @@ func (r *SQLiteRepo) CountActivitiesByEngineer(ctx context.Context, engineerID i
 	}
 	return cnt, nil
 }
+
+func (r *SQLiteRepo) CountSharedActivitiesByEngineer(ctx context.Context, engineerID int64) (int64, error) {
+	row := r.conn.QueryRow(ctx, `SELECT COUNT(*) FROM raw_activities WHERE engineer_id = ? AND visibility <> 'private'`, engineerID)
+	var cnt int64
+	if err := row.Scan(&cnt); err != nil {
+		return 0, err
+	}
+	return cnt, nil
+}
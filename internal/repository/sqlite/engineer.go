@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	"github.com/garnizeh/rag/internal/models"
 )
@@ -68,7 +69,46 @@ func (r *SQLiteRepo) UpdateEngineer(ctx context.Context, e *models.Engineer) err
 	return err
 }
 
+// engineerDependents are the statements DeleteEngineer runs before removing
+// the engineer row. They apply the ON DELETE clauses of the schema by hand,
+// since foreign keys are not enforced on our connections: everything owned by
+// the engineer is deleted, including teams they created, and llm_calls keep
+// their usage totals with the engineer cleared.
+var engineerDependents = []string{
+	`DELETE FROM engineer_profiles WHERE engineer_id = ?`,
+	`DELETE FROM raw_activities WHERE engineer_id = ?`,
+	`DELETE FROM ai_questions WHERE engineer_id = ?`,
+	`DELETE FROM engineer_contexts WHERE engineer_id = ?`,
+	`DELETE FROM engineer_context_history WHERE engineer_id = ?`,
+	`DELETE FROM password_resets WHERE engineer_id = ?`,
+	`DELETE FROM team_members WHERE engineer_id = ? OR team_id IN (SELECT id FROM teams WHERE created_by = ?)`,
+	`DELETE FROM teams WHERE created_by = ?`,
+	`DELETE FROM jobs WHERE engineer_id = ?`,
+	`DELETE FROM dead_letter_jobs WHERE engineer_id = ?`,
+	`UPDATE llm_calls SET engineer_id = NULL WHERE engineer_id = ?`,
+}
+
+// DeleteEngineer removes an engineer together with every row that belongs to
+// them, in one transaction.
 func (r *SQLiteRepo) DeleteEngineer(ctx context.Context, id int64) error {
-	_, err := r.conn.Exec(ctx, `DELETE FROM engineers WHERE id = ?`, id)
-	return err
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, q := range engineerDependents {
+		args := make([]any, strings.Count(q, "?"))
+		for i := range args {
+			args[i] = id
+		}
+		if _, err := tx.ExecContext(ctx, q, args...); err != nil {
+			return fmt.Errorf("delete engineer %d: %w", id, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM engineers WHERE id = ?`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
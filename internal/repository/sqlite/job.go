@@ -5,83 +5,148 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/garnizeh/rag/internal/models"
+	"github.com/garnizeh/rag/pkg/repository"
 )
 
-func (r *SQLiteRepo) CreateJob(ctx context.Context, j *models.Job) (int64, error) {
-	if j == nil {
-		return 0, fmt.Errorf("job is nil")
+// pendingDedupe matches the partial unique index on jobs(dedupe_key): a key
+// blocks new jobs only while its job has not finished.
+const pendingDedupe = `dedupe_key IS NOT NULL AND status IN ('queued', 'retry', 'running')`
+
+// Enqueue inserts a job into the jobs table and returns the new ID. A job
+// with a DedupeKey already held by an unfinished job is not inserted; the
+// existing job's ID is returned instead.
+func (r *SQLiteRepo) Enqueue(ctx context.Context, j *models.BackgroundJob) (int64, error) {
+	payload := string(j.Payload)
+	if j.MaxAttempts == 0 {
+		j.MaxAttempts = 5
+	}
+	var dedupe *string
+	if j.DedupeKey != "" {
+		dedupe = &j.DedupeKey
 	}
 
-	res, err := r.conn.Exec(ctx, `INSERT INTO processing_jobs (status, created) VALUES (?, ?)`, j.Status, now())
+	now := now()
+	var engineerID *int64
+	if j.EngineerID > 0 {
+		engineerID = &j.EngineerID
+	}
+
+	q := `INSERT INTO jobs(type, payload, status, attempts, max_attempts, priority, scheduled_at, dedupe_key, engineer_id, created, updated) VALUES(?,?,?,?,?,?,?,?,?,?,?)`
+	if dedupe != nil {
+		q += ` ON CONFLICT(dedupe_key) WHERE ` + pendingDedupe + ` DO NOTHING`
+	}
+	res, err := r.conn.Exec(ctx, q, j.Type, payload, "queued", j.Attempts, j.MaxAttempts, j.Priority, j.ScheduledAt.UTC().Unix(), dedupe, engineerID, now, now)
+	if err != nil {
+		return 0, fmt.Errorf("enqueue failed: %w", err)
+	}
+	if dedupe != nil {
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			var id int64
+			if err := r.conn.QueryRow(ctx, `SELECT id FROM jobs WHERE dedupe_key = ? AND `+pendingDedupe, j.DedupeKey).Scan(&id); err != nil {
+				return 0, fmt.Errorf("find duplicate job: %w", err)
+			}
+			return id, nil
+		}
+	}
+	id, err := res.LastInsertId()
 	if err != nil {
 		return 0, err
 	}
+	r.notifyEnqueue(j.ScheduledAt)
 
-	return res.LastInsertId()
+	return id, nil
 }
 
-func (r *SQLiteRepo) UpdateStatus(ctx context.Context, id int64, status string) error {
-	_, err := r.conn.Exec(ctx, `UPDATE processing_jobs SET status = ? WHERE id = ?`, status, id)
-	return err
+// OnEnqueue registers fn to be called after every successful Enqueue or requeue.
+// Hooks run synchronously on the enqueueing goroutine and must not block.
+func (r *SQLiteRepo) OnEnqueue(fn func(runAt time.Time)) {
+	if fn == nil {
+		return
+	}
+	r.hookMu.Lock()
+	r.enqueueHooks = append(r.enqueueHooks, fn)
+	r.hookMu.Unlock()
 }
 
-// Enqueue inserts a job into the jobs table and returns the new ID
-func (r *SQLiteRepo) Enqueue(ctx context.Context, j *models.BackgroundJob) (int64, error) {
-	payload := string(j.Payload)
-	if j.MaxAttempts == 0 {
-		j.MaxAttempts = 5
+func (r *SQLiteRepo) notifyEnqueue(runAt time.Time) {
+	r.hookMu.RLock()
+	defer r.hookMu.RUnlock()
+	for _, fn := range r.enqueueHooks {
+		fn(runAt)
 	}
-	now := time.Now().UTC().UnixMilli()
-	q := `INSERT INTO jobs(type, payload, status, attempts, max_attempts, priority, scheduled_at, created, updated) VALUES(?,?,?,?,?,?,?,?,?)`
-	res, err := r.conn.Exec(ctx, q, j.Type, payload, "queued", j.Attempts, j.MaxAttempts, j.Priority, j.ScheduledAt.UTC().Unix(), now, now)
-	if err != nil {
-		return 0, fmt.Errorf("enqueue failed: %w", err)
+}
+
+// jobTypeClause renders f as an SQL condition on the type column, with its arguments.
+func jobTypeClause(f models.JobTypeFilter) (string, []any) {
+	clause := `1=1`
+	var args []any
+	if len(f.Only) > 0 {
+		clause += ` AND type IN (?` + strings.Repeat(`,?`, len(f.Only)-1) + `)`
+		for _, t := range f.Only {
+			args = append(args, t)
+		}
+	}
+	if len(f.Except) > 0 {
+		clause += ` AND type NOT IN (?` + strings.Repeat(`,?`, len(f.Except)-1) + `)`
+		for _, t := range f.Except {
+			args = append(args, t)
+		}
+	}
+
+	return clause, args
+}
+
+// NextJobTime returns the earliest time a job matching f becomes claimable, or
+// nil if none is pending.
+func (r *SQLiteRepo) NextJobTime(ctx context.Context, f models.JobTypeFilter) (*time.Time, error) {
+	typeClause, typeArgs := jobTypeClause(f)
+	q := `SELECT MIN(t) FROM (
+		SELECT MAX(scheduled_at, COALESCE(next_try_at, 0)) AS t FROM jobs WHERE status IN ('queued', 'retry') AND ` + typeClause + `
+		UNION ALL
+		SELECT lease_until AS t FROM jobs WHERE status = 'running' AND lease_until IS NOT NULL AND ` + typeClause + `
+	)`
+	var next sql.NullInt64
+	if err := r.conn.QueryRow(ctx, q, append(typeArgs, typeArgs...)...).Scan(&next); err != nil {
+		return nil, fmt.Errorf("next job time: %w", err)
+	}
+	if !next.Valid {
+		return nil, nil
 	}
+	t := time.Unix(next.Int64, 0)
 
-	return res.LastInsertId()
+	return &t, nil
 }
 
-// FetchNext fetches the next available job respecting priority and schedule
-func (r *SQLiteRepo) FetchNext(ctx context.Context) (*models.BackgroundJob, error) {
-	q := `SELECT id, type, payload, status, attempts, max_attempts, priority, scheduled_at, next_try_at, last_error, created, updated FROM jobs WHERE (status = 'queued' OR status = 'retry') AND (next_try_at IS NULL OR next_try_at <= ?) AND scheduled_at <= ? ORDER BY priority ASC, scheduled_at ASC LIMIT 1`
-	now := time.Now().UTC().Unix()
-	row := r.conn.QueryRow(ctx, q, now, now)
+const jobColumns = `id, type, payload, status, attempts, max_attempts, priority, scheduled_at, next_try_at, last_error, locked_by, lease_until, dedupe_key, engineer_id, result, created, updated`
+
+// scanJob reads a jobs row selected with jobColumns. scheduled_at, next_try_at
+// and lease_until are unix seconds; created and updated are unix milliseconds.
+func scanJob(row interface{ Scan(dest ...any) error }) (*models.BackgroundJob, error) {
 	var (
-		id          int64
-		typ         string
+		j           models.BackgroundJob
 		payload     sql.NullString
-		status      string
-		attempts    int
-		maxAttempts int
-		priority    int
 		scheduledAt int64
 		nextTry     sql.NullInt64
 		lastError   sql.NullString
+		lockedBy    sql.NullString
+		leaseUntil  sql.NullInt64
+		dedupeKey   sql.NullString
+		engineerID  sql.NullInt64
+		result      sql.NullString
 		created     int64
 		updated     int64
 	)
-	if err := row.Scan(&id, &typ, &payload, &status, &attempts, &maxAttempts, &priority, &scheduledAt, &nextTry, &lastError, &created, &updated); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
-
-		return nil, fmt.Errorf("fetch next job: %w", err)
+	if err := row.Scan(&j.ID, &j.Type, &payload, &j.Status, &j.Attempts, &j.MaxAttempts, &j.Priority, &scheduledAt, &nextTry, &lastError, &lockedBy, &leaseUntil, &dedupeKey, &engineerID, &result, &created, &updated); err != nil {
+		return nil, err
 	}
 
-	j := &models.BackgroundJob{
-		ID:          id,
-		Type:        typ,
-		Status:      status,
-		Attempts:    attempts,
-		MaxAttempts: maxAttempts,
-		Priority:    priority,
-		ScheduledAt: time.Unix(scheduledAt, 0),
-		Created:     time.Unix(created, 0),
-		Updated:     time.Unix(updated, 0),
-	}
+	j.ScheduledAt = time.Unix(scheduledAt, 0)
+	j.Created = time.UnixMilli(created)
+	j.Updated = time.UnixMilli(updated)
 	if payload.Valid {
 		j.Payload = json.RawMessage(payload.String)
 	}
@@ -92,11 +157,61 @@ func (r *SQLiteRepo) FetchNext(ctx context.Context) (*models.BackgroundJob, erro
 	if lastError.Valid {
 		j.LastError = lastError.String
 	}
+	j.LockedBy = lockedBy.String
+	j.DedupeKey = dedupeKey.String
+	j.EngineerID = engineerID.Int64
+	if result.Valid {
+		j.Result = json.RawMessage(result.String)
+	}
+	if leaseUntil.Valid {
+		t := time.Unix(leaseUntil.Int64, 0)
+		j.LeaseUntil = &t
+	}
+
+	return &j, nil
+}
+
+// FetchNext claims the next available job of a type matching f for workerID
+// and returns it, or nil when there is nothing to do. Claiming sets status
+// 'running', locked_by and lease_until in a single statement, so concurrent
+// workers never receive the same job. Running jobs whose lease has expired
+// (their worker crashed or hung) are reclaimed; the lost run counts as a failed attempt.
+func (r *SQLiteRepo) FetchNext(ctx context.Context, workerID string, lease time.Duration, f models.JobTypeFilter) (*models.BackgroundJob, error) {
+	typeClause, typeArgs := jobTypeClause(f)
+	q := `UPDATE jobs SET
+		status = 'running',
+		locked_by = ?,
+		lease_until = ?,
+		attempts = attempts + CASE WHEN status = 'running' THEN 1 ELSE 0 END,
+		last_error = CASE WHEN status = 'running' THEN 'lease expired (worker ' || COALESCE(locked_by, '?') || ')' ELSE last_error END,
+		updated = ?
+	WHERE id = (
+		SELECT id FROM jobs
+		WHERE (((status = 'queued' OR status = 'retry') AND (next_try_at IS NULL OR next_try_at <= ?) AND scheduled_at <= ?)
+			OR (status = 'running' AND lease_until <= ?))
+			AND ` + typeClause + `
+		ORDER BY priority ASC, scheduled_at ASC
+		LIMIT 1
+	)
+	RETURNING ` + jobColumns
+	nowSec := time.Now().UTC().Unix()
+	leaseUntil := time.Now().Add(lease).UTC().Unix()
+	args := append([]any{workerID, leaseUntil, now(), nowSec, nowSec, nowSec}, typeArgs...)
+	j, err := scanJob(r.conn.QueryRow(ctx, q, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("fetch next job: %w", err)
+	}
 
 	return j, nil
 }
 
-// UpdateJob updates attempts, status, next_try_at, last_error
+// UpdateJob updates attempts, status, next_try_at, last_error and result and
+// releases the lease. When j.LockedBy is set the update only applies if that
+// worker still holds the job; otherwise repository.ErrLeaseLost is returned.
 func (r *SQLiteRepo) UpdateJob(ctx context.Context, j *models.BackgroundJob) error {
 	var nextTry any
 	if j.NextTryAt != nil {
@@ -104,10 +219,22 @@ func (r *SQLiteRepo) UpdateJob(ctx context.Context, j *models.BackgroundJob) err
 	} else {
 		nextTry = nil
 	}
-	q := `UPDATE jobs SET status = ?, attempts = ?, next_try_at = ?, last_error = ?, updated = ? WHERE id = ?`
-	_, err := r.conn.Exec(ctx, q, j.Status, j.Attempts, nextTry, j.LastError, time.Now().UTC().Unix(), j.ID)
+	var result any
+	if len(j.Result) > 0 {
+		result = string(j.Result)
+	}
+	q := `UPDATE jobs SET status = ?, attempts = ?, next_try_at = ?, last_error = ?, result = ?, locked_by = NULL, lease_until = NULL, updated = ? WHERE id = ? AND (? = '' OR locked_by = ?)`
+	res, err := r.conn.Exec(ctx, q, j.Status, j.Attempts, nextTry, j.LastError, result, now(), j.ID, j.LockedBy, j.LockedBy)
+	if err != nil {
+		return err
+	}
+	if j.LockedBy != "" {
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return repository.ErrLeaseLost
+		}
+	}
 
-	return err
+	return nil
 }
 
 // MoveToDeadLetter moves a job to dead_letter_jobs and deletes the original
@@ -118,8 +245,19 @@ func (r *SQLiteRepo) MoveToDeadLetter(ctx context.Context, j *models.BackgroundJ
 	}
 
 	payload := string(j.Payload)
-	insert := `INSERT INTO dead_letter_jobs(job_id, type, payload, attempts, last_error, failed_at) VALUES(?,?,?,?,?,?)`
-	if _, err := tx.ExecContext(ctx, insert, j.ID, j.Type, payload, j.Attempts, j.LastError, time.Now().UTC().Unix()); err != nil {
+	var engineerID *int64
+	if j.EngineerID > 0 {
+		engineerID = &j.EngineerID
+	}
+	var dedupe *string
+	if j.DedupeKey != "" {
+		dedupe = &j.DedupeKey
+	}
+	if j.MaxAttempts == 0 {
+		j.MaxAttempts = 5
+	}
+	insert := `INSERT INTO dead_letter_jobs(job_id, type, payload, attempts, last_error, failed_at, engineer_id, priority, max_attempts, dedupe_key) VALUES(?,?,?,?,?,?,?,?,?,?)`
+	if _, err := tx.ExecContext(ctx, insert, j.ID, j.Type, payload, j.Attempts, j.LastError, time.Now().UTC().Unix(), engineerID, j.Priority, j.MaxAttempts, dedupe); err != nil {
 		_ = tx.Rollback()
 		return err
 	}
@@ -131,3 +269,252 @@ func (r *SQLiteRepo) MoveToDeadLetter(ctx context.Context, j *models.BackgroundJ
 
 	return tx.Commit()
 }
+
+// ListJobs returns jobs matching the filter, newest first.
+func (r *SQLiteRepo) ListJobs(ctx context.Context, f models.JobFilter) ([]models.BackgroundJob, error) {
+	q := `SELECT ` + jobColumns + ` FROM jobs WHERE 1=1`
+	var args []any
+	if f.Status != "" {
+		q += ` AND status = ?`
+		args = append(args, f.Status)
+	}
+	if f.Type != "" {
+		q += ` AND type = ?`
+		args = append(args, f.Type)
+	}
+	q += ` ORDER BY id DESC LIMIT ? OFFSET ?`
+	limit, offset := f.Page()
+	args = append(args, limit, offset)
+
+	rows, err := r.conn.QueryRows(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.BackgroundJob
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *j)
+	}
+
+	return out, nil
+}
+
+func (r *SQLiteRepo) GetJobByDedupeKey(ctx context.Context, key string) (*models.BackgroundJob, error) {
+	j, err := scanJob(r.conn.QueryRow(ctx, `SELECT `+jobColumns+` FROM jobs WHERE dedupe_key = ? ORDER BY id DESC LIMIT 1`, key))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return j, nil
+}
+
+func (r *SQLiteRepo) GetJob(ctx context.Context, id int64) (*models.BackgroundJob, error) {
+	j, err := scanJob(r.conn.QueryRow(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return j, nil
+}
+
+// CancelJob marks a queued or retrying job as canceled. It reports false when
+// the job does not exist or is no longer waiting to run.
+func (r *SQLiteRepo) CancelJob(ctx context.Context, id int64) (bool, error) {
+	res, err := r.conn.Exec(ctx, `UPDATE jobs SET status = 'canceled', updated = ? WHERE id = ? AND status IN ('queued', 'retry')`, now(), id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// PurgeJobs deletes finished (done or canceled) jobs last updated before cutoff
+// and returns how many were removed.
+func (r *SQLiteRepo) PurgeJobs(ctx context.Context, before time.Time) (int64, error) {
+	res, err := r.conn.Exec(ctx, `DELETE FROM jobs WHERE status IN ('done', 'canceled') AND updated < ?`, before.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// CountJobs returns the number of jobs per status and type; dead letters are
+// counted under status 'dead_letter'.
+func (r *SQLiteRepo) CountJobs(ctx context.Context) ([]models.JobCount, error) {
+	q := `SELECT status, type, COUNT(*) FROM jobs GROUP BY status, type
+		UNION ALL
+		SELECT 'dead_letter', type, COUNT(*) FROM dead_letter_jobs GROUP BY type`
+	rows, err := r.conn.QueryRows(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.JobCount
+	for rows.Next() {
+		var c models.JobCount
+		if err := rows.Scan(&c.Status, &c.Type, &c.Count); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+
+	return out, rows.Err()
+}
+
+const deadLetterColumns = `id, job_id, type, payload, attempts, last_error, failed_at, engineer_id, priority, max_attempts, dedupe_key`
+
+func scanDeadLetter(row interface{ Scan(dest ...any) error }) (*models.DeadLetterJob, error) {
+	var (
+		d          models.DeadLetterJob
+		payload    sql.NullString
+		lastError  sql.NullString
+		failedAt   int64
+		engineerID sql.NullInt64
+		dedupe     sql.NullString
+	)
+	if err := row.Scan(&d.ID, &d.JobID, &d.Type, &payload, &d.Attempts, &lastError, &failedAt, &engineerID, &d.Priority, &d.MaxAttempts, &dedupe); err != nil {
+		return nil, err
+	}
+	if payload.Valid {
+		d.Payload = json.RawMessage(payload.String)
+	}
+	d.LastError = lastError.String
+	d.FailedAt = time.Unix(failedAt, 0)
+	d.EngineerID = engineerID.Int64
+	d.DedupeKey = dedupe.String
+
+	return &d, nil
+}
+
+// ListDeadLetters returns dead-lettered jobs, most recently failed first. Only
+// the Type filter applies; every dead letter has failed.
+func (r *SQLiteRepo) ListDeadLetters(ctx context.Context, f models.JobFilter) ([]models.DeadLetterJob, error) {
+	q := `SELECT ` + deadLetterColumns + ` FROM dead_letter_jobs`
+	var args []any
+	if f.Type != "" {
+		q += ` WHERE type = ?`
+		args = append(args, f.Type)
+	}
+	q += ` ORDER BY failed_at DESC, id DESC LIMIT ? OFFSET ?`
+	limit, offset := f.Page()
+	args = append(args, limit, offset)
+
+	rows, err := r.conn.QueryRows(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.DeadLetterJob
+	for rows.Next() {
+		d, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *d)
+	}
+
+	return out, nil
+}
+
+func (r *SQLiteRepo) GetDeadLetter(ctx context.Context, id int64) (*models.DeadLetterJob, error) {
+	d, err := scanDeadLetter(r.conn.QueryRow(ctx, `SELECT `+deadLetterColumns+` FROM dead_letter_jobs WHERE id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func (r *SQLiteRepo) GetDeadLetterByJobID(ctx context.Context, jobID int64) (*models.DeadLetterJob, error) {
+	d, err := scanDeadLetter(r.conn.QueryRow(ctx, `SELECT `+deadLetterColumns+` FROM dead_letter_jobs WHERE job_id = ? ORDER BY id DESC LIMIT 1`, jobID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// RequeueDeadLetter moves a dead-lettered job back into jobs with a fresh
+// attempt budget and returns the new job id, or 0 if the dead letter does not exist.
+func (r *SQLiteRepo) RequeueDeadLetter(ctx context.Context, id int64) (int64, error) {
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var (
+		typ         string
+		payload     sql.NullString
+		engineerID  sql.NullInt64
+		priority    int
+		maxAttempts int
+		dedupe      sql.NullString
+	)
+	q := `SELECT type, payload, engineer_id, priority, max_attempts, dedupe_key FROM dead_letter_jobs WHERE id = ?`
+	if err := tx.QueryRowContext(ctx, q, id).Scan(&typ, &payload, &engineerID, &priority, &maxAttempts, &dedupe); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+
+	now := now()
+	insert := `INSERT INTO jobs(type, payload, status, attempts, max_attempts, priority, scheduled_at, dedupe_key, engineer_id, created, updated) VALUES(?, ?, 'queued', 0, ?, ?, ?, ?, ?, ?, ?)`
+	if dedupe.Valid {
+		insert += ` ON CONFLICT(dedupe_key) WHERE ` + pendingDedupe + ` DO NOTHING`
+	}
+	res, err := tx.ExecContext(ctx, insert, typ, payload, maxAttempts, priority, time.Now().UTC().Unix(), dedupe, engineerID, now, now)
+	if err != nil {
+		return 0, fmt.Errorf("requeue job: %w", err)
+	}
+	var jobID int64
+	if n, err := res.RowsAffected(); err == nil && n == 0 && dedupe.Valid {
+		// the same work is already pending; the dead letter is settled by it
+		if err := tx.QueryRowContext(ctx, `SELECT id FROM jobs WHERE dedupe_key = ? AND `+pendingDedupe, dedupe.String).Scan(&jobID); err != nil {
+			return 0, fmt.Errorf("find duplicate job: %w", err)
+		}
+	} else if jobID, err = res.LastInsertId(); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM dead_letter_jobs WHERE id = ?`, id); err != nil {
+		return 0, fmt.Errorf("delete dead letter: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	r.notifyEnqueue(time.Now())
+
+	return jobID, nil
+}
@@ -0,0 +1,171 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/garnizeh/rag/internal/models"
+)
+
+const scheduleColumns = `id, name, spec, type, payload, priority, max_attempts, enabled, next_run_at, last_run_at, last_job_id, created, updated`
+
+// scanSchedule reads a job_schedules row selected with scheduleColumns.
+// next_run_at and last_run_at are unix seconds; created and updated are unix milliseconds.
+func scanSchedule(row interface{ Scan(dest ...any) error }) (*models.JobSchedule, error) {
+	var (
+		s         models.JobSchedule
+		payload   sql.NullString
+		nextRunAt int64
+		lastRunAt sql.NullInt64
+		lastJobID sql.NullInt64
+		created   int64
+		updated   int64
+	)
+	if err := row.Scan(&s.ID, &s.Name, &s.Spec, &s.Type, &payload, &s.Priority, &s.MaxAttempts, &s.Enabled, &nextRunAt, &lastRunAt, &lastJobID, &created, &updated); err != nil {
+		return nil, err
+	}
+
+	s.NextRunAt = time.Unix(nextRunAt, 0)
+	s.Created = time.UnixMilli(created)
+	s.Updated = time.UnixMilli(updated)
+	if payload.Valid {
+		s.Payload = json.RawMessage(payload.String)
+	}
+	if lastRunAt.Valid {
+		t := time.Unix(lastRunAt.Int64, 0)
+		s.LastRunAt = &t
+	}
+	s.LastJobID = lastJobID.Int64
+
+	return &s, nil
+}
+
+func (r *SQLiteRepo) CreateSchedule(ctx context.Context, s *models.JobSchedule) (int64, error) {
+	if s == nil {
+		return 0, fmt.Errorf("schedule is nil")
+	}
+	if s.MaxAttempts == 0 {
+		s.MaxAttempts = 5
+	}
+
+	now := now()
+	q := `INSERT INTO job_schedules(name, spec, type, payload, priority, max_attempts, enabled, next_run_at, created, updated) VALUES(?,?,?,?,?,?,?,?,?,?)`
+	res, err := r.conn.Exec(ctx, q, s.Name, s.Spec, s.Type, string(s.Payload), s.Priority, s.MaxAttempts, s.Enabled, s.NextRunAt.UTC().Unix(), now, now)
+	if err != nil {
+		return 0, fmt.Errorf("create schedule: %w", err)
+	}
+
+	return res.LastInsertId()
+}
+
+func (r *SQLiteRepo) GetSchedule(ctx context.Context, id int64) (*models.JobSchedule, error) {
+	s, err := scanSchedule(r.conn.QueryRow(ctx, `SELECT `+scheduleColumns+` FROM job_schedules WHERE id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// ListSchedules returns every schedule ordered by name.
+func (r *SQLiteRepo) ListSchedules(ctx context.Context) ([]models.JobSchedule, error) {
+	return r.querySchedules(ctx, `SELECT `+scheduleColumns+` FROM job_schedules ORDER BY name`)
+}
+
+// DueSchedules returns enabled schedules whose next run is at or before now,
+// earliest first.
+func (r *SQLiteRepo) DueSchedules(ctx context.Context, now time.Time) ([]models.JobSchedule, error) {
+	return r.querySchedules(ctx, `SELECT `+scheduleColumns+` FROM job_schedules WHERE enabled = 1 AND next_run_at <= ? ORDER BY next_run_at`, now.UTC().Unix())
+}
+
+func (r *SQLiteRepo) querySchedules(ctx context.Context, q string, args ...any) ([]models.JobSchedule, error) {
+	rows, err := r.conn.QueryRows(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.JobSchedule
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *s)
+	}
+
+	return out, rows.Err()
+}
+
+func (r *SQLiteRepo) UpdateSchedule(ctx context.Context, s *models.JobSchedule) error {
+	q := `UPDATE job_schedules SET spec = ?, type = ?, payload = ?, priority = ?, max_attempts = ?, enabled = ?, next_run_at = ?, updated = ? WHERE id = ?`
+	_, err := r.conn.Exec(ctx, q, s.Spec, s.Type, string(s.Payload), s.Priority, s.MaxAttempts, s.Enabled, s.NextRunAt.UTC().Unix(), now(), s.ID)
+	return err
+}
+
+// DeleteSchedule removes a schedule. Jobs it already enqueued are kept. It
+// reports false when the schedule does not exist.
+func (r *SQLiteRepo) DeleteSchedule(ctx context.Context, id int64) (bool, error) {
+	res, err := r.conn.Exec(ctx, `DELETE FROM job_schedules WHERE id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// FireSchedule enqueues the run of s due at s.NextRunAt and moves the schedule
+// on to next in one transaction. The schedule update is guarded on the
+// next_run_at value s was read with, so when several instances race for the
+// same run only the first commit enqueues a job; the others get 0.
+func (r *SQLiteRepo) FireSchedule(ctx context.Context, s *models.JobSchedule, next time.Time) (int64, error) {
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	runAt := s.NextRunAt.UTC().Unix()
+	now := now()
+	res, err := tx.ExecContext(ctx, `INSERT INTO jobs(type, payload, status, attempts, max_attempts, priority, scheduled_at, created, updated) VALUES(?, ?, 'queued', 0, ?, ?, ?, ?, ?)`,
+		s.Type, string(s.Payload), s.MaxAttempts, s.Priority, runAt, now, now)
+	if err != nil {
+		return 0, fmt.Errorf("enqueue scheduled job: %w", err)
+	}
+	jobID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	q := `UPDATE job_schedules SET next_run_at = ?, last_run_at = ?, last_job_id = ?, updated = ? WHERE id = ? AND enabled = 1 AND next_run_at = ?`
+	res, err = tx.ExecContext(ctx, q, next.UTC().Unix(), runAt, jobID, now, s.ID, runAt)
+	if err != nil {
+		return 0, fmt.Errorf("advance schedule: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		// lost the race or the schedule was edited; the deferred rollback drops the job
+		return 0, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	r.notifyEnqueue(s.NextRunAt)
+
+	return jobID, nil
+}
@@ -0,0 +1,92 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/garnizeh/rag/internal/models"
+)
+
+// GetCachedResponse returns the unexpired cache entry for key, or nil if none,
+// and bumps its hit count and last use.
+func (r *SQLiteRepo) GetCachedResponse(ctx context.Context, key string) (*models.LLMCacheEntry, error) {
+	ts := now()
+	row := r.conn.QueryRow(ctx, `SELECT key, model, response, size, hits, expires_at, last_used, created FROM llm_cache WHERE key = ? AND expires_at > ?`, key, ts)
+	var e models.LLMCacheEntry
+	if err := row.Scan(&e.Key, &e.Model, &e.Response, &e.Size, &e.Hits, &e.ExpiresAt, &e.LastUsed, &e.Created); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	if _, err := r.conn.Exec(ctx, `UPDATE llm_cache SET hits = hits + 1, last_used = ? WHERE key = ?`, ts, key); err != nil {
+		return nil, err
+	}
+	e.Hits++
+	e.LastUsed = ts
+
+	return &e, nil
+}
+
+// PutCachedResponse stores a cache entry, replacing an existing one with the
+// same key. Size defaults to the length of the response.
+func (r *SQLiteRepo) PutCachedResponse(ctx context.Context, e *models.LLMCacheEntry) error {
+	if e == nil {
+		return fmt.Errorf("cache entry is nil")
+	}
+	if e.Size <= 0 {
+		e.Size = int64(len(e.Response))
+	}
+
+	ts := now()
+	_, err := r.conn.Exec(ctx, `INSERT INTO llm_cache (key, model, response, size, hits, expires_at, last_used, created) VALUES (?, ?, ?, ?, 0, ?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET model = excluded.model, response = excluded.response, size = excluded.size, hits = 0,
+		expires_at = excluded.expires_at, last_used = excluded.last_used, created = excluded.created`,
+		e.Key, e.Model, e.Response, e.Size, e.ExpiresAt, ts, ts)
+	return err
+}
+
+// PruneLLMCache deletes expired entries, then evicts the least recently used
+// ones until the remaining entries hold at most maxBytes. A non-positive
+// maxBytes only removes expired entries.
+func (r *SQLiteRepo) PruneLLMCache(ctx context.Context, maxBytes int64) (int64, error) {
+	res, err := r.conn.Exec(ctx, `DELETE FROM llm_cache WHERE expires_at <= ?`, now())
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	if maxBytes <= 0 {
+		return n, nil
+	}
+
+	// keep the most recently used entries whose running size fits the cap
+	res, err = r.conn.Exec(ctx, `DELETE FROM llm_cache WHERE key IN (
+		SELECT key FROM (SELECT key, SUM(size) OVER (ORDER BY last_used DESC, key) AS running FROM llm_cache)
+		WHERE running > ?)`, maxBytes)
+	if err != nil {
+		return n, err
+	}
+	evicted, _ := res.RowsAffected()
+
+	return n + evicted, nil
+}
+
+// PurgeLLMCache deletes the cache entries of model, or all entries when model
+// is empty.
+func (r *SQLiteRepo) PurgeLLMCache(ctx context.Context, model string) (int64, error) {
+	var res sql.Result
+	var err error
+	if model == "" {
+		res, err = r.conn.Exec(ctx, `DELETE FROM llm_cache`)
+	} else {
+		res, err = r.conn.Exec(ctx, `DELETE FROM llm_cache WHERE model = ?`, model)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
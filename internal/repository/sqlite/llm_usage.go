@@ -0,0 +1,96 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/garnizeh/rag/internal/models"
+)
+
+func (r *SQLiteRepo) CreateLLMCall(ctx context.Context, c *models.LLMCall) (int64, error) {
+	if c == nil {
+		return 0, fmt.Errorf("llm call is nil")
+	}
+	if c.Created == 0 {
+		c.Created = now()
+	}
+
+	res, err := r.conn.Exec(ctx, `INSERT INTO llm_calls (engineer_id, template_name, template_version, model, outcome, prompt_tokens, eval_tokens, duration_ms, error, created) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.EngineerID, c.TemplateName, c.TemplateVersion, c.Model, c.Outcome, c.PromptTokens, c.EvalTokens, c.DurationMS, c.Error, c.Created)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.LastInsertId()
+}
+
+// LLMUsage aggregates llm_calls per engineer, template (name and version) or
+// model. Cached calls count as calls but add no tokens or model time.
+func (r *SQLiteRepo) LLMUsage(ctx context.Context, f models.LLMUsageFilter) ([]models.LLMUsage, error) {
+	var keys string
+	switch f.GroupBy {
+	case models.LLMUsageByEngineer:
+		keys = `engineer_id`
+	case models.LLMUsageByTemplate:
+		keys = `template_name, template_version`
+	case models.LLMUsageByModel:
+		keys = `model`
+	default:
+		return nil, fmt.Errorf("unknown llm usage grouping %q", f.GroupBy)
+	}
+
+	q := `SELECT ` + keys + `, COUNT(*),
+		COALESCE(SUM(outcome = 'cached'), 0), COALESCE(SUM(outcome IN ('invalid', 'error')), 0),
+		COALESCE(SUM(outcome = 'ok' OR (outcome = 'invalid' AND eval_tokens > 0)), 0),
+		COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(eval_tokens), 0), COALESCE(SUM(duration_ms), 0)
+		FROM llm_calls WHERE 1=1`
+	var args []any
+	if f.EngineerID > 0 {
+		q += ` AND engineer_id = ?`
+		args = append(args, f.EngineerID)
+	}
+	if f.Since > 0 {
+		q += ` AND created >= ?`
+		args = append(args, f.Since)
+	}
+	if f.Until > 0 {
+		q += ` AND created < ?`
+		args = append(args, f.Until)
+	}
+	q += ` GROUP BY ` + keys + ` ORDER BY SUM(duration_ms) DESC, COUNT(*) DESC`
+
+	rows, err := r.conn.QueryRows(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.LLMUsage
+	for rows.Next() {
+		var u models.LLMUsage
+		totals := []any{&u.Calls, &u.CachedCalls, &u.FailedCalls, &u.BilledCalls, &u.PromptTokens, &u.EvalTokens, &u.DurationMS}
+		switch f.GroupBy {
+		case models.LLMUsageByEngineer:
+			var id sql.NullInt64
+			if err := rows.Scan(append([]any{&id}, totals...)...); err != nil {
+				return nil, err
+			}
+			if id.Valid {
+				v := id.Int64
+				u.EngineerID = &v
+			}
+		case models.LLMUsageByTemplate:
+			if err := rows.Scan(append([]any{&u.TemplateName, &u.TemplateVersion}, totals...)...); err != nil {
+				return nil, err
+			}
+		case models.LLMUsageByModel:
+			if err := rows.Scan(append([]any{&u.Model}, totals...)...); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, u)
+	}
+
+	return out, rows.Err()
+}
@@ -0,0 +1,65 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/garnizeh/rag/internal/models"
+)
+
+func (r *SQLiteRepo) CreateReset(ctx context.Context, pr *models.PasswordReset) (int64, error) {
+	if pr == nil {
+		return 0, fmt.Errorf("password reset is nil")
+	}
+
+	res, err := r.conn.Exec(ctx, `INSERT INTO password_resets (engineer_id, token_hash, expires_at, created) VALUES (?, ?, ?, ?)`, pr.EngineerID, pr.TokenHash, pr.ExpiresAt, now())
+	if err != nil {
+		return 0, err
+	}
+
+	return res.LastInsertId()
+}
+
+// GetResetByTokenHash returns the reset row matching the hashed token, or nil if none.
+func (r *SQLiteRepo) GetResetByTokenHash(ctx context.Context, tokenHash string) (*models.PasswordReset, error) {
+	row := r.conn.QueryRow(ctx, `SELECT id, engineer_id, token_hash, expires_at, used_at, created FROM password_resets WHERE token_hash = ?`, tokenHash)
+	var pr models.PasswordReset
+	var used sql.NullInt64
+	if err := row.Scan(&pr.ID, &pr.EngineerID, &pr.TokenHash, &pr.ExpiresAt, &used, &pr.Created); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	if used.Valid {
+		v := used.Int64
+		pr.UsedAt = &v
+	}
+
+	return &pr, nil
+}
+
+// MarkResetUsed flags a reset token as consumed so it cannot be replayed. It
+// reports false when the token was already used, so of two concurrent
+// resets with the same token only one consumes it.
+func (r *SQLiteRepo) MarkResetUsed(ctx context.Context, id int64) (bool, error) {
+	res, err := r.conn.Exec(ctx, `UPDATE password_resets SET used_at = ? WHERE id = ? AND used_at IS NULL`, now(), id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+// DeleteResetsByEngineer removes every outstanding reset token for an engineer.
+func (r *SQLiteRepo) DeleteResetsByEngineer(ctx context.Context, engineerID int64) error {
+	_, err := r.conn.Exec(ctx, `DELETE FROM password_resets WHERE engineer_id = ?`, engineerID)
+	return err
+}
@@ -1,6 +1,7 @@
 package sqlite
 
 import (
+	"sync"
 	"time"
 
 	"log/slog"
@@ -13,6 +14,9 @@ import (
 type SQLiteRepo struct {
 	conn   *db.DB
 	logger *slog.Logger
+
+	hookMu       sync.RWMutex
+	enqueueHooks []func(runAt time.Time)
 }
 
 // Ensure SQLiteRepo implements the public interfaces.
@@ -24,6 +28,12 @@ var _ repository.JobRepo = (*SQLiteRepo)(nil)
 var _ repository.ContextRepo = (*SQLiteRepo)(nil)
 var _ repository.SchemaRepo = (*SQLiteRepo)(nil)
 var _ repository.TemplateRepo = (*SQLiteRepo)(nil)
+var _ repository.PasswordResetRepo = (*SQLiteRepo)(nil)
+var _ repository.TeamRepo = (*SQLiteRepo)(nil)
+var _ repository.ScheduleRepo = (*SQLiteRepo)(nil)
+var _ repository.LLMCacheRepo = (*SQLiteRepo)(nil)
+var _ repository.LLMUsageRepo = (*SQLiteRepo)(nil)
+var _ repository.EnqueueNotifier = (*SQLiteRepo)(nil)
 
 func New(conn *db.DB, logger *slog.Logger) *SQLiteRepo {
 	if logger == nil {
@@ -2,12 +2,16 @@ package sqlite_test
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
+	dbfs "github.com/garnizeh/rag/db"
 	dbpkg "github.com/garnizeh/rag/internal/db"
 	"github.com/garnizeh/rag/internal/models"
 	sqlite "github.com/garnizeh/rag/internal/repository/sqlite"
+	"github.com/garnizeh/rag/pkg/repository"
 )
 
 func setupRepo(t *testing.T) (*sqlite.SQLiteRepo, func()) {
@@ -22,11 +26,22 @@ func setupRepo(t *testing.T) (*sqlite.SQLiteRepo, func()) {
 	stmts := []string{
 		`CREATE TABLE IF NOT EXISTS engineers (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, email TEXT, updated INTEGER, password_hash TEXT);`,
 		`CREATE TABLE IF NOT EXISTS engineer_profiles (id INTEGER PRIMARY KEY AUTOINCREMENT, engineer_id INTEGER, bio TEXT, updated INTEGER);`,
-		`CREATE TABLE IF NOT EXISTS raw_activities (id INTEGER PRIMARY KEY AUTOINCREMENT, engineer_id INTEGER, activity TEXT, created INTEGER);`,
+		`CREATE TABLE IF NOT EXISTS raw_activities (id INTEGER PRIMARY KEY AUTOINCREMENT, engineer_id INTEGER, activity TEXT, visibility TEXT NOT NULL DEFAULT 'shared', redactions TEXT, idempotency_key TEXT, created INTEGER);`,
 		`CREATE TABLE IF NOT EXISTS ai_questions (id INTEGER PRIMARY KEY AUTOINCREMENT, engineer_id INTEGER, question TEXT, answered INTEGER, created INTEGER);`,
-		`CREATE TABLE IF NOT EXISTS processing_jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, status TEXT, created INTEGER);`,
 		`CREATE TABLE IF NOT EXISTS ai_schemas (id INTEGER PRIMARY KEY AUTOINCREMENT, version TEXT UNIQUE, description TEXT, schema_json TEXT, created INTEGER, updated INTEGER);`,
 		`CREATE TABLE IF NOT EXISTS ai_templates (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, version TEXT, template_text TEXT, schema_version TEXT, metadata TEXT, created INTEGER, updated INTEGER, UNIQUE(name, version));`,
+		`CREATE TABLE IF NOT EXISTS engineer_contexts (id INTEGER PRIMARY KEY AUTOINCREMENT, engineer_id INTEGER NOT NULL UNIQUE, context_json TEXT NOT NULL, version INTEGER NOT NULL DEFAULT 1, updated INTEGER NOT NULL);`,
+		`CREATE TABLE IF NOT EXISTS engineer_context_history (id INTEGER PRIMARY KEY AUTOINCREMENT, engineer_id INTEGER NOT NULL, context_json TEXT NOT NULL, changes_json TEXT, conflicts_json TEXT, applied_by TEXT, created INTEGER NOT NULL, version INTEGER NOT NULL);`,
+		`CREATE TABLE IF NOT EXISTS password_resets (id INTEGER PRIMARY KEY AUTOINCREMENT, engineer_id INTEGER, token_hash TEXT UNIQUE, expires_at INTEGER, used_at INTEGER, created INTEGER);`,
+		`CREATE TABLE IF NOT EXISTS jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, type TEXT NOT NULL, payload TEXT, status TEXT NOT NULL DEFAULT 'queued', attempts INTEGER NOT NULL DEFAULT 0, max_attempts INTEGER NOT NULL DEFAULT 5, priority INTEGER NOT NULL DEFAULT 100, scheduled_at INTEGER NOT NULL, next_try_at INTEGER, last_error TEXT, locked_by TEXT, lease_until INTEGER, dedupe_key TEXT, result TEXT, engineer_id INTEGER, created INTEGER NOT NULL, updated INTEGER NOT NULL);`,
+		`CREATE TABLE IF NOT EXISTS dead_letter_jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, job_id INTEGER NOT NULL, type TEXT NOT NULL, payload TEXT, attempts INTEGER NOT NULL, last_error TEXT, failed_at INTEGER NOT NULL, engineer_id INTEGER, priority INTEGER NOT NULL DEFAULT 100, max_attempts INTEGER NOT NULL DEFAULT 5, dedupe_key TEXT);`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_activities_idempotency ON raw_activities(engineer_id, idempotency_key) WHERE idempotency_key IS NOT NULL;`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_dedupe_pending ON jobs(dedupe_key) WHERE dedupe_key IS NOT NULL AND status IN ('queued', 'retry', 'running');`,
+		`CREATE TABLE IF NOT EXISTS job_schedules (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL UNIQUE, spec TEXT NOT NULL, type TEXT NOT NULL, payload TEXT, priority INTEGER NOT NULL DEFAULT 100, max_attempts INTEGER NOT NULL DEFAULT 5, enabled INTEGER NOT NULL DEFAULT 1, next_run_at INTEGER NOT NULL, last_run_at INTEGER, last_job_id INTEGER, created INTEGER NOT NULL, updated INTEGER NOT NULL);`,
+		`CREATE TABLE IF NOT EXISTS teams (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT UNIQUE, description TEXT, created_by INTEGER, created INTEGER, updated INTEGER);`,
+		`CREATE TABLE IF NOT EXISTS team_members (team_id INTEGER, engineer_id INTEGER, role TEXT, created INTEGER, PRIMARY KEY (team_id, engineer_id));`,
+		`CREATE TABLE IF NOT EXISTS llm_calls (id INTEGER PRIMARY KEY AUTOINCREMENT, engineer_id INTEGER, template_name TEXT NOT NULL, template_version TEXT NOT NULL, model TEXT NOT NULL, outcome TEXT NOT NULL, prompt_tokens INTEGER NOT NULL DEFAULT 0, eval_tokens INTEGER NOT NULL DEFAULT 0, duration_ms INTEGER NOT NULL DEFAULT 0, error TEXT, created INTEGER NOT NULL);`,
+		`CREATE TABLE IF NOT EXISTS llm_cache (key TEXT PRIMARY KEY, model TEXT NOT NULL, response TEXT NOT NULL, size INTEGER NOT NULL, hits INTEGER NOT NULL DEFAULT 0, expires_at INTEGER NOT NULL, last_used INTEGER NOT NULL, created INTEGER NOT NULL);`,
 	}
 
 	for _, s := range stmts {
@@ -117,6 +132,105 @@ func TestEngineerCRUD(t *testing.T) {
 	}
 }
 
+// TestDeleteEngineerRemovesDependents runs against the migrated schema and
+// checks that no table still references a deleted engineer.
+func TestDeleteEngineerRemovesDependents(t *testing.T) {
+	ctx := context.Background()
+	d, err := dbpkg.New(ctx, "file:delete_engineer?mode=memory&cache=shared", nil)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer d.Close()
+	if err := dbpkg.Migrate(ctx, d, dbfs.Migrations, dbfs.SeedFiles); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	repo := sqlite.New(d, nil)
+
+	gone, err := repo.CreateEngineer(ctx, &models.Engineer{Name: "Gone", Email: "gone@example.com"})
+	if err != nil {
+		t.Fatalf("CreateEngineer error: %v", err)
+	}
+	kept, err := repo.CreateEngineer(ctx, &models.Engineer{Name: "Kept", Email: "kept@example.com"})
+	if err != nil {
+		t.Fatalf("CreateEngineer error: %v", err)
+	}
+
+	for _, id := range []int64{gone, kept} {
+		stmts := []string{
+			`INSERT INTO engineer_profiles (engineer_id, bio, updated) VALUES (?, 'private bio', 1)`,
+			`INSERT INTO raw_activities (engineer_id, activity, created) VALUES (?, 'secret work', 1)`,
+			`INSERT INTO ai_questions (engineer_id, question, created) VALUES (?, 'why?', 1)`,
+			`INSERT INTO engineer_contexts (engineer_id, context_json, updated) VALUES (?, '{}', 1)`,
+			`INSERT INTO engineer_context_history (engineer_id, context_json, created, version) VALUES (?, '{}', 1, 1)`,
+			`INSERT INTO password_resets (engineer_id, token_hash, expires_at, created) VALUES (?, 'hash-' || ?, 1, 1)`,
+			`INSERT INTO teams (name, created_by, created, updated) VALUES ('team-' || ?, ?, 1, 1)`,
+			`INSERT INTO team_members (team_id, engineer_id, created) VALUES (last_insert_rowid(), ?, 1)`,
+			`INSERT INTO jobs (type, payload, scheduled_at, engineer_id, created, updated) VALUES ('ai.analyze_activity', '{}', 1, ?, 1, 1)`,
+			`INSERT INTO dead_letter_jobs (job_id, type, payload, attempts, failed_at, engineer_id) VALUES (1, 'ai.analyze_activity', '{}', 3, 1, ?)`,
+			`INSERT INTO llm_calls (engineer_id, template_name, template_version, model, outcome, created) VALUES (?, 'activity', 'v1', 'm', 'ok', 1)`,
+		}
+		for _, q := range stmts {
+			args := make([]any, strings.Count(q, "?"))
+			for i := range args {
+				args[i] = id
+			}
+			if _, err := d.Exec(ctx, q, args...); err != nil {
+				t.Fatalf("seed %q: %v", q, err)
+			}
+		}
+	}
+	// the kept engineer is also a member of the deleted engineer's team
+	if _, err := d.Exec(ctx, `INSERT INTO team_members (team_id, engineer_id, created) SELECT id, ?, 1 FROM teams WHERE created_by = ?`, kept, gone); err != nil {
+		t.Fatalf("seed membership: %v", err)
+	}
+
+	if err := repo.DeleteEngineer(ctx, gone); err != nil {
+		t.Fatalf("DeleteEngineer error: %v", err)
+	}
+
+	// every column pointing at engineers, found from the schema so new tables are covered
+	rows, err := d.QueryRows(ctx, `SELECT m.name, f."from" FROM sqlite_master m, pragma_foreign_key_list(m.name) f WHERE m.type = 'table' AND f."table" = 'engineers'`)
+	if err != nil {
+		t.Fatalf("list foreign keys: %v", err)
+	}
+	type ref struct{ table, column string }
+	var refs []ref
+	for rows.Next() {
+		var r ref
+		if err := rows.Scan(&r.table, &r.column); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		refs = append(refs, r)
+	}
+	rows.Close()
+	// jobs reference engineers without a foreign key
+	refs = append(refs, ref{"jobs", "engineer_id"}, ref{"dead_letter_jobs", "engineer_id"})
+	if len(refs) < 10 {
+		t.Fatalf("expected the engineer references of the schema, got %v", refs)
+	}
+
+	for _, r := range refs {
+		var n int
+		if err := d.QueryRow(ctx, `SELECT COUNT(*) FROM `+r.table+` WHERE `+r.column+` = ?`, gone).Scan(&n); err != nil {
+			t.Fatalf("count %s: %v", r.table, err)
+		}
+		if n != 0 {
+			t.Errorf("%s.%s still references the deleted engineer in %d rows", r.table, r.column, n)
+		}
+		if err := d.QueryRow(ctx, `SELECT COUNT(*) FROM `+r.table+` WHERE `+r.column+` = ?`, kept).Scan(&n); err != nil {
+			t.Fatalf("count %s: %v", r.table, err)
+		}
+		if n == 0 {
+			t.Errorf("%s.%s lost the rows of the other engineer", r.table, r.column)
+		}
+	}
+
+	var anon int
+	if err := d.QueryRow(ctx, `SELECT COUNT(*) FROM llm_calls WHERE engineer_id IS NULL`).Scan(&anon); err != nil || anon != 1 {
+		t.Fatalf("expected the deleted engineer's llm call kept without its engineer, got %d err=%v", anon, err)
+	}
+}
+
 func TestProfileCRUD(t *testing.T) {
 	repo, cleanup := setupRepo(t)
 	defer cleanup()
@@ -215,6 +329,14 @@ func TestActivityAndQuestionList(t *testing.T) {
 		t.Fatalf("expected 3 activities got %d", len(acts))
 	}
 
+	got, err := repo.GetActivity(ctx, acts[0].ID)
+	if err != nil || got == nil || got.ID != acts[0].ID || got.EngineerID != eid || got.Activity != "act" {
+		t.Fatalf("GetActivity: got %#v err=%v", got, err)
+	}
+	if got, err := repo.GetActivity(ctx, 9999); err != nil || got != nil {
+		t.Fatalf("expected nil for a missing activity, got %#v err=%v", got, err)
+	}
+
 	// Offset pagination: first page (limit=2, offset=0) and second page (limit=2, offset=2)
 	page1, err := repo.ListByEngineer(ctx, eid, 2, 0)
 	if err != nil {
@@ -267,29 +389,6 @@ func TestActivityAndQuestionList(t *testing.T) {
 	}
 }
 
-func TestJobCreateAndUpdate(t *testing.T) {
-	repo, cleanup := setupRepo(t)
-	defer cleanup()
-	ctx := context.Background()
-
-	j := &models.Job{Status: "pending"}
-	jid, err := repo.CreateJob(ctx, j)
-	if err != nil {
-		t.Fatalf("CreateJob error: %v", err)
-	}
-	if jid == 0 {
-		t.Fatalf("expected job id > 0")
-	}
-
-	if err := repo.UpdateStatus(ctx, jid, "done"); err != nil {
-		t.Fatalf("UpdateStatus error: %v", err)
-	}
-
-	if _, err := repo.CreateJob(ctx, nil); err == nil {
-		t.Fatalf("expected error when creating nil job")
-	}
-}
-
 func TestSchemaCRUD(t *testing.T) {
 	repo, cleanup := setupRepo(t)
 	defer cleanup()
@@ -379,3 +478,587 @@ func TestTemplateCRUD(t *testing.T) {
 		t.Fatalf("expected nil after delete got: %#v", after)
 	}
 }
+
+func TestPasswordResetLifecycle(t *testing.T) {
+	repo, cleanup := setupRepo(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	if _, err := repo.CreateReset(ctx, nil); err == nil {
+		t.Fatalf("expected error when creating nil reset")
+	}
+
+	pr := &models.PasswordReset{EngineerID: 42, TokenHash: "hash-abc", ExpiresAt: time.Now().Add(time.Hour).UnixMilli()}
+	id, err := repo.CreateReset(ctx, pr)
+	if err != nil {
+		t.Fatalf("CreateReset error: %v", err)
+	}
+
+	got, err := repo.GetResetByTokenHash(ctx, "hash-abc")
+	if err != nil {
+		t.Fatalf("GetResetByTokenHash error: %v", err)
+	}
+	if got == nil || got.ID != id || got.UsedAt != nil {
+		t.Fatalf("unexpected reset: %#v", got)
+	}
+
+	if ok, err := repo.MarkResetUsed(ctx, id); err != nil || !ok {
+		t.Fatalf("MarkResetUsed: ok=%v err=%v", ok, err)
+	}
+	if ok, err := repo.MarkResetUsed(ctx, id); err != nil || ok {
+		t.Fatalf("expected a used token not to be consumed again: ok=%v err=%v", ok, err)
+	}
+	got, err = repo.GetResetByTokenHash(ctx, "hash-abc")
+	if err != nil || got == nil || got.UsedAt == nil {
+		t.Fatalf("expected used reset, got %#v err=%v", got, err)
+	}
+
+	if err := repo.DeleteResetsByEngineer(ctx, 42); err != nil {
+		t.Fatalf("DeleteResetsByEngineer error: %v", err)
+	}
+	got, err = repo.GetResetByTokenHash(ctx, "hash-abc")
+	if err != nil || got != nil {
+		t.Fatalf("expected nil after delete, got %#v err=%v", got, err)
+	}
+}
+
+func TestTeamMembership(t *testing.T) {
+	repo, cleanup := setupRepo(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	ownerID, err := repo.CreateEngineer(ctx, &models.Engineer{Name: "Owner", Email: "owner@example.com"})
+	if err != nil {
+		t.Fatalf("CreateEngineer error: %v", err)
+	}
+
+	teamID, err := repo.CreateTeam(ctx, &models.Team{Name: "platform", CreatedBy: ownerID})
+	if err != nil {
+		t.Fatalf("CreateTeam error: %v", err)
+	}
+	if _, err := repo.CreateTeam(ctx, &models.Team{Name: "platform", CreatedBy: ownerID}); err == nil {
+		t.Fatalf("expected duplicate team name to fail")
+	}
+
+	m, err := repo.GetMember(ctx, teamID, ownerID)
+	if err != nil || m == nil || m.Role != models.TeamRoleOwner || m.Name != "Owner" {
+		t.Fatalf("expected owner membership, got %#v err=%v", m, err)
+	}
+
+	if err := repo.AddMember(ctx, teamID, 99, models.TeamRoleMember); err != nil {
+		t.Fatalf("AddMember error: %v", err)
+	}
+	if err := repo.AddMember(ctx, teamID, 99, models.TeamRoleLead); err != nil {
+		t.Fatalf("AddMember upsert error: %v", err)
+	}
+	members, err := repo.ListMembers(ctx, teamID)
+	if err != nil || len(members) != 2 {
+		t.Fatalf("expected 2 members, got %d err=%v", len(members), err)
+	}
+	if m, _ := repo.GetMember(ctx, teamID, 99); m == nil || m.Role != models.TeamRoleLead {
+		t.Fatalf("expected role upsert to lead, got %#v", m)
+	}
+
+	teams, err := repo.ListTeamsByEngineer(ctx, 99)
+	if err != nil || len(teams) != 1 || teams[0].ID != teamID {
+		t.Fatalf("unexpected teams for member: %#v err=%v", teams, err)
+	}
+
+	if err := repo.RemoveMember(ctx, teamID, 99); err != nil {
+		t.Fatalf("RemoveMember error: %v", err)
+	}
+	if m, _ := repo.GetMember(ctx, teamID, 99); m != nil {
+		t.Fatalf("expected membership removed, got %#v", m)
+	}
+
+	if err := repo.DeleteTeam(ctx, teamID); err != nil {
+		t.Fatalf("DeleteTeam error: %v", err)
+	}
+	if got, err := repo.GetTeam(ctx, teamID); err != nil || got != nil {
+		t.Fatalf("expected nil after delete, got %#v err=%v", got, err)
+	}
+	if m, _ := repo.GetMember(ctx, teamID, ownerID); m != nil {
+		t.Fatalf("expected memberships deleted with team, got %#v", m)
+	}
+}
+
+func TestActivityVisibilityAndRedactions(t *testing.T) {
+	repo, cleanup := setupRepo(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	spans := []models.Redaction{{Kind: "email", Start: 5, End: 16}}
+	if _, err := repo.CreateActivity(ctx, &models.Activity{EngineerID: 7, Activity: "mail a@b.example now", Redactions: spans, Created: 1}); err != nil {
+		t.Fatalf("CreateActivity error: %v", err)
+	}
+	if _, err := repo.CreateActivity(ctx, &models.Activity{EngineerID: 7, Activity: "private note", Visibility: models.VisibilityPrivate, Created: 2}); err != nil {
+		t.Fatalf("CreateActivity error: %v", err)
+	}
+
+	all, err := repo.ListByEngineer(ctx, 7, 10, 0)
+	if err != nil || len(all) != 2 {
+		t.Fatalf("expected 2 activities, got %d err=%v", len(all), err)
+	}
+	if all[0].Visibility != models.VisibilityPrivate || all[0].Redactions != nil {
+		t.Fatalf("unexpected private activity: %#v", all[0])
+	}
+	if all[1].Visibility != models.VisibilityShared || len(all[1].Redactions) != 1 || all[1].Redactions[0] != spans[0] {
+		t.Fatalf("unexpected shared activity: %#v", all[1])
+	}
+
+	shared, err := repo.ListSharedByEngineer(ctx, 7, 10, 0)
+	if err != nil || len(shared) != 1 || shared[0].Visibility != models.VisibilityShared {
+		t.Fatalf("expected only the shared activity, got %#v err=%v", shared, err)
+	}
+	if n, err := repo.CountSharedActivitiesByEngineer(ctx, 7); err != nil || n != 1 {
+		t.Fatalf("expected shared count 1, got %d err=%v", n, err)
+	}
+	if n, err := repo.CountActivitiesByEngineer(ctx, 7); err != nil || n != 2 {
+		t.Fatalf("expected total count 2, got %d err=%v", n, err)
+	}
+}
+
+func TestJobAdminOperations(t *testing.T) {
+	repo, cleanup := setupRepo(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	queuedID, err := repo.Enqueue(ctx, &models.BackgroundJob{Type: "email", Payload: []byte(`{"to":"a"}`), ScheduledAt: time.Now()})
+	if err != nil {
+		t.Fatalf("Enqueue error: %v", err)
+	}
+	doneID, err := repo.Enqueue(ctx, &models.BackgroundJob{Type: "report", Payload: []byte(`{}`), ScheduledAt: time.Now()})
+	if err != nil {
+		t.Fatalf("Enqueue error: %v", err)
+	}
+	if err := repo.UpdateJob(ctx, &models.BackgroundJob{ID: doneID, Status: "done"}); err != nil {
+		t.Fatalf("UpdateJob error: %v", err)
+	}
+
+	queued, err := repo.ListJobs(ctx, models.JobFilter{Status: "queued"})
+	if err != nil || len(queued) != 1 || queued[0].ID != queuedID || string(queued[0].Payload) != `{"to":"a"}` {
+		t.Fatalf("unexpected queued jobs: %#v err=%v", queued, err)
+	}
+	if byType, _ := repo.ListJobs(ctx, models.JobFilter{Type: "report"}); len(byType) != 1 || byType[0].ID != doneID {
+		t.Fatalf("unexpected jobs by type: %#v", byType)
+	}
+
+	// only jobs that have not run yet can be canceled
+	if ok, err := repo.CancelJob(ctx, doneID); err != nil || ok {
+		t.Fatalf("expected done job not to be cancelable, ok=%v err=%v", ok, err)
+	}
+	if ok, err := repo.CancelJob(ctx, queuedID); err != nil || !ok {
+		t.Fatalf("expected queued job to be canceled, ok=%v err=%v", ok, err)
+	}
+	if j, _ := repo.GetJob(ctx, queuedID); j == nil || j.Status != "canceled" {
+		t.Fatalf("expected canceled status, got %#v", j)
+	}
+
+	// purge removes finished jobs last updated before the cutoff
+	if n, err := repo.PurgeJobs(ctx, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Fatalf("expected nothing purged for old cutoff, got %d err=%v", n, err)
+	}
+	if n, err := repo.PurgeJobs(ctx, time.Now().Add(time.Second)); err != nil || n != 2 {
+		t.Fatalf("expected 2 purged, got %d err=%v", n, err)
+	}
+
+	// dead letter round trip
+	failedID, err := repo.Enqueue(ctx, &models.BackgroundJob{Type: "email", Payload: []byte(`{"to":"b"}`), ScheduledAt: time.Now()})
+	if err != nil {
+		t.Fatalf("Enqueue error: %v", err)
+	}
+	failed := &models.BackgroundJob{ID: failedID, Type: "email", Payload: []byte(`{"to":"b"}`), Attempts: 3, MaxAttempts: 3, Priority: 10, DedupeKey: "email:b", LastError: "smtp down"}
+	if err := repo.MoveToDeadLetter(ctx, failed); err != nil {
+		t.Fatalf("MoveToDeadLetter error: %v", err)
+	}
+	dead, err := repo.ListDeadLetters(ctx, models.JobFilter{})
+	if err != nil || len(dead) != 1 || dead[0].JobID != failedID || dead[0].LastError != "smtp down" || dead[0].Priority != 10 || dead[0].MaxAttempts != 3 || dead[0].DedupeKey != "email:b" {
+		t.Fatalf("unexpected dead letters: %#v err=%v", dead, err)
+	}
+
+	newID, err := repo.RequeueDeadLetter(ctx, dead[0].ID)
+	if err != nil || newID == 0 {
+		t.Fatalf("RequeueDeadLetter: id=%d err=%v", newID, err)
+	}
+	j, err := repo.GetJob(ctx, newID)
+	if err != nil || j == nil || j.Status != "queued" || j.Attempts != 0 || string(j.Payload) != `{"to":"b"}` || j.Priority != 10 || j.MaxAttempts != 3 || j.DedupeKey != "email:b" {
+		t.Fatalf("unexpected requeued job: %#v err=%v", j, err)
+	}
+	if d, _ := repo.GetDeadLetter(ctx, dead[0].ID); d != nil {
+		t.Fatalf("expected dead letter removed, got %#v", d)
+	}
+	if id, err := repo.RequeueDeadLetter(ctx, dead[0].ID); err != nil || id != 0 {
+		t.Fatalf("expected 0 for missing dead letter, got %d err=%v", id, err)
+	}
+
+	// requeueing while the same work is pending settles on the pending job
+	failed.ID = newID
+	if err := repo.MoveToDeadLetter(ctx, failed); err != nil {
+		t.Fatalf("MoveToDeadLetter error: %v", err)
+	}
+	pending, err := repo.Enqueue(ctx, &models.BackgroundJob{Type: "email", Payload: []byte(`{"to":"b"}`), DedupeKey: "email:b", ScheduledAt: time.Now()})
+	if err != nil {
+		t.Fatalf("Enqueue error: %v", err)
+	}
+	dead, _ = repo.ListDeadLetters(ctx, models.JobFilter{})
+	if len(dead) != 1 {
+		t.Fatalf("expected one dead letter, got %#v", dead)
+	}
+	if id, err := repo.RequeueDeadLetter(ctx, dead[0].ID); err != nil || id != pending {
+		t.Fatalf("expected the pending job %d, got %d err=%v", pending, id, err)
+	}
+	if emails, _ := repo.ListJobs(ctx, models.JobFilter{Type: "email", Status: "queued"}); len(emails) != 1 {
+		t.Fatalf("expected a single pending email job, got %#v", emails)
+	}
+	if d, _ := repo.GetDeadLetter(ctx, dead[0].ID); d != nil {
+		t.Fatalf("expected dead letter removed, got %#v", d)
+	}
+}
+
+func TestFetchNextClaimsAndRecoversExpiredLeases(t *testing.T) {
+	repo, cleanup := setupRepo(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	id, err := repo.Enqueue(ctx, &models.BackgroundJob{Type: "t", Payload: []byte(`{}`), ScheduledAt: time.Now()})
+	if err != nil {
+		t.Fatalf("Enqueue error: %v", err)
+	}
+
+	// a claim with an already expired lease simulates a worker that crashed mid-run
+	j, err := repo.FetchNext(ctx, "w1", -time.Minute, models.JobTypeFilter{})
+	if err != nil || j == nil || j.ID != id {
+		t.Fatalf("expected to claim job %d, got %#v err=%v", id, j, err)
+	}
+	if j.Status != "running" || j.LockedBy != "w1" || j.LeaseUntil == nil {
+		t.Fatalf("expected running job locked by w1, got %#v", j)
+	}
+
+	reclaimed, err := repo.FetchNext(ctx, "w2", time.Minute, models.JobTypeFilter{})
+	if err != nil || reclaimed == nil || reclaimed.ID != id {
+		t.Fatalf("expected w2 to reclaim job %d, got %#v err=%v", id, reclaimed, err)
+	}
+	if reclaimed.LockedBy != "w2" || reclaimed.Attempts != 1 || !strings.Contains(reclaimed.LastError, "w1") {
+		t.Fatalf("unexpected reclaimed job: %#v", reclaimed)
+	}
+
+	// a live lease is not handed out again
+	if again, err := repo.FetchNext(ctx, "w3", time.Minute, models.JobTypeFilter{}); err != nil || again != nil {
+		t.Fatalf("expected no job while leased, got %#v err=%v", again, err)
+	}
+
+	// the worker that lost the lease cannot overwrite the new owner's result
+	j.Status = "done"
+	if err := repo.UpdateJob(ctx, j); !errors.Is(err, repository.ErrLeaseLost) {
+		t.Fatalf("expected ErrLeaseLost, got %v", err)
+	}
+	reclaimed.Status = "done"
+	if err := repo.UpdateJob(ctx, reclaimed); err != nil {
+		t.Fatalf("UpdateJob by lease owner: %v", err)
+	}
+	got, _ := repo.GetJob(ctx, id)
+	if got == nil || got.Status != "done" || got.LockedBy != "" || got.LeaseUntil != nil {
+		t.Fatalf("expected released done job, got %#v", got)
+	}
+}
+
+func TestNextJobTime(t *testing.T) {
+	repo, cleanup := setupRepo(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	if next, err := repo.NextJobTime(ctx, models.JobTypeFilter{}); err != nil || next != nil {
+		t.Fatalf("expected nil for empty queue, got %v err=%v", next, err)
+	}
+
+	later := time.Now().Add(time.Hour).Truncate(time.Second)
+	sooner := time.Now().Add(10 * time.Minute).Truncate(time.Second)
+	if _, err := repo.Enqueue(ctx, &models.BackgroundJob{Type: "t", ScheduledAt: later}); err != nil {
+		t.Fatalf("Enqueue error: %v", err)
+	}
+	id, err := repo.Enqueue(ctx, &models.BackgroundJob{Type: "t", ScheduledAt: time.Now().Add(-time.Minute)})
+	if err != nil {
+		t.Fatalf("Enqueue error: %v", err)
+	}
+	// a retry backoff pushes the due job past its schedule
+	if err := repo.UpdateJob(ctx, &models.BackgroundJob{ID: id, Status: "retry", Attempts: 1, NextTryAt: &sooner}); err != nil {
+		t.Fatalf("UpdateJob error: %v", err)
+	}
+
+	next, err := repo.NextJobTime(ctx, models.JobTypeFilter{})
+	if err != nil || next == nil || !next.Equal(sooner) {
+		t.Fatalf("expected %v, got %v err=%v", sooner, next, err)
+	}
+}
+
+func TestOnEnqueueHook(t *testing.T) {
+	repo, cleanup := setupRepo(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	var got []time.Time
+	repo.OnEnqueue(func(runAt time.Time) { got = append(got, runAt) })
+
+	at := time.Now().Add(time.Minute)
+	if _, err := repo.Enqueue(ctx, &models.BackgroundJob{Type: "t", ScheduledAt: at}); err != nil {
+		t.Fatalf("Enqueue error: %v", err)
+	}
+	if len(got) != 1 || !got[0].Equal(at) {
+		t.Fatalf("expected one notification for %v, got %v", at, got)
+	}
+}
+
+func TestJobScheduleFireOnce(t *testing.T) {
+	repo, cleanup := setupRepo(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	runAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	id, err := repo.CreateSchedule(ctx, &models.JobSchedule{Name: "nightly", Spec: "@daily", Type: "report", Payload: []byte(`{"kind":"daily"}`), Priority: 10, Enabled: true, NextRunAt: runAt})
+	if err != nil {
+		t.Fatalf("CreateSchedule error: %v", err)
+	}
+	if _, err := repo.CreateSchedule(ctx, &models.JobSchedule{Name: "nightly", Spec: "@daily", Type: "report", NextRunAt: runAt}); err == nil {
+		t.Fatalf("expected duplicate name to fail")
+	}
+
+	due, err := repo.DueSchedules(ctx, time.Now())
+	if err != nil || len(due) != 1 || due[0].ID != id || due[0].MaxAttempts != 5 {
+		t.Fatalf("unexpected due schedules: %#v err=%v", due, err)
+	}
+
+	next := runAt.Add(24 * time.Hour)
+	jobID, err := repo.FireSchedule(ctx, &due[0], next)
+	if err != nil || jobID == 0 {
+		t.Fatalf("FireSchedule: id=%d err=%v", jobID, err)
+	}
+	// a second scheduler holding the same stale copy loses
+	if again, err := repo.FireSchedule(ctx, &due[0], next); err != nil || again != 0 {
+		t.Fatalf("expected stale fire to be a no-op, got id=%d err=%v", again, err)
+	}
+
+	j, err := repo.GetJob(ctx, jobID)
+	if err != nil || j == nil || j.Type != "report" || j.Priority != 10 || string(j.Payload) != `{"kind":"daily"}` || !j.ScheduledAt.Equal(runAt) {
+		t.Fatalf("unexpected scheduled job: %#v err=%v", j, err)
+	}
+	if all, _ := repo.ListJobs(ctx, models.JobFilter{}); len(all) != 1 {
+		t.Fatalf("expected exactly one job, got %d", len(all))
+	}
+
+	s, err := repo.GetSchedule(ctx, id)
+	if err != nil || s == nil || !s.NextRunAt.Equal(next) || s.LastRunAt == nil || !s.LastRunAt.Equal(runAt) || s.LastJobID != jobID {
+		t.Fatalf("unexpected schedule after fire: %#v err=%v", s, err)
+	}
+	if due, _ := repo.DueSchedules(ctx, time.Now()); len(due) != 0 {
+		t.Fatalf("expected nothing due, got %#v", due)
+	}
+
+	// disabled schedules are never due
+	s.Enabled = false
+	s.NextRunAt = runAt
+	if err := repo.UpdateSchedule(ctx, s); err != nil {
+		t.Fatalf("UpdateSchedule error: %v", err)
+	}
+	if due, _ := repo.DueSchedules(ctx, time.Now()); len(due) != 0 {
+		t.Fatalf("expected disabled schedule not due, got %#v", due)
+	}
+
+	if ok, err := repo.DeleteSchedule(ctx, id); err != nil || !ok {
+		t.Fatalf("DeleteSchedule: ok=%v err=%v", ok, err)
+	}
+	if ok, _ := repo.DeleteSchedule(ctx, id); ok {
+		t.Fatalf("expected second delete to report missing")
+	}
+}
+
+func TestFetchNextByJobType(t *testing.T) {
+	repo, cleanup := setupRepo(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	for _, typ := range []string{"llm", "email", "report"} {
+		if _, err := repo.Enqueue(ctx, &models.BackgroundJob{Type: typ, Payload: []byte(`{}`), ScheduledAt: time.Now()}); err != nil {
+			t.Fatalf("Enqueue error: %v", err)
+		}
+	}
+
+	only := models.JobTypeFilter{Only: []string{"report"}}
+	if next, err := repo.NextJobTime(ctx, only); err != nil || next == nil {
+		t.Fatalf("expected a pending report job, got %v err=%v", next, err)
+	}
+	j, err := repo.FetchNext(ctx, "w1", time.Minute, only)
+	if err != nil || j == nil || j.Type != "report" {
+		t.Fatalf("expected report job, got %#v err=%v", j, err)
+	}
+	if j, err := repo.FetchNext(ctx, "w1", time.Minute, only); err != nil || j != nil {
+		t.Fatalf("expected no more report jobs, got %#v err=%v", j, err)
+	}
+
+	except := models.JobTypeFilter{Except: []string{"llm", "report"}}
+	j, err = repo.FetchNext(ctx, "w1", time.Minute, except)
+	if err != nil || j == nil || j.Type != "email" {
+		t.Fatalf("expected email job, got %#v err=%v", j, err)
+	}
+	if j, err := repo.FetchNext(ctx, "w1", time.Minute, except); err != nil || j != nil {
+		t.Fatalf("expected llm job to be skipped, got %#v err=%v", j, err)
+	}
+	// only the skipped llm job is left waiting; the claimed jobs hold live leases
+	if next, err := repo.NextJobTime(ctx, except); err != nil || next == nil || next.Before(time.Now()) {
+		t.Fatalf("expected only lease expiries for %+v, got %v err=%v", except, next, err)
+	}
+}
+
+func TestEnqueueDedupeKey(t *testing.T) {
+	repo, cleanup := setupRepo(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	notified := 0
+	repo.OnEnqueue(func(time.Time) { notified++ })
+
+	first, err := repo.Enqueue(ctx, &models.BackgroundJob{Type: "t", DedupeKey: "k1", ScheduledAt: time.Now()})
+	if err != nil {
+		t.Fatalf("Enqueue error: %v", err)
+	}
+	again, err := repo.Enqueue(ctx, &models.BackgroundJob{Type: "t", DedupeKey: "k1", ScheduledAt: time.Now()})
+	if err != nil || again != first {
+		t.Fatalf("expected duplicate to return job %d, got %d err=%v", first, again, err)
+	}
+	if notified != 1 {
+		t.Fatalf("expected a single enqueue notification, got %d", notified)
+	}
+	if j, _ := repo.GetJob(ctx, first); j == nil || j.DedupeKey != "k1" {
+		t.Fatalf("expected dedupe key to be stored, got %#v", j)
+	}
+
+	// the key is released once the job has finished
+	if err := repo.UpdateJob(ctx, &models.BackgroundJob{ID: first, Status: "done"}); err != nil {
+		t.Fatalf("UpdateJob error: %v", err)
+	}
+	next, err := repo.Enqueue(ctx, &models.BackgroundJob{Type: "t", DedupeKey: "k1", ScheduledAt: time.Now()})
+	if err != nil || next == first || next == 0 {
+		t.Fatalf("expected a new job after the first finished, got %d err=%v", next, err)
+	}
+}
+
+func TestActivityIdempotencyKey(t *testing.T) {
+	repo, cleanup := setupRepo(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	id, err := repo.CreateActivity(ctx, &models.Activity{EngineerID: 1, Activity: "deploy", IdempotencyKey: "req-1", Created: 1})
+	if err != nil {
+		t.Fatalf("CreateActivity error: %v", err)
+	}
+	if _, err := repo.CreateActivity(ctx, &models.Activity{EngineerID: 1, Activity: "deploy", IdempotencyKey: "req-1", Created: 2}); !errors.Is(err, repository.ErrDuplicateKey) {
+		t.Fatalf("expected ErrDuplicateKey, got %v", err)
+	}
+	// keys are scoped per engineer
+	if _, err := repo.CreateActivity(ctx, &models.Activity{EngineerID: 2, Activity: "deploy", IdempotencyKey: "req-1", Created: 3}); err != nil {
+		t.Fatalf("CreateActivity for another engineer: %v", err)
+	}
+
+	a, err := repo.GetActivityByIdempotencyKey(ctx, 1, "req-1")
+	if err != nil || a == nil || a.ID != id || a.Activity != "deploy" {
+		t.Fatalf("unexpected activity: %#v err=%v", a, err)
+	}
+	if a, err := repo.GetActivityByIdempotencyKey(ctx, 1, "req-2"); err != nil || a != nil {
+		t.Fatalf("expected nil for unknown key, got %#v err=%v", a, err)
+	}
+}
+
+func TestLLMCache(t *testing.T) {
+	repo, cleanup := setupRepo(t)
+	defer cleanup()
+	ctx := context.Background()
+	future := time.Now().Add(time.Hour).UnixMilli()
+
+	for _, e := range []models.LLMCacheEntry{
+		{Key: "a", Model: "m1", Response: `{"text":"a"}`, Size: 100, ExpiresAt: future},
+		{Key: "b", Model: "m1", Response: `{"text":"b"}`, Size: 100, ExpiresAt: future},
+		{Key: "c", Model: "m2", Response: `{"text":"c"}`, Size: 100, ExpiresAt: future},
+		{Key: "old", Model: "m2", Response: `{"text":"old"}`, ExpiresAt: time.Now().Add(-time.Minute).UnixMilli()},
+	} {
+		if err := repo.PutCachedResponse(ctx, &e); err != nil {
+			t.Fatalf("put %s: %v", e.Key, err)
+		}
+		time.Sleep(2 * time.Millisecond) // distinct last_used
+	}
+
+	if e, err := repo.GetCachedResponse(ctx, "old"); err != nil || e != nil {
+		t.Fatalf("expired entry: got %+v, %v", e, err)
+	}
+	// using "a" makes "b" the least recently used
+	e, err := repo.GetCachedResponse(ctx, "a")
+	if err != nil || e == nil || e.Response != `{"text":"a"}` || e.Hits != 1 {
+		t.Fatalf("get a: got %+v, %v", e, err)
+	}
+
+	n, err := repo.PruneLLMCache(ctx, 200)
+	if err != nil || n != 2 {
+		t.Fatalf("prune: deleted %d, %v; want expired and least recently used", n, err)
+	}
+	if e, _ := repo.GetCachedResponse(ctx, "b"); e != nil {
+		t.Fatalf("expected b evicted")
+	}
+	for _, k := range []string{"a", "c"} {
+		if e, _ := repo.GetCachedResponse(ctx, k); e == nil {
+			t.Fatalf("expected %s kept", k)
+		}
+	}
+
+	if n, err := repo.PurgeLLMCache(ctx, "m2"); err != nil || n != 1 {
+		t.Fatalf("purge m2: %d, %v", n, err)
+	}
+	if n, err := repo.PurgeLLMCache(ctx, ""); err != nil || n != 1 {
+		t.Fatalf("purge all: %d, %v", n, err)
+	}
+}
+
+func TestLLMUsage(t *testing.T) {
+	repo, cleanup := setupRepo(t)
+	defer cleanup()
+	ctx := context.Background()
+	alice, bob := int64(1), int64(2)
+	day := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC).UnixMilli()
+
+	for _, c := range []models.LLMCall{
+		{EngineerID: &alice, TemplateName: "activity", TemplateVersion: "v1", Model: "m1", Outcome: models.LLMCallOK, PromptTokens: 100, EvalTokens: 20, DurationMS: 900, Created: day + 1},
+		{EngineerID: &alice, TemplateName: "activity", TemplateVersion: "v2", Model: "m1", Outcome: models.LLMCallCached, Created: day + 2},
+		{EngineerID: &bob, TemplateName: "activity", TemplateVersion: "v2", Model: "m2", Outcome: models.LLMCallInvalid, PromptTokens: 50, EvalTokens: 10, DurationMS: 300, Created: day + 3},
+		// no model answered: failed, but not billed
+		{EngineerID: &bob, TemplateName: "activity", TemplateVersion: "v2", Model: "m2", Outcome: models.LLMCallError, Created: day + 4},
+		// the day before is outside the window
+		{EngineerID: &alice, TemplateName: "activity", TemplateVersion: "v1", Model: "m1", Outcome: models.LLMCallOK, PromptTokens: 999, Created: day - 1},
+	} {
+		if _, err := repo.CreateLLMCall(ctx, &c); err != nil {
+			t.Fatalf("create call: %v", err)
+		}
+	}
+
+	byEngineer, err := repo.LLMUsage(ctx, models.LLMUsageFilter{GroupBy: models.LLMUsageByEngineer, Since: day})
+	if err != nil || len(byEngineer) != 2 {
+		t.Fatalf("by engineer: %+v, %v", byEngineer, err)
+	}
+	a := byEngineer[0]
+	if a.EngineerID == nil || *a.EngineerID != alice || a.Calls != 2 || a.CachedCalls != 1 || a.BilledCalls != 1 || a.PromptTokens != 100 || a.EvalTokens != 20 || a.DurationMS != 900 {
+		t.Fatalf("unexpected usage for alice: %+v", a)
+	}
+	if b := byEngineer[1]; b.FailedCalls != 2 || b.Calls != 2 || b.BilledCalls != 1 {
+		t.Fatalf("unexpected usage for bob: %+v", b)
+	}
+
+	byTemplate, err := repo.LLMUsage(ctx, models.LLMUsageFilter{GroupBy: models.LLMUsageByTemplate, Since: day})
+	if err != nil || len(byTemplate) != 2 || byTemplate[0].TemplateVersion != "v1" || byTemplate[1].Calls != 3 {
+		t.Fatalf("by template: %+v, %v", byTemplate, err)
+	}
+
+	byModel, err := repo.LLMUsage(ctx, models.LLMUsageFilter{GroupBy: models.LLMUsageByModel, EngineerID: alice})
+	if err != nil || len(byModel) != 1 || byModel[0].Model != "m1" || byModel[0].Calls != 3 {
+		t.Fatalf("by model for alice: %+v, %v", byModel, err)
+	}
+
+	if _, err := repo.LLMUsage(ctx, models.LLMUsageFilter{GroupBy: "team"}); err == nil {
+		t.Fatal("expected an error for an unknown grouping")
+	}
+}
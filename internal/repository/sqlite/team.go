@@ -0,0 +1,144 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/garnizeh/rag/internal/models"
+)
+
+// CreateTeam inserts a team and its owner membership in one transaction.
+func (r *SQLiteRepo) CreateTeam(ctx context.Context, t *models.Team) (int64, error) {
+	if t == nil {
+		return 0, fmt.Errorf("team is nil")
+	}
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := now()
+	res, err := tx.ExecContext(ctx, `INSERT INTO teams (name, description, created_by, created, updated) VALUES (?, ?, ?, ?, ?)`, t.Name, t.Description, t.CreatedBy, now, now)
+	if err != nil {
+		return 0, fmt.Errorf("insert team: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO team_members (team_id, engineer_id, role, created) VALUES (?, ?, ?, ?)`, id, t.CreatedBy, models.TeamRoleOwner, now); err != nil {
+		return 0, fmt.Errorf("insert team owner: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+
+	return id, nil
+}
+
+func (r *SQLiteRepo) GetTeam(ctx context.Context, id int64) (*models.Team, error) {
+	row := r.conn.QueryRow(ctx, `SELECT id, name, description, created_by, created, updated FROM teams WHERE id = ?`, id)
+	var t models.Team
+	var desc sql.NullString
+	if err := row.Scan(&t.ID, &t.Name, &desc, &t.CreatedBy, &t.Created, &t.Updated); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+	t.Description = desc.String
+
+	return &t, nil
+}
+
+// ListTeamsByEngineer returns the teams the engineer is a member of.
+func (r *SQLiteRepo) ListTeamsByEngineer(ctx context.Context, engineerID int64) ([]models.Team, error) {
+	rows, err := r.conn.QueryRows(ctx, `SELECT t.id, t.name, t.description, t.created_by, t.created, t.updated FROM teams t JOIN team_members m ON m.team_id = t.id WHERE m.engineer_id = ? ORDER BY t.name`, engineerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.Team
+	for rows.Next() {
+		var t models.Team
+		var desc sql.NullString
+		if err := rows.Scan(&t.ID, &t.Name, &desc, &t.CreatedBy, &t.Created, &t.Updated); err != nil {
+			return nil, err
+		}
+		t.Description = desc.String
+		out = append(out, t)
+	}
+
+	return out, nil
+}
+
+// DeleteTeam removes a team and its memberships.
+func (r *SQLiteRepo) DeleteTeam(ctx context.Context, id int64) error {
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM team_members WHERE team_id = ?`, id); err != nil {
+		return fmt.Errorf("delete team members: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM teams WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("delete team: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// AddMember adds an engineer to a team or updates the role of an existing member.
+func (r *SQLiteRepo) AddMember(ctx context.Context, teamID, engineerID int64, role string) error {
+	_, err := r.conn.Exec(ctx, `INSERT INTO team_members (team_id, engineer_id, role, created) VALUES (?, ?, ?, ?) ON CONFLICT(team_id, engineer_id) DO UPDATE SET role = excluded.role`, teamID, engineerID, role, now())
+	return err
+}
+
+func (r *SQLiteRepo) RemoveMember(ctx context.Context, teamID, engineerID int64) error {
+	_, err := r.conn.Exec(ctx, `DELETE FROM team_members WHERE team_id = ? AND engineer_id = ?`, teamID, engineerID)
+	return err
+}
+
+// GetMember returns the membership row, or nil if the engineer is not on the team.
+func (r *SQLiteRepo) GetMember(ctx context.Context, teamID, engineerID int64) (*models.TeamMember, error) {
+	row := r.conn.QueryRow(ctx, `SELECT m.team_id, m.engineer_id, m.role, COALESCE(e.name, ''), COALESCE(e.email, ''), m.created FROM team_members m LEFT JOIN engineers e ON e.id = m.engineer_id WHERE m.team_id = ? AND m.engineer_id = ?`, teamID, engineerID)
+	var m models.TeamMember
+	if err := row.Scan(&m.TeamID, &m.EngineerID, &m.Role, &m.Name, &m.Email, &m.Created); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// ListMembers returns team members with their engineer name and email.
+func (r *SQLiteRepo) ListMembers(ctx context.Context, teamID int64) ([]models.TeamMember, error) {
+	rows, err := r.conn.QueryRows(ctx, `SELECT m.team_id, m.engineer_id, m.role, COALESCE(e.name, ''), COALESCE(e.email, ''), m.created FROM team_members m LEFT JOIN engineers e ON e.id = m.engineer_id WHERE m.team_id = ? ORDER BY m.created`, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.TeamMember
+	for rows.Next() {
+		var m models.TeamMember
+		if err := rows.Scan(&m.TeamID, &m.EngineerID, &m.Role, &m.Name, &m.Email, &m.Created); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+
+	return out, nil
+}
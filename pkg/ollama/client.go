@@ -6,10 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math/rand/v2"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -19,43 +21,96 @@ import (
 
 var ErrCircuitOpen = errors.New("ollama circuit open")
 
-// Client wraps the Ollama API client and adds retries, timeout, and circuit breaker.
+// Client wraps the Ollama API client and adds retries, timeout, and circuit
+// breakers. Requests are spread over the configured endpoints and fail over to
+// the next endpoint on errors.
 type Client struct {
-	api    *api.Client
-	cfg    config.OllamaConfig
-	client *http.Client
-
-	// simple circuit breaker state
-	failures  int32
-	openUntil int64 // unix nano
-	closed    int32 // atomic flag for Close()
+	cfg       config.OllamaConfig
+	client    *http.Client
+	endpoints []*endpoint
+	next      uint32 // round-robin cursor
+	closed    int32  // atomic flag for Close()
 }
 
-// GenerateResult is a typed representation of a model response.
+// GenerateResult is a typed representation of a model response. Text is the
+// full response text and Raw the final streamed chunk.
 type GenerateResult struct {
 	Text string          `json:"text"`
 	Raw  json.RawMessage `json:"raw"`
 	Meta map[string]any  `json:"meta,omitempty"`
 }
 
-// NewClient creates a new Ollama client wrapper.
+// GenerateResult.Meta keys for the usage Ollama reports with a generation.
+const (
+	MetaPromptTokens    = "prompt_eval_count"
+	MetaEvalTokens      = "eval_count"
+	MetaTotalDurationMS = "total_duration_ms"
+)
+
+// Usage is what one generation cost on the Ollama server.
+type Usage struct {
+	PromptTokens int64
+	EvalTokens   int64
+	// Duration covers model load, prompt evaluation and generation
+	Duration time.Duration
+}
+
+// Usage reads the token counts and duration from Meta. It also works on
+// results decoded from JSON, whose numbers are float64.
+func (r GenerateResult) Usage() Usage {
+	num := func(k string) int64 {
+		switch v := r.Meta[k].(type) {
+		case int:
+			return int64(v)
+		case int64:
+			return v
+		case float64:
+			return int64(v)
+		}
+		return 0
+	}
+	return Usage{
+		PromptTokens: num(MetaPromptTokens),
+		EvalTokens:   num(MetaEvalTokens),
+		Duration:     time.Duration(num(MetaTotalDurationMS)) * time.Millisecond,
+	}
+}
+
+// NewClient creates a new Ollama client wrapper over cfg.Endpoints, or
+// cfg.BaseURL when no endpoints are listed.
 func NewClient(cfg config.OllamaConfig, httpClient *http.Client) (*Client, error) {
 	if httpClient == nil {
 		httpClient = &http.Client{Timeout: cfg.Timeout}
 	}
 
-	u, err := url.ParseRequestURI(cfg.BaseURL)
-	if err != nil {
-		return nil, fmt.Errorf("invalid base url: %w", err)
+	urls := cfg.Endpoints
+	if len(urls) == 0 {
+		urls = []string{cfg.BaseURL}
 	}
 
 	c := &Client{
-		api:    api.NewClient(u, httpClient),
 		cfg:    cfg,
 		client: httpClient,
 	}
+	// the API client drops the status of non-JSON error answers and all
+	// headers, which retry classification needs
+	apiHTTP := *httpClient
+	if apiHTTP.Transport == nil {
+		apiHTTP.Transport = http.DefaultTransport
+	}
+	apiHTTP.Transport = responseInfoTransport{base: apiHTTP.Transport}
+	names := make([]string, 0, len(urls))
+	for _, raw := range urls {
+		u, err := url.ParseRequestURI(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base url %q: %w", raw, err)
+		}
+		e := newEndpoint(u, &apiHTTP, cfg)
+		c.endpoints = append(c.endpoints, e)
+		names = append(names, e.name)
+	}
 	// use package logger
-	logger.Info("ollama: NewClient created", slog.String("base_url", cfg.BaseURL), slog.Duration("timeout", cfg.Timeout))
+	logger.Info("ollama: NewClient created", slog.Any("endpoints", names), slog.String("balance", cfg.Balance), slog.Duration("timeout", cfg.Timeout))
 	return c, nil
 }
 
@@ -85,20 +140,6 @@ func NewDefaultClient(cfg config.OllamaConfig) (*Client, error) {
 	return NewClient(cfg, defaultClient)
 }
 
-func (c *Client) isCircuitOpen() bool {
-	if atomic.LoadInt32(&c.failures) < int32(c.cfg.CircuitFailureThreshold) {
-		return false
-	}
-
-	if time.Now().UnixNano() < atomic.LoadInt64(&c.openUntil) {
-		return true
-	}
-
-	// attempt half-open: reset failures and allow a request
-	atomic.StoreInt32(&c.failures, 0)
-	return false
-}
-
 // Close releases any resources held by the client. Currently this will close
 // idle connections on the underlying HTTP transport when supported. Close is
 // idempotent and safe to call multiple times.
@@ -129,72 +170,133 @@ func SetLogger(l *slog.Logger) {
 	}
 }
 
-func (c *Client) recordFailure() {
-	v := atomic.AddInt32(&c.failures, 1)
-	if v >= int32(c.cfg.CircuitFailureThreshold) {
-		atomic.StoreInt64(&c.openUntil, time.Now().Add(c.cfg.CircuitReset).UnixNano())
-	}
-}
-
-// Health pings the Ollama instance by requesting info about models.
+// Health pings every endpoint that accepts requests by requesting info about
+// models; for a half-open endpoint the ping is its trial request. It succeeds when at least one endpoint is healthy.
 func (c *Client) Health(ctx context.Context) error {
-	if c.isCircuitOpen() {
+	eps := c.candidates()
+	if len(eps) == 0 {
 		return ErrCircuitOpen
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
 	defer cancel()
 
-	// list models via HTTP API
-	models, err := c.ListModels(ctx)
-	if err != nil {
-		c.recordFailure()
-		return fmt.Errorf("health check failed: %w", err)
+	var errs []error
+	healthy := false
+	for _, e := range eps {
+		// list models via HTTP API
+		models, err := c.listModels(ctx, e)
+		if err == nil && len(models) == 0 {
+			e.recordFailure()
+			err = fmt.Errorf("%s: no models returned", e.name)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		healthy = true
 	}
-	if len(models) == 0 {
-		c.recordFailure()
-		return fmt.Errorf("health check failed: no models returned")
+	if !healthy {
+		return fmt.Errorf("health check failed: %w", errors.Join(errs...))
 	}
 
-	// success: reset failures
-	atomic.StoreInt32(&c.failures, 0)
 	return nil
 }
 
-// ListModels returns model metadata from Ollama.
-// ModelInfo is a lightweight model descriptor returned by ListModels.
+// ModelInfo is a model descriptor returned by ListModels, parsed from the
+// /api/tags entry kept in Raw. Endpoints names the endpoints it is installed on.
 type ModelInfo struct {
-	Name string          `json:"name"`
-	Raw  json.RawMessage `json:"-"`
+	Name          string          `json:"name"`
+	Size          int64           `json:"size"`
+	Digest        string          `json:"digest,omitempty"`
+	ModifiedAt    time.Time       `json:"modified_at"`
+	Format        string          `json:"format,omitempty"`
+	Family        string          `json:"family,omitempty"`
+	ParameterSize string          `json:"parameter_size,omitempty"`
+	Quantization  string          `json:"quantization,omitempty"`
+	Endpoints     []string        `json:"endpoints,omitempty"`
+	Raw           json.RawMessage `json:"-"`
 }
 
-// ListModels calls the Ollama /api/tags endpoint and returns basic model info.
+// ListModels calls the Ollama /api/tags endpoint of every endpoint whose
+// circuit is closed and merges the results: a model installed on several
+// endpoints is listed once, with the endpoints that have it. Endpoints that
+// fail are left out; it fails only when none answers.
 func (c *Client) ListModels(ctx context.Context) ([]ModelInfo, error) {
-	if c.isCircuitOpen() {
+	eps := c.candidates()
+	if len(eps) == 0 {
 		return nil, ErrCircuitOpen
 	}
 
-	// build URL: use /api/tags (Ollama models metadata endpoint)
-	base, err := url.Parse(c.cfg.BaseURL)
-	if err != nil {
-		return nil, err
+	var (
+		merged   []ModelInfo
+		index    = map[string]int{}
+		lastErr  error
+		answered bool
+	)
+	for _, e := range eps {
+		models, err := c.listModels(ctx, e)
+		if err != nil {
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+			logger.Warn("ollama: list models failed", slog.String("endpoint", e.name), slog.Any("err", err))
+			continue
+		}
+		answered = true
+		for _, m := range models {
+			i, ok := index[m.Name]
+			if !ok {
+				i = len(merged)
+				index[m.Name] = i
+				merged = append(merged, m)
+			}
+			merged[i].Endpoints = append(merged[i].Endpoints, e.name)
+		}
+	}
+	if !answered {
+		return nil, lastErr
+	}
+
+	return merged, nil
+}
+
+// listModels lists the models of a single endpoint.
+func (c *Client) listModels(ctx context.Context, e *endpoint) (_ []ModelInfo, err error) {
+	if !e.allow() {
+		return nil, fmt.Errorf("%s: %w", e.name, ErrCircuitOpen)
 	}
-	u := base.ResolveReference(&url.URL{Path: "/api/tags"})
+	defer e.begin()()
+	defer observeRequest("list_models", time.Now(), &err)
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("%s: %w", e.name, err)
+		}
+	}()
+
+	// build URL: use /api/tags (Ollama models metadata endpoint)
+	u := e.baseURL.ResolveReference(&url.URL{Path: "/api/tags"})
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
-		c.recordFailure()
+		e.recordFailure()
 		return nil, err
 	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		c.recordFailure()
+		// a caller that gave up says nothing about the endpoint
+		if errors.Is(ctx.Err(), context.Canceled) {
+			e.release()
+		} else {
+			e.recordFailure()
+		}
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		c.recordFailure()
+		e.recordFailure()
 		return nil, fmt.Errorf("models endpoint returned status %d", resp.StatusCode)
 	}
 
@@ -206,7 +308,7 @@ func (c *Client) ListModels(ctx context.Context) ([]ModelInfo, error) {
 	// try decoding into a generic value first
 	var v any
 	if err := dec.Decode(&v); err != nil {
-		c.recordFailure()
+		e.recordFailure()
 		return nil, fmt.Errorf("failed to decode models response: %w", err)
 	}
 
@@ -235,78 +337,189 @@ func (c *Client) ListModels(ctx context.Context) ([]ModelInfo, error) {
 			}
 		}
 	default:
-		c.recordFailure()
+		e.recordFailure()
 		return nil, fmt.Errorf("unexpected models response type: %T", v)
 	}
 
 	out := make([]ModelInfo, 0, len(items))
 	for _, m := range items {
-		name := ""
+		b, _ := json.Marshal(m)
+		// entries that do not match the current shape still yield their name
+		var lm api.ListModelResponse
+		_ = json.Unmarshal(b, &lm)
+		name := lm.Name
 		if v, ok := m["name"].(string); ok {
 			name = v
 		}
-		b, _ := json.Marshal(m)
-		out = append(out, ModelInfo{Name: name, Raw: b})
+		out = append(out, ModelInfo{
+			Name:          name,
+			Size:          lm.Size,
+			Digest:        lm.Digest,
+			ModifiedAt:    lm.ModifiedAt,
+			Format:        lm.Details.Format,
+			Family:        lm.Details.Family,
+			ParameterSize: lm.Details.ParameterSize,
+			Quantization:  lm.Details.QuantizationLevel,
+			Raw:           b,
+		})
 	}
 
-	atomic.StoreInt32(&c.failures, 0)
+	e.recordSuccess()
 	return out, nil
 }
 
-// Generate sends a prompt to the model and collects the response. It supports retries and timeouts.
-// Generate sends a prompt to Ollama and returns a string representation of the response.
+// Generate sends a prompt to the model and collects the response. Each attempt
+// tries the endpoints in balancing order, failing over to the next one on
+// errors. Only temporary errors (network, timeouts, 5xx, 429) are retried,
+// with jittered exponential backoff or the server's Retry-After, until every
+// circuit is open, the retries are used up or ctx is done. Errors are
+// *RequestError values matching ErrModelNotFound, ErrBadRequest,
+// ErrRateLimited or ErrUnavailable.
 func (c *Client) Generate(ctx context.Context, model string, prompt string) (GenerateResult, error) {
+	return c.GenerateWithOptions(ctx, model, prompt, nil)
+}
+
+// GenerateWithOptions is Generate with sampling and runtime options; nil opts
+// keeps the model's defaults. Invalid options fail with ErrBadRequest before
+// anything is sent.
+func (c *Client) GenerateWithOptions(ctx context.Context, model string, prompt string, opts *GenerateOptions) (GenerateResult, error) {
+	if err := opts.Validate(); err != nil {
+		return GenerateResult{}, fmt.Errorf("%w: %w", ErrBadRequest, err)
+	}
+
 	var lastErr error
 	var empty GenerateResult
-	if c.isCircuitOpen() {
-		return empty, ErrCircuitOpen
-	}
 
 	for attempt := 0; attempt <= c.cfg.Retries; attempt++ {
-		ctxReq, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
-		req := &api.GenerateRequest{Model: model, Prompt: prompt}
-		var lastRaw any
-		var outText string
-		var lastRawB []byte
-		start := time.Now()
-		err := c.api.Generate(ctxReq, req, func(r api.GenerateResponse) error {
-			// store raw response and a textual representation
-			lastRaw = r
-			// prefer a stable JSON representation of the response as text
-			if b, merr := json.Marshal(r); merr == nil {
-				lastRawB = b
-				outText = string(b)
-			} else {
-				// fallback to a formatted string if marshalling fails
-				outText = fmt.Sprintf("%+v", r)
-			}
-			return nil
-		})
+		eps := c.candidates()
+		if len(eps) == 0 {
+			return empty, ErrCircuitOpen
+		}
 
-		cancel()
-		latency := time.Since(start)
-		if err == nil {
-			// marshal raw into JSON for Raw field (reuse lastRawB if we created it)
-			var rawB []byte
-			if lastRawB != nil {
-				rawB = lastRawB
-			} else {
-				rawB, _ = json.Marshal(lastRaw)
+		retry := false
+		var retryAfter time.Duration
+		for _, e := range eps {
+			res, err := c.generate(ctx, e, model, prompt, opts)
+			if err == nil {
+				return res, nil
+			}
+			lastErr = err
+			// the caller gave up; trying other endpoints would fail the same way
+			if ctx.Err() != nil {
+				return empty, fmt.Errorf("generate failed: %w", lastErr)
+			}
+			var re *RequestError
+			if !errors.As(err, &re) {
+				// no slot on a half-open endpoint; another may have one next time
+				retry = true
+				continue
+			}
+			// another endpoint may have the model, but none will accept a bad request
+			if errors.Is(err, ErrBadRequest) {
+				return empty, fmt.Errorf("generate failed: %w", lastErr)
+			}
+			if re.Temporary {
+				retry = true
+				retryAfter = max(retryAfter, re.RetryAfter)
 			}
-			atomic.StoreInt32(&c.failures, 0)
-			meta := map[string]any{"model": model, "latency_ms": latency.Milliseconds()}
-			return GenerateResult{Text: outText, Raw: rawB, Meta: meta}, nil
 		}
 
-		lastErr = err
-		c.recordFailure()
-
-		// backoff
-		time.Sleep(c.cfg.Backoff * time.Duration(attempt+1))
-		if c.isCircuitOpen() {
-			return empty, ErrCircuitOpen
+		if !retry {
+			return empty, fmt.Errorf("generate failed: %w", lastErr)
+		}
+		if attempt == c.cfg.Retries {
+			break
+		}
+		wait := max(c.backoff(attempt), retryAfter)
+		if dl, ok := ctx.Deadline(); ok && time.Until(dl) < wait {
+			// the caller would give up before the next attempt
+			break
+		}
+		if err := sleepCtx(ctx, wait); err != nil {
+			return empty, fmt.Errorf("generate failed: %w", lastErr)
 		}
 	}
 
 	return empty, fmt.Errorf("generate failed after retries: %w", lastErr)
 }
+
+// backoff returns the delay before the retry following attempt: Backoff
+// doubled per attempt and capped at MaxBackoff, with the upper half jittered
+// so clients that failed together do not retry together.
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.cfg.Backoff
+	if d <= 0 {
+		return 0
+	}
+	for range attempt {
+		if c.cfg.MaxBackoff > 0 && d >= c.cfg.MaxBackoff {
+			break
+		}
+		d *= 2
+	}
+	if c.cfg.MaxBackoff > 0 && d > c.cfg.MaxBackoff {
+		d = c.cfg.MaxBackoff
+	}
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
+
+// sleepCtx waits for d or until ctx is done, whichever comes first.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// generate sends one generate request to a single endpoint.
+func (c *Client) generate(ctx context.Context, e *endpoint, model string, prompt string, opts *GenerateOptions) (GenerateResult, error) {
+	if !e.allow() {
+		return GenerateResult{}, fmt.Errorf("%s: %w", e.name, ErrCircuitOpen)
+	}
+	defer e.begin()()
+
+	var info responseInfo
+	ctxReq, cancel := context.WithTimeout(withResponseInfo(ctx, &info), c.cfg.Timeout)
+	req := &api.GenerateRequest{Model: model, Prompt: prompt}
+	opts.apply(req)
+	// streamed chunks each carry a piece of the response; the last one also
+	// carries the final stats
+	var text strings.Builder
+	var last api.GenerateResponse
+	start := time.Now()
+	err := e.api.Generate(ctxReq, req, func(r api.GenerateResponse) error {
+		text.WriteString(r.Response)
+		last = r
+		return nil
+	})
+
+	cancel()
+	latency := time.Since(start)
+	observeRequest("generate", start, &err)
+	if err != nil {
+		re := classify(ctx, e, err, &info)
+		e.finish(re)
+		return GenerateResult{}, re
+	}
+
+	rawB, _ := json.Marshal(last)
+	e.finish(nil)
+	meta := map[string]any{"model": model, "endpoint": e.name, "latency_ms": latency.Milliseconds()}
+	// the final chunk reports what the generation cost on the server
+	m := last.Metrics
+	meta[MetaPromptTokens] = m.PromptEvalCount
+	meta[MetaEvalTokens] = m.EvalCount
+	meta[MetaTotalDurationMS] = m.TotalDuration.Milliseconds()
+	meta["load_duration_ms"] = m.LoadDuration.Milliseconds()
+	meta["prompt_eval_duration_ms"] = m.PromptEvalDuration.Milliseconds()
+	meta["eval_duration_ms"] = m.EvalDuration.Milliseconds()
+	return GenerateResult{Text: text.String(), Raw: rawB, Meta: meta}, nil
+}
@@ -0,0 +1,133 @@
+package ollama
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ollama/ollama/api"
+)
+
+var (
+	// ErrBadRequest is returned when an endpoint rejects a request as invalid
+	// (a 4xx other than 404 and 429); sending it again will not help.
+	ErrBadRequest = errors.New("ollama rejected request")
+	// ErrRateLimited is returned when an endpoint answers 429.
+	ErrRateLimited = errors.New("ollama rate limited")
+	// ErrUnavailable is returned for network errors, timeouts and 5xx answers.
+	ErrUnavailable = errors.New("ollama unavailable")
+)
+
+// RequestError describes a failed request to one endpoint. It matches
+// ErrModelNotFound, ErrBadRequest, ErrRateLimited or ErrUnavailable with
+// errors.Is, as well as the underlying error.
+type RequestError struct {
+	Endpoint string
+	// StatusCode is the HTTP status of the answer, 0 when none was received.
+	StatusCode int
+	// Temporary is set for errors worth retrying: network errors, timeouts,
+	// 5xx and 429.
+	Temporary bool
+	// RetryAfter is the delay asked for by the server's Retry-After header.
+	RetryAfter time.Duration
+	Err        error
+
+	kind error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Endpoint, e.Err)
+}
+
+func (e *RequestError) Unwrap() []error {
+	if e.kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.kind, e.Err}
+}
+
+// IsRetryable reports whether an error returned by the client may succeed
+// when the call is made again later. Missing models and rejected requests
+// are not retryable; everything else, including open circuits, is.
+func IsRetryable(err error) bool {
+	return err != nil && !errors.Is(err, ErrModelNotFound) && !errors.Is(err, ErrBadRequest)
+}
+
+// classify turns the error of a request to e into a *RequestError. ctx is the
+// caller's context: when it is done the caller gave up, which says nothing
+// about the endpoint, so the error is neither temporary nor of any kind.
+func classify(ctx context.Context, e *endpoint, err error, info *responseInfo) *RequestError {
+	re := &RequestError{Endpoint: e.name, Err: err}
+	if info != nil {
+		re.StatusCode = info.status
+		re.RetryAfter = info.retryAfter
+	}
+	var se api.StatusError
+	if errors.As(err, &se) {
+		re.StatusCode = se.StatusCode
+	}
+
+	switch {
+	case ctx.Err() != nil:
+	case re.StatusCode == http.StatusNotFound:
+		re.kind = ErrModelNotFound
+	case re.StatusCode == http.StatusTooManyRequests:
+		re.kind, re.Temporary = ErrRateLimited, true
+	case re.StatusCode >= http.StatusInternalServerError:
+		re.kind, re.Temporary = ErrUnavailable, true
+	case re.StatusCode >= http.StatusBadRequest:
+		re.kind = ErrBadRequest
+	default:
+		// no error status: the connection failed, timed out or the stream broke
+		re.kind, re.Temporary = ErrUnavailable, true
+	}
+	return re
+}
+
+// responseInfo keeps what the API client does not report about an answer:
+// its status even when the body is not JSON, and its Retry-After header.
+type responseInfo struct {
+	status     int
+	retryAfter time.Duration
+}
+
+type responseInfoKey struct{}
+
+// withResponseInfo returns a context whose requests record their answer in info.
+func withResponseInfo(ctx context.Context, info *responseInfo) context.Context {
+	return context.WithValue(ctx, responseInfoKey{}, info)
+}
+
+// responseInfoTransport fills the responseInfo attached to a request's context.
+type responseInfoTransport struct {
+	base http.RoundTripper
+}
+
+func (t responseInfoTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if info, ok := req.Context().Value(responseInfoKey{}).(*responseInfo); ok {
+		info.status = resp.StatusCode
+		info.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return resp, nil
+}
+
+// parseRetryAfter reads a Retry-After value in seconds or as an HTTP date.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return max(time.Duration(secs)*time.Second, 0)
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(t.Sub(now), 0)
+	}
+	return 0
+}
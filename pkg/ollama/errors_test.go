@@ -0,0 +1,157 @@
+package ollama_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/garnizeh/rag/internal/config"
+	"github.com/garnizeh/rag/pkg/ollama"
+)
+
+func TestGenerate_ClientErrorsNotRetried(t *testing.T) {
+	cases := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"model not found", http.StatusNotFound, `{"error":"model \"m\" not found, try pulling it first"}`, ollama.ErrModelNotFound},
+		{"bad request", http.StatusBadRequest, `{"error":"invalid options"}`, ollama.ErrBadRequest},
+		{"plain text body", http.StatusBadRequest, "bad\n", ollama.ErrBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var hits int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&hits, 1)
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			defer srv.Close()
+
+			cfg := config.OllamaConfig{BaseURL: srv.URL, Timeout: 2 * time.Second, Retries: 3, Backoff: time.Millisecond, CircuitFailureThreshold: 1, CircuitReset: time.Minute}
+			client, err := ollama.NewClient(cfg, srv.Client())
+			if err != nil {
+				t.Fatalf("NewClient error: %v", err)
+			}
+			defer client.Close()
+
+			_, err = client.Generate(context.Background(), "m", "p")
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, err)
+			}
+			if ollama.IsRetryable(err) {
+				t.Fatalf("expected %v not to be retryable", err)
+			}
+			var re *ollama.RequestError
+			if !errors.As(err, &re) || re.StatusCode != tc.status || re.Temporary {
+				t.Fatalf("unexpected request error %+v", re)
+			}
+			if got := atomic.LoadInt32(&hits); got != 1 {
+				t.Fatalf("expected a single request, got %d", got)
+			}
+			// the server answered, so its circuit stays closed
+			if st := client.Endpoints()[0]; st.State != ollama.CircuitClosed || st.Failures != 0 {
+				t.Fatalf("expected a closed circuit, got %+v", st)
+			}
+		})
+	}
+}
+
+func TestGenerate_RateLimitedHonoursRetryAfter(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "busy", http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		writeSequence(w, []map[string]any{{"response": "ok", "done": true}}, 0)
+	}))
+	defer srv.Close()
+
+	cfg := config.OllamaConfig{BaseURL: srv.URL, Timeout: 2 * time.Second, Retries: 1, Backoff: time.Millisecond, CircuitFailureThreshold: 5}
+	client, err := ollama.NewClient(cfg, srv.Client())
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer client.Close()
+
+	start := time.Now()
+	res, err := client.Generate(context.Background(), "m", "p")
+	if err != nil {
+		t.Fatalf("expected the retry to succeed, got %v", err)
+	}
+	if res.Text != "ok" {
+		t.Fatalf("unexpected text %q", res.Text)
+	}
+	if d := time.Since(start); d < 900*time.Millisecond {
+		t.Fatalf("expected the retry to wait for Retry-After, waited %s", d)
+	}
+}
+
+func TestGenerate_RetryAfterBeyondDeadline(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, "busy", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	cfg := config.OllamaConfig{BaseURL: srv.URL, Timeout: 2 * time.Second, Retries: 3, Backoff: time.Millisecond, CircuitFailureThreshold: 5}
+	client, err := ollama.NewClient(cfg, srv.Client())
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err = client.Generate(ctx, "m", "p")
+	if !errors.Is(err, ollama.ErrRateLimited) || !ollama.IsRetryable(err) {
+		t.Fatalf("expected a retryable rate limit error, got %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("expected Generate to give up instead of waiting past the deadline, took %s", d)
+	}
+	if got := atomic.LoadInt32(&hits); got != 1 {
+		t.Fatalf("expected a single request, got %d", got)
+	}
+}
+
+func TestGenerate_CallerCancelDoesNotCountTowardCircuit(t *testing.T) {
+	stop := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-stop:
+		}
+	}))
+	defer srv.Close()
+	defer close(stop)
+
+	cfg := config.OllamaConfig{BaseURL: srv.URL, Timeout: 2 * time.Second, Retries: 3, Backoff: time.Millisecond, CircuitFailureThreshold: 1, CircuitReset: time.Minute}
+	client, err := ollama.NewClient(cfg, srv.Client())
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, err = client.Generate(ctx, "m", "p")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if st := client.Endpoints()[0]; st.State != ollama.CircuitClosed || st.Failures != 0 {
+		t.Fatalf("expected the cancellation not to count as a failure, got %+v", st)
+	}
+}
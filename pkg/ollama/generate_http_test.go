@@ -38,7 +38,7 @@ func TestClient_Generate_Retries_Backoff_Succeeds(t *testing.T) {
 				return
 			}
 			w.Header().Set("Content-Type", "application/json")
-			writeSequence(w, []map[string]any{{"response": "ok", "done": true}}, 0)
+			writeSequence(w, []map[string]any{{"response": "ok", "done": true, "prompt_eval_count": 12, "eval_count": 5, "total_duration": 2500000000}}, 0)
 			return
 		}
 		http.NotFound(w, r)
@@ -62,6 +62,16 @@ func TestClient_Generate_Retries_Backoff_Succeeds(t *testing.T) {
 	if _, ok := res.Meta["latency_ms"]; !ok {
 		t.Fatalf("expected latency_ms in meta")
 	}
+	want := ollama.Usage{PromptTokens: 12, EvalTokens: 5, Duration: 2500 * time.Millisecond}
+	if u := res.Usage(); u != want {
+		t.Fatalf("expected usage %+v, got %+v", want, u)
+	}
+	// usage survives a JSON round trip, e.g. through the response cache
+	var decoded ollama.GenerateResult
+	b, _ := json.Marshal(res)
+	if err := json.Unmarshal(b, &decoded); err != nil || decoded.Usage() != want {
+		t.Fatalf("expected usage after decoding, got %+v, %v", decoded.Usage(), err)
+	}
 	if atomic.LoadInt32(&attempts) != 2 {
 		t.Fatalf("expected 2 attempts, got %d", attempts)
 	}
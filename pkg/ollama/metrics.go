@@ -0,0 +1,27 @@
+package ollama
+
+import (
+	"time"
+
+	"github.com/garnizeh/rag/internal/metrics"
+)
+
+var (
+	requestDuration = metrics.NewHistogram("rag_ollama_request_duration_seconds",
+		"Latency of Ollama API calls by operation (generate, list_models, show_model) and outcome (ok, error).",
+		metrics.SlowBuckets, "op", "outcome")
+	circuitState = metrics.NewGauge("rag_ollama_circuit_state",
+		"1 for the current circuit breaker state (closed, open, half_open) of an Ollama endpoint, 0 for the others.", "endpoint", "state")
+	consecutiveFailures = metrics.NewGauge("rag_ollama_consecutive_failures",
+		"Failed calls to an Ollama endpoint since its last success.", "endpoint")
+)
+
+// observeRequest records the latency of one call started at start; *err is
+// read when the call returns so it can be used with defer.
+func observeRequest(op string, start time.Time, err *error) {
+	outcome := "ok"
+	if *err != nil {
+		outcome = "error"
+	}
+	requestDuration.With(op, outcome).Observe(time.Since(start).Seconds())
+}
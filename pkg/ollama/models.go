@@ -0,0 +1,130 @@
+package ollama
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ollama/ollama/api"
+)
+
+// ErrModelNotFound is returned when no endpoint has the requested model.
+var ErrModelNotFound = errors.New("model not found")
+
+// PullProgress is one progress update of a model pull on one endpoint.
+type PullProgress struct {
+	Endpoint  string `json:"endpoint"`
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+}
+
+// PullModel downloads model onto every endpoint whose circuit is closed, one
+// endpoint after the other, so any of them can serve it. fn receives each
+// progress update; returning an error from fn aborts the pull. Endpoints that
+// fail do not stop the others; their errors are joined in the result.
+func (c *Client) PullModel(ctx context.Context, model string, fn func(PullProgress) error) error {
+	eps := c.candidates()
+	if len(eps) == 0 {
+		return ErrCircuitOpen
+	}
+
+	var errs []error
+	for _, e := range eps {
+		err := e.api.Pull(ctx, &api.PullRequest{Model: model}, func(p api.ProgressResponse) error {
+			return fn(PullProgress{Endpoint: e.name, Status: p.Status, Digest: p.Digest, Total: p.Total, Completed: p.Completed})
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.name, err))
+			if ctx.Err() != nil {
+				break
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ModelDetails describes a single model as reported by /api/show.
+type ModelDetails struct {
+	Name          string    `json:"name"`
+	Format        string    `json:"format,omitempty"`
+	Family        string    `json:"family,omitempty"`
+	Families      []string  `json:"families,omitempty"`
+	ParameterSize string    `json:"parameter_size,omitempty"`
+	Quantization  string    `json:"quantization,omitempty"`
+	ContextLength int64     `json:"context_length,omitempty"`
+	Capabilities  []string  `json:"capabilities,omitempty"`
+	Parameters    string    `json:"parameters,omitempty"`
+	Template      string    `json:"template,omitempty"`
+	License       string    `json:"license,omitempty"`
+	ModifiedAt    time.Time `json:"modified_at"`
+}
+
+// ShowModel returns the details of model, failing over to the next endpoint
+// on errors. It returns ErrModelNotFound when the model is not installed.
+func (c *Client) ShowModel(ctx context.Context, model string) (*ModelDetails, error) {
+	eps := c.candidates()
+	if len(eps) == 0 {
+		return nil, ErrCircuitOpen
+	}
+
+	var lastErr error
+	for _, e := range eps {
+		d, err := c.showModel(ctx, e, model)
+		if err == nil {
+			return d, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return nil, lastErr
+}
+
+func (c *Client) showModel(ctx context.Context, e *endpoint, model string) (_ *ModelDetails, err error) {
+	defer e.begin()()
+	defer observeRequest("show_model", time.Now(), &err)
+
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	resp, err := e.api.Show(ctx, &api.ShowRequest{Model: model})
+	if err != nil {
+		var se api.StatusError
+		if errors.As(err, &se) && se.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%s: %w: %s", e.name, ErrModelNotFound, model)
+		}
+		return nil, fmt.Errorf("%s: %w", e.name, err)
+	}
+
+	d := &ModelDetails{
+		Name:          model,
+		Format:        resp.Details.Format,
+		Family:        resp.Details.Family,
+		Families:      resp.Details.Families,
+		ParameterSize: resp.Details.ParameterSize,
+		Quantization:  resp.Details.QuantizationLevel,
+		Parameters:    resp.Parameters,
+		Template:      resp.Template,
+		License:       resp.License,
+		ModifiedAt:    resp.ModifiedAt,
+	}
+	for _, capability := range resp.Capabilities {
+		d.Capabilities = append(d.Capabilities, string(capability))
+	}
+	// model_info keys are prefixed with the architecture, e.g. "llama.context_length"
+	for k, v := range resp.ModelInfo {
+		if n, ok := v.(float64); ok && strings.HasSuffix(k, ".context_length") {
+			d.ContextLength = int64(n)
+		}
+	}
+
+	return d, nil
+}
@@ -0,0 +1,155 @@
+package ollama_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/garnizeh/rag/internal/config"
+	"github.com/garnizeh/rag/pkg/ollama"
+)
+
+// modelServer fakes the Ollama model endpoints with a single installed model.
+func modelServer(t *testing.T, pulled *[]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/tags":
+			_, _ = w.Write([]byte(`{"models":[{"name":"llama3:8b","model":"llama3:8b","modified_at":"2026-10-01T12:00:00Z","size":4661224676,"digest":"abc","details":{"format":"gguf","family":"llama","parameter_size":"8.0B","quantization_level":"Q4_0"}}]}`))
+		case "/api/show":
+			var req struct {
+				Model string `json:"model"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			if req.Model != "llama3:8b" {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"error":"model '` + req.Model + `' not found"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"parameters":"stop \"<|eot_id|>\"","details":{"format":"gguf","family":"llama","families":["llama"],"parameter_size":"8.0B","quantization_level":"Q4_0"},"model_info":{"general.architecture":"llama","llama.context_length":8192},"capabilities":["completion"]}`))
+		case "/api/pull":
+			var req struct {
+				Model string `json:"model"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			*pulled = append(*pulled, req.Model)
+			writeSequence(w, []map[string]any{
+				{"status": "pulling manifest"},
+				{"status": "downloading", "digest": "sha256:abc", "total": 100, "completed": 50},
+				{"status": "success"},
+			}, 0)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClient_ModelManagement(t *testing.T) {
+	var pulledA, pulledB []string
+	a := modelServer(t, &pulledA)
+	b := modelServer(t, &pulledB)
+
+	cfg := config.OllamaConfig{Endpoints: []string{a.URL, b.URL}, Timeout: 2 * time.Second}
+	client, err := ollama.NewClient(cfg, http.DefaultClient)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer client.Close()
+	ctx := context.Background()
+
+	list, err := client.ListModels(ctx)
+	if err != nil {
+		t.Fatalf("ListModels: %v", err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("expected one model, got %#v", list)
+	}
+	m := list[0]
+	if m.Name != "llama3:8b" || m.Size != 4661224676 || m.Family != "llama" || m.ParameterSize != "8.0B" || m.Quantization != "Q4_0" || m.ModifiedAt.IsZero() {
+		t.Fatalf("model not parsed: %#v", m)
+	}
+
+	d, err := client.ShowModel(ctx, "llama3:8b")
+	if err != nil {
+		t.Fatalf("ShowModel: %v", err)
+	}
+	if d.ContextLength != 8192 || d.Quantization != "Q4_0" || len(d.Capabilities) != 1 {
+		t.Fatalf("details not parsed: %#v", d)
+	}
+	if _, err := client.ShowModel(ctx, "missing"); !errors.Is(err, ollama.ErrModelNotFound) {
+		t.Fatalf("expected ErrModelNotFound, got %v", err)
+	}
+
+	var progress []ollama.PullProgress
+	if err := client.PullModel(ctx, "qwen2:7b", func(p ollama.PullProgress) error {
+		progress = append(progress, p)
+		return nil
+	}); err != nil {
+		t.Fatalf("PullModel: %v", err)
+	}
+	// every endpoint gets the model so any of them can serve it
+	if len(pulledA) != 1 || len(pulledB) != 1 || pulledA[0] != "qwen2:7b" {
+		t.Fatalf("expected a pull on each endpoint, got %v and %v", pulledA, pulledB)
+	}
+	if len(progress) != 6 || progress[1].Completed != 50 || progress[0].Endpoint == progress[5].Endpoint {
+		t.Fatalf("unexpected progress %#v", progress)
+	}
+}
+
+func TestClient_ListModelsMergesEndpoints(t *testing.T) {
+	tags := func(body string) *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if body == "" {
+				http.Error(w, "down", http.StatusInternalServerError)
+				return
+			}
+			_, _ = w.Write([]byte(body))
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	a := tags(`{"models":[{"name":"llama3:8b"},{"name":"qwen2:7b"}]}`)
+	b := tags(`{"models":[{"name":"qwen2:7b"},{"name":"phi3:mini"}]}`)
+	down := tags("")
+
+	client, err := ollama.NewClient(config.OllamaConfig{Endpoints: []string{a.URL, down.URL, b.URL}, Timeout: 2 * time.Second}, http.DefaultClient)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer client.Close()
+
+	list, err := client.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("ListModels: %v", err)
+	}
+	got := map[string][]string{}
+	for _, m := range list {
+		got[m.Name] = slices.Sorted(slices.Values(m.Endpoints))
+	}
+	want := map[string][]string{"llama3:8b": {a.URL}, "qwen2:7b": slices.Sorted(slices.Values([]string{a.URL, b.URL})), "phi3:mini": {b.URL}}
+	if len(got) != len(want) {
+		t.Fatalf("expected the models of every answering endpoint, got %v", got)
+	}
+	for name, eps := range want {
+		if !slices.Equal(got[name], eps) {
+			t.Errorf("%s: expected endpoints %v, got %v", name, eps, got[name])
+		}
+	}
+
+	only, err := ollama.NewClient(config.OllamaConfig{Endpoints: []string{down.URL}, Timeout: 2 * time.Second}, http.DefaultClient)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer only.Close()
+	if _, err := only.ListModels(context.Background()); err == nil {
+		t.Fatal("expected an error when no endpoint answers")
+	}
+}
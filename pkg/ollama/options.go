@@ -0,0 +1,106 @@
+package ollama
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ollama/ollama/api"
+)
+
+// GenerateOptions tunes a generate request. Unset fields keep the model's
+// defaults.
+type GenerateOptions struct {
+	// Temperature controls randomness; 0 makes sampling greedy.
+	Temperature *float64 `json:"temperature,omitempty"`
+	// TopP restricts sampling to the smallest token set with this cumulative probability.
+	TopP *float64 `json:"top_p,omitempty"`
+	// NumCtx is the context window in tokens.
+	NumCtx *int `json:"num_ctx,omitempty"`
+	// Seed makes sampling reproducible for the same prompt and options.
+	Seed *int `json:"seed,omitempty"`
+	// Stop ends generation at the first of these sequences.
+	Stop []string `json:"stop,omitempty"`
+	// KeepAlive is how long the model stays loaded after the request, as a Go
+	// duration ("10m"); a negative value keeps it loaded.
+	KeepAlive string `json:"keep_alive,omitempty"`
+}
+
+// ParseGenerateOptions decodes options from JSON, rejecting unknown fields so
+// typos do not go unnoticed, and validates them. Empty input yields nil.
+func ParseGenerateOptions(b []byte) (*GenerateOptions, error) {
+	if len(bytes.TrimSpace(b)) == 0 || string(bytes.TrimSpace(b)) == "null" {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	var o GenerateOptions
+	if err := dec.Decode(&o); err != nil {
+		return nil, fmt.Errorf("decode generate options: %w", err)
+	}
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// Validate checks the option ranges Ollama accepts.
+func (o *GenerateOptions) Validate() error {
+	if o == nil {
+		return nil
+	}
+	var errs []error
+	if o.Temperature != nil && (*o.Temperature < 0 || *o.Temperature > 2) {
+		errs = append(errs, fmt.Errorf("temperature must be between 0 and 2, got %v", *o.Temperature))
+	}
+	if o.TopP != nil && (*o.TopP <= 0 || *o.TopP > 1) {
+		errs = append(errs, fmt.Errorf("top_p must be in (0, 1], got %v", *o.TopP))
+	}
+	if o.NumCtx != nil && *o.NumCtx <= 0 {
+		errs = append(errs, fmt.Errorf("num_ctx must be positive, got %d", *o.NumCtx))
+	}
+	for _, s := range o.Stop {
+		if s == "" {
+			errs = append(errs, errors.New("stop sequences must not be empty"))
+			break
+		}
+	}
+	if o.KeepAlive != "" {
+		if _, err := time.ParseDuration(o.KeepAlive); err != nil {
+			errs = append(errs, fmt.Errorf("keep_alive: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// apply sets the options on req. o must be valid.
+func (o *GenerateOptions) apply(req *api.GenerateRequest) {
+	if o == nil {
+		return
+	}
+	opts := map[string]any{}
+	if o.Temperature != nil {
+		opts["temperature"] = *o.Temperature
+	}
+	if o.TopP != nil {
+		opts["top_p"] = *o.TopP
+	}
+	if o.NumCtx != nil {
+		opts["num_ctx"] = *o.NumCtx
+	}
+	if o.Seed != nil {
+		opts["seed"] = *o.Seed
+	}
+	if len(o.Stop) > 0 {
+		opts["stop"] = o.Stop
+	}
+	if len(opts) > 0 {
+		req.Options = opts
+	}
+	if o.KeepAlive != "" {
+		d, _ := time.ParseDuration(o.KeepAlive)
+		req.KeepAlive = &api.Duration{Duration: d}
+	}
+}
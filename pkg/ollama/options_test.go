@@ -0,0 +1,68 @@
+package ollama_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/garnizeh/rag/internal/config"
+	"github.com/garnizeh/rag/pkg/ollama"
+)
+
+func TestGenerateWithOptions(t *testing.T) {
+	var hits int32
+	var got map[string]json.RawMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		got = nil
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "application/json")
+		writeSequence(w, []map[string]any{{"response": "ok", "done": true}}, 0)
+	}))
+	defer srv.Close()
+
+	client, err := ollama.NewClient(config.OllamaConfig{BaseURL: srv.URL, Timeout: 2 * time.Second}, srv.Client())
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer client.Close()
+	ctx := context.Background()
+
+	opts, err := ollama.ParseGenerateOptions([]byte(`{"temperature":0.2,"top_p":0.9,"num_ctx":4096,"seed":42,"stop":["END"],"keep_alive":"-1s"}`))
+	if err != nil {
+		t.Fatalf("parse options: %v", err)
+	}
+	if _, err := client.GenerateWithOptions(ctx, "m", "p", opts); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	want := `{"num_ctx":4096,"seed":42,"stop":["END"],"temperature":0.2,"top_p":0.9}`
+	if string(got["options"]) != want {
+		t.Fatalf("expected options %s, got %s", want, got["options"])
+	}
+	if len(got["keep_alive"]) == 0 {
+		t.Fatal("expected keep_alive to be sent")
+	}
+
+	// without options the model defaults apply
+	if _, err := client.Generate(ctx, "m", "p"); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if o := string(got["options"]); o != "" && o != "null" {
+		t.Fatalf("expected no options, got %s", o)
+	}
+
+	// invalid options never reach the server
+	bad := 3.0
+	_, err = client.GenerateWithOptions(ctx, "m", "p", &ollama.GenerateOptions{Temperature: &bad})
+	if !errors.Is(err, ollama.ErrBadRequest) {
+		t.Fatalf("expected ErrBadRequest, got %v", err)
+	}
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Fatalf("expected 2 requests, got %d", n)
+	}
+}
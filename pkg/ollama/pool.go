@@ -0,0 +1,259 @@
+package ollama
+
+import (
+	"cmp"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/garnizeh/rag/internal/config"
+	"github.com/ollama/ollama/api"
+)
+
+// CircuitState is the state of an endpoint's circuit breaker.
+type CircuitState string
+
+const (
+	// CircuitClosed lets every request through.
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen skips the endpoint until the reset period has passed.
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen lets a limited number of trial requests through; the
+	// first success closes the circuit and a failure opens it again.
+	CircuitHalfOpen CircuitState = "half_open"
+)
+
+var circuitStateValues = []CircuitState{CircuitClosed, CircuitOpen, CircuitHalfOpen}
+
+// EndpointStatus is a snapshot of one endpoint for readiness checks.
+type EndpointStatus struct {
+	Endpoint  string       `json:"endpoint"`
+	State     CircuitState `json:"state"`
+	Failures  int          `json:"consecutive_failures"`
+	InFlight  int          `json:"in_flight"`
+	OpenUntil *time.Time   `json:"open_until,omitempty"`
+}
+
+// endpoint is one Ollama server with its own circuit breaker, so a failing
+// box is skipped while the others keep serving.
+type endpoint struct {
+	name    string
+	baseURL *url.URL
+	api     *api.Client
+
+	threshold int // non-positive disables the breaker
+	reset     time.Duration
+	maxTrials int
+
+	inFlight int32
+
+	mu        sync.Mutex
+	state     CircuitState
+	failures  int
+	openUntil time.Time
+	trials    int // half-open trial requests in flight
+}
+
+// newEndpoint creates the endpoint for u; httpClient must record responseInfo
+// (see responseInfoTransport).
+func newEndpoint(u *url.URL, httpClient *http.Client, cfg config.OllamaConfig) *endpoint {
+	e := &endpoint{
+		name:      u.Redacted(),
+		baseURL:   u,
+		api:       api.NewClient(u, httpClient),
+		threshold: cfg.CircuitFailureThreshold,
+		reset:     cfg.CircuitReset,
+		maxTrials: max(cfg.CircuitHalfOpenRequests, 1),
+	}
+	e.setState(CircuitClosed)
+	consecutiveFailures.With(e.name).Set(0)
+	return e
+}
+
+// setState moves the breaker to s and updates the state gauge; e.mu must be
+// held once the endpoint is shared.
+func (e *endpoint) setState(s CircuitState) {
+	if e.state != "" && e.state != s {
+		logger.Info("ollama: circuit state changed", slog.String("endpoint", e.name),
+			slog.String("from", string(e.state)), slog.String("to", string(s)))
+	}
+	e.state = s
+	e.trials = 0
+	for _, v := range circuitStateValues {
+		g := 0.0
+		if v == s {
+			g = 1
+		}
+		circuitState.With(e.name, string(v)).Set(g)
+	}
+}
+
+// available reports whether allow would currently let a request through,
+// without reserving a half-open trial.
+func (e *endpoint) available() bool {
+	if e.threshold <= 0 {
+		return true
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch e.state {
+	case CircuitOpen:
+		return !time.Now().Before(e.openUntil)
+	case CircuitHalfOpen:
+		return e.trials < e.maxTrials
+	default:
+		return true
+	}
+}
+
+// allow reports whether a request may be sent to the endpoint. Once the reset
+// period of an open circuit has passed it turns half-open and hands out up to
+// maxTrials trial slots; every allowed request must end with recordSuccess or
+// recordFailure so the slot is returned.
+func (e *endpoint) allow() bool {
+	if e.threshold <= 0 {
+		return true
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch e.state {
+	case CircuitOpen:
+		if time.Now().Before(e.openUntil) {
+			return false
+		}
+		e.setState(CircuitHalfOpen)
+		fallthrough
+	case CircuitHalfOpen:
+		if e.trials >= e.maxTrials {
+			return false
+		}
+		e.trials++
+		return true
+	default:
+		return true
+	}
+}
+
+func (e *endpoint) recordFailure() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.failures++
+	consecutiveFailures.With(e.name).Set(float64(e.failures))
+	switch e.state {
+	case CircuitHalfOpen:
+		// the trial failed: the server is still broken
+		e.trip()
+	case CircuitClosed:
+		if e.threshold > 0 && e.failures >= e.threshold {
+			e.trip()
+		}
+	}
+}
+
+// release returns a half-open trial slot without judging the endpoint, for
+// requests the caller gave up on.
+func (e *endpoint) release() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.state == CircuitHalfOpen && e.trials > 0 {
+		e.trials--
+	}
+}
+
+// finish ends a request let through by allow. Temporary errors count toward
+// opening the circuit; any answer from the server, including a client error,
+// shows it is up; requests the caller gave up on say nothing either way.
+func (e *endpoint) finish(err *RequestError) {
+	switch {
+	case err == nil || err.kind != nil && !err.Temporary:
+		e.recordSuccess()
+	case err.Temporary:
+		e.recordFailure()
+	default:
+		e.release()
+	}
+}
+
+// trip opens the circuit for the reset period; e.mu must be held.
+func (e *endpoint) trip() {
+	e.openUntil = time.Now().Add(e.reset)
+	e.setState(CircuitOpen)
+}
+
+// recordSuccess resets the failure count and closes the circuit.
+func (e *endpoint) recordSuccess() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.failures = 0
+	consecutiveFailures.With(e.name).Set(0)
+	if e.state != CircuitClosed {
+		e.setState(CircuitClosed)
+	}
+}
+
+// status returns a snapshot of the endpoint.
+func (e *endpoint) status() EndpointStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	s := EndpointStatus{
+		Endpoint: e.name,
+		State:    e.state,
+		Failures: e.failures,
+		InFlight: int(atomic.LoadInt32(&e.inFlight)),
+	}
+	if e.state == CircuitOpen {
+		until := e.openUntil
+		s.OpenUntil = &until
+	}
+	return s
+}
+
+// begin marks a request as in flight; the returned func ends it.
+func (e *endpoint) begin() func() {
+	atomic.AddInt32(&e.inFlight, 1)
+	return func() { atomic.AddInt32(&e.inFlight, -1) }
+}
+
+// Endpoints returns the circuit state of every configured endpoint.
+func (c *Client) Endpoints() []EndpointStatus {
+	out := make([]EndpointStatus, 0, len(c.endpoints))
+	for _, e := range c.endpoints {
+		out = append(out, e.status())
+	}
+	return out
+}
+
+// candidates returns the endpoints that currently accept requests, ordered by
+// the configured balancing strategy: the first is preferred and the rest are
+// failover targets. The list is rotated on every call so round robin spreads
+// load and least-in-flight ties do not always land on the same server.
+// Half-open endpoints are listed while they have free trial slots; the slot is
+// only taken when a request is actually sent.
+func (c *Client) candidates() []*endpoint {
+	n := len(c.endpoints)
+	start := int((atomic.AddUint32(&c.next, 1) - 1) % uint32(n))
+
+	out := make([]*endpoint, 0, n)
+	for i := range n {
+		e := c.endpoints[(start+i)%n]
+		if e.available() {
+			out = append(out, e)
+		}
+	}
+	if c.cfg.Balance == config.BalanceLeastInFlight {
+		slices.SortStableFunc(out, func(a, b *endpoint) int {
+			return cmp.Compare(atomic.LoadInt32(&a.inFlight), atomic.LoadInt32(&b.inFlight))
+		})
+	}
+	return out
+}
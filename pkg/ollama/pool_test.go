@@ -0,0 +1,282 @@
+package ollama_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/garnizeh/rag/internal/config"
+	"github.com/garnizeh/rag/pkg/ollama"
+)
+
+// countingServer answers generate requests with status (200 streams a final
+// response) and counts the requests it receives.
+func countingServer(t *testing.T, status int, hits *int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		if status != http.StatusOK {
+			http.Error(w, "unavailable", status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		writeSequence(w, []map[string]any{{"response": "ok", "done": true}}, 0)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestPool_FailoverAndPerEndpointCircuit(t *testing.T) {
+	var badHits, goodHits int32
+	bad := countingServer(t, http.StatusInternalServerError, &badHits)
+	good := countingServer(t, http.StatusOK, &goodHits)
+
+	cfg := config.OllamaConfig{Endpoints: []string{bad.URL, good.URL}, Balance: config.BalanceRoundRobin, Timeout: 2 * time.Second, Retries: 0, CircuitFailureThreshold: 1, CircuitReset: time.Minute}
+	client, err := ollama.NewClient(cfg, http.DefaultClient)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	for i := range 4 {
+		res, err := client.Generate(ctx, "m", "p")
+		if err != nil {
+			t.Fatalf("generate %d: expected failover to the healthy endpoint, got %v", i, err)
+		}
+		if res.Meta["endpoint"] != good.URL {
+			t.Fatalf("generate %d: served by %v", i, res.Meta["endpoint"])
+		}
+	}
+
+	// the failing endpoint opened its own circuit after one error and is skipped
+	if got := atomic.LoadInt32(&badHits); got != 1 {
+		t.Fatalf("expected the failing endpoint to be tried once, got %d", got)
+	}
+	if got := atomic.LoadInt32(&goodHits); got != 4 {
+		t.Fatalf("expected 4 requests on the healthy endpoint, got %d", got)
+	}
+}
+
+func TestPool_AllCircuitsOpen(t *testing.T) {
+	var hitsA, hitsB int32
+	a := countingServer(t, http.StatusBadGateway, &hitsA)
+	b := countingServer(t, http.StatusBadGateway, &hitsB)
+
+	cfg := config.OllamaConfig{Endpoints: []string{a.URL, b.URL}, Timeout: 2 * time.Second, Retries: 0, CircuitFailureThreshold: 1, CircuitReset: time.Minute}
+	client, err := ollama.NewClient(cfg, http.DefaultClient)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	if _, err := client.Generate(ctx, "m", "p"); err == nil || err == ollama.ErrCircuitOpen {
+		t.Fatalf("expected an endpoint error, got %v", err)
+	}
+	if _, err := client.Generate(ctx, "m", "p"); err != ollama.ErrCircuitOpen {
+		t.Fatalf("expected ErrCircuitOpen once every endpoint failed, got %v", err)
+	}
+	if atomic.LoadInt32(&hitsA) != 1 || atomic.LoadInt32(&hitsB) != 1 {
+		t.Fatalf("expected one request per endpoint, got %d and %d", hitsA, hitsB)
+	}
+}
+
+func TestPool_RoundRobin(t *testing.T) {
+	var hitsA, hitsB int32
+	a := countingServer(t, http.StatusOK, &hitsA)
+	b := countingServer(t, http.StatusOK, &hitsB)
+
+	cfg := config.OllamaConfig{Endpoints: []string{a.URL, b.URL}, Balance: config.BalanceRoundRobin, Timeout: 2 * time.Second}
+	client, err := ollama.NewClient(cfg, http.DefaultClient)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer client.Close()
+
+	for range 6 {
+		if _, err := client.Generate(context.Background(), "m", "p"); err != nil {
+			t.Fatalf("generate: %v", err)
+		}
+	}
+	if atomic.LoadInt32(&hitsA) != 3 || atomic.LoadInt32(&hitsB) != 3 {
+		t.Fatalf("expected requests split 3/3, got %d/%d", hitsA, hitsB)
+	}
+}
+
+func TestPool_LeastInFlight(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var slowHits, fastHits int32
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&slowHits, 1)
+		close(started)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		writeSequence(w, []map[string]any{{"response": "slow", "done": true}}, 0)
+	}))
+	defer slow.Close()
+	fast := countingServer(t, http.StatusOK, &fastHits)
+
+	cfg := config.OllamaConfig{Endpoints: []string{slow.URL, fast.URL}, Balance: config.BalanceLeastInFlight, Timeout: 5 * time.Second}
+	client, err := ollama.NewClient(cfg, http.DefaultClient)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer client.Close()
+
+	// the first request goes to the slow endpoint and stays in flight
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.Generate(context.Background(), "m", "p")
+		done <- err
+	}()
+	<-started
+
+	for range 4 {
+		res, err := client.Generate(context.Background(), "m", "p")
+		if err != nil {
+			t.Fatalf("generate: %v", err)
+		}
+		if res.Meta["endpoint"] != fast.URL {
+			t.Fatalf("expected the idle endpoint, got %v", res.Meta["endpoint"])
+		}
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("slow generate: %v", err)
+	}
+	if atomic.LoadInt32(&slowHits) != 1 || atomic.LoadInt32(&fastHits) != 4 {
+		t.Fatalf("unexpected distribution slow=%d fast=%d", slowHits, fastHits)
+	}
+}
+
+func TestPool_HalfOpenLimitsTrials(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	release := make(chan struct{})
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if fail.Load() {
+			http.Error(w, "down", http.StatusInternalServerError)
+			return
+		}
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		writeSequence(w, []map[string]any{{"response": "ok", "done": true}}, 0)
+	}))
+	defer srv.Close()
+
+	cfg := config.OllamaConfig{BaseURL: srv.URL, Timeout: 2 * time.Second, CircuitFailureThreshold: 1, CircuitReset: 50 * time.Millisecond, CircuitHalfOpenRequests: 1}
+	client, err := ollama.NewClient(cfg, srv.Client())
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	if _, err := client.Generate(ctx, "m", "p"); err == nil {
+		t.Fatal("expected the first call to fail")
+	}
+	if st := client.Endpoints()[0]; st.State != ollama.CircuitOpen || st.OpenUntil == nil || st.Failures != 1 {
+		t.Fatalf("expected an open circuit after one failure, got %+v", st)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	fail.Store(false)
+
+	// the trial request holds the only half-open slot until the server answers
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.Generate(ctx, "m", "p")
+		done <- err
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&hits) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if st := client.Endpoints()[0]; st.State != ollama.CircuitHalfOpen {
+		t.Fatalf("expected a half-open circuit during the trial, got %+v", st)
+	}
+	if _, err := client.Generate(ctx, "m", "p"); err != ollama.ErrCircuitOpen {
+		t.Fatalf("expected ErrCircuitOpen while the trial is in flight, got %v", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("trial request: %v", err)
+	}
+	if st := client.Endpoints()[0]; st.State != ollama.CircuitClosed || st.Failures != 0 {
+		t.Fatalf("expected the successful trial to close the circuit, got %+v", st)
+	}
+	if got := atomic.LoadInt32(&hits); got != 2 {
+		t.Fatalf("expected 2 requests on the server, got %d", got)
+	}
+}
+
+func TestPool_FailedTrialReopens(t *testing.T) {
+	var hits int32
+	srv := countingServer(t, http.StatusInternalServerError, &hits)
+
+	cfg := config.OllamaConfig{BaseURL: srv.URL, Timeout: 2 * time.Second, CircuitFailureThreshold: 2, CircuitReset: 50 * time.Millisecond}
+	client, err := ollama.NewClient(cfg, srv.Client())
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	for range 2 {
+		_, _ = client.Generate(ctx, "m", "p")
+	}
+	first := client.Endpoints()[0]
+	if first.State != ollama.CircuitOpen {
+		t.Fatalf("expected an open circuit, got %+v", first)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	// a single failed trial is enough to open the circuit again
+	if _, err := client.Generate(ctx, "m", "p"); err == nil || err == ollama.ErrCircuitOpen {
+		t.Fatalf("expected the trial to reach the server and fail, got %v", err)
+	}
+	st := client.Endpoints()[0]
+	if st.State != ollama.CircuitOpen || !st.OpenUntil.After(*first.OpenUntil) {
+		t.Fatalf("expected the circuit to reopen with a new reset period, got %+v", st)
+	}
+	if _, err := client.Generate(ctx, "m", "p"); err != ollama.ErrCircuitOpen {
+		t.Fatalf("expected ErrCircuitOpen after the failed trial, got %v", err)
+	}
+	if got := atomic.LoadInt32(&hits); got != 3 {
+		t.Fatalf("expected 3 requests on the server, got %d", got)
+	}
+}
+
+func TestGenerate_BackoffStopsOnContext(t *testing.T) {
+	var hits int32
+	srv := countingServer(t, http.StatusInternalServerError, &hits)
+
+	cfg := config.OllamaConfig{BaseURL: srv.URL, Timeout: 2 * time.Second, Retries: 5, Backoff: 5 * time.Second, MaxBackoff: 10 * time.Second}
+	client, err := ollama.NewClient(cfg, srv.Client())
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := client.Generate(ctx, "m", "p"); err == nil {
+		t.Fatal("expected an error")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("expected Generate to return when the context expires, took %s", d)
+	}
+	if got := atomic.LoadInt32(&hits); got != 1 {
+		t.Fatalf("expected a single attempt before the deadline, got %d", got)
+	}
+}
@@ -0,0 +1,271 @@
+package ollama
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// RecorderMode selects whether a Recorder talks to a real server.
+type RecorderMode int
+
+const (
+	// ModeReplay serves responses from the fixture file and answers requests
+	// that were not recorded with 400 Bad Request, so clients fail at once
+	// instead of retrying.
+	ModeReplay RecorderMode = iota
+	// ModeRecord forwards requests to the real transport and captures each
+	// exchange; Save writes them to the fixture file.
+	ModeRecord
+)
+
+// RecordEnv is the environment variable that switches recorder based tests
+// to ModeRecord when set to a true value, see RecorderModeFromEnv.
+const RecordEnv = "OLLAMA_RECORD"
+
+// recordedHeaders are the response headers worth replaying; the client reads
+// nothing else.
+var recordedHeaders = []string{"Content-Type", "Retry-After"}
+
+// Interaction is one recorded request and the response it got.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest identifies a request by method, path and body. JSON
+// bodies are stored as JSON so fixtures stay readable and key order does not
+// matter when matching.
+type RecordedRequest struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// RecordedResponse is a response replayed verbatim, streamed bodies included.
+type RecordedResponse struct {
+	Status int               `json:"status"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   string            `json:"body"`
+}
+
+type fixture struct {
+	// Note says where a hand-made fixture came from; replay ignores it and
+	// recording drops it, since recorded fixtures are real by definition.
+	Note         string        `json:"note,omitempty"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Recorder is an http.RoundTripper that records Ollama exchanges into a
+// fixture file or replays them from it, so tests can run against real model
+// output without a model. Pass it to NewClient through an http.Client:
+//
+//	rec, err := ollama.NewRecorder("testdata/analyze.json", ollama.RecorderModeFromEnv(), nil)
+//	client, err := ollama.NewClient(cfg, &http.Client{Transport: rec})
+//	defer rec.Save()
+//
+// Requests are matched on method, path and body; the host is ignored, and
+// identical requests are replayed in the order they were recorded.
+type Recorder struct {
+	mode RecorderMode
+	path string
+	next http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// RecorderModeFromEnv returns ModeRecord when RecordEnv is set to a true
+// value and ModeReplay otherwise.
+func RecorderModeFromEnv() RecorderMode {
+	if v, _ := strconv.ParseBool(os.Getenv(RecordEnv)); v {
+		return ModeRecord
+	}
+	return ModeReplay
+}
+
+// NewRecorder returns a recorder for the fixture at path. In replay mode the
+// fixture is loaded and must exist; in record mode requests go to next, or
+// http.DefaultTransport when next is nil, and the fixture is overwritten by
+// Save.
+func NewRecorder(path string, mode RecorderMode, next http.RoundTripper) (*Recorder, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	r := &Recorder{mode: mode, path: path, next: next}
+	if mode == ModeRecord {
+		return r, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read fixture (record it with %s=1): %w", RecordEnv, err)
+	}
+	var f fixture
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("parse fixture %s: %w", path, err)
+	}
+	for i := range f.Interactions {
+		f.Interactions[i].Request.Body = canonicalBody(f.Interactions[i].Request.Body)
+	}
+	r.interactions = f.Interactions
+	r.used = make([]bool, len(f.Interactions))
+	return r, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read request body: %w", err)
+		}
+		body = b
+	}
+	rr := RecordedRequest{Method: req.Method, Path: req.URL.Path, Body: canonicalBody(body)}
+
+	if r.mode == ModeRecord {
+		return r.record(req, body, rr)
+	}
+	return r.replay(req, rr)
+}
+
+func (r *Recorder) record(req *http.Request, body []byte, rr RecordedRequest) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	resp, err := r.next.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	// streamed responses are buffered whole; recording is not latency sensitive
+	b, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read response body: %w", err)
+	}
+
+	rec := RecordedResponse{Status: resp.StatusCode, Body: string(b)}
+	for _, h := range recordedHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			if rec.Header == nil {
+				rec.Header = map[string]string{}
+			}
+			rec.Header[h] = v
+		}
+	}
+	r.mu.Lock()
+	r.interactions = append(r.interactions, Interaction{Request: rr, Response: rec})
+	r.used = append(r.used, true)
+	r.mu.Unlock()
+
+	resp.Body = io.NopCloser(bytes.NewReader(b))
+	resp.ContentLength = int64(len(b))
+	return resp, nil
+}
+
+func (r *Recorder) replay(req *http.Request, rr RecordedRequest) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, it := range r.interactions {
+		if r.used[i] || it.Request.Method != rr.Method || it.Request.Path != rr.Path || !bytes.Equal(it.Request.Body, rr.Body) {
+			continue
+		}
+		r.used[i] = true
+		return replayResponse(req, it.Response), nil
+	}
+
+	// a request missing from the fixture is a test bug, not an outage: answer
+	// it the way Ollama answers requests it will never accept
+	msg := fmt.Sprintf("no recorded ollama interaction: %s %s %s (re-record with %s=1)", rr.Method, rr.Path, truncate(string(rr.Body), 200), RecordEnv)
+	body, _ := json.Marshal(map[string]string{"error": msg})
+	return replayResponse(req, RecordedResponse{
+		Status: http.StatusBadRequest,
+		Header: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+		Body:   string(body),
+	}), nil
+}
+
+func replayResponse(req *http.Request, rec RecordedResponse) *http.Response {
+	header := http.Header{}
+	for k, v := range rec.Header {
+		header.Set(k, v)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rec.Status, http.StatusText(rec.Status)),
+		StatusCode:    rec.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(rec.Body))),
+		ContentLength: int64(len(rec.Body)),
+		Request:       req,
+	}
+}
+
+// Remaining returns how many recorded interactions have not been replayed,
+// so tests can check every expected request was made.
+func (r *Recorder) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, u := range r.used {
+		if !u {
+			n++
+		}
+	}
+	return n
+}
+
+// Save writes the recorded interactions to the fixture file. It is a no-op
+// in replay mode.
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	f := fixture{Interactions: r.interactions}
+	b, err := json.MarshalIndent(f, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("encode fixture: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("create fixture dir: %w", err)
+	}
+	return os.WriteFile(r.path, append(b, '\n'), 0o644)
+}
+
+// canonicalBody returns a JSON body re-encoded compactly with sorted keys,
+// a non-JSON body as a JSON string, and nil for an empty body.
+func canonicalBody(b []byte) json.RawMessage {
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		s, _ := json.Marshal(string(b))
+		return s
+	}
+	out, _ := json.Marshal(v)
+	return out
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "…"
+}
@@ -0,0 +1,83 @@
+package ollama_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/garnizeh/rag/internal/config"
+	"github.com/garnizeh/rag/pkg/ollama"
+)
+
+func TestRecorder_RecordThenReplay(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		writeSequence(w, []map[string]any{
+			{"model": "m", "response": "Hello", "done": false},
+			{"model": "m", "response": ", world", "done": true, "eval_count": 3},
+		}, 0)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "generate.json")
+
+	rec, err := ollama.NewRecorder(path, ollama.ModeRecord, srv.Client().Transport)
+	if err != nil {
+		t.Fatalf("new recorder: %v", err)
+	}
+	client, err := ollama.NewClient(config.OllamaConfig{BaseURL: srv.URL, Timeout: 2 * time.Second}, &http.Client{Transport: rec})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	recorded, err := client.Generate(ctx, "m", "greet")
+	_ = client.Close()
+	if err != nil {
+		t.Fatalf("record generate: %v", err)
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	// replay never dials, so any host will do
+	rep, err := ollama.NewRecorder(path, ollama.ModeReplay, nil)
+	if err != nil {
+		t.Fatalf("load fixture: %v", err)
+	}
+	client, err = ollama.NewClient(config.OllamaConfig{BaseURL: "http://ollama.invalid:11434", Timeout: 2 * time.Second, Retries: 3}, &http.Client{Transport: rep})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	defer client.Close()
+
+	replayed, err := client.Generate(ctx, "m", "greet")
+	if err != nil || replayed.Text != "Hello, world" || replayed.Text != recorded.Text {
+		t.Fatalf("replay: got %q, %v; recorded %q", replayed.Text, err, recorded.Text)
+	}
+	if rep.Remaining() != 0 || calls.Load() != 1 {
+		t.Fatalf("expected the single recording replayed without calling the server, %d left, %d calls", rep.Remaining(), calls.Load())
+	}
+
+	// a prompt that was not recorded fails at once instead of being retried
+	_, err = client.Generate(ctx, "m", "greet again")
+	if !errors.Is(err, ollama.ErrBadRequest) || ollama.IsRetryable(err) || !strings.Contains(err.Error(), "no recorded ollama interaction") {
+		t.Fatalf("expected a non-retryable missing recording error, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected the missing recording not to reach the server, %d calls", calls.Load())
+	}
+}
+
+func TestRecorder_MissingFixture(t *testing.T) {
+	if _, err := ollama.NewRecorder(filepath.Join(t.TempDir(), "missing.json"), ollama.ModeReplay, nil); err == nil {
+		t.Fatal("expected an error for a missing fixture in replay mode")
+	}
+}
@@ -8,14 +8,16 @@ import (
 
 // Test helpers and mocks
 type Mocks struct {
-	EngRepo  *mockEngineerRepo
-	ProfRepo *mockProfileRepo
+	EngRepo   *mockEngineerRepo
+	ProfRepo  *mockProfileRepo
+	ResetRepo *mockResetRepo
 }
 
 func NewMocks() *Mocks {
 	return &Mocks{
-		EngRepo:  &mockEngineerRepo{},
-		ProfRepo: &mockProfileRepo{},
+		EngRepo:   &mockEngineerRepo{},
+		ProfRepo:  &mockProfileRepo{},
+		ResetRepo: &mockResetRepo{},
 	}
 }
 
@@ -58,16 +60,78 @@ func (m *mockEngineerRepo) DeleteEngineer(ctx context.Context, id int64) error {
 	return nil
 }
 
-type mockProfileRepo struct{}
+type mockProfileRepo struct {
+	Stored *models.Profile
+}
 
 func (m *mockProfileRepo) CreateProfile(ctx context.Context, p *models.Profile) (int64, error) {
+	m.Stored = &models.Profile{ID: 1, EngineerID: p.EngineerID, Bio: p.Bio}
 	return 1, nil
 }
 
 func (m *mockProfileRepo) GetByEngineerID(ctx context.Context, engineerID int64) (*models.Profile, error) {
+	if m.Stored != nil && m.Stored.EngineerID == engineerID {
+		cp := *m.Stored
+		return &cp, nil
+	}
 	return nil, nil
 }
 
-func (m *mockProfileRepo) UpdateProfile(ctx context.Context, p *models.Profile) error { return nil }
+func (m *mockProfileRepo) UpdateProfile(ctx context.Context, p *models.Profile) error {
+	if m.Stored != nil && m.Stored.ID == p.ID {
+		cp := *p
+		m.Stored = &cp
+	}
+	return nil
+}
+
+func (m *mockProfileRepo) DeleteProfile(ctx context.Context, id int64) error {
+	if m.Stored != nil && m.Stored.ID == id {
+		m.Stored = nil
+	}
+	return nil
+}
+
+type mockResetRepo struct {
+	Resets []models.PasswordReset
+}
 
-func (m *mockProfileRepo) DeleteProfile(ctx context.Context, id int64) error { return nil }
+func (m *mockResetRepo) CreateReset(ctx context.Context, pr *models.PasswordReset) (int64, error) {
+	id := int64(len(m.Resets) + 1)
+	cp := *pr
+	cp.ID = id
+	m.Resets = append(m.Resets, cp)
+	return id, nil
+}
+
+func (m *mockResetRepo) GetResetByTokenHash(ctx context.Context, tokenHash string) (*models.PasswordReset, error) {
+	for i := range m.Resets {
+		if m.Resets[i].TokenHash == tokenHash {
+			cp := m.Resets[i]
+			return &cp, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockResetRepo) MarkResetUsed(ctx context.Context, id int64) (bool, error) {
+	for i := range m.Resets {
+		if m.Resets[i].ID == id && m.Resets[i].UsedAt == nil {
+			v := int64(1)
+			m.Resets[i].UsedAt = &v
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *mockResetRepo) DeleteResetsByEngineer(ctx context.Context, engineerID int64) error {
+	kept := m.Resets[:0]
+	for _, r := range m.Resets {
+		if r.EngineerID != engineerID {
+			kept = append(kept, r)
+		}
+	}
+	m.Resets = kept
+	return nil
+}
@@ -2,6 +2,8 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/garnizeh/rag/internal/models"
 )
@@ -12,9 +14,14 @@ type Repository struct {
 	Activity ActivityRepo
 	Question QuestionRepo
 	Job      JobRepo
+	Schedule ScheduleRepo
 	Context  ContextRepo
 	Schema   SchemaRepo
 	Template TemplateRepo
+	Reset    PasswordResetRepo
+	Team     TeamRepo
+	LLMCache LLMCacheRepo
+	LLMUsage LLMUsageRepo
 }
 
 // Repository interfaces for domain entities. These are the public contracts
@@ -35,10 +42,49 @@ type ProfileRepo interface {
 	DeleteProfile(ctx context.Context, id int64) error
 }
 
+type PasswordResetRepo interface {
+	CreateReset(ctx context.Context, pr *models.PasswordReset) (int64, error)
+	GetResetByTokenHash(ctx context.Context, tokenHash string) (*models.PasswordReset, error)
+	MarkResetUsed(ctx context.Context, id int64) (bool, error)
+	DeleteResetsByEngineer(ctx context.Context, engineerID int64) error
+}
+
+type LLMCacheRepo interface {
+	// GetCachedResponse returns the unexpired entry for key, or nil, and
+	// records the hit.
+	GetCachedResponse(ctx context.Context, key string) (*models.LLMCacheEntry, error)
+	// PutCachedResponse stores e, replacing any entry with the same key.
+	PutCachedResponse(ctx context.Context, e *models.LLMCacheEntry) error
+	// PruneLLMCache deletes expired entries, then the least recently used ones
+	// until the entries left hold at most maxBytes. It returns the number deleted.
+	PruneLLMCache(ctx context.Context, maxBytes int64) (int64, error)
+	// PurgeLLMCache deletes the entries of model, or every entry when model is empty.
+	PurgeLLMCache(ctx context.Context, model string) (int64, error)
+}
+
+type LLMUsageRepo interface {
+	CreateLLMCall(ctx context.Context, c *models.LLMCall) (int64, error)
+	// LLMUsage aggregates the calls matching f, grouped by f.GroupBy and
+	// ordered by model time, highest first.
+	LLMUsage(ctx context.Context, f models.LLMUsageFilter) ([]models.LLMUsage, error)
+}
+
+// ErrDuplicateKey is returned when a row with the same idempotency key already exists.
+var ErrDuplicateKey = errors.New("duplicate idempotency key")
+
 type ActivityRepo interface {
+	// CreateActivity returns ErrDuplicateKey when the engineer already has an
+	// activity with a.IdempotencyKey.
 	CreateActivity(ctx context.Context, a *models.Activity) (int64, error)
+	// GetActivity returns nil when the activity does not exist.
+	GetActivity(ctx context.Context, id int64) (*models.Activity, error)
+	// GetActivityByIdempotencyKey returns nil when no activity has the key.
+	GetActivityByIdempotencyKey(ctx context.Context, engineerID int64, key string) (*models.Activity, error)
 	ListByEngineer(ctx context.Context, engineerID int64, limit, offset int) ([]models.Activity, error)
 	CountActivitiesByEngineer(ctx context.Context, engineerID int64) (int64, error)
+	// ListSharedByEngineer and CountSharedActivitiesByEngineer exclude private activities.
+	ListSharedByEngineer(ctx context.Context, engineerID int64, limit, offset int) ([]models.Activity, error)
+	CountSharedActivitiesByEngineer(ctx context.Context, engineerID int64) (int64, error)
 }
 
 type QuestionRepo interface {
@@ -46,13 +92,74 @@ type QuestionRepo interface {
 	ListUnansweredByEngineer(ctx context.Context, engineerID int64) ([]models.Question, error)
 }
 
-type JobRepo interface {
-	CreateJob(ctx context.Context, j *models.Job) (int64, error)
-	UpdateStatus(ctx context.Context, id int64, status string) error
+// ErrLeaseLost is returned when a worker updates a job whose lease it no
+// longer holds (the lease expired and another worker reclaimed the job).
+var ErrLeaseLost = errors.New("job lease lost")
+
+// JobQueue is the background job queue as seen by producers and workers.
+type JobQueue interface {
+	// Enqueue stores j and returns its id. When j.DedupeKey matches a job that
+	// is still queued, retrying or running, nothing is stored and the id of
+	// that job is returned instead.
 	Enqueue(ctx context.Context, j *models.BackgroundJob) (int64, error)
-	FetchNext(ctx context.Context) (*models.BackgroundJob, error)
+	// FetchNext claims the next runnable job whose type matches f.
+	FetchNext(ctx context.Context, workerID string, lease time.Duration, f models.JobTypeFilter) (*models.BackgroundJob, error)
 	UpdateJob(ctx context.Context, j *models.BackgroundJob) error
 	MoveToDeadLetter(ctx context.Context, j *models.BackgroundJob) error
+
+	// NextJobTime returns when the next job becomes claimable: the earliest
+	// schedule/retry time of waiting jobs or lease expiry of running ones. It
+	// returns nil when no job matching f is pending.
+	NextJobTime(ctx context.Context, f models.JobTypeFilter) (*time.Time, error)
+}
+
+// JobRepo is the job queue plus lookups and admin operations on jobs and
+// dead letters. The jobs table is the only record of processing state.
+type JobRepo interface {
+	JobQueue
+
+	// Admin operations
+	ListJobs(ctx context.Context, f models.JobFilter) ([]models.BackgroundJob, error)
+	GetJob(ctx context.Context, id int64) (*models.BackgroundJob, error)
+	// GetJobByDedupeKey returns the most recent job enqueued with key, or nil.
+	GetJobByDedupeKey(ctx context.Context, key string) (*models.BackgroundJob, error)
+	CancelJob(ctx context.Context, id int64) (bool, error)
+	PurgeJobs(ctx context.Context, before time.Time) (int64, error)
+	ListDeadLetters(ctx context.Context, f models.JobFilter) ([]models.DeadLetterJob, error)
+	GetDeadLetter(ctx context.Context, id int64) (*models.DeadLetterJob, error)
+	// GetDeadLetterByJobID returns the dead letter of the job with the given jobs.id, or nil.
+	GetDeadLetterByJobID(ctx context.Context, jobID int64) (*models.DeadLetterJob, error)
+	// RequeueDeadLetter queues a dead letter again with its original priority,
+	// max_attempts and dedupe key and removes it. It returns the new job's ID,
+	// the ID of the pending job already holding the dedupe key, or 0 when the
+	// dead letter does not exist.
+	RequeueDeadLetter(ctx context.Context, id int64) (int64, error)
+	// CountJobs returns job counts grouped by status and type, including dead letters.
+	CountJobs(ctx context.Context) ([]models.JobCount, error)
+}
+
+// EnqueueNotifier is implemented by job repositories that can tell in-process
+// workers about new jobs as soon as they are stored.
+type EnqueueNotifier interface {
+	// OnEnqueue registers fn to be called after a job is stored, with the time it becomes runnable.
+	OnEnqueue(fn func(runAt time.Time))
+}
+
+// ScheduleRepo stores recurring job schedules.
+type ScheduleRepo interface {
+	CreateSchedule(ctx context.Context, s *models.JobSchedule) (int64, error)
+	GetSchedule(ctx context.Context, id int64) (*models.JobSchedule, error)
+	ListSchedules(ctx context.Context) ([]models.JobSchedule, error)
+	// UpdateSchedule replaces the spec, job fields, enabled flag and next run time.
+	UpdateSchedule(ctx context.Context, s *models.JobSchedule) error
+	DeleteSchedule(ctx context.Context, id int64) (bool, error)
+	// DueSchedules returns enabled schedules whose next run is at or before now.
+	DueSchedules(ctx context.Context, now time.Time) ([]models.JobSchedule, error)
+	// FireSchedule enqueues the run of s due at s.NextRunAt and advances the
+	// schedule to next, atomically and only if the schedule still points at
+	// s.NextRunAt. It returns the new job id, or 0 when another scheduler
+	// already fired this run or the schedule was changed meanwhile.
+	FireSchedule(ctx context.Context, s *models.JobSchedule, next time.Time) (int64, error)
 }
 
 type SchemaRepo interface {
@@ -69,6 +176,18 @@ type TemplateRepo interface {
 	DeleteTemplate(ctx context.Context, name, version string) error
 }
 
+type TeamRepo interface {
+	// CreateTeam stores the team and registers its creator as owner.
+	CreateTeam(ctx context.Context, t *models.Team) (int64, error)
+	GetTeam(ctx context.Context, id int64) (*models.Team, error)
+	ListTeamsByEngineer(ctx context.Context, engineerID int64) ([]models.Team, error)
+	DeleteTeam(ctx context.Context, id int64) error
+	AddMember(ctx context.Context, teamID, engineerID int64, role string) error
+	RemoveMember(ctx context.Context, teamID, engineerID int64) error
+	GetMember(ctx context.Context, teamID, engineerID int64) (*models.TeamMember, error)
+	ListMembers(ctx context.Context, teamID int64) ([]models.TeamMember, error)
+}
+
 type ContextRepo interface {
 	UpsertEngineerContext(ctx context.Context, engineerID int64, contextJSON string, appliedBy string) (int64, error)
 	GetEngineerContext(ctx context.Context, engineerID int64) (string, int64, error)